@@ -20,8 +20,9 @@ core:
   queue_num: 0 # default queue number is 8192
   max_notification: 100
   # set true if you need get error message from fail push notification in API response.
-  # It only works when the queue engine is local.
+  # With a queue engine other than local, the workers send the results back through the stat storage.
   sync: false
+  sync_timeout: 30 # seconds the API waits for the results of a queue engine other than local
   # set webhook url if you need get error message asynchronously from fail push notification in API response.
   feedback_hook_url: ""
   feedback_timeout: 10 # default is 10 second
@@ -41,6 +42,11 @@ core:
     enabled: false # Automatically install TLS certificates from Let's Encrypt.
     folder: ".cache" # folder for storing TLS certificates
     host: "" # which domains the Let's Encrypt will attempt
+  retry:
+    backoff: 1 # initial delay in seconds before resending fail notification, doubled on every attempt
+    max_backoff: 60 # maximum delay in seconds between two attempts
+  idempotency_window: 86400 # seconds a notification idempotency key is remembered, zero disables it
+  dry_run: false # only validate the notifications with the providers, nothing is delivered
 
 grpc:
   enabled: false # enable gRPC server
@@ -48,6 +54,18 @@ grpc:
 
 api:
   push_uri: "/api/push"
+  push_stream_uri: "/api/push/stream"
+  push_bulk_uri: "/api/push/bulk"
+  topic_subscribe_uri: "/api/topic/subscribe"
+  topic_unsubscribe_uri: "/api/topic/unsubscribe"
+  live_activity_uri: "/api/live-activity"
+  mock_uri: "/api/mock"
+  dead_letter_uri: "/api/dead-letters"
+  delivery_status_uri: "/api/delivery"
+  campaign_uri: "/api/campaigns"
+  audience_uri: "/api/audience"
+  template_uri: "/api/templates"
+  experiment_uri: "/api/experiments"
   stat_go_uri: "/api/stat/go"
   stat_app_uri: "/api/stat/app"
   config_uri: "/api/config"
@@ -60,12 +78,31 @@ android:
   key_path: "" # path to fcm key file
   credential: "" # fcm credential data
   max_retry: 0 # resend fail notification, default value zero is disabled
+  max_concurrent_batches: 4 # large token lists are split into batches of 500 tokens
+  endpoint: "" # override the FCM API base URL, like https://localhost:8443/v1 for a local stub server
+  ca_path: "" # PEM bundle of extra CA certificates trusted for the FCM endpoint
+  worker_num: 0 # own pool of workers for the platform, zero shares the pool of core.worker_num
+  queue_num: 0 # capacity of the own pool, default value is core.queue_num
 
 huawei:
   enabled: false
   appsecret: "YOUR_APP_SECRET"
   appid: "YOUR_APP_ID"
   max_retry: 0 # resend fail notification, default value zero is disabled
+  max_concurrent_batches: 4 # large token lists are split into batches of 1000 tokens
+  auth_url: "" # override the HMS OAuth token URL
+  push_url: "" # override the HMS push API base URL
+  worker_num: 0 # own pool of workers for the platform, zero shares the pool of core.worker_num
+  queue_num: 0 # capacity of the own pool, default value is core.queue_num
+
+webpush:
+  enabled: false
+  vapid_private_key: "" # base64url encoded P-256 private key, the public key is derived from it
+  subject: "" # contact for the push service, mailto: or https: URL
+  ttl: 86400 # seconds the push service keeps an undelivered message
+  max_retry: 0 # resend fail notification, default value zero is disabled
+  worker_num: 0 # own pool of workers for the platform, zero shares the pool of core.worker_num
+  queue_num: 0 # capacity of the own pool, default value is core.queue_num
 
 queue:
   engine: "local" # support "local", "nsq", "nats" and "redis" default value is "local"
@@ -85,6 +122,12 @@ queue:
     with_tls: false
     username: ""
     password: ""
+  priority:
+    enabled: false # add a high priority lane with its own workers and capacity, see the lane of the notification
+    worker_num: 0 # default worker number is runtime.NumCPU()
+    queue_num: 0 # default queue number is 8192
+    suffix: "-high" # appended to the nsq topic, the nats subject and the redis stream of the lane
+    platforms: [] # platforms always sent on the high priority lane, like [4, 5, 6] for SMS, Telegram and calls
 
 ios:
   enabled: false
@@ -97,6 +140,10 @@ ios:
   max_retry: 0 # resend fail notification, default value zero is disabled
   key_id: "" # KeyID from developer account (Certificates, Identifiers & Profiles -> Keys)
   team_id: "" # TeamID from developer account (View Account -> Membership)
+  endpoint: "" # override the APNs host of both environments, like https://localhost:2197 for a local stub server
+  ca_path: "" # PEM bundle of extra CA certificates trusted for the APNs endpoint
+  worker_num: 0 # own pool of workers for the platform, zero shares the pool of core.worker_num
+  queue_num: 0 # capacity of the own pool, default value is core.queue_num
 
 log:
   format: "string" # string or json
@@ -124,6 +171,64 @@ stat:
     path: "level.db"
   badgerdb:
     path: "badger.db"
+
+dead_letter:
+  enabled: false # keep the notifications that still fail after max_retry or can't be queued
+  ttl: 604800 # seconds a dead letter is kept, zero keeps it until it is deleted
+
+delivery_status:
+  enabled: false # record the delivery status of the notifications with a notif_id
+  ttl: 86400 # seconds the delivery status is kept after its last change
+
+campaign:
+  ttl: 604800 # seconds a campaign is kept after its last change
+  paused_delay: 5 # seconds a notification of a paused campaign waits before being checked again
+
+frequency_cap:
+  enabled: false # limit the notifications of a category a device or a user receives
+  caps: [] # "category:limit/seconds" such as "promotional:3/86400", the "*" category caps those without their own cap
+  exempt: ["transactional"] # categories never capped
+
+template:
+  default_locale: "en" # locale of the templates rendered for a locale they don't have
+
+circuit_breaker:
+  enabled: false # stop calling a failing provider for a while, its notifications go back to the queue
+  failure_threshold: 5 # consecutive failed calls opening the breaker of a provider
+  open_timeout: 30 # seconds the breaker stays open before letting probes through
+  half_open_probes: 1 # successful probe calls closing the breaker again
+
+rate_limit:
+  enabled: false # make the notifications wait for capacity of the provider instead of being throttled by it
+  max_wait: 30 # seconds a notification waits for capacity before going back to the queue, zero waits as long as it takes
+  apns:
+    rate: 0 # messages per second of each credential, zero is unlimited
+    burst: 0 # messages sent at once after an idle time, default value is the rate
+  fcm:
+    rate: 0
+    burst: 0
+  hms:
+    rate: 0
+    burst: 0
+  webpush:
+    rate: 0
+    burst: 0
+  sms:
+    rate: 0
+    burst: 0
+  telegram:
+    rate: 0
+    burst: 0
+  telphin:
+    rate: 0
+    burst: 0
+
+mock:
+  enabled: false # replace the providers with in-process fakes, nothing leaves gorush
+  latency: 0 # milliseconds the fake providers wait before answering
+  error_rate: 0 # percentage of the requests answered with a 5xx error
+  invalid_tokens: [] # tokens the fake providers reject as unregistered
+  max_messages: 1000 # number of received messages kept for the inspection endpoint
 `)
 
 const (
@@ -139,6 +244,7 @@ type (
 		API             SectionAPI             `yaml:"api"`
 		Android         SectionAndroid         `yaml:"android"`
 		Huawei          SectionHuawei          `yaml:"huawei"`
+		WebPush         SectionWebPush         `yaml:"webpush"`
 		Ios             SectionIos             `yaml:"ios"`
 		Queue           SectionQueue           `yaml:"queue"`
 		Log             SectionLog             `yaml:"log"`
@@ -147,6 +253,14 @@ type (
 		SMS             SectionSMS             `yaml:"sms"`
 		CallAuto        SectionCallAuto        `yaml:"call_auto"`
 		TelegramGateway SectionTelegramGateway `yaml:"telegram_gateway"`
+		DeadLetter      SectionDeadLetter      `yaml:"dead_letter"`
+		DeliveryStatus  SectionDeliveryStatus  `yaml:"delivery_status"`
+		Campaign        SectionCampaign        `yaml:"campaign"`
+		FrequencyCap    SectionFrequencyCap    `yaml:"frequency_cap"`
+		Template        SectionTemplate        `yaml:"template"`
+		CircuitBreaker  SectionCircuitBreaker  `yaml:"circuit_breaker"`
+		RateLimit       SectionRateLimit       `yaml:"rate_limit"`
+		Mock            SectionMock            `yaml:"mock"`
 	}
 
 	// SectionCore is sub section of config.
@@ -160,6 +274,7 @@ type (
 		QueueNum        int64          `yaml:"queue_num"`
 		Mode            string         `yaml:"mode"`
 		Sync            bool           `yaml:"sync"`
+		SyncTimeout     int64          `yaml:"sync_timeout"`
 		SSL             bool           `yaml:"ssl"`
 		CertPath        string         `yaml:"cert_path"`
 		KeyPath         string         `yaml:"key_path"`
@@ -168,6 +283,10 @@ type (
 		HTTPProxy       string         `yaml:"http_proxy"`
 		PID             SectionPID     `yaml:"pid"`
 		AutoTLS         SectionAutoTLS `yaml:"auto_tls"`
+		Retry           SectionRetry   `yaml:"retry"`
+
+		IdempotencyWindow int64 `yaml:"idempotency_window"`
+		DryRun            bool  `yaml:"dry_run"`
 
 		FeedbackURL     string   `yaml:"feedback_hook_url"`
 		FeedbackTimeout int64    `yaml:"feedback_timeout"`
@@ -181,32 +300,71 @@ type (
 		Host    string `yaml:"host"`
 	}
 
+	// SectionRetry is sub section of config.
+	SectionRetry struct {
+		Backoff    int64 `yaml:"backoff"`
+		MaxBackoff int64 `yaml:"max_backoff"`
+	}
+
 	// SectionAPI is sub section of config.
 	SectionAPI struct {
-		PushURI           string `yaml:"push_uri"`
-		ScheduledRUSMSURI string `yaml:"scheduled_ru_sms_uri"`
-		StatGoURI         string `yaml:"stat_go_uri"`
-		StatAppURI        string `yaml:"stat_app_uri"`
-		ConfigURI         string `yaml:"config_uri"`
-		SysStatURI        string `yaml:"sys_stat_uri"`
-		MetricURI         string `yaml:"metric_uri"`
-		HealthURI         string `yaml:"health_uri"`
+		PushURI             string `yaml:"push_uri"`
+		PushStreamURI       string `yaml:"push_stream_uri"`
+		PushBulkURI         string `yaml:"push_bulk_uri"`
+		TopicSubscribeURI   string `yaml:"topic_subscribe_uri"`
+		TopicUnsubscribeURI string `yaml:"topic_unsubscribe_uri"`
+		LiveActivityURI     string `yaml:"live_activity_uri"`
+		MockURI             string `yaml:"mock_uri"`
+		DeadLetterURI       string `yaml:"dead_letter_uri"`
+		DeliveryStatusURI   string `yaml:"delivery_status_uri"`
+		CampaignURI         string `yaml:"campaign_uri"`
+		AudienceURI         string `yaml:"audience_uri"`
+		TemplateURI         string `yaml:"template_uri"`
+		ExperimentURI       string `yaml:"experiment_uri"`
+		ScheduledRUSMSURI   string `yaml:"scheduled_ru_sms_uri"`
+		StatGoURI           string `yaml:"stat_go_uri"`
+		StatAppURI          string `yaml:"stat_app_uri"`
+		ConfigURI           string `yaml:"config_uri"`
+		SysStatURI          string `yaml:"sys_stat_uri"`
+		MetricURI           string `yaml:"metric_uri"`
+		HealthURI           string `yaml:"health_uri"`
 	}
 
 	// SectionAndroid is sub section of config.
 	SectionAndroid struct {
-		Enabled    bool   `yaml:"enabled"`
-		KeyPath    string `yaml:"key_path"`
-		Credential string `yaml:"credential"`
-		MaxRetry   int    `yaml:"max_retry"`
+		Enabled              bool   `yaml:"enabled"`
+		KeyPath              string `yaml:"key_path"`
+		Credential           string `yaml:"credential"`
+		MaxRetry             int    `yaml:"max_retry"`
+		MaxConcurrentBatches int    `yaml:"max_concurrent_batches"`
+		Endpoint             string `yaml:"endpoint"`
+		CAPath               string `yaml:"ca_path"`
+		WorkerNum            int64  `yaml:"worker_num"`
+		QueueNum             int64  `yaml:"queue_num"`
 	}
 
 	// SectionHuawei is sub section of config.
 	SectionHuawei struct {
-		Enabled   bool   `yaml:"enabled"`
-		AppSecret string `yaml:"appsecret"`
-		AppID     string `yaml:"appid"`
-		MaxRetry  int    `yaml:"max_retry"`
+		Enabled              bool   `yaml:"enabled"`
+		AppSecret            string `yaml:"appsecret"`
+		AppID                string `yaml:"appid"`
+		MaxRetry             int    `yaml:"max_retry"`
+		MaxConcurrentBatches int    `yaml:"max_concurrent_batches"`
+		AuthURL              string `yaml:"auth_url"`
+		PushURL              string `yaml:"push_url"`
+		WorkerNum            int64  `yaml:"worker_num"`
+		QueueNum             int64  `yaml:"queue_num"`
+	}
+
+	// SectionWebPush is sub section of config.
+	SectionWebPush struct {
+		Enabled         bool   `yaml:"enabled"`
+		VAPIDPrivateKey string `yaml:"vapid_private_key"`
+		Subject         string `yaml:"subject"`
+		TTL             int    `yaml:"ttl"`
+		MaxRetry        int    `yaml:"max_retry"`
+		WorkerNum       int64  `yaml:"worker_num"`
+		QueueNum        int64  `yaml:"queue_num"`
 	}
 
 	// SectionIos is sub section of config.
@@ -221,6 +379,10 @@ type (
 		MaxRetry            int    `yaml:"max_retry"`
 		KeyID               string `yaml:"key_id"`
 		TeamID              string `yaml:"team_id"`
+		Endpoint            string `yaml:"endpoint"`
+		CAPath              string `yaml:"ca_path"`
+		WorkerNum           int64  `yaml:"worker_num"`
+		QueueNum            int64  `yaml:"queue_num"`
 	}
 
 	// SectionLog is sub section of config.
@@ -246,10 +408,20 @@ type (
 
 	// SectionQueue is sub section of config.
 	SectionQueue struct {
-		Engine string            `yaml:"engine"`
-		NSQ    SectionNSQ        `yaml:"nsq"`
-		NATS   SectionNATS       `yaml:"nats"`
-		Redis  SectionRedisQueue `yaml:"redis"`
+		Engine   string            `yaml:"engine"`
+		NSQ      SectionNSQ        `yaml:"nsq"`
+		NATS     SectionNATS       `yaml:"nats"`
+		Redis    SectionRedisQueue `yaml:"redis"`
+		Priority SectionPriority   `yaml:"priority"`
+	}
+
+	// SectionPriority is sub section of config.
+	SectionPriority struct {
+		Enabled   bool   `yaml:"enabled"`
+		WorkerNum int64  `yaml:"worker_num"`
+		QueueNum  int64  `yaml:"queue_num"`
+		Suffix    string `yaml:"suffix"`
+		Platforms []int  `yaml:"platforms"`
 	}
 
 	// SectionNSQ is sub section of config.
@@ -335,6 +507,11 @@ type (
 		DevinoSenderNumber string `yaml:"devino_sender_number"`
 		DevinoLogin        string `yaml:"devino_login"`
 		DevinoPassword     string `yaml:"devino_password"`
+
+		CAPath string `yaml:"ca_path"`
+
+		WorkerNum int64 `yaml:"worker_num"`
+		QueueNum  int64 `yaml:"queue_num"`
 	}
 
 	// SectionTelegramGateway is subsection of config.
@@ -343,6 +520,75 @@ type (
 		ApiURL      string `yaml:"api_url"`
 		ApiToken    string `yaml:"api_token"`
 		CallbackURL string `yaml:"callback_url"`
+		CAPath      string `yaml:"ca_path"`
+		WorkerNum   int64  `yaml:"worker_num"`
+		QueueNum    int64  `yaml:"queue_num"`
+	}
+
+	// SectionDeadLetter is sub section of config.
+	SectionDeadLetter struct {
+		Enabled bool  `yaml:"enabled"`
+		TTL     int64 `yaml:"ttl"`
+	}
+
+	// SectionDeliveryStatus is sub section of config.
+	SectionDeliveryStatus struct {
+		Enabled bool  `yaml:"enabled"`
+		TTL     int64 `yaml:"ttl"`
+	}
+
+	// SectionCampaign is sub section of config.
+	SectionCampaign struct {
+		TTL         int64 `yaml:"ttl"`
+		PausedDelay int64 `yaml:"paused_delay"`
+	}
+
+	// SectionFrequencyCap is sub section of config.
+	SectionFrequencyCap struct {
+		Enabled bool     `yaml:"enabled"`
+		Caps    []string `yaml:"caps"`
+		Exempt  []string `yaml:"exempt"`
+	}
+
+	// SectionTemplate is sub section of config.
+	SectionTemplate struct {
+		DefaultLocale string `yaml:"default_locale"`
+	}
+
+	// SectionCircuitBreaker is sub section of config.
+	SectionCircuitBreaker struct {
+		Enabled          bool  `yaml:"enabled"`
+		FailureThreshold int   `yaml:"failure_threshold"`
+		OpenTimeout      int64 `yaml:"open_timeout"`
+		HalfOpenProbes   int   `yaml:"half_open_probes"`
+	}
+
+	// SectionRateLimit is sub section of config.
+	SectionRateLimit struct {
+		Enabled  bool                     `yaml:"enabled"`
+		MaxWait  int64                    `yaml:"max_wait"`
+		APNs     SectionRateLimitProvider `yaml:"apns"`
+		FCM      SectionRateLimitProvider `yaml:"fcm"`
+		HMS      SectionRateLimitProvider `yaml:"hms"`
+		WebPush  SectionRateLimitProvider `yaml:"webpush"`
+		SMS      SectionRateLimitProvider `yaml:"sms"`
+		Telegram SectionRateLimitProvider `yaml:"telegram"`
+		Telphin  SectionRateLimitProvider `yaml:"telphin"`
+	}
+
+	// SectionRateLimitProvider is sub section of config.
+	SectionRateLimitProvider struct {
+		Rate  float64 `yaml:"rate"`
+		Burst int     `yaml:"burst"`
+	}
+
+	// SectionMock is sub section of config.
+	SectionMock struct {
+		Enabled       bool     `yaml:"enabled"`
+		Latency       int64    `yaml:"latency"`
+		ErrorRate     int      `yaml:"error_rate"`
+		InvalidTokens []string `yaml:"invalid_tokens"`
+		MaxMessages   int      `yaml:"max_messages"`
 	}
 
 	// SectionCallAuto is sub section of config.
@@ -351,11 +597,16 @@ type (
 		ApiURL    string `yaml:"api_url"`
 		AppID     string `yaml:"app_id"`
 		AppSecret string `yaml:"app_secret"`
+		WorkerNum int64  `yaml:"worker_num"`
+		QueueNum  int64  `yaml:"queue_num"`
 	}
 )
 
 func setDefault() {
 	viper.SetDefault("ios.max_concurrent_pushes", uint(100))
+	viper.SetDefault("android.max_concurrent_batches", 4)
+	viper.SetDefault("huawei.max_concurrent_batches", 4)
+	viper.SetDefault("webpush.ttl", 86400)
 }
 
 // LoadConf load config from file and read in environment variables that match
@@ -404,6 +655,7 @@ func LoadConf(confPath ...string) (*ConfYaml, error) {
 	conf.Core.QueueNum = int64(viper.GetInt("core.queue_num"))
 	conf.Core.Mode = viper.GetString("core.mode")
 	conf.Core.Sync = viper.GetBool("core.sync")
+	conf.Core.SyncTimeout = int64(viper.GetInt("core.sync_timeout"))
 	conf.Core.FeedbackURL = viper.GetString("core.feedback_hook_url")
 	conf.Core.FeedbackTimeout = int64(viper.GetInt("core.feedback_timeout"))
 	conf.Core.FeedbackHeader = viper.GetStringSlice("core.feedback_header")
@@ -420,9 +672,25 @@ func LoadConf(confPath ...string) (*ConfYaml, error) {
 	conf.Core.AutoTLS.Enabled = viper.GetBool("core.auto_tls.enabled")
 	conf.Core.AutoTLS.Folder = viper.GetString("core.auto_tls.folder")
 	conf.Core.AutoTLS.Host = viper.GetString("core.auto_tls.host")
+	conf.Core.Retry.Backoff = int64(viper.GetInt("core.retry.backoff"))
+	conf.Core.Retry.MaxBackoff = int64(viper.GetInt("core.retry.max_backoff"))
+	conf.Core.IdempotencyWindow = int64(viper.GetInt("core.idempotency_window"))
+	conf.Core.DryRun = viper.GetBool("core.dry_run")
 
 	// Api
 	conf.API.PushURI = viper.GetString("api.push_uri")
+	conf.API.PushStreamURI = viper.GetString("api.push_stream_uri")
+	conf.API.PushBulkURI = viper.GetString("api.push_bulk_uri")
+	conf.API.TopicSubscribeURI = viper.GetString("api.topic_subscribe_uri")
+	conf.API.TopicUnsubscribeURI = viper.GetString("api.topic_unsubscribe_uri")
+	conf.API.LiveActivityURI = viper.GetString("api.live_activity_uri")
+	conf.API.MockURI = viper.GetString("api.mock_uri")
+	conf.API.DeadLetterURI = viper.GetString("api.dead_letter_uri")
+	conf.API.DeliveryStatusURI = viper.GetString("api.delivery_status_uri")
+	conf.API.CampaignURI = viper.GetString("api.campaign_uri")
+	conf.API.AudienceURI = viper.GetString("api.audience_uri")
+	conf.API.TemplateURI = viper.GetString("api.template_uri")
+	conf.API.ExperimentURI = viper.GetString("api.experiment_uri")
 	conf.API.ScheduledRUSMSURI = viper.GetString("api.scheduled_ru_sms_uri")
 	conf.API.StatGoURI = viper.GetString("api.stat_go_uri")
 	conf.API.StatAppURI = viper.GetString("api.stat_app_uri")
@@ -436,12 +704,31 @@ func LoadConf(confPath ...string) (*ConfYaml, error) {
 	conf.Android.KeyPath = viper.GetString("android.key_path")
 	conf.Android.Credential = viper.GetString("android.credential")
 	conf.Android.MaxRetry = viper.GetInt("android.max_retry")
+	conf.Android.MaxConcurrentBatches = viper.GetInt("android.max_concurrent_batches")
+	conf.Android.Endpoint = viper.GetString("android.endpoint")
+	conf.Android.CAPath = viper.GetString("android.ca_path")
+	conf.Android.WorkerNum = int64(viper.GetInt("android.worker_num"))
+	conf.Android.QueueNum = int64(viper.GetInt("android.queue_num"))
 
 	// Huawei
 	conf.Huawei.Enabled = viper.GetBool("huawei.enabled")
 	conf.Huawei.AppSecret = viper.GetString("huawei.appsecret")
 	conf.Huawei.AppID = viper.GetString("huawei.appid")
 	conf.Huawei.MaxRetry = viper.GetInt("huawei.max_retry")
+	conf.Huawei.MaxConcurrentBatches = viper.GetInt("huawei.max_concurrent_batches")
+	conf.Huawei.AuthURL = viper.GetString("huawei.auth_url")
+	conf.Huawei.PushURL = viper.GetString("huawei.push_url")
+	conf.Huawei.WorkerNum = int64(viper.GetInt("huawei.worker_num"))
+	conf.Huawei.QueueNum = int64(viper.GetInt("huawei.queue_num"))
+
+	// Web Push
+	conf.WebPush.Enabled = viper.GetBool("webpush.enabled")
+	conf.WebPush.VAPIDPrivateKey = viper.GetString("webpush.vapid_private_key")
+	conf.WebPush.Subject = viper.GetString("webpush.subject")
+	conf.WebPush.TTL = viper.GetInt("webpush.ttl")
+	conf.WebPush.MaxRetry = viper.GetInt("webpush.max_retry")
+	conf.WebPush.WorkerNum = int64(viper.GetInt("webpush.worker_num"))
+	conf.WebPush.QueueNum = int64(viper.GetInt("webpush.queue_num"))
 
 	// iOS
 	conf.Ios.Enabled = viper.GetBool("ios.enabled")
@@ -454,6 +741,10 @@ func LoadConf(confPath ...string) (*ConfYaml, error) {
 	conf.Ios.MaxRetry = viper.GetInt("ios.max_retry")
 	conf.Ios.KeyID = viper.GetString("ios.key_id")
 	conf.Ios.TeamID = viper.GetString("ios.team_id")
+	conf.Ios.Endpoint = viper.GetString("ios.endpoint")
+	conf.Ios.CAPath = viper.GetString("ios.ca_path")
+	conf.Ios.WorkerNum = int64(viper.GetInt("ios.worker_num"))
+	conf.Ios.QueueNum = int64(viper.GetInt("ios.queue_num"))
 
 	// log
 	conf.Log.Format = viper.GetString("log.format")
@@ -479,6 +770,11 @@ func LoadConf(confPath ...string) (*ConfYaml, error) {
 	conf.Queue.Redis.WithTLS = viper.GetBool("queue.redis.with_tls")
 	conf.Queue.Redis.Username = viper.GetString("queue.redis.username")
 	conf.Queue.Redis.Password = viper.GetString("queue.redis.password")
+	conf.Queue.Priority.Enabled = viper.GetBool("queue.priority.enabled")
+	conf.Queue.Priority.WorkerNum = int64(viper.GetInt("queue.priority.worker_num"))
+	conf.Queue.Priority.QueueNum = int64(viper.GetInt("queue.priority.queue_num"))
+	conf.Queue.Priority.Suffix = viper.GetString("queue.priority.suffix")
+	conf.Queue.Priority.Platforms = viper.GetIntSlice("queue.priority.platforms")
 
 	// Stat Engine
 	conf.Stat.Engine = viper.GetString("stat.engine")
@@ -493,6 +789,57 @@ func LoadConf(confPath ...string) (*ConfYaml, error) {
 	conf.Stat.LevelDB.Path = viper.GetString("stat.leveldb.path")
 	conf.Stat.BadgerDB.Path = viper.GetString("stat.badgerdb.path")
 
+	// Dead letters
+	conf.DeadLetter.Enabled = viper.GetBool("dead_letter.enabled")
+	conf.DeadLetter.TTL = viper.GetInt64("dead_letter.ttl")
+
+	// Delivery status
+	conf.DeliveryStatus.Enabled = viper.GetBool("delivery_status.enabled")
+	conf.DeliveryStatus.TTL = viper.GetInt64("delivery_status.ttl")
+
+	// Campaign
+	conf.Campaign.TTL = viper.GetInt64("campaign.ttl")
+	conf.Campaign.PausedDelay = viper.GetInt64("campaign.paused_delay")
+
+	// Frequency cap
+	conf.FrequencyCap.Enabled = viper.GetBool("frequency_cap.enabled")
+	conf.FrequencyCap.Caps = viper.GetStringSlice("frequency_cap.caps")
+	conf.FrequencyCap.Exempt = viper.GetStringSlice("frequency_cap.exempt")
+
+	// Template
+	conf.Template.DefaultLocale = viper.GetString("template.default_locale")
+
+	// Circuit breaker
+	conf.CircuitBreaker.Enabled = viper.GetBool("circuit_breaker.enabled")
+	conf.CircuitBreaker.FailureThreshold = viper.GetInt("circuit_breaker.failure_threshold")
+	conf.CircuitBreaker.OpenTimeout = viper.GetInt64("circuit_breaker.open_timeout")
+	conf.CircuitBreaker.HalfOpenProbes = viper.GetInt("circuit_breaker.half_open_probes")
+
+	// Rate limit
+	conf.RateLimit.Enabled = viper.GetBool("rate_limit.enabled")
+	conf.RateLimit.MaxWait = viper.GetInt64("rate_limit.max_wait")
+	conf.RateLimit.APNs.Rate = viper.GetFloat64("rate_limit.apns.rate")
+	conf.RateLimit.APNs.Burst = viper.GetInt("rate_limit.apns.burst")
+	conf.RateLimit.FCM.Rate = viper.GetFloat64("rate_limit.fcm.rate")
+	conf.RateLimit.FCM.Burst = viper.GetInt("rate_limit.fcm.burst")
+	conf.RateLimit.HMS.Rate = viper.GetFloat64("rate_limit.hms.rate")
+	conf.RateLimit.HMS.Burst = viper.GetInt("rate_limit.hms.burst")
+	conf.RateLimit.WebPush.Rate = viper.GetFloat64("rate_limit.webpush.rate")
+	conf.RateLimit.WebPush.Burst = viper.GetInt("rate_limit.webpush.burst")
+	conf.RateLimit.SMS.Rate = viper.GetFloat64("rate_limit.sms.rate")
+	conf.RateLimit.SMS.Burst = viper.GetInt("rate_limit.sms.burst")
+	conf.RateLimit.Telegram.Rate = viper.GetFloat64("rate_limit.telegram.rate")
+	conf.RateLimit.Telegram.Burst = viper.GetInt("rate_limit.telegram.burst")
+	conf.RateLimit.Telphin.Rate = viper.GetFloat64("rate_limit.telphin.rate")
+	conf.RateLimit.Telphin.Burst = viper.GetInt("rate_limit.telphin.burst")
+
+	// Mock providers
+	conf.Mock.Enabled = viper.GetBool("mock.enabled")
+	conf.Mock.Latency = int64(viper.GetInt("mock.latency"))
+	conf.Mock.ErrorRate = viper.GetInt("mock.error_rate")
+	conf.Mock.InvalidTokens = viper.GetStringSlice("mock.invalid_tokens")
+	conf.Mock.MaxMessages = viper.GetInt("mock.max_messages")
+
 	// gRPC Server
 	conf.GRPC.Enabled = viper.GetBool("grpc.enabled")
 	conf.GRPC.Port = viper.GetString("grpc.port")
@@ -509,6 +856,9 @@ func LoadConf(confPath ...string) (*ConfYaml, error) {
 	conf.SMS.DevinoSenderNumber = viper.GetString("sms.devino_sender_number")
 	conf.SMS.DevinoLogin = viper.GetString("sms.devino_login")
 	conf.SMS.DevinoPassword = viper.GetString("sms.devino_password")
+	conf.SMS.CAPath = viper.GetString("sms.ca_path")
+	conf.SMS.WorkerNum = int64(viper.GetInt("sms.worker_num"))
+	conf.SMS.QueueNum = int64(viper.GetInt("sms.queue_num"))
 
 	if conf.SMS.Provider == "" {
 		conf.SMS.Provider = SMSProviderDevinoV1
@@ -519,12 +869,17 @@ func LoadConf(confPath ...string) (*ConfYaml, error) {
 	conf.TelegramGateway.ApiURL = viper.GetString("telegram_gateway.api_url")
 	conf.TelegramGateway.ApiToken = viper.GetString("telegram_gateway.api_token")
 	conf.TelegramGateway.CallbackURL = viper.GetString("telegram_gateway.callback_url")
+	conf.TelegramGateway.CAPath = viper.GetString("telegram_gateway.ca_path")
+	conf.TelegramGateway.WorkerNum = int64(viper.GetInt("telegram_gateway.worker_num"))
+	conf.TelegramGateway.QueueNum = int64(viper.GetInt("telegram_gateway.queue_num"))
 
 	// CallAuto
 	conf.CallAuto.Enabled = viper.GetBool("call_auto.enabled")
 	conf.CallAuto.ApiURL = viper.GetString("call_auto.api_url")
 	conf.CallAuto.AppID = viper.GetString("call_auto.app_id")
 	conf.CallAuto.AppSecret = viper.GetString("call_auto.app_secret")
+	conf.CallAuto.WorkerNum = int64(viper.GetInt("call_auto.worker_num"))
+	conf.CallAuto.QueueNum = int64(viper.GetInt("call_auto.queue_num"))
 
 	if conf.Core.WorkerNum == int64(0) {
 		conf.Core.WorkerNum = int64(runtime.NumCPU())
@@ -534,5 +889,13 @@ func LoadConf(confPath ...string) (*ConfYaml, error) {
 		conf.Core.QueueNum = int64(8192)
 	}
 
+	if conf.Queue.Priority.WorkerNum == int64(0) {
+		conf.Queue.Priority.WorkerNum = int64(runtime.NumCPU())
+	}
+
+	if conf.Queue.Priority.QueueNum == int64(0) {
+		conf.Queue.Priority.QueueNum = int64(8192)
+	}
+
 	return conf, nil
 }
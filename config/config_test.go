@@ -24,6 +24,9 @@ func TestEmptyConfig(t *testing.T) {
 	}
 
 	assert.Equal(t, uint(100), conf.Ios.MaxConcurrentPushes)
+	assert.Equal(t, 4, conf.Android.MaxConcurrentBatches)
+	assert.Equal(t, 4, conf.Huawei.MaxConcurrentBatches)
+	assert.Equal(t, 86400, conf.WebPush.TTL)
 }
 
 type ConfigTestSuite struct {
@@ -54,6 +57,7 @@ func (suite *ConfigTestSuite) TestValidateConfDefault() {
 	assert.Equal(suite.T(), int64(8192), suite.ConfGorushDefault.Core.QueueNum)
 	assert.Equal(suite.T(), "release", suite.ConfGorushDefault.Core.Mode)
 	assert.Equal(suite.T(), false, suite.ConfGorushDefault.Core.Sync)
+	assert.Equal(suite.T(), int64(30), suite.ConfGorushDefault.Core.SyncTimeout)
 	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Core.FeedbackURL)
 	assert.Equal(suite.T(), 0, len(suite.ConfGorushDefault.Core.FeedbackHeader))
 	assert.Equal(suite.T(), int64(10), suite.ConfGorushDefault.Core.FeedbackTimeout)
@@ -71,9 +75,25 @@ func (suite *ConfigTestSuite) TestValidateConfDefault() {
 	assert.Equal(suite.T(), false, suite.ConfGorushDefault.Core.AutoTLS.Enabled)
 	assert.Equal(suite.T(), ".cache", suite.ConfGorushDefault.Core.AutoTLS.Folder)
 	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Core.AutoTLS.Host)
+	assert.Equal(suite.T(), int64(1), suite.ConfGorushDefault.Core.Retry.Backoff)
+	assert.Equal(suite.T(), int64(60), suite.ConfGorushDefault.Core.Retry.MaxBackoff)
+	assert.Equal(suite.T(), int64(86400), suite.ConfGorushDefault.Core.IdempotencyWindow)
+	assert.Equal(suite.T(), false, suite.ConfGorushDefault.Core.DryRun)
 
 	// Api
 	assert.Equal(suite.T(), "/api/push", suite.ConfGorushDefault.API.PushURI)
+	assert.Equal(suite.T(), "/api/push/stream", suite.ConfGorushDefault.API.PushStreamURI)
+	assert.Equal(suite.T(), "/api/push/bulk", suite.ConfGorushDefault.API.PushBulkURI)
+	assert.Equal(suite.T(), "/api/topic/subscribe", suite.ConfGorushDefault.API.TopicSubscribeURI)
+	assert.Equal(suite.T(), "/api/topic/unsubscribe", suite.ConfGorushDefault.API.TopicUnsubscribeURI)
+	assert.Equal(suite.T(), "/api/live-activity", suite.ConfGorushDefault.API.LiveActivityURI)
+	assert.Equal(suite.T(), "/api/mock", suite.ConfGorushDefault.API.MockURI)
+	assert.Equal(suite.T(), "/api/dead-letters", suite.ConfGorushDefault.API.DeadLetterURI)
+	assert.Equal(suite.T(), "/api/delivery", suite.ConfGorushDefault.API.DeliveryStatusURI)
+	assert.Equal(suite.T(), "/api/campaigns", suite.ConfGorushDefault.API.CampaignURI)
+	assert.Equal(suite.T(), "/api/audience", suite.ConfGorushDefault.API.AudienceURI)
+	assert.Equal(suite.T(), "/api/templates", suite.ConfGorushDefault.API.TemplateURI)
+	assert.Equal(suite.T(), "/api/experiments", suite.ConfGorushDefault.API.ExperimentURI)
 	assert.Equal(suite.T(), "/api/stat/go", suite.ConfGorushDefault.API.StatGoURI)
 	assert.Equal(suite.T(), "/api/stat/app", suite.ConfGorushDefault.API.StatAppURI)
 	assert.Equal(suite.T(), "/api/config", suite.ConfGorushDefault.API.ConfigURI)
@@ -86,6 +106,24 @@ func (suite *ConfigTestSuite) TestValidateConfDefault() {
 	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Android.KeyPath)
 	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Android.Credential)
 	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.Android.MaxRetry)
+	assert.Equal(suite.T(), 4, suite.ConfGorushDefault.Android.MaxConcurrentBatches)
+	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Android.Endpoint)
+	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Android.CAPath)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorushDefault.Android.WorkerNum)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorushDefault.Android.QueueNum)
+	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Huawei.AuthURL)
+	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Huawei.PushURL)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorushDefault.Huawei.WorkerNum)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorushDefault.Huawei.QueueNum)
+
+	// Web Push
+	assert.Equal(suite.T(), false, suite.ConfGorushDefault.WebPush.Enabled)
+	assert.Equal(suite.T(), "", suite.ConfGorushDefault.WebPush.VAPIDPrivateKey)
+	assert.Equal(suite.T(), "", suite.ConfGorushDefault.WebPush.Subject)
+	assert.Equal(suite.T(), 86400, suite.ConfGorushDefault.WebPush.TTL)
+	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.WebPush.MaxRetry)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorushDefault.WebPush.WorkerNum)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorushDefault.WebPush.QueueNum)
 
 	// iOS
 	assert.Equal(suite.T(), false, suite.ConfGorushDefault.Ios.Enabled)
@@ -98,6 +136,10 @@ func (suite *ConfigTestSuite) TestValidateConfDefault() {
 	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.Ios.MaxRetry)
 	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Ios.KeyID)
 	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Ios.TeamID)
+	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Ios.Endpoint)
+	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Ios.CAPath)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorushDefault.Ios.WorkerNum)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorushDefault.Ios.QueueNum)
 
 	// queue
 	assert.Equal(suite.T(), "local", suite.ConfGorushDefault.Queue.Engine)
@@ -117,6 +159,12 @@ func (suite *ConfigTestSuite) TestValidateConfDefault() {
 	assert.Equal(suite.T(), "", suite.ConfGorushDefault.Queue.Redis.Password)
 	assert.Equal(suite.T(), false, suite.ConfGorushDefault.Queue.Redis.WithTLS)
 
+	assert.Equal(suite.T(), false, suite.ConfGorushDefault.Queue.Priority.Enabled)
+	assert.Equal(suite.T(), int64(runtime.NumCPU()), suite.ConfGorushDefault.Queue.Priority.WorkerNum)
+	assert.Equal(suite.T(), int64(8192), suite.ConfGorushDefault.Queue.Priority.QueueNum)
+	assert.Equal(suite.T(), "-high", suite.ConfGorushDefault.Queue.Priority.Suffix)
+	assert.Equal(suite.T(), []int{}, suite.ConfGorushDefault.Queue.Priority.Platforms)
+
 	// log
 	assert.Equal(suite.T(), "string", suite.ConfGorushDefault.Log.Format)
 	assert.Equal(suite.T(), "stdout", suite.ConfGorushDefault.Log.AccessLog)
@@ -140,6 +188,46 @@ func (suite *ConfigTestSuite) TestValidateConfDefault() {
 	assert.Equal(suite.T(), "level.db", suite.ConfGorushDefault.Stat.LevelDB.Path)
 	assert.Equal(suite.T(), "badger.db", suite.ConfGorushDefault.Stat.BadgerDB.Path)
 
+	assert.Equal(suite.T(), false, suite.ConfGorushDefault.DeadLetter.Enabled)
+	assert.Equal(suite.T(), int64(604800), suite.ConfGorushDefault.DeadLetter.TTL)
+
+	assert.Equal(suite.T(), false, suite.ConfGorushDefault.DeliveryStatus.Enabled)
+	assert.Equal(suite.T(), int64(86400), suite.ConfGorushDefault.DeliveryStatus.TTL)
+	assert.Equal(suite.T(), int64(604800), suite.ConfGorushDefault.Campaign.TTL)
+	assert.Equal(suite.T(), int64(5), suite.ConfGorushDefault.Campaign.PausedDelay)
+	assert.Equal(suite.T(), false, suite.ConfGorushDefault.FrequencyCap.Enabled)
+	assert.Empty(suite.T(), suite.ConfGorushDefault.FrequencyCap.Caps)
+	assert.Equal(suite.T(), []string{"transactional"}, suite.ConfGorushDefault.FrequencyCap.Exempt)
+
+	assert.Equal(suite.T(), "en", suite.ConfGorushDefault.Template.DefaultLocale)
+
+	assert.Equal(suite.T(), false, suite.ConfGorushDefault.CircuitBreaker.Enabled)
+	assert.Equal(suite.T(), 5, suite.ConfGorushDefault.CircuitBreaker.FailureThreshold)
+	assert.Equal(suite.T(), int64(30), suite.ConfGorushDefault.CircuitBreaker.OpenTimeout)
+	assert.Equal(suite.T(), 1, suite.ConfGorushDefault.CircuitBreaker.HalfOpenProbes)
+	assert.Equal(suite.T(), false, suite.ConfGorushDefault.RateLimit.Enabled)
+	assert.Equal(suite.T(), int64(30), suite.ConfGorushDefault.RateLimit.MaxWait)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorushDefault.RateLimit.APNs.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.RateLimit.APNs.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorushDefault.RateLimit.FCM.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.RateLimit.FCM.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorushDefault.RateLimit.HMS.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.RateLimit.HMS.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorushDefault.RateLimit.WebPush.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.RateLimit.WebPush.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorushDefault.RateLimit.SMS.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.RateLimit.SMS.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorushDefault.RateLimit.Telegram.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.RateLimit.Telegram.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorushDefault.RateLimit.Telphin.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.RateLimit.Telphin.Burst)
+
+	assert.Equal(suite.T(), false, suite.ConfGorushDefault.Mock.Enabled)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorushDefault.Mock.Latency)
+	assert.Equal(suite.T(), 0, suite.ConfGorushDefault.Mock.ErrorRate)
+	assert.Empty(suite.T(), suite.ConfGorushDefault.Mock.InvalidTokens)
+	assert.Equal(suite.T(), 1000, suite.ConfGorushDefault.Mock.MaxMessages)
+
 	// gRPC
 	assert.Equal(suite.T(), false, suite.ConfGorushDefault.GRPC.Enabled)
 	assert.Equal(suite.T(), "9000", suite.ConfGorushDefault.GRPC.Port)
@@ -154,6 +242,7 @@ func (suite *ConfigTestSuite) TestValidateConf() {
 	assert.Equal(suite.T(), int64(8192), suite.ConfGorush.Core.QueueNum)
 	assert.Equal(suite.T(), "release", suite.ConfGorush.Core.Mode)
 	assert.Equal(suite.T(), false, suite.ConfGorush.Core.Sync)
+	assert.Equal(suite.T(), int64(30), suite.ConfGorush.Core.SyncTimeout)
 	assert.Equal(suite.T(), "", suite.ConfGorush.Core.FeedbackURL)
 	assert.Equal(suite.T(), int64(10), suite.ConfGorush.Core.FeedbackTimeout)
 	assert.Equal(suite.T(), 1, len(suite.ConfGorush.Core.FeedbackHeader))
@@ -172,9 +261,25 @@ func (suite *ConfigTestSuite) TestValidateConf() {
 	assert.Equal(suite.T(), false, suite.ConfGorush.Core.AutoTLS.Enabled)
 	assert.Equal(suite.T(), ".cache", suite.ConfGorush.Core.AutoTLS.Folder)
 	assert.Equal(suite.T(), "", suite.ConfGorush.Core.AutoTLS.Host)
+	assert.Equal(suite.T(), int64(1), suite.ConfGorush.Core.Retry.Backoff)
+	assert.Equal(suite.T(), int64(60), suite.ConfGorush.Core.Retry.MaxBackoff)
+	assert.Equal(suite.T(), int64(86400), suite.ConfGorush.Core.IdempotencyWindow)
+	assert.Equal(suite.T(), false, suite.ConfGorush.Core.DryRun)
 
 	// Api
 	assert.Equal(suite.T(), "/api/push", suite.ConfGorush.API.PushURI)
+	assert.Equal(suite.T(), "/api/push/stream", suite.ConfGorush.API.PushStreamURI)
+	assert.Equal(suite.T(), "/api/push/bulk", suite.ConfGorush.API.PushBulkURI)
+	assert.Equal(suite.T(), "/api/topic/subscribe", suite.ConfGorush.API.TopicSubscribeURI)
+	assert.Equal(suite.T(), "/api/topic/unsubscribe", suite.ConfGorush.API.TopicUnsubscribeURI)
+	assert.Equal(suite.T(), "/api/live-activity", suite.ConfGorush.API.LiveActivityURI)
+	assert.Equal(suite.T(), "/api/mock", suite.ConfGorush.API.MockURI)
+	assert.Equal(suite.T(), "/api/dead-letters", suite.ConfGorush.API.DeadLetterURI)
+	assert.Equal(suite.T(), "/api/delivery", suite.ConfGorush.API.DeliveryStatusURI)
+	assert.Equal(suite.T(), "/api/campaigns", suite.ConfGorush.API.CampaignURI)
+	assert.Equal(suite.T(), "/api/audience", suite.ConfGorush.API.AudienceURI)
+	assert.Equal(suite.T(), "/api/templates", suite.ConfGorush.API.TemplateURI)
+	assert.Equal(suite.T(), "/api/experiments", suite.ConfGorush.API.ExperimentURI)
 	assert.Equal(suite.T(), "/api/stat/go", suite.ConfGorush.API.StatGoURI)
 	assert.Equal(suite.T(), "/api/stat/app", suite.ConfGorush.API.StatAppURI)
 	assert.Equal(suite.T(), "/api/config", suite.ConfGorush.API.ConfigURI)
@@ -187,6 +292,24 @@ func (suite *ConfigTestSuite) TestValidateConf() {
 	assert.Equal(suite.T(), "key.json", suite.ConfGorush.Android.KeyPath)
 	assert.Equal(suite.T(), "CREDENTIAL_JSON_DATA", suite.ConfGorush.Android.Credential)
 	assert.Equal(suite.T(), 0, suite.ConfGorush.Android.MaxRetry)
+	assert.Equal(suite.T(), 4, suite.ConfGorush.Android.MaxConcurrentBatches)
+	assert.Equal(suite.T(), "", suite.ConfGorush.Android.Endpoint)
+	assert.Equal(suite.T(), "", suite.ConfGorush.Android.CAPath)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorush.Android.WorkerNum)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorush.Android.QueueNum)
+	assert.Equal(suite.T(), "", suite.ConfGorush.Huawei.AuthURL)
+	assert.Equal(suite.T(), "", suite.ConfGorush.Huawei.PushURL)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorush.Huawei.WorkerNum)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorush.Huawei.QueueNum)
+
+	// Web Push
+	assert.Equal(suite.T(), false, suite.ConfGorush.WebPush.Enabled)
+	assert.Equal(suite.T(), "", suite.ConfGorush.WebPush.VAPIDPrivateKey)
+	assert.Equal(suite.T(), "", suite.ConfGorush.WebPush.Subject)
+	assert.Equal(suite.T(), 86400, suite.ConfGorush.WebPush.TTL)
+	assert.Equal(suite.T(), 0, suite.ConfGorush.WebPush.MaxRetry)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorush.WebPush.WorkerNum)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorush.WebPush.QueueNum)
 
 	// iOS
 	assert.Equal(suite.T(), false, suite.ConfGorush.Ios.Enabled)
@@ -199,6 +322,10 @@ func (suite *ConfigTestSuite) TestValidateConf() {
 	assert.Equal(suite.T(), 0, suite.ConfGorush.Ios.MaxRetry)
 	assert.Equal(suite.T(), "", suite.ConfGorush.Ios.KeyID)
 	assert.Equal(suite.T(), "", suite.ConfGorush.Ios.TeamID)
+	assert.Equal(suite.T(), "", suite.ConfGorush.Ios.Endpoint)
+	assert.Equal(suite.T(), "", suite.ConfGorush.Ios.CAPath)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorush.Ios.WorkerNum)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorush.Ios.QueueNum)
 
 	// log
 	assert.Equal(suite.T(), "string", suite.ConfGorush.Log.Format)
@@ -222,6 +349,46 @@ func (suite *ConfigTestSuite) TestValidateConf() {
 	assert.Equal(suite.T(), "level.db", suite.ConfGorush.Stat.LevelDB.Path)
 	assert.Equal(suite.T(), "badger.db", suite.ConfGorush.Stat.BadgerDB.Path)
 
+	assert.Equal(suite.T(), false, suite.ConfGorush.DeadLetter.Enabled)
+	assert.Equal(suite.T(), int64(604800), suite.ConfGorush.DeadLetter.TTL)
+
+	assert.Equal(suite.T(), false, suite.ConfGorush.DeliveryStatus.Enabled)
+	assert.Equal(suite.T(), int64(86400), suite.ConfGorush.DeliveryStatus.TTL)
+	assert.Equal(suite.T(), int64(604800), suite.ConfGorush.Campaign.TTL)
+	assert.Equal(suite.T(), int64(5), suite.ConfGorush.Campaign.PausedDelay)
+	assert.Equal(suite.T(), false, suite.ConfGorush.FrequencyCap.Enabled)
+	assert.Empty(suite.T(), suite.ConfGorush.FrequencyCap.Caps)
+	assert.Equal(suite.T(), []string{"transactional"}, suite.ConfGorush.FrequencyCap.Exempt)
+
+	assert.Equal(suite.T(), "en", suite.ConfGorush.Template.DefaultLocale)
+
+	assert.Equal(suite.T(), false, suite.ConfGorush.CircuitBreaker.Enabled)
+	assert.Equal(suite.T(), 5, suite.ConfGorush.CircuitBreaker.FailureThreshold)
+	assert.Equal(suite.T(), int64(30), suite.ConfGorush.CircuitBreaker.OpenTimeout)
+	assert.Equal(suite.T(), 1, suite.ConfGorush.CircuitBreaker.HalfOpenProbes)
+	assert.Equal(suite.T(), false, suite.ConfGorush.RateLimit.Enabled)
+	assert.Equal(suite.T(), int64(30), suite.ConfGorush.RateLimit.MaxWait)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorush.RateLimit.APNs.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorush.RateLimit.APNs.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorush.RateLimit.FCM.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorush.RateLimit.FCM.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorush.RateLimit.HMS.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorush.RateLimit.HMS.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorush.RateLimit.WebPush.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorush.RateLimit.WebPush.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorush.RateLimit.SMS.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorush.RateLimit.SMS.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorush.RateLimit.Telegram.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorush.RateLimit.Telegram.Burst)
+	assert.Equal(suite.T(), float64(0), suite.ConfGorush.RateLimit.Telphin.Rate)
+	assert.Equal(suite.T(), 0, suite.ConfGorush.RateLimit.Telphin.Burst)
+
+	assert.Equal(suite.T(), false, suite.ConfGorush.Mock.Enabled)
+	assert.Equal(suite.T(), int64(0), suite.ConfGorush.Mock.Latency)
+	assert.Equal(suite.T(), 0, suite.ConfGorush.Mock.ErrorRate)
+	assert.Empty(suite.T(), suite.ConfGorush.Mock.InvalidTokens)
+	assert.Equal(suite.T(), 1000, suite.ConfGorush.Mock.MaxMessages)
+
 	// gRPC
 	assert.Equal(suite.T(), false, suite.ConfGorush.GRPC.Enabled)
 	assert.Equal(suite.T(), "9000", suite.ConfGorush.GRPC.Port)
@@ -7,6 +7,7 @@ const (
 	PlatformSMS             = 4
 	PlatformTelegramGateway = 5
 	PlatformCallAuto        = 6
+	PlatformWebPush         = 7
 )
 
 const (
@@ -14,4 +15,6 @@ const (
 	SucceededPush = "succeeded-push"
 	// FailedPush is log block
 	FailedPush = "failed-push"
+	// CappedPush is log block of a recipient over its frequency cap
+	CappedPush = "capped-push"
 )
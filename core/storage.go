@@ -1,5 +1,10 @@
 package core
 
+import (
+	"context"
+	"time"
+)
+
 const (
 	// TotalCountKey is key name for total count of storage
 	TotalCountKey = "gorush-total-count"
@@ -31,4 +36,51 @@ type Storage interface {
 	Set(key string, count int64)
 	Get(key string) int64
 	Close() error
+
+	// SetValue stores a raw value, it expires after ttl when ttl is positive.
+	SetValue(key string, value []byte, ttl time.Duration) error
+	// SetValueNX stores the value only when the key does not exist yet, and
+	// reports whether it was stored.
+	SetValueNX(key string, value []byte, ttl time.Duration) (bool, error)
+	// GetValue returns nil when the key does not exist or has expired.
+	GetValue(key string) ([]byte, error)
+	// DelValue removes the key, missing keys are not an error.
+	DelValue(key string) error
+	// Keys lists the stored value keys that start with prefix.
+	Keys(prefix string) ([]string, error)
+	// IncrValue adds one to the counter value of key and returns it. A new
+	// counter expires after ttl when ttl is positive, incrementing it keeps
+	// its expiration.
+	IncrValue(key string, ttl time.Duration) (int64, error)
+
+	// SetField stores a field of the map of key. The whole map is kept for at
+	// least ttl after the last change of any of its fields when ttl is
+	// positive.
+	SetField(key, field string, value []byte, ttl time.Duration) error
+	// GetFields returns the fields of the map of key, empty when it does not
+	// exist or has expired.
+	GetFields(key string) (map[string][]byte, error)
+}
+
+// RateLimiter is implemented by the storage engines shared between replicas,
+// so that they all take from the same outbound rate limit buckets.
+type RateLimiter interface {
+	// Reserve takes count tokens from the bucket of key, which gets a token
+	// back every interval and holds burst tokens at most. It returns how long
+	// to wait for the tokens. When that is over maxWait, nothing is taken and
+	// false is returned with the wait it would have been. A zero maxWait has
+	// no limit.
+	Reserve(key string, interval time.Duration, burst, count int, maxWait time.Duration) (time.Duration, bool, error)
+}
+
+// Mailbox is implemented by the storage engines shared between replicas, so
+// that the API waiting for the result of a notification gets it from the
+// worker of any replica as soon as it is sent.
+type Mailbox interface {
+	// Post adds the message to the mailbox of key, which is removed after ttl
+	// when nobody reads it.
+	Post(key string, message []byte, ttl time.Duration) error
+	// Receive waits up to timeout for a message of the mailbox of key, and
+	// returns nil when none came.
+	Receive(ctx context.Context, key string, timeout time.Duration) ([]byte, error)
 }
@@ -29,6 +29,8 @@ type LogPushEntry struct {
 	Token    string `json:"token"`
 	Message  string `json:"message"`
 	Error    string `json:"error"`
+	Code     string `json:"code,omitempty"`
+	Variant  string `json:"variant,omitempty"`
 }
 
 var isTerm bool
@@ -130,6 +132,8 @@ func colorForPlatform(platform int) string {
 		return blue
 	case core.PlatformCallAuto:
 		return yellow
+	case core.PlatformWebPush:
+		return blue
 	default:
 		return reset
 	}
@@ -143,6 +147,8 @@ func typeForPlatform(platform int) string {
 		return "android"
 	case core.PlatformHuawei:
 		return "huawei"
+	case core.PlatformWebPush:
+		return "webpush"
 	default:
 		return ""
 	}
@@ -193,6 +199,8 @@ func GetLogPushEntry(input *InputLog) LogPushEntry {
 		Token:    token,
 		Message:  message,
 		Error:    errMsg,
+		Code:     input.Code,
+		Variant:  input.Variant,
 	}
 }
 
@@ -207,6 +215,8 @@ type InputLog struct {
 	HideToken   bool
 	HideMessage bool
 	Format      string
+	Code        string
+	Variant     string
 }
 
 // LogPush record user push request and server response.
@@ -232,6 +242,17 @@ func LogPush(input *InputLog) LogPushEntry {
 				typeColor = green
 			}
 
+			output = fmt.Sprintf("|%s %s %s| %s%s%s [%s] %s",
+				typeColor, log.Type, resetColor,
+				platColor, log.Platform, resetColor,
+				log.Token,
+				log.Message,
+			)
+		case core.CappedPush:
+			if isTerm {
+				typeColor = yellow
+			}
+
 			output = fmt.Sprintf("|%s %s %s| %s%s%s [%s] %s",
 				typeColor, log.Type, resetColor,
 				platColor, log.Platform, resetColor,
@@ -254,7 +275,7 @@ func LogPush(input *InputLog) LogPushEntry {
 	}
 
 	switch input.Status {
-	case core.SucceededPush:
+	case core.SucceededPush, core.CappedPush:
 		LogAccess.Info(output)
 	case core.FailedPush:
 		LogError.Error(output)
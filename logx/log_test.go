@@ -175,4 +175,8 @@ func TestLogPush(t *testing.T) {
 	in.Status = "failed-push"
 	in.Message = "failed"
 	assert.Equal(t, "failed", LogPush(&in).Message)
+
+	in.Status = "capped-push"
+	in.Message = "capped"
+	assert.Equal(t, "capped-push", LogPush(&in).Type)
 }
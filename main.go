@@ -40,6 +40,8 @@ func main() {
 		message     string
 		token       string
 		title       string
+		sendFile    string
+		sendRate    float64
 	)
 
 	flag.BoolVar(&showVersion, "version", false, "Print version information.")
@@ -77,6 +79,8 @@ func main() {
 	flag.StringVar(&topic, "topic", "", "apns topic in iOS")
 	flag.StringVar(&opts.Core.HTTPProxy, "proxy", "", "http proxy url")
 	flag.BoolVar(&ping, "ping", false, "ping server")
+	flag.StringVar(&sendFile, "send-file", "", "send the notifications of a JSONL file")
+	flag.Float64Var(&sendRate, "send-rate", 0, "notifications sent per second from the file")
 
 	flag.Usage = usage
 	flag.Parse()
@@ -288,6 +292,23 @@ func main() {
 		logx.LogError.Fatal(err)
 	}
 
+	// send the notifications of a JSONL file
+	if sendFile != "" {
+		if err := status.InitAppStatus(cfg); err != nil {
+			logx.LogError.Fatal(err)
+		}
+
+		if err := initProviders(g, cfg); err != nil {
+			logx.LogError.Fatal(err)
+		}
+
+		if err := sendNotificationFile(g.ShutdownContext(), cfg, sendFile, sendRate); err != nil {
+			logx.LogError.Fatal(err)
+		}
+
+		return
+	}
+
 	if opts.Core.PID.Path != "" {
 		cfg.Core.PID.Path = opts.Core.PID.Path
 		cfg.Core.PID.Enabled = true
@@ -302,63 +323,73 @@ func main() {
 		logx.LogError.Fatal(err)
 	}
 
-	var w qcore.Worker
-	switch core.Queue(cfg.Queue.Engine) {
-	case core.LocalQueue:
-		w = queue.NewRing(
-			queue.WithQueueSize(int(cfg.Core.QueueNum)),
-			queue.WithFn(notify.Run(cfg)),
-			queue.WithLogger(logx.QueueLogger()),
-		)
-	case core.NSQ:
-		w = nsq.NewWorker(
-			nsq.WithAddr(cfg.Queue.NSQ.Addr),
-			nsq.WithTopic(cfg.Queue.NSQ.Topic),
-			nsq.WithChannel(cfg.Queue.NSQ.Channel),
-			nsq.WithMaxInFlight(int(cfg.Core.WorkerNum)),
-			nsq.WithRunFunc(notify.Run(cfg)),
-			nsq.WithLogger(logx.QueueLogger()),
-		)
-	case core.NATS:
-		w = nats.NewWorker(
-			nats.WithAddr(cfg.Queue.NATS.Addr),
-			nats.WithSubj(cfg.Queue.NATS.Subj),
-			nats.WithQueue(cfg.Queue.NATS.Queue),
-			nats.WithRunFunc(notify.Run(cfg)),
-			nats.WithLogger(logx.QueueLogger()),
-		)
-	case core.Redis:
-		opts := []redisdb.Option{
-			redisdb.WithAddr(cfg.Queue.Redis.Addr),
-			redisdb.WithUsername(cfg.Queue.Redis.Username),
-			redisdb.WithPassword(cfg.Queue.Redis.Password),
-			redisdb.WithStreamName(cfg.Queue.Redis.StreamName),
-			redisdb.WithGroup(cfg.Queue.Redis.Group),
-			redisdb.WithConsumer(cfg.Queue.Redis.Consumer),
-			redisdb.WithMaxLength(cfg.Core.QueueNum),
-			redisdb.WithRunFunc(notify.Run(cfg)),
-			redisdb.WithLogger(logx.QueueLogger()),
-		}
-		if cfg.Queue.Redis.WithTLS {
-			opts = append(opts, redisdb.WithTLS())
+	// the results of an external queue come back through the stat engine
+	if cfg.Core.Sync {
+		if err = notify.CheckSyncWait(cfg); err != nil {
+			logx.LogError.Fatal(err)
 		}
-		w = redisdb.NewWorker(
-			opts...,
-		)
-	default:
-		logx.LogError.Fatalf("we don't support queue engine: %s", cfg.Queue.Engine)
 	}
 
 	q := queue.NewPool(
 		cfg.Core.WorkerNum,
-		queue.WithWorker(w),
+		queue.WithWorker(newQueueWorker(cfg, "", cfg.Core.QueueNum, cfg.Core.WorkerNum)),
 		queue.WithLogger(logx.QueueLogger()),
 	)
 
+	// failed notifications go back to the queue instead of blocking a worker
+	notify.RetryQueue = q
+
+	// the high priority lane has its own workers, transactional notifications
+	// never wait behind a bulk send
+	if cfg.Queue.Priority.Enabled {
+		notify.PriorityQueue = queue.NewPool(
+			cfg.Queue.Priority.WorkerNum,
+			queue.WithWorker(newQueueWorker(
+				cfg,
+				cfg.Queue.Priority.Suffix,
+				cfg.Queue.Priority.QueueNum,
+				cfg.Queue.Priority.WorkerNum,
+			)),
+			queue.WithLogger(logx.QueueLogger()),
+		)
+	}
+
+	// a platform with its own pool can't starve the others when its provider
+	// is slow
+	for _, platform := range []int{
+		core.PlatformIOS,
+		core.PlatformAndroid,
+		core.PlatformHuawei,
+		core.PlatformSMS,
+		core.PlatformTelegramGateway,
+		core.PlatformCallAuto,
+		core.PlatformWebPush,
+	} {
+		workerNum, queueNum := notify.PlatformPool(cfg, platform)
+		if workerNum == 0 {
+			continue
+		}
+		notify.PlatformQueues[platform] = queue.NewPool(
+			workerNum,
+			queue.WithWorker(newQueueWorker(cfg, "-"+notify.PlatformPoolName(platform), queueNum, workerNum)),
+			queue.WithLogger(logx.QueueLogger()),
+		)
+	}
+
+	// the retries and the held notifications left by the last shutdown
+	if err = notify.RequeuePendingNotifications(cfg); err != nil {
+		logx.LogError.Error("can't requeue pending notifications: ", err.Error())
+	}
+
 	g.AddShutdownJob(func() error {
+		// keep the pending notifications for the next start, or drain them
+		notify.StopPendingNotifications(cfg)
 		// logx.LogAccess.Info("close the queue system, current queue usage: ", q.Usage())
 		// stop queue system and wait job completed
 		q.Release()
+		for _, pool := range notify.Pools() {
+			pool.Release()
+		}
 		// close the connection with storage
 		logx.LogAccess.Info("close the storage connection: ", cfg.Stat.Engine)
 		if err := status.StatStorage.Close(); err != nil {
@@ -367,22 +398,8 @@ func main() {
 		return nil
 	})
 
-	if cfg.Ios.Enabled {
-		if err = notify.InitAPNSClient(g.ShutdownContext(), cfg); err != nil {
-			logx.LogError.Fatal(err)
-		}
-	}
-
-	if cfg.Android.Enabled {
-		if _, err = notify.InitFCMClient(g.ShutdownContext(), cfg); err != nil {
-			logx.LogError.Fatal(err)
-		}
-	}
-
-	if cfg.Huawei.Enabled {
-		if _, err = notify.InitHMSClient(cfg, cfg.Huawei.AppSecret, cfg.Huawei.AppID); err != nil {
-			logx.LogError.Fatal(err)
-		}
+	if err = initProviders(g, cfg); err != nil {
+		logx.LogError.Fatal(err)
 	}
 
 	go notify.RunScheduledRUSMSWorker()
@@ -426,6 +443,8 @@ Server Options:
     --pid <pid path>                 Process identifier path
     --redis-addr <redis addr>        Redis addr (default: localhost:6379)
     --ping                           healthy check command for container
+    --send-file <file>               Send the notifications of a JSONL file, one per line
+    --send-rate <rate>               Notifications sent per second from the file (default: unlimited)
 iOS Options:
     -i, --key <file>                 certificate key file path
     -P, --password <password>        certificate key password
@@ -444,6 +463,58 @@ Common Options:
     -V, --version                    Show version
 `
 
+// newQueueWorker returns the worker of a lane for the queue engine, the suffix
+// keeps the topic, subject or stream of each lane apart.
+func newQueueWorker(cfg *config.ConfYaml, suffix string, queueNum, workerNum int64) qcore.Worker {
+	switch core.Queue(cfg.Queue.Engine) {
+	case core.LocalQueue:
+		return queue.NewRing(
+			queue.WithQueueSize(int(queueNum)),
+			queue.WithFn(notify.Run(cfg)),
+			queue.WithLogger(logx.QueueLogger()),
+		)
+	case core.NSQ:
+		return nsq.NewWorker(
+			nsq.WithAddr(cfg.Queue.NSQ.Addr),
+			nsq.WithTopic(cfg.Queue.NSQ.Topic+suffix),
+			nsq.WithChannel(cfg.Queue.NSQ.Channel),
+			nsq.WithMaxInFlight(int(workerNum)),
+			nsq.WithRunFunc(notify.Run(cfg)),
+			nsq.WithLogger(logx.QueueLogger()),
+		)
+	case core.NATS:
+		return nats.NewWorker(
+			nats.WithAddr(cfg.Queue.NATS.Addr),
+			nats.WithSubj(cfg.Queue.NATS.Subj+suffix),
+			nats.WithQueue(cfg.Queue.NATS.Queue),
+			nats.WithRunFunc(notify.Run(cfg)),
+			nats.WithLogger(logx.QueueLogger()),
+		)
+	case core.Redis:
+		opts := []redisdb.Option{
+			redisdb.WithAddr(cfg.Queue.Redis.Addr),
+			redisdb.WithUsername(cfg.Queue.Redis.Username),
+			redisdb.WithPassword(cfg.Queue.Redis.Password),
+			redisdb.WithStreamName(cfg.Queue.Redis.StreamName + suffix),
+			redisdb.WithGroup(cfg.Queue.Redis.Group),
+			redisdb.WithConsumer(cfg.Queue.Redis.Consumer),
+			redisdb.WithMaxLength(queueNum),
+			redisdb.WithRunFunc(notify.Run(cfg)),
+			redisdb.WithLogger(logx.QueueLogger()),
+		}
+		if cfg.Queue.Redis.WithTLS {
+			opts = append(opts, redisdb.WithTLS())
+		}
+		return redisdb.NewWorker(
+			opts...,
+		)
+	default:
+		logx.LogError.Fatalf("we don't support queue engine: %s", cfg.Queue.Engine)
+	}
+
+	return nil
+}
+
 // usage will print out the flag options for the server.
 func usage() {
 	fmt.Printf("%s\n", usageStr)
@@ -479,6 +550,86 @@ func pinger(ctx context.Context, cfg *config.ConfYaml) error {
 	return nil
 }
 
+// initProviders initializes the clients of the enabled providers.
+func initProviders(g *graceful.Manager, cfg *config.ConfYaml) error {
+	// the clients of the fake providers are used by the inits below
+	if cfg.Mock.Enabled {
+		mock, err := notify.InitMockProviders(g.ShutdownContext(), cfg)
+		if err != nil {
+			return err
+		}
+		g.AddShutdownJob(mock.Close)
+	}
+
+	if cfg.Ios.Enabled && !cfg.Mock.Enabled {
+		if err := notify.InitAPNSClient(g.ShutdownContext(), cfg); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Android.Enabled {
+		if _, err := notify.InitFCMClient(g.ShutdownContext(), cfg); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Huawei.Enabled {
+		if _, err := notify.InitHMSClient(cfg, cfg.Huawei.AppSecret, cfg.Huawei.AppID); err != nil {
+			return err
+		}
+	}
+
+	if cfg.WebPush.Enabled {
+		if _, err := notify.InitWebPushClient(cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendNotificationFile sends the notifications of a JSONL file, one per line,
+// at most rate per second. A zero rate has no limit. The invalid lines are
+// logged and skipped.
+func sendNotificationFile(ctx context.Context, cfg *config.ConfYaml, path string, rate float64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// there is no queue to retry on, the results are logged as they come
+	cfg.Core.Sync = true
+
+	var ticker *time.Ticker
+	if rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+	}
+
+	sent, rejected, err := notify.ReadBulkNotifications(ctx, cfg, file, func(_ int, req *notify.PushNotification) error {
+		if ticker != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+
+		if _, err := notify.SendNotification(ctx, req, cfg); err != nil {
+			logx.LogError.Error(err)
+		}
+		return nil
+	})
+
+	for _, line := range rejected {
+		logx.LogError.Error(line.Error())
+	}
+	logx.LogAccess.Infof("%d notifications sent from %s, %d lines rejected", sent, path, len(rejected))
+
+	return err
+}
+
 func createPIDFile(cfg *config.ConfYaml) error {
 	if !cfg.Core.PID.Enabled {
 		return nil
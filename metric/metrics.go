@@ -1,6 +1,8 @@
 package metric
 
 import (
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/notify"
 	"github.com/appleboy/gorush/status"
 
 	"github.com/golang-queue/queue"
@@ -23,7 +25,26 @@ type Metrics struct {
 	SuccessTasks   *prometheus.Desc
 	FailureTasks   *prometheus.Desc
 	SubmittedTasks *prometheus.Desc
-	q              *queue.Queue
+	// the same for the high priority lane and the platform pools, by pool
+	PoolBusyWorkers    *prometheus.Desc
+	PoolSuccessTasks   *prometheus.Desc
+	PoolFailureTasks   *prometheus.Desc
+	PoolSubmittedTasks *prometheus.Desc
+	// 0 when closed, 1 when half-open and 2 when open, by provider
+	CircuitBreakerState *prometheus.Desc
+	RateLimitWait       *prometheus.Desc
+	// the failed pushes, by platform and error code
+	PushErrors *prometheus.Desc
+	q          *queue.Queue
+}
+
+// pushPlatforms are the platforms logging the result of each push.
+var pushPlatforms = []int{core.PlatformIOS, core.PlatformAndroid, core.PlatformHuawei, core.PlatformWebPush}
+
+var breakerStates = map[string]float64{
+	notify.BreakerClosed:   0,
+	notify.BreakerHalfOpen: 1,
+	notify.BreakerOpen:     2,
 }
 
 // NewMetrics returns a new Metrics with all prometheus.Desc initialized
@@ -84,6 +105,41 @@ func NewMetrics(q *queue.Queue) Metrics {
 			"Length of Submitted Tasks",
 			nil, nil,
 		),
+		PoolBusyWorkers: prometheus.NewDesc(
+			namespace+"pool_busy_workers",
+			"Length of busy workers of the pool",
+			[]string{"pool"}, nil,
+		),
+		PoolSuccessTasks: prometheus.NewDesc(
+			namespace+"pool_success_tasks",
+			"Length of Success Tasks of the pool",
+			[]string{"pool"}, nil,
+		),
+		PoolFailureTasks: prometheus.NewDesc(
+			namespace+"pool_failure_tasks",
+			"Length of Failure Tasks of the pool",
+			[]string{"pool"}, nil,
+		),
+		PoolSubmittedTasks: prometheus.NewDesc(
+			namespace+"pool_submitted_tasks",
+			"Length of Submitted Tasks of the pool",
+			[]string{"pool"}, nil,
+		),
+		CircuitBreakerState: prometheus.NewDesc(
+			namespace+"circuit_breaker_state",
+			"State of the circuit breaker of the provider, 0 closed, 1 half-open and 2 open",
+			[]string{"provider"}, nil,
+		),
+		RateLimitWait: prometheus.NewDesc(
+			namespace+"rate_limit_wait_seconds",
+			"Time the notifications waited for the capacity of the provider",
+			[]string{"provider"}, nil,
+		),
+		PushErrors: prometheus.NewDesc(
+			namespace+"push_errors",
+			"Number of failed pushes by platform and error code",
+			[]string{"platform", "code"}, nil,
+		),
 		q: q,
 	}
 
@@ -103,6 +159,13 @@ func (c Metrics) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.SuccessTasks
 	ch <- c.FailureTasks
 	ch <- c.SubmittedTasks
+	ch <- c.PoolBusyWorkers
+	ch <- c.PoolSuccessTasks
+	ch <- c.PoolFailureTasks
+	ch <- c.PoolSubmittedTasks
+	ch <- c.CircuitBreakerState
+	ch <- c.RateLimitWait
+	ch <- c.PushErrors
 }
 
 // Collect returns the metrics with values
@@ -162,4 +225,62 @@ func (c Metrics) Collect(ch chan<- prometheus.Metric) {
 		prometheus.CounterValue,
 		float64(c.q.SubmittedTasks()),
 	)
+	for name, pool := range notify.Pools() {
+		ch <- prometheus.MustNewConstMetric(
+			c.PoolBusyWorkers,
+			prometheus.GaugeValue,
+			float64(pool.BusyWorkers()),
+			name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.PoolSuccessTasks,
+			prometheus.CounterValue,
+			float64(pool.SuccessTasks()),
+			name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.PoolFailureTasks,
+			prometheus.CounterValue,
+			float64(pool.FailureTasks()),
+			name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.PoolSubmittedTasks,
+			prometheus.CounterValue,
+			float64(pool.SubmittedTasks()),
+			name,
+		)
+	}
+	for provider, breaker := range notify.CircuitBreakers() {
+		ch <- prometheus.MustNewConstMetric(
+			c.CircuitBreakerState,
+			prometheus.GaugeValue,
+			breakerStates[breaker.State()],
+			provider,
+		)
+	}
+	for provider, wait := range notify.RateLimitWaits() {
+		ch <- prometheus.MustNewConstSummary(
+			c.RateLimitWait,
+			wait.Count,
+			wait.Seconds,
+			nil,
+			provider,
+		)
+	}
+	for _, platform := range pushPlatforms {
+		for _, code := range notify.ErrorCodes {
+			count := notify.PushErrorCount(platform, code)
+			if count == 0 {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.PushErrors,
+				prometheus.CounterValue,
+				float64(count),
+				notify.PlatformPoolName(platform),
+				code,
+			)
+		}
+	}
 }
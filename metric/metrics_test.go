@@ -5,7 +5,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/notify"
+	"github.com/appleboy/gorush/status"
+
 	"github.com/golang-queue/queue"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,3 +28,61 @@ func TestNewMetrics(t *testing.T) {
 	assert.Equal(t, uint64(2), m.q.SubmittedTasks())
 	assert.Equal(t, uint64(2), m.q.SuccessTasks())
 }
+
+func TestPoolMetrics(t *testing.T) {
+	pool := queue.NewPool(1)
+	defer pool.Release()
+	notify.PlatformQueues[core.PlatformIOS] = pool
+	defer delete(notify.PlatformQueues, core.PlatformIOS)
+
+	q := queue.NewPool(1)
+	defer q.Release()
+
+	assert.NoError(t, status.InitAppStatus(&config.ConfYaml{Stat: config.SectionStat{Engine: "memory"}}))
+
+	ch := make(chan prometheus.Metric, 32)
+	NewMetrics(q).Collect(ch)
+	close(ch)
+
+	pools := 0
+	for m := range ch {
+		var metric dto.Metric
+		assert.NoError(t, m.Write(&metric))
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == "pool" {
+				assert.Equal(t, "ios", label.GetValue())
+				pools++
+			}
+		}
+	}
+	assert.Equal(t, 4, pools)
+}
+
+func TestPushErrorMetrics(t *testing.T) {
+	q := queue.NewPool(1)
+	defer q.Release()
+
+	assert.NoError(t, status.InitAppStatus(&config.ConfYaml{Stat: config.SectionStat{Engine: "memory"}}))
+	status.StatStorage.AddCount("gorush-push-error-count:2:unregistered", 3)
+
+	ch := make(chan prometheus.Metric, 32)
+	NewMetrics(q).Collect(ch)
+	close(ch)
+
+	found := false
+	for m := range ch {
+		if m.Desc().String() != NewMetrics(q).PushErrors.String() {
+			continue
+		}
+		var metric dto.Metric
+		assert.NoError(t, m.Write(&metric))
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		assert.Equal(t, map[string]string{"platform": "android", "code": notify.ErrorCodeUnregistered}, labels)
+		assert.Equal(t, float64(3), metric.GetCounter().GetValue())
+		found = true
+	}
+	assert.True(t, found)
+}
@@ -0,0 +1,512 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+)
+
+const (
+	deviceKey = "gorush-device:"
+	// deviceTagKey indexes the devices by tag, a key per tag and device
+	// holding the platform and the token, so that an audience is resolved
+	// from the keys of its tags alone.
+	deviceTagKey = "gorush-device-tag:"
+)
+
+// The operators of the tag expressions, in any case.
+const (
+	audienceAnd = "AND"
+	audienceOr  = "OR"
+	audienceNot = "NOT"
+)
+
+var (
+	// ErrDeviceNotFound is returned for a token without registered device.
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrInvalidDevice is returned for a device that can't be registered.
+	ErrInvalidDevice = errors.New("invalid device")
+	// ErrInvalidAudience is returned for an audience that can't be sent to.
+	ErrInvalidAudience = errors.New("invalid audience")
+	// ErrEmptyAudience is returned when no device matches the audience.
+	ErrEmptyAudience = errors.New("no device matches the audience")
+)
+
+// Device is the metadata gorush keeps for a token, its tags are matched by
+// the audiences of the notifications.
+type Device struct {
+	Token     string   `json:"token"`
+	Platform  int      `json:"platform"`
+	Tags      []string `json:"tags"`
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+func deviceRecordKey(token string) string {
+	return deviceKey + url.QueryEscape(token)
+}
+
+// deviceTagPrefix is the prefix of the index keys of the devices with the
+// tag. The empty tag indexes every device.
+func deviceTagPrefix(tag string) string {
+	return deviceTagKey + url.QueryEscape(tag) + ":"
+}
+
+func deviceTagRecordKey(tag string, platform int, token string) string {
+	return deviceTagPrefix(tag) + strconv.Itoa(platform) + ":" + url.QueryEscape(token)
+}
+
+// deviceIndexKeys returns the index keys of the device, including the one of
+// the empty tag.
+func deviceIndexKeys(device *Device) []string {
+	keys := []string{deviceTagRecordKey("", device.Platform, device.Token)}
+	for _, tag := range device.Tags {
+		keys = append(keys, deviceTagRecordKey(tag, device.Platform, device.Token))
+	}
+	return keys
+}
+
+// indexedDevice is a device found in the index of a tag.
+type indexedDevice struct {
+	platform int
+	token    string
+}
+
+// taggedDevices returns the devices indexed under the tag.
+func taggedDevices(tag string) (map[indexedDevice]bool, error) {
+	prefix := deviceTagPrefix(tag)
+	keys, err := status.StatStorage.Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make(map[indexedDevice]bool, len(keys))
+	for _, key := range keys {
+		platform, token, ok := strings.Cut(strings.TrimPrefix(key, prefix), ":")
+		if !ok {
+			continue
+		}
+		p, err := strconv.Atoi(platform)
+		if err != nil {
+			continue
+		}
+		if token, err = url.QueryUnescape(token); err != nil {
+			continue
+		}
+		devices[indexedDevice{platform: p, token: token}] = true
+	}
+
+	return devices, nil
+}
+
+// audiencePlatform reports whether the devices of the platform can be part
+// of an audience: those with a single device token.
+func audiencePlatform(platform int) bool {
+	switch platform {
+	case core.PlatformIOS, core.PlatformAndroid, core.PlatformHuawei:
+		return true
+	}
+	return false
+}
+
+// checkTag rejects the tags an expression could not name.
+func checkTag(tag string) error {
+	switch {
+	case tag == "":
+		return errors.New("empty tag")
+	case strings.ContainsAny(tag, " \t\r\n()"):
+		return fmt.Errorf("tag %q has a space or a parenthesis", tag)
+	}
+
+	switch strings.ToUpper(tag) {
+	case audienceAnd, audienceOr, audienceNot:
+		return fmt.Errorf("tag %q is an operator", tag)
+	}
+
+	return nil
+}
+
+// RegisterDevice stores the device, or replaces the tags of a device already
+// registered with the token.
+func RegisterDevice(device *Device) error {
+	if device.Token == "" {
+		return fmt.Errorf("%w: the token cannot be empty", ErrInvalidDevice)
+	}
+	if !audiencePlatform(device.Platform) {
+		return fmt.Errorf("%w: platform %d has no device tokens", ErrInvalidDevice, device.Platform)
+	}
+
+	tags := make([]string, 0, len(device.Tags))
+	for _, tag := range device.Tags {
+		if err := checkTag(tag); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidDevice, err)
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	device.Tags = tags
+	device.UpdatedAt = time.Now().Unix()
+
+	data, err := json.Marshal(device)
+	if err != nil {
+		return err
+	}
+
+	// the index keys of the tags the device no longer has are removed
+	stale := []string{}
+	previous, err := GetDevice(device.Token)
+	switch {
+	case err == nil:
+		stale = deviceIndexKeys(previous)
+	case !errors.Is(err, ErrDeviceNotFound):
+		return err
+	}
+
+	if err := status.StatStorage.SetValue(deviceRecordKey(device.Token), data, 0); err != nil {
+		return err
+	}
+
+	current := deviceIndexKeys(device)
+	for _, key := range current {
+		if err := status.StatStorage.SetValue(key, []byte{1}, 0); err != nil {
+			return err
+		}
+	}
+	for _, key := range stale {
+		if slices.Contains(current, key) {
+			continue
+		}
+		if err := status.StatStorage.DelValue(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetDevice returns the device registered with the token.
+func GetDevice(token string) (*Device, error) {
+	data, err := status.StatStorage.GetValue(deviceRecordKey(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, ErrDeviceNotFound
+	}
+
+	device := &Device{}
+	if err := json.Unmarshal(data, device); err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// DeleteDevice removes the device from every audience.
+func DeleteDevice(token string) error {
+	device, err := GetDevice(token)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range deviceIndexKeys(device) {
+		if err := status.StatStorage.DelValue(key); err != nil {
+			return err
+		}
+	}
+
+	return status.StatStorage.DelValue(deviceRecordKey(token))
+}
+
+// Audience is a parsed tag expression, such as
+// `lang:ru AND plan:premium AND NOT churned`. NOT binds tighter than AND,
+// which binds tighter than OR, parentheses group the terms.
+type Audience struct {
+	expr audienceExpr
+}
+
+type audienceExpr interface {
+	match(tags map[string]bool) bool
+}
+
+type (
+	audienceTag   string
+	audienceNotOp struct{ expr audienceExpr }
+	audienceAndOp struct{ left, right audienceExpr }
+	audienceOrOp  struct{ left, right audienceExpr }
+)
+
+func (t audienceTag) match(tags map[string]bool) bool   { return tags[string(t)] }
+func (o audienceNotOp) match(tags map[string]bool) bool { return !o.expr.match(tags) }
+
+func (o audienceAndOp) match(tags map[string]bool) bool {
+	return o.left.match(tags) && o.right.match(tags)
+}
+
+func (o audienceOrOp) match(tags map[string]bool) bool {
+	return o.left.match(tags) || o.right.match(tags)
+}
+
+// audienceTags returns the tags the expression names.
+func audienceTags(expr audienceExpr) []string {
+	switch o := expr.(type) {
+	case audienceTag:
+		return []string{string(o)}
+	case audienceNotOp:
+		return audienceTags(o.expr)
+	case audienceAndOp:
+		return append(audienceTags(o.left), audienceTags(o.right)...)
+	case audienceOrOp:
+		return append(audienceTags(o.left), audienceTags(o.right)...)
+	}
+	return nil
+}
+
+// audienceParser is a recursive descent parser of the tag expressions.
+type audienceParser struct {
+	terms []string
+	pos   int
+}
+
+func (p *audienceParser) peek() string {
+	if p.pos < len(p.terms) {
+		return p.terms[p.pos]
+	}
+	return ""
+}
+
+// operator consumes the next term when it is the operator.
+func (p *audienceParser) operator(op string) bool {
+	if strings.ToUpper(p.peek()) != op {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *audienceParser) parseOr() (audienceExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.operator(audienceOr) {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = audienceOrOp{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *audienceParser) parseAnd() (audienceExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.operator(audienceAnd) {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = audienceAndOp{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *audienceParser) parseNot() (audienceExpr, error) {
+	if p.operator(audienceNot) {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return audienceNotOp{expr}, nil
+	}
+
+	term := p.peek()
+	switch term {
+	case "":
+		return nil, errors.New("unexpected end of expression")
+	case "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	if err := checkTag(term); err != nil {
+		return nil, err
+	}
+	p.pos++
+
+	return audienceTag(term), nil
+}
+
+// ParseAudience parses the tag expression of an audience.
+func ParseAudience(expr string) (*Audience, error) {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	p := &audienceParser{terms: strings.Fields(expr)}
+
+	root, err := p.parseOr()
+	if err == nil && p.pos < len(p.terms) {
+		err = fmt.Errorf("unexpected %q", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAudience, err)
+	}
+
+	return &Audience{expr: root}, nil
+}
+
+// Match reports whether a device with the tags is part of the audience.
+func (a *Audience) Match(tags []string) bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	return a.expr.match(set)
+}
+
+// CheckAudience validates the audience of the notification, which replaces
+// any other target and may only be narrowed to a platform with device tokens.
+func CheckAudience(req *PushNotification) error {
+	if req.Audience == "" {
+		return nil
+	}
+
+	if req.Platform != 0 && !audiencePlatform(req.Platform) {
+		return fmt.Errorf("%w: platform %d has no device tokens", ErrInvalidAudience, req.Platform)
+	}
+
+	if len(req.Tokens) > 0 || req.To != "" || req.Topic != "" || req.Condition != "" ||
+		len(req.Subscriptions) > 0 || len(req.PhoneNumbers) > 0 {
+		return fmt.Errorf("%w: it can't be combined with other targets", ErrInvalidAudience)
+	}
+
+	_, err := ParseAudience(req.Audience)
+	return err
+}
+
+// ResolveAudience returns the tokens of the devices of the audience by
+// platform, restricted to the platform unless it is zero. The devices of the
+// disabled platforms are left out. Only the index of the tags of the audience
+// is read, and that of every device when the audience matches the devices
+// without any of them, such as `NOT churned`.
+func ResolveAudience(cfg *config.ConfYaml, expr string, platform int) (map[int][]string, error) {
+	audience, err := ParseAudience(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := map[string]map[indexedDevice]bool{}
+	candidates := map[indexedDevice]bool{}
+	for _, tag := range audienceTags(audience.expr) {
+		if _, ok := tagged[tag]; ok {
+			continue
+		}
+		devices, err := taggedDevices(tag)
+		if err != nil {
+			return nil, err
+		}
+		tagged[tag] = devices
+		for device := range devices {
+			candidates[device] = true
+		}
+	}
+
+	if audience.expr.match(map[string]bool{}) {
+		if candidates, err = taggedDevices(""); err != nil {
+			return nil, err
+		}
+	}
+
+	enabled := map[int]bool{
+		core.PlatformIOS:     cfg.Ios.Enabled,
+		core.PlatformAndroid: cfg.Android.Enabled,
+		core.PlatformHuawei:  cfg.Huawei.Enabled,
+	}
+
+	tokens := map[int][]string{}
+	for device := range candidates {
+		if !enabled[device.platform] || (platform != 0 && device.platform != platform) {
+			continue
+		}
+
+		tags := make(map[string]bool, len(tagged))
+		for tag, devices := range tagged {
+			tags[tag] = devices[device]
+		}
+
+		if audience.expr.match(tags) {
+			tokens[device.platform] = append(tokens[device.platform], device.token)
+		}
+	}
+
+	for _, platformTokens := range tokens {
+		slices.Sort(platformTokens)
+	}
+
+	return tokens, nil
+}
+
+// sendAudience sends the notification to the devices its audience matches
+// now, as a notification per platform.
+func sendAudience(ctx context.Context, req *PushNotification, cfg *config.ConfYaml) (*ResponsePush, error) {
+	tokens, err := ResolveAudience(cfg, req.Audience, req.Platform)
+	if err == nil && len(tokens) == 0 {
+		err = ErrEmptyAudience
+	}
+	if err != nil {
+		logx.LogError.Errorf("can't send to audience %q: %v", req.Audience, err)
+		SetDeliveryState(cfg, req, DeliveryFailed, err)
+		countCampaignResult(req, nil, err)
+		return &ResponsePush{}, err
+	}
+
+	size := 0
+	for _, platformTokens := range tokens {
+		size += len(platformTokens)
+	}
+	logx.LogAccess.Debugf("audience %q matches %d devices", req.Audience, size)
+
+	// the campaign counted the notification as a single message when queued
+	addCampaignCount(req, campaignQueued, size-messageCount(req))
+
+	batches := []*PushNotification{}
+	for _, platform := range []int{core.PlatformIOS, core.PlatformAndroid, core.PlatformHuawei} {
+		if len(tokens[platform]) == 0 {
+			continue
+		}
+
+		batch := *req
+		batch.Audience = ""
+		batch.Platform = platform
+		batch.Tokens = tokens[platform]
+		// a batch holds the devices of many users, capped per device
+		batch.UserID = ""
+		batches = append(batches, &batch)
+	}
+
+	return sendBatches(ctx, req, cfg, &ResponsePush{}, batches)
+}
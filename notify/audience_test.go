@@ -0,0 +1,243 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/status"
+
+	"github.com/golang-queue/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+func registerTestDevices(t *testing.T, devices ...*Device) {
+	t.Helper()
+
+	for _, device := range devices {
+		assert.NoError(t, RegisterDevice(device))
+		token := device.Token
+		t.Cleanup(func() { _ = DeleteDevice(token) })
+	}
+}
+
+func TestParseAudience(t *testing.T) {
+	tags := []string{"lang:ru", "plan:premium"}
+
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{"lang:ru", true},
+		{"lang:en", false},
+		{"lang:ru AND plan:premium AND NOT churned", true},
+		{"lang:ru and not plan:premium", false},
+		{"lang:en OR plan:premium", true},
+		{"lang:en OR lang:de AND plan:premium", false},
+		{"NOT NOT lang:ru", true},
+		{"(lang:en OR lang:ru) AND (plan:premium)", true},
+		{"NOT (lang:ru AND plan:premium)", false},
+	}
+
+	for _, test := range tests {
+		audience, err := ParseAudience(test.expr)
+		assert.NoError(t, err, test.expr)
+		assert.Equal(t, test.match, audience.Match(tags), test.expr)
+	}
+
+	for _, expr := range []string{"", "lang:ru AND", "(lang:ru", "lang:ru)", "lang:ru plan:premium", "OR lang:ru", "()"} {
+		_, err := ParseAudience(expr)
+		assert.ErrorIs(t, err, ErrInvalidAudience, expr)
+	}
+}
+
+func TestRegisterDevice(t *testing.T) {
+	registerTestDevices(t, &Device{
+		Token:    "device-aaaa",
+		Platform: core.PlatformIOS,
+		Tags:     []string{"lang:ru", "lang:ru", "plan:premium"},
+	})
+
+	device, err := GetDevice("device-aaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lang:ru", "plan:premium"}, device.Tags)
+	assert.NotZero(t, device.UpdatedAt)
+
+	// the tags are replaced
+	assert.NoError(t, RegisterDevice(&Device{Token: "device-aaaa", Platform: core.PlatformIOS}))
+	device, err = GetDevice("device-aaaa")
+	assert.NoError(t, err)
+	assert.Empty(t, device.Tags)
+
+	assert.ErrorIs(t, RegisterDevice(&Device{Platform: core.PlatformIOS}), ErrInvalidDevice)
+	assert.ErrorIs(t, RegisterDevice(&Device{Token: "device-sms", Platform: core.PlatformSMS}), ErrInvalidDevice)
+	assert.ErrorIs(t, RegisterDevice(&Device{
+		Token:    "device-bbbb",
+		Platform: core.PlatformAndroid,
+		Tags:     []string{"plan premium"},
+	}), ErrInvalidDevice)
+	assert.ErrorIs(t, RegisterDevice(&Device{
+		Token:    "device-bbbb",
+		Platform: core.PlatformAndroid,
+		Tags:     []string{"not"},
+	}), ErrInvalidDevice)
+
+	assert.NoError(t, DeleteDevice("device-aaaa"))
+	_, err = GetDevice("device-aaaa")
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+	assert.ErrorIs(t, DeleteDevice("device-aaaa"), ErrDeviceNotFound)
+}
+
+func TestCheckAudience(t *testing.T) {
+	assert.NoError(t, CheckAudience(&PushNotification{}))
+	assert.NoError(t, CheckAudience(&PushNotification{Audience: "lang:ru"}))
+	assert.NoError(t, CheckAudience(&PushNotification{Audience: "lang:ru", Platform: core.PlatformHuawei}))
+
+	assert.ErrorIs(t, CheckAudience(&PushNotification{
+		Audience: "lang:ru",
+		Platform: core.PlatformSMS,
+	}), ErrInvalidAudience)
+	assert.ErrorIs(t, CheckAudience(&PushNotification{
+		Audience: "lang:ru",
+		Platform: core.PlatformAndroid,
+		Topic:    "/topics/news",
+	}), ErrInvalidAudience)
+	assert.ErrorIs(t, CheckAudience(&PushNotification{Audience: "lang:ru AND"}), ErrInvalidAudience)
+
+	cfg, _ := config.LoadConf()
+	assert.NoError(t, CheckBulkNotification(cfg, &PushNotification{Audience: "lang:ru"}))
+	assert.ErrorIs(t, CheckBulkNotification(cfg, &PushNotification{}), ErrUnknownPlatform)
+}
+
+func TestResolveAudience(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Ios.Enabled = true
+	cfg.Android.Enabled = true
+
+	registerTestDevices(t,
+		&Device{Token: "resolve-aaaa", Platform: core.PlatformIOS, Tags: []string{"resolve", "lang:ru"}},
+		&Device{Token: "resolve-bbbb", Platform: core.PlatformAndroid, Tags: []string{"resolve", "lang:ru"}},
+		&Device{Token: "resolve-cccc", Platform: core.PlatformAndroid, Tags: []string{"resolve", "lang:ru", "churned"}},
+		&Device{Token: "resolve-dddd", Platform: core.PlatformHuawei, Tags: []string{"resolve", "lang:ru"}},
+	)
+
+	tokens, err := ResolveAudience(cfg, "resolve AND lang:ru AND NOT churned", 0)
+	assert.NoError(t, err)
+	// huawei is disabled
+	assert.Equal(t, map[int][]string{
+		core.PlatformIOS:     {"resolve-aaaa"},
+		core.PlatformAndroid: {"resolve-bbbb"},
+	}, tokens)
+
+	tokens, err = ResolveAudience(cfg, "resolve", core.PlatformAndroid)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]string{core.PlatformAndroid: {"resolve-bbbb", "resolve-cccc"}}, tokens)
+
+	tokens, err = ResolveAudience(cfg, "resolve AND lang:en", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, tokens)
+
+	// the devices without any tag of the audience are matched too
+	tokens, err = ResolveAudience(cfg, "NOT resolve", core.PlatformIOS)
+	assert.NoError(t, err)
+	assert.NotContains(t, tokens[core.PlatformIOS], "resolve-aaaa")
+
+	// the index follows the tags of the device
+	assert.NoError(t, RegisterDevice(&Device{Token: "resolve-aaaa", Platform: core.PlatformIOS, Tags: []string{"resolve"}}))
+	tokens, err = ResolveAudience(cfg, "resolve AND lang:ru", core.PlatformIOS)
+	assert.NoError(t, err)
+	assert.Empty(t, tokens)
+	tokens, err = ResolveAudience(cfg, "resolve AND NOT lang:ru", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]string{core.PlatformIOS: {"resolve-aaaa"}}, tokens)
+
+	assert.NoError(t, DeleteDevice("resolve-aaaa"))
+	tokens, err = ResolveAudience(cfg, "resolve", core.PlatformIOS)
+	assert.NoError(t, err)
+	assert.Empty(t, tokens)
+	keys, err := status.StatStorage.Keys(deviceTagKey)
+	assert.NoError(t, err)
+	for _, key := range keys {
+		assert.NotContains(t, key, "resolve-aaaa")
+	}
+}
+
+func TestSendAudience(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.Ios.Enabled = true
+	cfg.Android.Enabled = true
+
+	registerTestDevices(t,
+		&Device{Token: "send-aaaa", Platform: core.PlatformIOS, Tags: []string{"send", "plan:premium"}},
+		&Device{Token: "send-bbbb", Platform: core.PlatformAndroid, Tags: []string{"send", "plan:premium"}},
+		&Device{Token: "send-cccc", Platform: core.PlatformAndroid, Tags: []string{"send"}},
+	)
+
+	_, err := CreateCampaign(cfg, "campaign-audience", "")
+	assert.NoError(t, err)
+
+	req := &PushNotification{
+		Audience:   "send AND plan:premium",
+		Message:    "Welcome",
+		CampaignID: "campaign-audience",
+	}
+	CampaignQueued(req)
+
+	_, err = SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, mock.Messages("apns"), 1)
+	assert.Len(t, mock.Messages("fcm"), 1)
+
+	campaign, err := GetCampaign("campaign-audience")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), campaign.Queued)
+	assert.Equal(t, int64(2), campaign.Sent)
+	assert.Equal(t, CampaignDone, campaign.State)
+
+	resp, err := SendNotification(context.Background(), &PushNotification{
+		Audience: "send AND plan:free",
+		Message:  "Welcome",
+	}, cfg)
+	assert.ErrorIs(t, err, ErrEmptyAudience)
+	assert.Empty(t, resp.Logs)
+}
+
+func TestSendAudiencePlatformPools(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.Ios.Enabled = true
+	cfg.Android.Enabled = true
+	cfg.DeliveryStatus.Enabled = true
+
+	registerTestDevices(t,
+		&Device{Token: "pool-aaaa", Platform: core.PlatformIOS, Tags: []string{"pool"}},
+		&Device{Token: "pool-bbbb", Platform: core.PlatformAndroid, Tags: []string{"pool"}},
+	)
+
+	iosQueue, received := newTestQueue(t)
+	PlatformQueues[core.PlatformIOS] = iosQueue
+	t.Cleanup(func() { PlatformQueues = map[int]*queue.Queue{} })
+
+	req := &PushNotification{ID: "audience-pools", Audience: "pool", Message: "Welcome"}
+	_, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+
+	// the android batch is sent by the worker, the ios one by its pool
+	assert.Len(t, mock.Messages("fcm"), 1)
+	assert.Empty(t, mock.Messages("apns"))
+
+	select {
+	case batch := <-received:
+		assert.Equal(t, core.PlatformIOS, batch.Platform)
+		assert.Equal(t, []string{"pool-aaaa"}, batch.Tokens)
+		assert.Empty(t, batch.Audience)
+	case <-time.After(time.Second):
+		t.Fatal("the ios batch was not queued to its pool")
+	}
+
+	// the queued batch is still to be sent
+	delivery, err := GetDeliveryStatus(req.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, DeliveryQueued, delivery.State)
+}
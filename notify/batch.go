@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"sync"
+)
+
+const (
+	// MaxAndroidBatchSize is the maximum number of messages FCM accepts per send call.
+	// ref: https://firebase.google.com/docs/cloud-messaging/send-message#send-messages-to-multiple-devices
+	MaxAndroidBatchSize = 500
+
+	// MaxHuaweiBatchSize is the maximum number of tokens HMS accepts per message.
+	// ref: https://developer.huawei.com/consumer/en/doc/development/HMSCore-References/https-send-api-0000001050986197
+	MaxHuaweiBatchSize = 1000
+)
+
+// chunkSlice splits items into consecutive batches of at most size elements.
+func chunkSlice[T any](items []T, size int) [][]T {
+	if size <= 0 || len(items) <= size {
+		return [][]T{items}
+	}
+
+	batches := make([][]T, 0, (len(items)+size-1)/size)
+	for start := 0; start < len(items); start += size {
+		end := start + size
+		if end > len(items) {
+			end = len(items)
+		}
+		batches = append(batches, items[start:end])
+	}
+
+	return batches
+}
+
+// runBatches calls fn for each batch index in [0, n) with at most limit
+// calls running concurrently, and waits for all of them to complete.
+func runBatches(n, limit int, fn func(i int)) {
+	if limit <= 0 {
+		limit = 1
+	}
+
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/appleboy/go-hms-push/push/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkSlice(t *testing.T) {
+	tokens := []string{"a", "b", "c", "d", "e"}
+
+	assert.Equal(t, [][]string{tokens}, chunkSlice(tokens, 5))
+	assert.Equal(t, [][]string{tokens}, chunkSlice(tokens, 0))
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, chunkSlice(tokens, 2))
+	assert.Equal(t, [][]string{nil}, chunkSlice([]string(nil), 2))
+}
+
+func TestRunBatchesLimit(t *testing.T) {
+	var running, peak int32
+	done := make([]bool, 10)
+
+	runBatches(len(done), 3, func(i int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		done[i] = true
+		atomic.AddInt32(&running, -1)
+	})
+
+	assert.LessOrEqual(t, peak, int32(3))
+	for _, ok := range done {
+		assert.True(t, ok)
+	}
+}
+
+func TestMergeBatchResponses(t *testing.T) {
+	failed := failedBatchResponse(2, errors.New("unavailable"))
+	success := &messaging.BatchResponse{
+		SuccessCount: 1,
+		Responses:    []*messaging.SendResponse{{Success: true, MessageID: "1"}},
+	}
+
+	res := mergeBatchResponses([]*messaging.BatchResponse{success, failed})
+
+	assert.Equal(t, 1, res.SuccessCount)
+	assert.Equal(t, 2, res.FailureCount)
+	assert.Len(t, res.Responses, 3)
+	assert.True(t, res.Responses[0].Success)
+	assert.EqualError(t, res.Responses[2].Error, "unavailable")
+}
+
+func TestSplitHuaweiNotification(t *testing.T) {
+	tokens := make([]string, MaxHuaweiBatchSize*2+1)
+	for i := range tokens {
+		tokens[i] = string(rune('a' + i%26))
+	}
+
+	notification := model.NewNotificationMsgRequest()
+	notification.Message.Token = tokens
+	notification.Message.Topic = "news"
+
+	batches := splitHuaweiNotification(notification)
+
+	assert.Len(t, batches, 3)
+	assert.Equal(t, tokens[:MaxHuaweiBatchSize], batches[0].Message.Token)
+	assert.Equal(t, tokens[MaxHuaweiBatchSize*2:], batches[2].Message.Token)
+	assert.Equal(t, "news", batches[0].Message.Topic)
+	assert.Equal(t, "", batches[1].Message.Topic)
+	assert.Equal(t, tokens, notification.Message.Token)
+}
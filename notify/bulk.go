@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+)
+
+// maxBulkLineSize bounds a line of a bulk request, far above the payload
+// limits of the providers.
+const maxBulkLineSize = 1 << 20
+
+var (
+	// ErrUnknownPlatform is returned for a platform gorush can't send to.
+	ErrUnknownPlatform = errors.New("unknown platform")
+	// ErrPlatformDisabled is returned for a platform disabled in config.
+	ErrPlatformDisabled = errors.New("platform is disabled")
+	// ErrNoTarget is returned for a notification without any target.
+	ErrNoTarget = errors.New("no token, phone number, subscription, topic or audience")
+)
+
+// BulkLineError is a line of a bulk request that was not accepted.
+type BulkLineError struct {
+	Line    int    `json:"line"`
+	Message string `json:"error"`
+}
+
+func (e BulkLineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// CheckBulkNotification validates a notification read from a bulk request,
+// which has no other check before being queued.
+func CheckBulkNotification(cfg *config.ConfYaml, req *PushNotification) error {
+	switch req.Platform {
+	case core.PlatformIOS:
+		if !cfg.Ios.Enabled {
+			return ErrPlatformDisabled
+		}
+	case core.PlatformAndroid:
+		if !cfg.Android.Enabled {
+			return ErrPlatformDisabled
+		}
+	case core.PlatformHuawei:
+		if !cfg.Huawei.Enabled {
+			return ErrPlatformDisabled
+		}
+	case core.PlatformWebPush:
+		if !cfg.WebPush.Enabled {
+			return ErrPlatformDisabled
+		}
+	case core.PlatformSMS, core.PlatformTelegramGateway, core.PlatformCallAuto:
+	case 0:
+		// an audience may target the devices of every platform
+		if req.Audience == "" {
+			return ErrUnknownPlatform
+		}
+	default:
+		return ErrUnknownPlatform
+	}
+
+	if len(req.Recipients()) == 0 && len(req.PhoneNumbers) == 0 && req.To == "" && !req.IsTopic() && req.Audience == "" {
+		return ErrNoTarget
+	}
+
+	return CheckNotification(cfg, req)
+}
+
+// ReadBulkNotifications reads one notification per line of r, in NDJSON, and
+// passes the valid ones to fn with their line number. Blank lines are
+// skipped. The lines that are invalid, or that fn fails with, are returned,
+// and so is the line that could not be read, which ends the reading. It only
+// fails when ctx is done.
+func ReadBulkNotifications(
+	ctx context.Context,
+	cfg *config.ConfYaml,
+	r io.Reader,
+	fn func(line int, req *PushNotification) error,
+) (int, []BulkLineError, error) {
+	accepted := 0
+	rejected := []BulkLineError{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBulkLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if err := ctx.Err(); err != nil {
+			return accepted, rejected, err
+		}
+
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		req := &PushNotification{}
+		err := json.Unmarshal(data, req)
+		if err == nil {
+			err = CheckBulkNotification(cfg, req)
+		}
+		if err == nil {
+			err = fn(line, req)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return accepted, rejected, ctx.Err()
+			}
+			rejected = append(rejected, BulkLineError{Line: line, Message: err.Error()})
+			continue
+		}
+
+		accepted++
+	}
+
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return accepted, rejected, ctx.Err()
+		}
+		rejected = append(rejected, BulkLineError{Line: line + 1, Message: err.Error()})
+	}
+
+	return accepted, rejected, nil
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBulkNotification(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Ios.Enabled = false
+
+	assert.NoError(t, CheckBulkNotification(cfg, &PushNotification{
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"aaaa"},
+	}))
+	assert.NoError(t, CheckBulkNotification(cfg, &PushNotification{
+		Platform: core.PlatformAndroid,
+		Topic:    "/topics/news",
+	}))
+	assert.NoError(t, CheckBulkNotification(cfg, &PushNotification{
+		Platform:     core.PlatformSMS,
+		PhoneNumbers: []string{"79000000000"},
+	}))
+
+	assert.ErrorIs(t, CheckBulkNotification(cfg, &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa"},
+	}), ErrPlatformDisabled)
+	assert.ErrorIs(t, CheckBulkNotification(cfg, &PushNotification{
+		Platform: 42,
+		Tokens:   []string{"aaaa"},
+	}), ErrUnknownPlatform)
+	assert.ErrorIs(t, CheckBulkNotification(cfg, &PushNotification{
+		Platform: core.PlatformAndroid,
+	}), ErrNoTarget)
+	assert.ErrorIs(t, CheckBulkNotification(cfg, &PushNotification{
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"aaaa"},
+		Lane:     "urgent",
+	}), ErrInvalidLane)
+}
+
+func TestReadBulkNotifications(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Android.Enabled = true
+
+	body := strings.Join([]string{
+		`{"platform":2,"tokens":["aaaa"],"message":"Welcome"}`,
+		``,
+		`{"platform":2,"tokens":["bbbb"],"message":"Welcome"}`,
+		`not json`,
+		`{"platform":2,"message":"Welcome"}`,
+		`{"platform":2,"tokens":["cccc"],"message":"Welcome"}`,
+	}, "\n")
+
+	tokens := []string{}
+	accepted, rejected, err := ReadBulkNotifications(context.Background(), cfg, strings.NewReader(body),
+		func(line int, req *PushNotification) error {
+			if line == 6 {
+				return errors.New("max capacity reached")
+			}
+			tokens = append(tokens, req.Tokens...)
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, accepted)
+	assert.Equal(t, []string{"aaaa", "bbbb"}, tokens)
+
+	assert.Len(t, rejected, 3)
+	assert.Equal(t, 4, rejected[0].Line)
+	assert.Equal(t, BulkLineError{Line: 5, Message: ErrNoTarget.Error()}, rejected[1])
+	assert.Equal(t, BulkLineError{Line: 6, Message: "max capacity reached"}, rejected[2])
+}
+
+func TestReadBulkNotificationsTooLong(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Android.Enabled = true
+
+	body := `{"platform":2,"tokens":["aaaa"]}` + "\n" + strings.Repeat("a", maxBulkLineSize+1)
+
+	accepted, rejected, err := ReadBulkNotifications(context.Background(), cfg, strings.NewReader(body),
+		func(int, *PushNotification) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, accepted)
+	assert.Len(t, rejected, 1)
+	assert.Equal(t, 2, rejected[0].Line)
+}
+
+func TestReadBulkNotificationsCanceled(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Android.Enabled = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	body := `{"platform":2,"tokens":["aaaa"]}` + "\n" + `{"platform":2,"tokens":["bbbb"]}`
+
+	accepted, _, err := ReadBulkNotifications(ctx, cfg, strings.NewReader(body),
+		func(int, *PushNotification) error {
+			cancel()
+			return nil
+		})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, accepted)
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// caClients caches the HTTP client of every CA bundle, the bundle is only read
+// once.
+var caClients sync.Map
+
+// loadCAPool returns the system certificate pool with the certificates of the
+// PEM bundle added, or nil when no bundle is configured.
+func loadCAPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificate found in the CA bundle %s", path)
+	}
+
+	return pool, nil
+}
+
+// newCATransport copies the default transport, the proxy included, and makes
+// it trust the certificates of the PEM bundle.
+func newCATransport(path string) (*http.Transport, error) {
+	pool, err := loadCAPool(path)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	return transport, nil
+}
+
+// providerHTTPClient returns the client calling an HTTP provider, the default
+// one unless a CA bundle is configured.
+func providerHTTPClient(path string) (*http.Client, error) {
+	if path == "" {
+		return http.DefaultClient, nil
+	}
+
+	if client, ok := caClients.Load(path); ok {
+		return client.(*http.Client), nil
+	}
+
+	transport, err := newCATransport(path)
+	if err != nil {
+		return nil, err
+	}
+
+	client, _ := caClients.LoadOrStore(path, &http.Client{Transport: transport})
+	return client.(*http.Client), nil
+}
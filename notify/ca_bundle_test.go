@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/sideshow/apns2"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCABundle saves the certificate of the test server as a PEM bundle.
+func writeCABundle(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	return path
+}
+
+func TestLoadCAPool(t *testing.T) {
+	pool, err := loadCAPool("")
+	assert.NoError(t, err)
+	assert.Nil(t, pool)
+
+	_, err = loadCAPool("not_found.pem")
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "invalid.pem")
+	assert.NoError(t, os.WriteFile(path, []byte("invalid"), 0o600))
+	_, err = loadCAPool(path)
+	assert.Error(t, err)
+
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	pool, err = loadCAPool(writeCABundle(t, server))
+	assert.NoError(t, err)
+	assert.NotNil(t, pool)
+}
+
+func TestProviderHTTPClient(t *testing.T) {
+	client, err := providerHTTPClient("")
+	assert.NoError(t, err)
+	assert.Equal(t, http.DefaultClient, client)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	path := writeCABundle(t, server)
+	client, err = providerHTTPClient(path)
+	assert.NoError(t, err)
+
+	// the client is only built once per bundle
+	cached, _ := providerHTTPClient(path)
+	assert.Same(t, client, cached)
+
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the server certificate isn't trusted without the bundle
+	_, err = http.DefaultClient.Get(server.URL)
+	assert.Error(t, err)
+}
+
+func TestSMSCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.SectionSMS{MTSApiURL: server.URL}
+	assert.False(t, sendViaMTS("79000000000", &PushNotification{SMSMessage: "Welcome"}, cfg))
+
+	cfg.CAPath = writeCABundle(t, server)
+	assert.True(t, sendViaMTS("79000000000", &PushNotification{SMSMessage: "Welcome"}, cfg))
+}
+
+func TestAPNSEndpoint(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/3/device/aaaa", r.URL.Path)
+		w.Header().Set("apns-id", "stand-in")
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	cfg, _ := config.LoadConf()
+	cfg.Ios.Endpoint = server.URL
+	cfg.Ios.CAPath = writeCABundle(t, server)
+
+	client, err := newApnsClient(cfg, tls.Certificate{})
+	assert.NoError(t, err)
+
+	apnsClient := ApnsClient
+	defer func() { ApnsClient = apnsClient }()
+	ApnsClient = client
+
+	client = getApnsClient(cfg, &PushNotification{Platform: core.PlatformIOS, Production: true})
+	assert.Equal(t, server.URL, client.Host)
+
+	res, err := client.Push(&apns2.Notification{DeviceToken: "aaaa", Topic: "test", Payload: []byte("{}")})
+	assert.NoError(t, err)
+	assert.Equal(t, "stand-in", res.ApnsID)
+
+	cfg.Ios.CAPath = "not_found.pem"
+	_, err = newApnsClient(cfg, tls.Certificate{})
+	assert.Error(t, err)
+}
+
+func TestHuaweiEndpoint(t *testing.T) {
+	mock, err := NewMockServer(10, MockFailures{})
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	hmsClient := HMSClient
+	defer func() { HMSClient = hmsClient }()
+	HMSClient = nil
+
+	cfg, _ := config.LoadConf()
+	cfg.Huawei.AppID = "id"
+	cfg.Huawei.AppSecret = "secret"
+	cfg.Huawei.AuthURL = mock.URL + "/hms/oauth2/v3/token"
+	cfg.Huawei.PushURL = mock.URL + "/hms"
+
+	_, err = PushToHuawei(context.Background(), &PushNotification{
+		Platform: core.PlatformHuawei,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, mock.Messages("hms"), 1)
+}
+
+func TestFCMCABundleNotFound(t *testing.T) {
+	fcmClient := FCMClient
+	defer func() { FCMClient = fcmClient }()
+	FCMClient = nil
+
+	cfg, _ := config.LoadConf()
+	cfg.Android.Credential = "{}"
+	cfg.Android.CAPath = "not_found.pem"
+
+	_, err := InitFCMClient(context.Background(), cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,344 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+)
+
+// The states of a campaign. Only running, paused and canceled are stored, a
+// running campaign without pending messages is reported as done.
+const (
+	CampaignRunning  = "running"
+	CampaignPaused   = "paused"
+	CampaignCanceled = "canceled"
+	CampaignDone     = "done"
+)
+
+// The counters of a campaign, in messages: a token, a phone number or a topic.
+const (
+	campaignQueued   = "queued"
+	campaignSent     = "sent"
+	campaignFailed   = "failed"
+	campaignCanceled = "canceled"
+	campaignCapped   = "capped"
+)
+
+const campaignKey = "gorush-campaign:"
+
+var (
+	// ErrCampaignNotFound is returned for unknown or expired campaigns.
+	ErrCampaignNotFound = errors.New("campaign not found")
+	// ErrCampaignExists is returned when a campaign ID is already used.
+	ErrCampaignExists = errors.New("campaign already exists")
+	// ErrCampaignState is returned for a change the campaign can't make from
+	// its current state.
+	ErrCampaignState = errors.New("campaign can't change from its current state")
+	// ErrCampaignPaused is the reason the notifications of a paused campaign
+	// wait before being sent.
+	ErrCampaignPaused = errors.New("campaign is paused")
+	// ErrCampaignCanceled is returned for the notifications of a canceled
+	// campaign, which are not sent.
+	ErrCampaignCanceled = errors.New("campaign is canceled")
+)
+
+// Campaign groups the notifications sent with its ID, and tracks how far
+// their messages have got.
+type Campaign struct {
+	ID        string `json:"id"`
+	Name      string `json:"name,omitempty"`
+	State     string `json:"state"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+
+	Queued   int64 `json:"queued"`
+	Sent     int64 `json:"sent"`
+	Failed   int64 `json:"failed"`
+	Canceled int64 `json:"canceled"`
+	Capped   int64 `json:"capped"`
+	Pending  int64 `json:"pending"`
+	// ETA is the number of seconds left to send the pending messages at the
+	// pace of the campaign so far, zero when it is not running.
+	ETA int64 `json:"eta"`
+}
+
+func campaignRecordKey(id string) string {
+	return campaignKey + url.QueryEscape(id)
+}
+
+func campaignCounterKey(id, counter string) string {
+	return campaignRecordKey(id) + ":" + counter
+}
+
+func newCampaignID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func saveCampaign(cfg *config.ConfYaml, campaign *Campaign) error {
+	// the counters are kept apart, the workers update them concurrently
+	data, err := json.Marshal(Campaign{
+		ID:        campaign.ID,
+		Name:      campaign.Name,
+		State:     campaign.State,
+		CreatedAt: campaign.CreatedAt,
+		UpdatedAt: campaign.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Duration(cfg.Campaign.TTL) * time.Second
+	return status.StatStorage.SetValue(campaignRecordKey(campaign.ID), data, ttl)
+}
+
+func getCampaignState(id string) (*Campaign, error) {
+	data, err := status.StatStorage.GetValue(campaignRecordKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, ErrCampaignNotFound
+	}
+
+	campaign := &Campaign{}
+	if err := json.Unmarshal(data, campaign); err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+// CreateCampaign stores a new running campaign, with a random ID when id is
+// empty.
+func CreateCampaign(cfg *config.ConfYaml, id, name string) (*Campaign, error) {
+	if id == "" {
+		var err error
+		if id, err = newCampaignID(); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().Unix()
+	campaign := &Campaign{
+		ID:        id,
+		Name:      name,
+		State:     CampaignRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	data, err := json.Marshal(campaign)
+	if err != nil {
+		return nil, err
+	}
+
+	// the storage sets the key atomically, so a single request wins the ID
+	ttl := time.Duration(cfg.Campaign.TTL) * time.Second
+	stored, err := status.StatStorage.SetValueNX(campaignRecordKey(id), data, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		return nil, ErrCampaignExists
+	}
+
+	// counters outlive the campaigns, start over when an ID is used again
+	for _, counter := range []string{campaignQueued, campaignSent, campaignFailed, campaignCanceled, campaignCapped} {
+		key := campaignCounterKey(id, counter)
+		status.StatStorage.AddCount(key, -status.StatStorage.GetCount(key))
+	}
+
+	return campaign, nil
+}
+
+// GetCampaign returns the campaign with its progress.
+func GetCampaign(id string) (*Campaign, error) {
+	campaign, err := getCampaignState(id)
+	if err != nil {
+		return nil, err
+	}
+
+	campaign.Queued = status.StatStorage.GetCount(campaignCounterKey(id, campaignQueued))
+	campaign.Sent = status.StatStorage.GetCount(campaignCounterKey(id, campaignSent))
+	campaign.Failed = status.StatStorage.GetCount(campaignCounterKey(id, campaignFailed))
+	campaign.Canceled = status.StatStorage.GetCount(campaignCounterKey(id, campaignCanceled))
+	campaign.Capped = status.StatStorage.GetCount(campaignCounterKey(id, campaignCapped))
+	campaign.Pending = max(campaign.Queued-campaign.Sent-campaign.Failed-campaign.Canceled-campaign.Capped, 0)
+
+	if campaign.State == CampaignRunning {
+		if campaign.Queued > 0 && campaign.Pending == 0 {
+			campaign.State = CampaignDone
+		}
+
+		done := campaign.Sent + campaign.Failed
+		elapsed := time.Now().Unix() - campaign.CreatedAt
+		if campaign.Pending > 0 && done > 0 && elapsed > 0 {
+			campaign.ETA = campaign.Pending * elapsed / done
+		}
+	}
+
+	return campaign, nil
+}
+
+// SetCampaignState pauses, resumes or cancels the campaign. A canceled
+// campaign can't change anymore.
+func SetCampaignState(cfg *config.ConfYaml, id, state string) (*Campaign, error) {
+	campaign, err := getCampaignState(id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case campaign.State == CampaignCanceled:
+		return nil, ErrCampaignState
+	case state == CampaignPaused && campaign.State != CampaignRunning:
+		return nil, ErrCampaignState
+	case state == CampaignRunning && campaign.State != CampaignPaused:
+		return nil, ErrCampaignState
+	}
+
+	campaign.State = state
+	campaign.UpdatedAt = time.Now().Unix()
+	if err := saveCampaign(cfg, campaign); err != nil {
+		return nil, err
+	}
+
+	return GetCampaign(id)
+}
+
+// CheckCampaign validates the campaign of the notification, which has to
+// exist and not be canceled.
+func CheckCampaign(req *PushNotification) error {
+	if req.CampaignID == "" {
+		return nil
+	}
+
+	campaign, err := getCampaignState(req.CampaignID)
+	if err != nil {
+		return err
+	}
+
+	if campaign.State == CampaignCanceled {
+		return ErrCampaignCanceled
+	}
+
+	return nil
+}
+
+// addCampaignCount counts the messages of a campaign notification.
+func addCampaignCount(req *PushNotification, counter string, count int) {
+	if req.CampaignID == "" || count <= 0 {
+		return
+	}
+	status.StatStorage.AddCount(campaignCounterKey(req.CampaignID, counter), int64(count))
+}
+
+// CampaignQueued counts the messages of a notification added to the queue.
+func CampaignQueued(req *PushNotification) {
+	addCampaignCount(req, campaignQueued, messageCount(req))
+}
+
+// CampaignFailed counts the messages of a notification that could not be
+// queued.
+func CampaignFailed(req *PushNotification) {
+	addCampaignCount(req, campaignFailed, messageCount(req))
+}
+
+// countCampaignResult counts the messages of a sent notification, the failed
+// ones are those logged as failed and the capped ones those over their
+// frequency cap.
+func countCampaignResult(req *PushNotification, resp *ResponsePush, err error) {
+	if req.CampaignID == "" {
+		return
+	}
+
+	sent, failed, capped := resultCounts(req, resp, err)
+	addCampaignCount(req, campaignSent, sent)
+	addCampaignCount(req, campaignFailed, failed)
+	addCampaignCount(req, campaignCapped, capped)
+}
+
+// resultCounts splits the messages of a sent notification into the sent, the
+// failed and the capped ones. Without failed logs, an error fails every
+// message left. The messages left to a retry through the queue are counted
+// by the retry, and the failures of the messages sent again only count when
+// the last attempt failed.
+func resultCounts(req *PushNotification, resp *ResponsePush, err error) (sent, failed, capped int) {
+	total := max(messageCount(req)-req.deferred, 0)
+	if resp != nil {
+		for _, l := range resp.Logs {
+			switch l.Type {
+			case core.FailedPush:
+				failed++
+			case core.CappedPush:
+				capped++
+			}
+		}
+	}
+	failed = max(failed-req.retried, 0)
+	capped = min(capped, total)
+	if err != nil && failed == 0 {
+		failed = total - capped
+	}
+	failed = min(failed, total-capped)
+
+	return total - failed - capped, failed, capped
+}
+
+// holdCampaign keeps the notifications of a paused campaign from being sent,
+// and drops those of a canceled one. It reports whether the notification was
+// held or dropped. A paused notification goes back to the queue, or waits in
+// place without a queue to go back to.
+func holdCampaign(ctx context.Context, cfg *config.ConfYaml, req *PushNotification) (bool, error) {
+	if req.CampaignID == "" {
+		return false, nil
+	}
+
+	delay := time.Duration(cfg.Campaign.PausedDelay) * time.Second
+	for {
+		campaign, err := getCampaignState(req.CampaignID)
+		if err != nil {
+			// an expired campaign doesn't stop its notifications
+			if !errors.Is(err, ErrCampaignNotFound) {
+				logx.LogError.Error("can't read campaign: " + err.Error())
+			}
+			return false, nil
+		}
+
+		switch campaign.State {
+		case CampaignCanceled:
+			logx.LogAccess.Debugf("campaign %s is canceled, notification dropped", req.CampaignID)
+			addCampaignCount(req, campaignCanceled, messageCount(req))
+			SetDeliveryState(cfg, req, DeliveryFailed, ErrCampaignCanceled)
+			return true, nil
+		case CampaignPaused:
+			if q := NotificationQueue(req, RetryQueue); q != nil && !cfg.Core.Sync {
+				_, err := holdNotification(cfg, req, max(delay, time.Second), ErrCampaignPaused, DeadLetterMaxCapacity)
+				return true, err
+			}
+		default:
+			return false, nil
+		}
+
+		timer := time.NewTimer(max(delay, time.Second))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return true, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/status"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaignStates(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	campaign, err := CreateCampaign(cfg, "campaign-states", "Spring sale")
+	assert.NoError(t, err)
+	assert.Equal(t, CampaignRunning, campaign.State)
+
+	_, err = CreateCampaign(cfg, "campaign-states", "Spring sale")
+	assert.ErrorIs(t, err, ErrCampaignExists)
+
+	_, err = SetCampaignState(cfg, "campaign-states", CampaignRunning)
+	assert.ErrorIs(t, err, ErrCampaignState)
+
+	campaign, err = SetCampaignState(cfg, "campaign-states", CampaignPaused)
+	assert.NoError(t, err)
+	assert.Equal(t, CampaignPaused, campaign.State)
+	assert.Equal(t, "Spring sale", campaign.Name)
+
+	campaign, err = SetCampaignState(cfg, "campaign-states", CampaignRunning)
+	assert.NoError(t, err)
+	assert.Equal(t, CampaignRunning, campaign.State)
+
+	_, err = SetCampaignState(cfg, "campaign-states", CampaignCanceled)
+	assert.NoError(t, err)
+	_, err = SetCampaignState(cfg, "campaign-states", CampaignRunning)
+	assert.ErrorIs(t, err, ErrCampaignState)
+
+	assert.ErrorIs(t, CheckCampaign(&PushNotification{CampaignID: "campaign-states"}), ErrCampaignCanceled)
+	assert.ErrorIs(t, CheckCampaign(&PushNotification{CampaignID: "campaign-unknown"}), ErrCampaignNotFound)
+	assert.NoError(t, CheckCampaign(&PushNotification{}))
+
+	_, err = GetCampaign("campaign-unknown")
+	assert.ErrorIs(t, err, ErrCampaignNotFound)
+
+	// an empty ID gets a random one
+	campaign, err = CreateCampaign(cfg, "", "")
+	assert.NoError(t, err)
+	assert.Len(t, campaign.ID, 16)
+}
+
+func TestCampaignProgress(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	mock.SetFailures(MockFailures{InvalidTokens: []string{"bbbb"}})
+
+	_, err := CreateCampaign(cfg, "campaign-progress", "")
+	assert.NoError(t, err)
+
+	req := &PushNotification{
+		Platform:   core.PlatformIOS,
+		Tokens:     []string{"aaaa", "bbbb"},
+		Message:    "Welcome",
+		CampaignID: "campaign-progress",
+	}
+	other := &PushNotification{
+		Platform:   core.PlatformAndroid,
+		Tokens:     []string{"cccc"},
+		Message:    "Welcome",
+		CampaignID: "campaign-progress",
+	}
+	CampaignQueued(req)
+	CampaignQueued(other)
+
+	campaign, err := GetCampaign("campaign-progress")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), campaign.Queued)
+	assert.Equal(t, int64(3), campaign.Pending)
+
+	_, err = SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+
+	campaign, err = GetCampaign("campaign-progress")
+	assert.NoError(t, err)
+	assert.Equal(t, CampaignRunning, campaign.State)
+	assert.Equal(t, int64(1), campaign.Sent)
+	assert.Equal(t, int64(1), campaign.Failed)
+	assert.Equal(t, int64(1), campaign.Pending)
+
+	_, err = SendNotification(context.Background(), other, cfg)
+	assert.NoError(t, err)
+
+	campaign, err = GetCampaign("campaign-progress")
+	assert.NoError(t, err)
+	assert.Equal(t, CampaignDone, campaign.State)
+	assert.Equal(t, int64(2), campaign.Sent)
+	assert.Equal(t, int64(0), campaign.Pending)
+	assert.Equal(t, int64(0), campaign.ETA)
+
+	// a new campaign with the same ID starts over
+	_, err = SetCampaignState(cfg, "campaign-progress", CampaignCanceled)
+	assert.NoError(t, err)
+	assert.NoError(t, status.StatStorage.DelValue(campaignRecordKey("campaign-progress")))
+	_, err = CreateCampaign(cfg, "campaign-progress", "")
+	assert.NoError(t, err)
+	campaign, err = GetCampaign("campaign-progress")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), campaign.Queued)
+	assert.Equal(t, int64(0), campaign.Sent)
+}
+
+func TestCampaignCanceled(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+
+	_, err := CreateCampaign(cfg, "campaign-canceled", "")
+	assert.NoError(t, err)
+
+	req := &PushNotification{
+		Platform:   core.PlatformIOS,
+		Tokens:     []string{"aaaa", "bbbb"},
+		Message:    "Welcome",
+		CampaignID: "campaign-canceled",
+	}
+	CampaignQueued(req)
+
+	_, err = SetCampaignState(cfg, "campaign-canceled", CampaignCanceled)
+	assert.NoError(t, err)
+
+	resp, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+	assert.Empty(t, mock.Messages("apns"))
+
+	campaign, err := GetCampaign("campaign-canceled")
+	assert.NoError(t, err)
+	assert.Equal(t, CampaignCanceled, campaign.State)
+	assert.Equal(t, int64(2), campaign.Canceled)
+	assert.Equal(t, int64(0), campaign.Pending)
+}
+
+func TestCampaignPausedRequeues(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.Campaign.PausedDelay = 1
+
+	retryQueue, received := newTestQueue(t)
+	RetryQueue = retryQueue
+	t.Cleanup(func() { RetryQueue = nil })
+
+	_, err := CreateCampaign(cfg, "campaign-paused", "")
+	assert.NoError(t, err)
+	_, err = SetCampaignState(cfg, "campaign-paused", CampaignPaused)
+	assert.NoError(t, err)
+
+	req := &PushNotification{
+		ID:         "campaign-paused",
+		Platform:   core.PlatformIOS,
+		Tokens:     []string{"aaaa"},
+		Message:    "Welcome",
+		CampaignID: "campaign-paused",
+	}
+
+	resp, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+	assert.Empty(t, mock.Messages("apns"))
+
+	select {
+	case v := <-received:
+		assert.Equal(t, "campaign-paused", v.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("paused notification was not queued again")
+	}
+}
+
+func TestCampaignCountsRetriedMessagesOnce(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.Ios.MaxRetry = 1
+	cfg.Core.Retry.Backoff = 0
+	mock.SetFailures(MockFailures{ErrorRate: 100})
+
+	retryQueue, received := newTestQueue(t)
+	RetryQueue = retryQueue
+	t.Cleanup(func() { RetryQueue = nil })
+
+	_, err := CreateCampaign(cfg, "campaign-retry", "")
+	assert.NoError(t, err)
+
+	req := &PushNotification{
+		ID:         "campaign-retry",
+		Platform:   core.PlatformIOS,
+		Tokens:     []string{"aaaa"},
+		Message:    "Welcome",
+		CampaignID: "campaign-retry",
+	}
+	CampaignQueued(req)
+
+	_, err = SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+
+	// the failed token is left to the retry
+	campaign, err := GetCampaign("campaign-retry")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), campaign.Sent)
+	assert.Equal(t, int64(0), campaign.Failed)
+	assert.Equal(t, int64(1), campaign.Pending)
+
+	var retry *PushNotification
+	select {
+	case retry = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed token was not retried")
+	}
+
+	mock.SetFailures(MockFailures{})
+	_, err = SendNotification(context.Background(), retry, cfg)
+	assert.NoError(t, err)
+
+	campaign, err = GetCampaign("campaign-retry")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), campaign.Sent)
+	assert.Equal(t, int64(0), campaign.Failed)
+	assert.Equal(t, CampaignDone, campaign.State)
+}
+
+func TestCampaignPausedWaits(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.Core.Sync = true
+	cfg.Campaign.PausedDelay = 1
+
+	_, err := CreateCampaign(cfg, "campaign-paused-sync", "")
+	assert.NoError(t, err)
+	_, err = SetCampaignState(cfg, "campaign-paused-sync", CampaignPaused)
+	assert.NoError(t, err)
+
+	req := &PushNotification{
+		Platform:   core.PlatformIOS,
+		Tokens:     []string{"aaaa"},
+		Message:    "Welcome",
+		CampaignID: "campaign-paused-sync",
+	}
+
+	// without a queue to go back to, it waits for the campaign to resume
+	time.AfterFunc(100*time.Millisecond, func() {
+		_, err := SetCampaignState(cfg, "campaign-paused-sync", CampaignRunning)
+		assert.NoError(t, err)
+	})
+
+	start := time.Now()
+	_, err = SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Len(t, mock.Messages("apns"), 1)
+
+	// and gives up when its context is done
+	_, err = SetCampaignState(cfg, "campaign-paused-sync", CampaignPaused)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = SendNotification(ctx, req, cfg)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, mock.Messages("apns"), 1)
+}
@@ -0,0 +1,268 @@
+package notify
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+)
+
+// The states of a circuit breaker.
+const (
+	BreakerClosed   = "closed"
+	BreakerHalfOpen = "half-open"
+	BreakerOpen     = "open"
+)
+
+// The providers guarded by a circuit breaker.
+const (
+	ProviderAPNs     = "apns"
+	ProviderFCM      = "fcm"
+	ProviderHMS      = "hms"
+	ProviderWebPush  = "webpush"
+	ProviderSMS      = "sms"
+	ProviderTelegram = "telegram"
+	ProviderTelphin  = "telphin"
+)
+
+// DeadLetterCircuitOpen is the reason of the notifications held back by an
+// open circuit breaker that could not go back to the queue.
+const DeadLetterCircuitOpen = "circuit_open"
+
+// ErrCircuitOpen is returned while the circuit breaker of the provider is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker stops calling a provider after failureThreshold consecutive
+// failed calls. Once openTimeout has passed, the breaker is half-open and lets
+// probes through, halfOpenProbes successful calls close it again and a failed
+// one opens it again. Each half-open period is a generation of its own, the
+// probes of an older one no longer count once they are done.
+type CircuitBreaker struct {
+	Provider string
+
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenProbes   int
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	successes int
+	probing   int
+	halfOpens uint64
+	openedAt  time.Time
+}
+
+// NewCircuitBreaker returns a closed circuit breaker for the provider.
+func NewCircuitBreaker(provider string, failureThreshold int, openTimeout time.Duration, halfOpenProbes int) *CircuitBreaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	if halfOpenProbes < 1 {
+		halfOpenProbes = 1
+	}
+
+	return &CircuitBreaker{
+		Provider:         provider,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		halfOpenProbes:   halfOpenProbes,
+		state:            BreakerClosed,
+	}
+}
+
+// State returns the state of the breaker.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.openTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Remaining returns how long the breaker stays open.
+func (b *CircuitBreaker) Remaining() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerOpen {
+		return 0
+	}
+	return max(b.openTimeout-time.Since(b.openedAt), 0)
+}
+
+// Ready reports whether the provider can be called, without taking a probe.
+func (b *CircuitBreaker) Ready() bool {
+	return b.State() != BreakerOpen
+}
+
+// Allow lets a notification through the breaker. A half-open breaker only
+// lets halfOpenProbes notifications through at a time, each of them has to
+// call Done with the returned generation once sent. The generation is zero
+// for a notification that is not a probe.
+func (b *CircuitBreaker) Allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if time.Since(b.openedAt) < b.openTimeout {
+			return 0, ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+	}
+
+	if b.state == BreakerHalfOpen {
+		if b.probing >= b.halfOpenProbes {
+			return 0, ErrCircuitOpen
+		}
+		b.probing++
+		return b.halfOpens, nil
+	}
+
+	return 0, nil
+}
+
+// Done releases the probe of the generation taken by Allow. The probes of a
+// half-open period that has ended hold no slot of the current one.
+func (b *CircuitBreaker) Done(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation == b.halfOpens && b.state == BreakerHalfOpen && b.probing > 0 {
+		b.probing--
+	}
+}
+
+// Record counts the outcome of a call to the provider.
+func (b *CircuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.setState(BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		if failed {
+			b.setState(BreakerOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.halfOpenProbes {
+			b.setState(BreakerClosed)
+		}
+	}
+}
+
+func (b *CircuitBreaker) setState(state string) {
+	logx.LogAccess.Infof("circuit breaker of %s is %s", b.Provider, state)
+
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	switch state {
+	case BreakerOpen:
+		b.openedAt = time.Now()
+	case BreakerHalfOpen:
+		b.halfOpens++
+		b.probing = 0
+	}
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*CircuitBreaker{}
+)
+
+// providerBreaker returns the circuit breaker of the provider, nil when the
+// circuit breakers are disabled.
+func providerBreaker(cfg *config.ConfYaml, provider string) *CircuitBreaker {
+	if !cfg.CircuitBreaker.Enabled || provider == "" {
+		return nil
+	}
+
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	breaker, ok := breakers[provider]
+	if !ok {
+		breaker = NewCircuitBreaker(
+			provider,
+			cfg.CircuitBreaker.FailureThreshold,
+			time.Duration(cfg.CircuitBreaker.OpenTimeout)*time.Second,
+			cfg.CircuitBreaker.HalfOpenProbes,
+		)
+		breakers[provider] = breaker
+	}
+	return breaker
+}
+
+// CircuitBreakers returns the circuit breakers in use by provider.
+func CircuitBreakers() map[string]*CircuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	result := make(map[string]*CircuitBreaker, len(breakers))
+	for provider, breaker := range breakers {
+		result[provider] = breaker
+	}
+	return result
+}
+
+// platformProvider returns the provider gating the notifications of the
+// platform. Telegram has none, it falls back to SMS on its own.
+func platformProvider(platform int) string {
+	switch platform {
+	case core.PlatformIOS:
+		return ProviderAPNs
+	case core.PlatformAndroid:
+		return ProviderFCM
+	case core.PlatformHuawei:
+		return ProviderHMS
+	case core.PlatformWebPush:
+		return ProviderWebPush
+	case core.PlatformSMS:
+		return ProviderSMS
+	case core.PlatformCallAuto:
+		return ProviderTelphin
+	}
+	return ""
+}
+
+// recordProviderCall counts the outcome of a call to the provider, failed
+// only for the errors telling the provider is unavailable. The breaker is
+// created by the gate in front of the provider, there is none to count with
+// when the circuit breakers are disabled.
+func recordProviderCall(provider string, failed bool) {
+	breakersMu.Lock()
+	breaker := breakers[provider]
+	breakersMu.Unlock()
+
+	if breaker != nil {
+		breaker.Record(failed)
+	}
+}
+
+// providerCallFailed reports whether an HTTP call to a gateway failed because
+// the gateway is unavailable.
+func providerCallFailed(res *http.Response, err error) bool {
+	return err != nil || res.StatusCode >= http.StatusInternalServerError
+}
+
+// providerReady reports whether the provider can be called.
+func providerReady(cfg *config.ConfYaml, provider string) bool {
+	breaker := providerBreaker(cfg, provider)
+	return breaker == nil || breaker.Ready()
+}
@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func resetCircuitBreakers(t *testing.T) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		breakersMu.Lock()
+		breakers = map[string]*CircuitBreaker{}
+		breakersMu.Unlock()
+	})
+}
+
+func assertAllowed(t *testing.T, breaker *CircuitBreaker) uint64 {
+	t.Helper()
+
+	generation, err := breaker.Allow()
+	assert.NoError(t, err)
+	return generation
+}
+
+func assertBlocked(t *testing.T, breaker *CircuitBreaker) {
+	t.Helper()
+
+	_, err := breaker.Allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := NewCircuitBreaker(ProviderFCM, 2, 50*time.Millisecond, 1)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	// a success resets the consecutive failures
+	breaker.Record(true)
+	breaker.Record(false)
+	breaker.Record(true)
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assertAllowed(t, breaker)
+
+	breaker.Record(true)
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.False(t, breaker.Ready())
+	assertBlocked(t, breaker)
+	assert.Greater(t, breaker.Remaining(), time.Duration(0))
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	assert.True(t, breaker.Ready())
+
+	// a single probe at a time
+	probe := assertAllowed(t, breaker)
+	assertBlocked(t, breaker)
+
+	breaker.Record(false)
+	breaker.Done(probe)
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assertAllowed(t, breaker)
+}
+
+func TestCircuitBreakerProbeFails(t *testing.T) {
+	breaker := NewCircuitBreaker(ProviderSMS, 1, 20*time.Millisecond, 2)
+
+	breaker.Record(true)
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	time.Sleep(30 * time.Millisecond)
+	assertAllowed(t, breaker)
+	assertAllowed(t, breaker)
+
+	breaker.Record(false)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	breaker.Record(true)
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
+
+func TestCircuitBreakerReopensWithProbeInFlight(t *testing.T) {
+	breaker := NewCircuitBreaker(ProviderAPNs, 1, 20*time.Millisecond, 1)
+
+	breaker.Record(true)
+	time.Sleep(30 * time.Millisecond)
+	stale := assertAllowed(t, breaker)
+
+	// the breaker reopens while the probe is still being sent
+	breaker.Record(true)
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	time.Sleep(30 * time.Millisecond)
+	current := assertAllowed(t, breaker)
+	assert.NotEqual(t, stale, current)
+
+	// the stale probe doesn't free the slot of the current one
+	breaker.Done(stale)
+	assertBlocked(t, breaker)
+
+	breaker.Done(current)
+	assertAllowed(t, breaker)
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	cfg, _ := initMockProviders(t)
+	resetCircuitBreakers(t)
+
+	assert.Nil(t, providerBreaker(cfg, ProviderAPNs))
+	assert.True(t, providerReady(cfg, ProviderAPNs))
+	recordProviderCall(ProviderAPNs, true)
+	assert.Empty(t, CircuitBreakers())
+}
+
+func TestCircuitBreakerHoldsNotifications(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	resetCircuitBreakers(t)
+	cfg.CircuitBreaker.Enabled = true
+	cfg.CircuitBreaker.FailureThreshold = 1
+	cfg.DeadLetter.Enabled = true
+	mock.SetFailures(MockFailures{ErrorRate: 100})
+
+	req := &PushNotification{
+		ID:       "circuit-breaker",
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}
+
+	_, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, mock.Messages("apns"), 1)
+	assert.Equal(t, BreakerOpen, CircuitBreakers()[ProviderAPNs].State())
+
+	// without a queue to go back to, the notification is kept as a dead letter
+	resp, err := SendNotification(context.Background(), req, cfg)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Len(t, resp.Logs, 1)
+	assert.Equal(t, core.FailedPush, resp.Logs[0].Type)
+	assert.Len(t, mock.Messages("apns"), 1)
+
+	letters, err := ListDeadLetters(DeadLetterFilter{NotifID: "circuit-breaker", Reason: DeadLetterCircuitOpen})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+
+	// the first one also failed after its retries
+	_, err = DeleteDeadLetters(nil, DeadLetterFilter{NotifID: "circuit-breaker"})
+	assert.NoError(t, err)
+}
+
+func TestCircuitBreakerRequeues(t *testing.T) {
+	cfg, _ := initMockProviders(t)
+	resetCircuitBreakers(t)
+	cfg.CircuitBreaker.Enabled = true
+	cfg.CircuitBreaker.OpenTimeout = 1
+
+	retryQueue, received := newTestQueue(t)
+	RetryQueue = retryQueue
+	t.Cleanup(func() { RetryQueue = nil })
+
+	breaker := providerBreaker(cfg, ProviderFCM)
+	for range cfg.CircuitBreaker.FailureThreshold {
+		breaker.Record(true)
+	}
+
+	resp, err := SendNotification(context.Background(), &PushNotification{
+		ID:       "circuit-breaker-requeue",
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+
+	select {
+	case v := <-received:
+		assert.Equal(t, "circuit-breaker-requeue", v.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("held notification was not queued again")
+	}
+}
@@ -0,0 +1,286 @@
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+
+	"github.com/golang-queue/queue"
+)
+
+const (
+	// DeadLetterMaxRetry is the reason of the notifications that still fail
+	// once every retry has been used.
+	DeadLetterMaxRetry = "max_retry"
+	// DeadLetterMaxCapacity is the reason of the notifications that could not
+	// be queued.
+	DeadLetterMaxCapacity = "max_capacity"
+
+	deadLetterKey = "gorush-dead-letter:"
+)
+
+var (
+	// ErrDeadLetterNotFound is returned for unknown or expired dead letters.
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrDeadLetterNoQueue is returned when a dead letter is requeued without
+	// a queue to put it on.
+	ErrDeadLetterNoQueue = errors.New("no queue to requeue the dead letter")
+)
+
+// DeadLetter is a notification that could not be delivered, kept with the
+// error of its last attempt so that it can be inspected and requeued.
+type DeadLetter struct {
+	ID           string           `json:"id"`
+	Reason       string           `json:"reason"`
+	Error        string           `json:"error"`
+	Platform     int              `json:"platform"`
+	Attempts     int              `json:"attempts"`
+	CreatedAt    int64            `json:"created_at"`
+	Notification PushNotification `json:"notification"`
+}
+
+// DeadLetterFilter selects dead letters, the zero value matches all of them.
+type DeadLetterFilter struct {
+	Platform int    `json:"platform,omitempty" form:"platform"`
+	Reason   string `json:"reason,omitempty" form:"reason"`
+	NotifID  string `json:"notif_id,omitempty" form:"notif_id"`
+	// Error matches the dead letters whose error contains it.
+	Error string `json:"error,omitempty" form:"error"`
+	// Since and Until bound the creation time, in unix seconds.
+	Since int64 `json:"since,omitempty" form:"since"`
+	Until int64 `json:"until,omitempty" form:"until"`
+	Limit int   `json:"limit,omitempty" form:"limit"`
+}
+
+// Match reports whether the dead letter is selected by the filter.
+func (f DeadLetterFilter) Match(letter *DeadLetter) bool {
+	switch {
+	case f.Platform != 0 && letter.Platform != f.Platform:
+		return false
+	case f.Reason != "" && letter.Reason != f.Reason:
+		return false
+	case f.NotifID != "" && letter.Notification.ID != f.NotifID:
+		return false
+	case f.Error != "" && !strings.Contains(letter.Error, f.Error):
+		return false
+	case f.Since > 0 && letter.CreatedAt < f.Since:
+		return false
+	case f.Until > 0 && letter.CreatedAt > f.Until:
+		return false
+	}
+	return true
+}
+
+func newDeadLetterID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// AddDeadLetter stores the notification with the error of its last attempt.
+// Nothing is stored when dead letters are disabled.
+func AddDeadLetter(cfg *config.ConfYaml, req *PushNotification, reason, errMsg string) (*DeadLetter, error) {
+	if !cfg.DeadLetter.Enabled {
+		return nil, nil
+	}
+
+	id, err := newDeadLetterID()
+	if err != nil {
+		return nil, err
+	}
+
+	letter := &DeadLetter{
+		ID:           id,
+		Reason:       reason,
+		Error:        errMsg,
+		Platform:     req.Platform,
+		Attempts:     req.RetryAttempt + 1,
+		CreatedAt:    time.Now().Unix(),
+		Notification: *req,
+	}
+	// a requeued notification starts over, nobody waits for its result
+	letter.Notification.RetryAttempt = 0
+	letter.Notification.ReplyTo = ""
+
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(cfg.DeadLetter.TTL) * time.Second
+	if err := status.StatStorage.SetValue(deadLetterKey+id, data, ttl); err != nil {
+		return nil, err
+	}
+
+	logx.LogAccess.Infof("dead letter %s stored for %d targets: %s", id, len(req.Recipients()), errMsg)
+
+	return letter, nil
+}
+
+// addRetriedDeadLetter stores the notification left to the targets that kept
+// failing after the last retry, resp holds the error of every attempt.
+func addRetriedDeadLetter(cfg *config.ConfYaml, req *PushNotification, resp *ResponsePush) {
+	errMsg := ""
+	for i := len(resp.Logs) - 1; i >= 0; i-- {
+		if resp.Logs[i].Type == core.FailedPush {
+			errMsg = resp.Logs[i].Error
+			break
+		}
+	}
+
+	if _, err := AddDeadLetter(cfg, req, DeadLetterMaxRetry, errMsg); err != nil {
+		logx.LogError.Error("can't store dead letter: " + err.Error())
+	}
+}
+
+// GetDeadLetter returns the stored dead letter.
+func GetDeadLetter(id string) (*DeadLetter, error) {
+	data, err := status.StatStorage.GetValue(deadLetterKey + id)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	letter := &DeadLetter{}
+	if err := json.Unmarshal(data, letter); err != nil {
+		return nil, err
+	}
+
+	return letter, nil
+}
+
+// ListDeadLetters returns the dead letters selected by the filter, the
+// oldest first.
+func ListDeadLetters(filter DeadLetterFilter) ([]*DeadLetter, error) {
+	keys, err := status.StatStorage.Keys(deadLetterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]*DeadLetter, 0, len(keys))
+	for _, key := range keys {
+		letter, err := GetDeadLetter(strings.TrimPrefix(key, deadLetterKey))
+		if errors.Is(err, ErrDeadLetterNotFound) {
+			// expired while listing
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if filter.Match(letter) {
+			letters = append(letters, letter)
+		}
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		if letters[i].CreatedAt != letters[j].CreatedAt {
+			return letters[i].CreatedAt < letters[j].CreatedAt
+		}
+		return letters[i].ID < letters[j].ID
+	})
+
+	if filter.Limit > 0 && len(letters) > filter.Limit {
+		letters = letters[:filter.Limit]
+	}
+
+	return letters, nil
+}
+
+// DeleteDeadLetter removes the dead letter.
+func DeleteDeadLetter(id string) error {
+	if _, err := GetDeadLetter(id); err != nil {
+		return err
+	}
+	return status.StatStorage.DelValue(deadLetterKey + id)
+}
+
+// SelectDeadLetters returns the dead letters of the IDs, the unknown ones
+// skipped, or the ones selected by the filter when no ID is given.
+func SelectDeadLetters(ids []string, filter DeadLetterFilter) ([]*DeadLetter, error) {
+	if len(ids) == 0 {
+		return ListDeadLetters(filter)
+	}
+
+	letters := make([]*DeadLetter, 0, len(ids))
+	for _, id := range ids {
+		letter, err := GetDeadLetter(id)
+		if errors.Is(err, ErrDeadLetterNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+// DeleteDeadLetters removes the selected dead letters and returns them.
+func DeleteDeadLetters(ids []string, filter DeadLetterFilter) ([]*DeadLetter, error) {
+	letters, err := SelectDeadLetters(ids, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, letter := range letters {
+		if err := status.StatStorage.DelValue(deadLetterKey + letter.ID); err != nil {
+			return letters[:i], err
+		}
+	}
+
+	return letters, nil
+}
+
+// RequeueDeadLetter puts the notification of the dead letter back on the
+// queue and removes the dead letter. It is stored again if it fails again.
+func RequeueDeadLetter(q *queue.Queue, id string) (*DeadLetter, error) {
+	if q == nil {
+		return nil, ErrDeadLetterNoQueue
+	}
+
+	letter, err := GetDeadLetter(id)
+	if err != nil {
+		return nil, err
+	}
+
+	notification := letter.Notification
+	if err := NotificationQueue(&notification, q).Queue(&notification); err != nil {
+		return nil, err
+	}
+
+	return letter, status.StatStorage.DelValue(deadLetterKey + id)
+}
+
+// RequeueDeadLetters requeues the selected dead letters and returns the
+// requeued ones.
+func RequeueDeadLetters(q *queue.Queue, ids []string, filter DeadLetterFilter) ([]*DeadLetter, error) {
+	letters, err := SelectDeadLetters(ids, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	requeued := make([]*DeadLetter, 0, len(letters))
+	for _, letter := range letters {
+		if _, err := RequeueDeadLetter(q, letter.ID); err != nil {
+			return requeued, err
+		}
+		requeued = append(requeued, letter)
+	}
+
+	return requeued, nil
+}
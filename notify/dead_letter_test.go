@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/golang-queue/queue"
+	qcore "github.com/golang-queue/queue/core"
+	"github.com/sideshow/apns2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddDeadLetterDisabled(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	letter, err := AddDeadLetter(cfg, &PushNotification{ID: "disabled"}, DeadLetterMaxRetry, "error")
+	assert.NoError(t, err)
+	assert.Nil(t, letter)
+
+	letters, err := ListDeadLetters(DeadLetterFilter{NotifID: "disabled"})
+	assert.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestDeadLetters(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.DeadLetter.Enabled = true
+
+	ios, err := AddDeadLetter(cfg, &PushNotification{
+		ID:           "dead-letters",
+		Platform:     core.PlatformIOS,
+		Tokens:       []string{"aaaa"},
+		Message:      "Welcome",
+		RetryAttempt: 2,
+	}, DeadLetterMaxRetry, "ServiceUnavailable")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, ios.Attempts)
+	assert.Equal(t, 0, ios.Notification.RetryAttempt)
+
+	android, err := AddDeadLetter(cfg, &PushNotification{
+		ID:       "dead-letters",
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"bbbb"},
+		Message:  "Welcome",
+	}, DeadLetterMaxCapacity, "max capacity reached")
+	assert.NoError(t, err)
+
+	letter, err := GetDeadLetter(ios.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, DeadLetterMaxRetry, letter.Reason)
+	assert.Equal(t, "ServiceUnavailable", letter.Error)
+	assert.Equal(t, []string{"aaaa"}, letter.Notification.Tokens)
+
+	_, err = GetDeadLetter("unknown")
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+
+	letters, err := ListDeadLetters(DeadLetterFilter{NotifID: "dead-letters"})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 2)
+
+	letters, err = ListDeadLetters(DeadLetterFilter{NotifID: "dead-letters", Platform: core.PlatformAndroid})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, android.ID, letters[0].ID)
+
+	letters, err = ListDeadLetters(DeadLetterFilter{NotifID: "dead-letters", Error: "Unavailable"})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, ios.ID, letters[0].ID)
+
+	letters, err = ListDeadLetters(DeadLetterFilter{NotifID: "dead-letters", Since: time.Now().Add(time.Hour).Unix()})
+	assert.NoError(t, err)
+	assert.Empty(t, letters)
+
+	letters, err = ListDeadLetters(DeadLetterFilter{NotifID: "dead-letters", Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+
+	// unknown IDs are skipped
+	letters, err = SelectDeadLetters([]string{ios.ID, "unknown"}, DeadLetterFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+
+	assert.NoError(t, DeleteDeadLetter(ios.ID))
+	assert.ErrorIs(t, DeleteDeadLetter(ios.ID), ErrDeadLetterNotFound)
+
+	letters, err = DeleteDeadLetters(nil, DeadLetterFilter{NotifID: "dead-letters"})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+
+	letters, err = ListDeadLetters(DeadLetterFilter{NotifID: "dead-letters"})
+	assert.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestRequeueDeadLetters(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.DeadLetter.Enabled = true
+
+	letter, err := AddDeadLetter(cfg, &PushNotification{
+		ID:           "requeue",
+		Platform:     core.PlatformIOS,
+		Tokens:       []string{"aaaa"},
+		RetryAttempt: 1,
+	}, DeadLetterMaxRetry, "ServiceUnavailable")
+	assert.NoError(t, err)
+
+	_, err = RequeueDeadLetter(nil, letter.ID)
+	assert.ErrorIs(t, err, ErrDeadLetterNoQueue)
+
+	received := make(chan *PushNotification, 1)
+	q := queue.NewPool(1, queue.WithFn(func(ctx context.Context, msg qcore.TaskMessage) error {
+		v := &PushNotification{}
+		if err := json.Unmarshal(msg.Payload(), v); err != nil {
+			return err
+		}
+		received <- v
+		return nil
+	}))
+	defer q.Release()
+
+	letters, err := RequeueDeadLetters(q, nil, DeadLetterFilter{NotifID: "requeue"})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+
+	select {
+	case v := <-received:
+		assert.Equal(t, "requeue", v.ID)
+		assert.Equal(t, 0, v.RetryAttempt)
+		assert.Equal(t, []string{"aaaa"}, v.Tokens)
+	case <-time.After(5 * time.Second):
+		t.Fatal("dead letter was not requeued")
+	}
+
+	_, err = GetDeadLetter(letter.ID)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+}
+
+func TestDeadLetterAfterMaxRetry(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.DeadLetter.Enabled = true
+	cfg.Ios.MaxRetry = 1
+	cfg.Core.Retry.Backoff = 0
+	mock.SetFailures(MockFailures{ErrorRate: 100})
+
+	req := &PushNotification{
+		ID:       "max-retry",
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}
+
+	_, err := PushToIOS(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, mock.Messages("apns"), 2)
+
+	letters, err := DeleteDeadLetters(nil, DeadLetterFilter{NotifID: "max-retry"})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, DeadLetterMaxRetry, letters[0].Reason)
+	assert.Equal(t, apns2.ReasonServiceUnavailable, letters[0].Error)
+	assert.Equal(t, 2, letters[0].Attempts)
+	assert.Equal(t, []string{"aaaa"}, letters[0].Notification.Tokens)
+}
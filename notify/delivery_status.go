@@ -0,0 +1,267 @@
+package notify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+)
+
+// The states of a notification, from the API request to the provider.
+const (
+	DeliveryAccepted = "accepted"
+	DeliveryQueued   = "queued"
+	DeliverySending  = "sending"
+	DeliveryRetrying = "retrying"
+	DeliveryDone     = "done"
+	DeliveryFailed   = "failed"
+)
+
+// The outcome of a single token.
+const (
+	DeliverySent       = "sent"
+	DeliveryTokenError = "failed"
+	DeliveryCapped     = "capped"
+)
+
+const deliveryStatusKey = "gorush-delivery:"
+
+// ErrDeliveryStatusNotFound is returned for unknown or expired notifications.
+var ErrDeliveryStatusNotFound = errors.New("delivery status not found")
+
+// TokenStatus is the outcome of the last attempt to a token.
+type TokenStatus struct {
+	Token string `json:"token"`
+	// Status is either sent, failed or capped.
+	Status string `json:"status"`
+	// ProviderID is the ID the provider gave to the message, when it has one.
+	ProviderID string `json:"provider_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Code       string `json:"code,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
+
+// DeliveryStatus is the record of a notification, its state and the outcome
+// of every token tried so far.
+type DeliveryStatus struct {
+	ID           string         `json:"notif_id"`
+	Platform     int            `json:"platform"`
+	State        string         `json:"state"`
+	Error        string         `json:"error,omitempty"`
+	RetryAttempt int            `json:"retry_attempt"`
+	CreatedAt    int64          `json:"created_at"`
+	UpdatedAt    int64          `json:"updated_at"`
+	Tokens       []*TokenStatus `json:"tokens"`
+}
+
+// deliveryTracked reports whether the status of the notification is recorded,
+// which needs a notif_id to query it by. A dry run delivers nothing to track.
+func deliveryTracked(cfg *config.ConfYaml, req *PushNotification) bool {
+	return cfg.DeliveryStatus.Enabled && req.ID != "" && !req.IsDryRun(cfg)
+}
+
+func deliveryKey(id string) string {
+	return deliveryStatusKey + url.QueryEscape(id)
+}
+
+// deliveryStatesKey keeps the state of each batch of the notification, in a
+// map by batch so that the batches sent at once never overwrite each other.
+func deliveryStatesKey(id string) string {
+	return deliveryKey(id) + ":states"
+}
+
+// deliveryTokensKey keeps the tokens apart from the state, in a map by token
+// so that the concurrent sends never overwrite each other.
+func deliveryTokensKey(id string) string {
+	return deliveryKey(id) + ":tokens"
+}
+
+// deliveryBatch identifies the batch of the notification by its attempt and
+// its recipients. A retry is a batch of its own, a notification that goes
+// back to the queue as it is stays the same batch.
+func deliveryBatch(req *PushNotification) string {
+	h := sha256.New()
+	for _, recipient := range req.Recipients() {
+		_, _ = h.Write([]byte(recipient))
+		_, _ = h.Write([]byte{0})
+	}
+	return strconv.Itoa(req.RetryAttempt) + ":" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func deliveryTTL(cfg *config.ConfYaml) time.Duration {
+	return time.Duration(cfg.DeliveryStatus.TTL) * time.Second
+}
+
+// deliveryProgress orders the states of a batch still on its way, the
+// notification is in the earliest state of its batches.
+var deliveryProgress = []string{DeliveryAccepted, DeliveryQueued, DeliverySending, DeliveryRetrying}
+
+// getDeliveryState returns the state of the notification from those of its
+// batches: the earliest of the batches on their way, failed when a batch
+// failed, done otherwise.
+func getDeliveryState(id string) (*DeliveryStatus, error) {
+	fields, err := status.StatStorage.GetFields(deliveryStatesKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 0 {
+		return nil, ErrDeliveryStatusNotFound
+	}
+
+	batches := make([]*DeliveryStatus, 0, len(fields))
+	for _, data := range fields {
+		batch := &DeliveryStatus{}
+		if err := json.Unmarshal(data, batch); err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	sort.SliceStable(batches, func(i, j int) bool {
+		return batches[i].UpdatedAt < batches[j].UpdatedAt
+	})
+
+	record := &DeliveryStatus{ID: id, State: DeliveryDone}
+	progress := len(deliveryProgress)
+	for _, batch := range batches {
+		record.Platform = batch.Platform
+		record.RetryAttempt = max(record.RetryAttempt, batch.RetryAttempt)
+		record.UpdatedAt = batch.UpdatedAt
+		if record.CreatedAt == 0 || batch.CreatedAt < record.CreatedAt {
+			record.CreatedAt = batch.CreatedAt
+		}
+
+		if i := slices.Index(deliveryProgress, batch.State); i >= 0 && i <= progress {
+			progress = i
+			record.State, record.Error = batch.State, batch.Error
+		}
+		if progress == len(deliveryProgress) && batch.State == DeliveryFailed {
+			record.State, record.Error = batch.State, batch.Error
+		}
+	}
+
+	if data, err := status.StatStorage.GetValue(deliveryKey(id)); err == nil && data != nil {
+		created := &DeliveryStatus{}
+		if json.Unmarshal(data, created) == nil && created.CreatedAt > 0 {
+			record.CreatedAt = created.CreatedAt
+		}
+	}
+
+	return record, nil
+}
+
+// SetDeliveryState records the state of the batch of the notification.
+// Nothing is recorded when the delivery status is disabled or the
+// notification has no notif_id.
+func SetDeliveryState(cfg *config.ConfYaml, req *PushNotification, state string, err error) {
+	if !deliveryTracked(cfg, req) {
+		return
+	}
+
+	now := time.Now().Unix()
+	record := &DeliveryStatus{
+		ID:           req.ID,
+		Platform:     req.Platform,
+		State:        state,
+		RetryAttempt: req.RetryAttempt,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	data, err := json.Marshal(record)
+	if err == nil {
+		// the first state of the notification tells when it was created
+		_, err = status.StatStorage.SetValueNX(deliveryKey(req.ID), data, deliveryTTL(cfg))
+	}
+	if err == nil {
+		err = status.StatStorage.SetField(deliveryStatesKey(req.ID), deliveryBatch(req), data, deliveryTTL(cfg))
+	}
+	if err != nil {
+		logx.LogError.Error("can't record delivery status: " + err.Error())
+	}
+}
+
+// finishDelivery records the end of an attempt, the retry of its failed
+// tokens is a batch of its own.
+func finishDelivery(cfg *config.ConfYaml, req *PushNotification, err error) {
+	if err != nil {
+		SetDeliveryState(cfg, req, DeliveryFailed, err)
+		return
+	}
+
+	SetDeliveryState(cfg, req, DeliveryDone, nil)
+}
+
+// recordTokenStatus records the outcome of the attempt to the token, the
+// token is kept as it is logged so that hidden tokens stay hidden.
+func recordTokenStatus(cfg *config.ConfYaml, req *PushNotification, token, providerID string, entry logx.LogPushEntry) {
+	if !deliveryTracked(cfg, req) || token == "" {
+		return
+	}
+
+	record := &TokenStatus{
+		Token:      entry.Token,
+		Status:     DeliverySent,
+		ProviderID: providerID,
+		Error:      entry.Error,
+		Code:       entry.Code,
+		Variant:    entry.Variant,
+		UpdatedAt:  time.Now().Unix(),
+	}
+	switch entry.Type {
+	case core.FailedPush:
+		record.Status = DeliveryTokenError
+	case core.CappedPush:
+		record.Status = DeliveryCapped
+	}
+
+	data, err := json.Marshal(record)
+	if err == nil {
+		err = status.StatStorage.SetField(deliveryTokensKey(req.ID), token, data, deliveryTTL(cfg))
+	}
+	if err != nil {
+		logx.LogError.Error("can't record delivery status: " + err.Error())
+	}
+}
+
+// GetDeliveryStatus returns the state of the notification with the outcome of
+// its tokens, sorted by token.
+func GetDeliveryStatus(id string) (*DeliveryStatus, error) {
+	record, err := getDeliveryState(id)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := status.StatStorage.GetFields(deliveryTokensKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	record.Tokens = make([]*TokenStatus, 0, len(fields))
+	for _, data := range fields {
+		token := &TokenStatus{}
+		if err := json.Unmarshal(data, token); err != nil {
+			return nil, err
+		}
+		record.Tokens = append(record.Tokens, token)
+	}
+
+	sort.Slice(record.Tokens, func(i, j int) bool {
+		return record.Tokens[i].Token < record.Tokens[j].Token
+	})
+
+	return record, nil
+}
@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryStatusDisabled(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	SetDeliveryState(cfg, &PushNotification{ID: "delivery-disabled"}, DeliveryAccepted, nil)
+
+	_, err := GetDeliveryStatus("delivery-disabled")
+	assert.ErrorIs(t, err, ErrDeliveryStatusNotFound)
+}
+
+func TestDeliveryStatusState(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.DeliveryStatus.Enabled = true
+
+	// nothing to query a notification without notif_id by
+	SetDeliveryState(cfg, &PushNotification{}, DeliveryAccepted, nil)
+	_, err := GetDeliveryStatus("")
+	assert.ErrorIs(t, err, ErrDeliveryStatusNotFound)
+
+	req := &PushNotification{ID: "delivery-state", Platform: core.PlatformIOS}
+	SetDeliveryState(cfg, req, DeliveryAccepted, nil)
+
+	record, err := GetDeliveryStatus("delivery-state")
+	assert.NoError(t, err)
+	assert.Equal(t, DeliveryAccepted, record.State)
+	assert.Equal(t, core.PlatformIOS, record.Platform)
+	assert.Empty(t, record.Tokens)
+	createdAt := record.CreatedAt
+
+	// the retry queued by the attempt is on its way once the attempt is done
+	retry := *req
+	retry.RetryAttempt = 1
+	SetDeliveryState(cfg, &retry, DeliveryRetrying, nil)
+	finishDelivery(cfg, req, nil)
+
+	record, err = GetDeliveryStatus("delivery-state")
+	assert.NoError(t, err)
+	assert.Equal(t, DeliveryRetrying, record.State)
+	assert.Equal(t, 1, record.RetryAttempt)
+	assert.Equal(t, createdAt, record.CreatedAt)
+
+	finishDelivery(cfg, &retry, errors.New("max capacity reached"))
+
+	record, err = GetDeliveryStatus("delivery-state")
+	assert.NoError(t, err)
+	assert.Equal(t, DeliveryFailed, record.State)
+	assert.Equal(t, "max capacity reached", record.Error)
+}
+
+func TestDeliveryStatusBatches(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.DeliveryStatus.Enabled = true
+
+	first := &PushNotification{ID: "delivery-batches", Platform: core.PlatformIOS, Tokens: []string{"aaaa"}}
+	second := &PushNotification{ID: "delivery-batches", Platform: core.PlatformIOS, Tokens: []string{"bbbb"}}
+	SetDeliveryState(cfg, first, DeliverySending, nil)
+	SetDeliveryState(cfg, second, DeliverySending, nil)
+
+	// the retry of the first batch is queued, the second one is done
+	retry := *first
+	retry.RetryAttempt = 1
+	SetDeliveryState(cfg, &retry, DeliveryRetrying, nil)
+	finishDelivery(cfg, first, nil)
+	finishDelivery(cfg, second, nil)
+
+	record, err := GetDeliveryStatus("delivery-batches")
+	assert.NoError(t, err)
+	assert.Equal(t, DeliveryRetrying, record.State)
+
+	SetDeliveryState(cfg, &retry, DeliverySending, nil)
+	finishDelivery(cfg, &retry, nil)
+
+	record, err = GetDeliveryStatus("delivery-batches")
+	assert.NoError(t, err)
+	assert.Equal(t, DeliveryDone, record.State)
+	assert.Equal(t, 1, record.RetryAttempt)
+}
+
+func TestDeliveryStatusTokens(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.DeliveryStatus.Enabled = true
+	cfg.Android.MaxRetry = 0
+	mock.SetFailures(MockFailures{InvalidTokens: []string{"bbbb"}})
+
+	_, err := SendNotification(context.Background(), &PushNotification{
+		ID:       "delivery-tokens",
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"bbbb", "aaaa"},
+		Message:  "Welcome",
+	}, cfg)
+	assert.NoError(t, err)
+
+	record, err := GetDeliveryStatus("delivery-tokens")
+	assert.NoError(t, err)
+	assert.Equal(t, DeliveryDone, record.State)
+	assert.Len(t, record.Tokens, 2)
+
+	assert.Equal(t, "aaaa", record.Tokens[0].Token)
+	assert.Equal(t, DeliverySent, record.Tokens[0].Status)
+	assert.Contains(t, record.Tokens[0].ProviderID, "/messages/")
+	assert.Empty(t, record.Tokens[0].Error)
+
+	assert.Equal(t, "bbbb", record.Tokens[1].Token)
+	assert.Equal(t, DeliveryTokenError, record.Tokens[1].Status)
+	assert.NotEmpty(t, record.Tokens[1].Error)
+}
+
+func TestDeliveryStatusDryRun(t *testing.T) {
+	cfg, _ := initMockProviders(t)
+	cfg.DeliveryStatus.Enabled = true
+
+	_, err := SendNotification(context.Background(), &PushNotification{
+		ID:       "delivery-dry-run",
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+		DryRun:   true,
+	}, cfg)
+	assert.NoError(t, err)
+
+	_, err = GetDeliveryStatus("delivery-dry-run")
+	assert.ErrorIs(t, err, ErrDeliveryStatusNotFound)
+}
@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/sideshow/apns2"
+)
+
+// IsDryRun reports whether the notification is only validated, either by the
+// request or by the core.dry_run setting, without being delivered.
+func (p *PushNotification) IsDryRun(cfg *config.ConfYaml) bool {
+	return p.DryRun || cfg.Core.DryRun
+}
+
+// dryRunIOS validates the APNs notification of every token without calling
+// APNs, which has no validate-only endpoint. The result of every token is
+// logged, the same way FCM and HMS report a dry run.
+func dryRunIOS(cfg *config.ConfYaml, req *PushNotification) *ResponsePush {
+	resp := &ResponsePush{}
+
+	// the payload is shared by every token
+	payloadErr := CheckPayloadSize(req)
+
+	for _, token := range req.Tokens {
+		err := payloadErr
+		if err == nil {
+			err = checkIOSToken(token)
+		}
+
+		if err != nil {
+			resp.Logs = append(resp.Logs, logPush(cfg, core.FailedPush, token, req, err))
+			continue
+		}
+		resp.Logs = append(resp.Logs, logPush(cfg, core.SucceededPush, token, req, nil))
+	}
+
+	return resp
+}
+
+// checkIOSToken checks the device token is the hexadecimal string APNs
+// expects, with the reason APNs would return otherwise.
+func checkIOSToken(token string) error {
+	if token == "" {
+		return apnsError(http.StatusBadRequest, apns2.ReasonMissingDeviceToken)
+	}
+	if _, err := hex.DecodeString(token); err != nil {
+		return apnsError(http.StatusBadRequest, apns2.ReasonBadDeviceToken)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDryRun(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	req := &PushNotification{}
+	assert.False(t, req.IsDryRun(cfg))
+
+	req.DryRun = true
+	assert.True(t, req.IsDryRun(cfg))
+
+	req.DryRun = false
+	cfg.Core.DryRun = true
+	assert.True(t, req.IsDryRun(cfg))
+}
+
+func TestPushToIOSDryRun(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	// no APNs client is needed as nothing is sent
+	req := &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"11aa01229f15f0f0c52029d8cf8cd0aeaf2365fe4cebc4af26cd6d76b7919ef7", "bbbbb", ""},
+		Message:  "Welcome",
+		DryRun:   true,
+	}
+
+	resp, err := PushToIOS(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 3)
+	assert.Equal(t, core.SucceededPush, resp.Logs[0].Type)
+	assert.Equal(t, core.FailedPush, resp.Logs[1].Type)
+	assert.Equal(t, "BadDeviceToken", resp.Logs[1].Error)
+	assert.Equal(t, core.FailedPush, resp.Logs[2].Type)
+	assert.Equal(t, "MissingDeviceToken", resp.Logs[2].Error)
+
+	// every token fails with an oversized payload
+	req.Message = strings.Repeat("a", 4200)
+	resp, err = PushToIOS(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 3)
+	for _, l := range resp.Logs {
+		assert.Equal(t, core.FailedPush, l.Type)
+	}
+	assert.Contains(t, resp.Logs[0].Error, "payload too large")
+}
+
+func TestSendNotificationDryRunGateway(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Core.DryRun = true
+
+	resp, err := SendNotification(context.Background(), &PushNotification{
+		Platform: core.PlatformSMS,
+		Tokens:   []string{"+70000000000"},
+		Message:  "Welcome",
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+}
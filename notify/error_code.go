@@ -0,0 +1,240 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+
+	"firebase.google.com/go/v4/errorutils"
+	"firebase.google.com/go/v4/messaging"
+	"github.com/sideshow/apns2"
+)
+
+const pushErrorKey = "gorush-push-error-count:"
+
+// The codes of the failed pushes, the same whatever the provider. The raw
+// message of the provider is kept apart in the error of the push logs.
+const (
+	ErrorCodeInvalidToken        = "invalid_token"
+	ErrorCodeUnregistered        = "unregistered"
+	ErrorCodePayloadTooLarge     = "payload_too_large"
+	ErrorCodeInvalidRequest      = "invalid_request"
+	ErrorCodeAuthFailed          = "auth_failed"
+	ErrorCodeQuotaExceeded       = "quota_exceeded"
+	ErrorCodeProviderUnavailable = "provider_unavailable"
+	ErrorCodeRateLimited         = "rate_limited"
+	ErrorCodeRejectedByPolicy    = "rejected_by_policy"
+	ErrorCodeTimeout             = "timeout"
+	ErrorCodeUnknown             = "unknown"
+)
+
+// ErrorCodes lists every error code.
+var ErrorCodes = []string{
+	ErrorCodeInvalidToken,
+	ErrorCodeUnregistered,
+	ErrorCodePayloadTooLarge,
+	ErrorCodeInvalidRequest,
+	ErrorCodeAuthFailed,
+	ErrorCodeQuotaExceeded,
+	ErrorCodeProviderUnavailable,
+	ErrorCodeRateLimited,
+	ErrorCodeRejectedByPolicy,
+	ErrorCodeTimeout,
+	ErrorCodeUnknown,
+}
+
+// ProviderError is an error returned by a provider, with its error code. Its
+// message is the one of the provider.
+type ProviderError struct {
+	Code string
+	Err  error
+}
+
+func (e *ProviderError) Error() string { return e.Err.Error() }
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// ErrorCode returns the code of the error of a push, empty without error.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Code
+	}
+
+	switch {
+	case errors.Is(err, ErrInvalidSubscription):
+		return ErrorCodeUnregistered
+	case errors.Is(err, ErrPayloadTooLarge):
+		return ErrorCodePayloadTooLarge
+	case errors.Is(err, ErrRateLimited):
+		return ErrorCodeRateLimited
+	case errors.Is(err, ErrCircuitOpen):
+		return ErrorCodeProviderUnavailable
+	case errors.Is(err, ErrFrequencyCapped), errors.Is(err, ErrCampaignCanceled):
+		return ErrorCodeRejectedByPolicy
+	case errors.Is(err, ErrTemplateNotFound), errors.Is(err, ErrTemplateRender),
+		errors.Is(err, ErrInvalidTemplate):
+		return ErrorCodeInvalidRequest
+	}
+
+	if code := fcmErrorCode(err); code != "" {
+		return code
+	}
+
+	return networkErrorCode(err)
+}
+
+// networkErrorCode returns the code of an error without response from the
+// provider.
+func networkErrorCode(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorCodeTimeout
+	case errors.As(err, &netErr):
+		return ErrorCodeProviderUnavailable
+	}
+	return ErrorCodeUnknown
+}
+
+// statusErrorCode returns the code of an HTTP status of a provider.
+func statusErrorCode(statusCode int) string {
+	switch {
+	case statusCode == http.StatusNotFound, statusCode == http.StatusGone:
+		return ErrorCodeUnregistered
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return ErrorCodePayloadTooLarge
+	case statusCode == http.StatusTooManyRequests:
+		return ErrorCodeRateLimited
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrorCodeAuthFailed
+	case statusCode >= http.StatusInternalServerError:
+		return ErrorCodeProviderUnavailable
+	case statusCode >= http.StatusBadRequest:
+		return ErrorCodeInvalidRequest
+	}
+	return ErrorCodeUnknown
+}
+
+// transportError returns the error of a request without response from the
+// provider.
+func transportError(err error) error {
+	code := networkErrorCode(err)
+	if code == ErrorCodeUnknown && !errors.Is(err, context.Canceled) {
+		code = ErrorCodeProviderUnavailable
+	}
+	return &ProviderError{Code: code, Err: err}
+}
+
+// apnsError returns the error of an APNs reason.
+// See https://apple.co/3AdNane (Handling Notification Responses from APNs)
+func apnsError(statusCode int, reason string) error {
+	code := statusErrorCode(statusCode)
+	switch reason {
+	case apns2.ReasonBadDeviceToken, apns2.ReasonMissingDeviceToken, apns2.ReasonDeviceTokenNotForTopic:
+		code = ErrorCodeInvalidToken
+	case apns2.ReasonUnregistered, apns2.ReasonExpiredToken:
+		code = ErrorCodeUnregistered
+	case apns2.ReasonPayloadTooLarge:
+		code = ErrorCodePayloadTooLarge
+	case apns2.ReasonTooManyRequests, apns2.ReasonTooManyProviderTokenUpdates:
+		code = ErrorCodeRateLimited
+	case apns2.ReasonBadCertificate, apns2.ReasonBadCertificateEnvironment, apns2.ReasonForbidden,
+		apns2.ReasonExpiredProviderToken, apns2.ReasonInvalidProviderToken, apns2.ReasonMissingProviderToken:
+		code = ErrorCodeAuthFailed
+	case apns2.ReasonInternalServerError, apns2.ReasonServiceUnavailable, apns2.ReasonShutdown:
+		code = ErrorCodeProviderUnavailable
+	}
+
+	if code == ErrorCodeUnknown && reason != "" {
+		code = ErrorCodeInvalidRequest
+	}
+
+	return &ProviderError{Code: code, Err: errors.New(reason)}
+}
+
+// fcmErrorCode returns the code of an FCM error, empty when it isn't one.
+// See https://firebase.google.com/docs/cloud-messaging/send/admin-sdk#admin-sdk-error-reference
+func fcmErrorCode(err error) string {
+	switch {
+	case messaging.IsUnregistered(err):
+		return ErrorCodeUnregistered
+	case messaging.IsSenderIDMismatch(err):
+		return ErrorCodeInvalidToken
+	case messaging.IsInvalidArgument(err):
+		// the invalid tokens and the messages too big are invalid arguments
+		msg := strings.ToLower(err.Error())
+		switch {
+		case strings.Contains(msg, "registration token"):
+			return ErrorCodeInvalidToken
+		case strings.Contains(msg, "too big"), strings.Contains(msg, "too large"):
+			return ErrorCodePayloadTooLarge
+		}
+		return ErrorCodeInvalidRequest
+	case messaging.IsQuotaExceeded(err):
+		return ErrorCodeQuotaExceeded
+	case messaging.IsThirdPartyAuthError(err), messaging.IsMismatchedCredential(err),
+		errorutils.IsUnauthenticated(err), errorutils.IsPermissionDenied(err):
+		return ErrorCodeAuthFailed
+	case messaging.IsUnavailable(err), messaging.IsInternal(err):
+		return ErrorCodeProviderUnavailable
+	case errorutils.IsDeadlineExceeded(err):
+		return ErrorCodeTimeout
+	}
+	return ""
+}
+
+// hmsError returns the error of an HMS result code.
+// See https://developer.huawei.com/consumer/en/doc/HMSCore-References/https-send-api-0000001050986197#section13968115715131
+func hmsError(code, msg string) error {
+	errorCode := ErrorCodeUnknown
+	switch code {
+	case "80100000", "80300007":
+		errorCode = ErrorCodeInvalidToken
+	case "80100001", "80100003", "80100004", "80100013", "80300010":
+		errorCode = ErrorCodeInvalidRequest
+	case "80100016", "80300011":
+		errorCode = ErrorCodeRejectedByPolicy
+	case "80100017":
+		errorCode = ErrorCodeRateLimited
+	case "80200001", "80200003", "80300002", "80600003":
+		errorCode = ErrorCodeAuthFailed
+	case "80300008":
+		errorCode = ErrorCodePayloadTooLarge
+	case "81000001":
+		errorCode = ErrorCodeProviderUnavailable
+	}
+
+	return &ProviderError{Code: errorCode, Err: errors.New(code + ": " + msg)}
+}
+
+func pushErrorCountKey(platform int, code string) string {
+	return pushErrorKey + strconv.Itoa(platform) + ":" + code
+}
+
+// countPushError counts the failed push by platform and error code, the dry
+// runs are not counted.
+func countPushError(cfg *config.ConfYaml, req *PushNotification, entry logx.LogPushEntry) {
+	if entry.Type != core.FailedPush || entry.Code == "" || req.IsDryRun(cfg) {
+		return
+	}
+	status.StatStorage.AddCount(pushErrorCountKey(req.Platform, entry.Code), 1)
+}
+
+// PushErrorCount returns the number of failed pushes of the platform with
+// the error code.
+func PushErrorCount(platform int, code string) int64 {
+	return status.StatStorage.GetCount(pushErrorCountKey(platform, code))
+}
@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/appleboy/gorush/core"
+
+	"github.com/sideshow/apns2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{nil, ""},
+		{apnsError(http.StatusBadRequest, apns2.ReasonBadDeviceToken), ErrorCodeInvalidToken},
+		{apnsError(http.StatusGone, apns2.ReasonUnregistered), ErrorCodeUnregistered},
+		{apnsError(http.StatusRequestEntityTooLarge, apns2.ReasonPayloadTooLarge), ErrorCodePayloadTooLarge},
+		{apnsError(http.StatusForbidden, apns2.ReasonExpiredProviderToken), ErrorCodeAuthFailed},
+		{apnsError(http.StatusTooManyRequests, apns2.ReasonTooManyRequests), ErrorCodeRateLimited},
+		{apnsError(http.StatusServiceUnavailable, apns2.ReasonServiceUnavailable), ErrorCodeProviderUnavailable},
+		{apnsError(http.StatusBadRequest, apns2.ReasonBadTopic), ErrorCodeInvalidRequest},
+		{hmsError("80300007", "All the tokens are invalid"), ErrorCodeInvalidToken},
+		{hmsError("80200003", "OAuth token expired"), ErrorCodeAuthFailed},
+		{hmsError("80300008", "The message body size exceeds the default value"), ErrorCodePayloadTooLarge},
+		{hmsError("99999999", "unexpected"), ErrorCodeUnknown},
+		{&ProviderError{Code: statusErrorCode(http.StatusUnauthorized), Err: errors.New("401")}, ErrorCodeAuthFailed},
+		{transportError(errors.New("connection reset")), ErrorCodeProviderUnavailable},
+		{transportError(context.DeadlineExceeded), ErrorCodeTimeout},
+		{fmt.Errorf("%w: 410", ErrInvalidSubscription), ErrorCodeUnregistered},
+		{fmt.Errorf("can't send: %w", ErrPayloadTooLarge), ErrorCodePayloadTooLarge},
+		{ErrFrequencyCapped, ErrorCodeRejectedByPolicy},
+		{ErrRateLimited, ErrorCodeRateLimited},
+		{ErrCircuitOpen, ErrorCodeProviderUnavailable},
+		{errors.New("something else"), ErrorCodeUnknown},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.code, ErrorCode(test.err), fmt.Sprint(test.err))
+	}
+
+	// the message is the raw one of the provider
+	assert.Equal(t, apns2.ReasonBadDeviceToken, apnsError(http.StatusBadRequest, apns2.ReasonBadDeviceToken).Error())
+	assert.Equal(t, "80300007: All the tokens are invalid", hmsError("80300007", "All the tokens are invalid").Error())
+}
+
+func TestPushErrorCode(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.Ios.Enabled = true
+	cfg.Android.Enabled = true
+	mock.SetFailures(MockFailures{InvalidTokens: []string{"code-bbbb"}})
+
+	unregistered := PushErrorCount(core.PlatformIOS, ErrorCodeUnregistered)
+
+	resp, err := SendNotification(context.Background(), &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"code-aaaa", "code-bbbb"},
+		Message:  "Welcome",
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 1)
+	assert.Equal(t, ErrorCodeUnregistered, resp.Logs[0].Code)
+	assert.Equal(t, apns2.ReasonUnregistered, resp.Logs[0].Error)
+	assert.Equal(t, unregistered+1, PushErrorCount(core.PlatformIOS, ErrorCodeUnregistered))
+
+	resp, err = SendNotification(context.Background(), &PushNotification{
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"code-aaaa", "code-bbbb"},
+		Message:  "Welcome",
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 1)
+	assert.Equal(t, ErrorCodeUnregistered, resp.Logs[0].Code)
+	assert.Equal(t, "Requested entity was not found.", resp.Logs[0].Error)
+
+	// the dry runs are not counted
+	unregistered = PushErrorCount(core.PlatformIOS, ErrorCodeUnregistered)
+	resp, err = SendNotification(context.Background(), &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"zzzz"},
+		Message:  "Welcome",
+		DryRun:   true,
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrorCodeInvalidToken, resp.Logs[0].Code)
+	assert.Equal(t, unregistered, PushErrorCount(core.PlatformIOS, ErrorCodeUnregistered))
+	assert.Zero(t, PushErrorCount(core.PlatformIOS, ErrorCodeInvalidToken))
+}
@@ -0,0 +1,210 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+)
+
+const (
+	frequencyCapKey = "gorush-frequency-cap:"
+
+	// frequencyCapAny is the category of the cap of the categories without
+	// their own cap.
+	frequencyCapAny = "*"
+)
+
+// ErrFrequencyCapped is the error of the recipients over their frequency cap.
+var ErrFrequencyCapped = errors.New("frequency cap reached")
+
+// frequencyCaps caches the parsed caps of every config, they are only parsed
+// once.
+var frequencyCaps sync.Map
+
+// FrequencyCap allows Limit notifications of a category in a Period, which
+// starts with the first of them.
+type FrequencyCap struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseFrequencyCaps parses the caps of the config by category, each one
+// written "category:limit/seconds".
+func ParseFrequencyCaps(entries []string) (map[string]FrequencyCap, error) {
+	caps := make(map[string]FrequencyCap, len(entries))
+	for _, entry := range entries {
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid frequency cap %q: missing category", entry)
+		}
+
+		limit, period, ok := strings.Cut(entry[i+1:], "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid frequency cap %q: missing period", entry)
+		}
+
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid frequency cap %q: the limit must be a positive number", entry)
+		}
+
+		seconds, err := strconv.ParseInt(period, 10, 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid frequency cap %q: the period must be a positive number of seconds", entry)
+		}
+
+		caps[entry[:i]] = FrequencyCap{Limit: n, Period: time.Duration(seconds) * time.Second}
+	}
+
+	return caps, nil
+}
+
+// configFrequencyCaps returns the parsed caps of the config.
+func configFrequencyCaps(cfg *config.ConfYaml) (map[string]FrequencyCap, error) {
+	key := strings.Join(cfg.FrequencyCap.Caps, "\n")
+	if caps, ok := frequencyCaps.Load(key); ok {
+		return caps.(map[string]FrequencyCap), nil
+	}
+
+	caps, err := ParseFrequencyCaps(cfg.FrequencyCap.Caps)
+	if err != nil {
+		return nil, err
+	}
+	frequencyCaps.Store(key, caps)
+	return caps, nil
+}
+
+// notificationFrequencyCap returns the cap of the notification category. The
+// retries and the dry runs are not counted.
+func notificationFrequencyCap(cfg *config.ConfYaml, req *PushNotification) (FrequencyCap, bool) {
+	if !cfg.FrequencyCap.Enabled || req.RetryAttempt > 0 || req.IsDryRun(cfg) ||
+		slices.Contains(cfg.FrequencyCap.Exempt, req.MessageCategory) {
+		return FrequencyCap{}, false
+	}
+
+	caps, err := configFrequencyCaps(cfg)
+	if err != nil {
+		logx.LogError.Error(err)
+		return FrequencyCap{}, false
+	}
+
+	limit, ok := caps[req.MessageCategory]
+	if !ok {
+		limit, ok = caps[frequencyCapAny]
+	}
+
+	return limit, ok
+}
+
+// takeFrequencySlot counts a notification for the recipient key, in a single
+// counter that expires a period after its first notification. It reports
+// false when the recipient already got the limit of notifications in the
+// period. The storage increments the counter atomically, so the replicas
+// sharing it never go over the limit together.
+func takeFrequencySlot(key string, limit FrequencyCap) (bool, error) {
+	count, err := status.StatStorage.IncrValue(key, limit.Period)
+	if err != nil {
+		return false, err
+	}
+
+	return count <= int64(limit.Limit), nil
+}
+
+func frequencyKey(category, kind, id string) string {
+	return frequencyCapKey + url.QueryEscape(category) + ":" + kind + ":" + url.QueryEscape(id)
+}
+
+// countUserFrequency counts a notification split into batches once for its
+// user, before the batches are sent: a batch per locale or per burst is
+// still a single notification to the user. It reports whether the user was
+// counted and was over the cap, a batch split again inherits it.
+func countUserFrequency(cfg *config.ConfYaml, req *PushNotification) (bool, bool) {
+	if req.UserID == "" || req.userCounted {
+		return req.userCounted, req.userCapped
+	}
+
+	limit, ok := notificationFrequencyCap(cfg, req)
+	if !ok {
+		return false, false
+	}
+
+	allowed, err := takeFrequencySlot(frequencyKey(req.MessageCategory, "user", req.UserID), limit)
+	if err != nil {
+		logx.LogError.Error("can't count frequency cap: " + err.Error())
+		return true, false
+	}
+
+	return true, !allowed
+}
+
+// applyFrequencyCap counts the notification for its recipients, per user
+// when it has a user ID and per token otherwise. It returns the notification
+// left to the recipients under their cap, nil when there is none, and the
+// logs of the capped ones. The notifications without token are only capped
+// per user.
+func applyFrequencyCap(cfg *config.ConfYaml, req *PushNotification) (*PushNotification, []logx.LogPushEntry) {
+	limit, ok := notificationFrequencyCap(cfg, req)
+	if !ok {
+		return req, nil
+	}
+
+	if req.UserID != "" {
+		if _, capped := countUserFrequency(cfg, req); !capped {
+			return req, nil
+		}
+
+		logx.LogAccess.Debugf("user %s is over the frequency cap of %q", req.UserID, req.MessageCategory)
+		logs := []logx.LogPushEntry{}
+		for _, token := range req.Recipients() {
+			logs = append(logs, logPush(cfg, core.CappedPush, token, req, ErrFrequencyCapped))
+		}
+		return nil, logs
+	}
+
+	kept := map[string]bool{}
+	logs := []logx.LogPushEntry{}
+	for _, token := range req.Recipients() {
+		allowed, err := takeFrequencySlot(frequencyKey(req.MessageCategory, "token", token), limit)
+		if err != nil {
+			logx.LogError.Error("can't count frequency cap: " + err.Error())
+			allowed = true
+		}
+
+		if allowed {
+			kept[token] = true
+			continue
+		}
+		logs = append(logs, logPush(cfg, core.CappedPush, token, req, ErrFrequencyCapped))
+	}
+
+	switch {
+	case len(logs) == 0:
+		return req, nil
+	case len(kept) == 0:
+		return nil, logs
+	}
+
+	// the request may still be read by the API that queued it
+	sent := *req
+	if req.Platform == core.PlatformWebPush {
+		sent.Subscriptions = slices.DeleteFunc(slices.Clone(req.Subscriptions), func(sub WebPushSubscription) bool {
+			return !kept[sub.Endpoint]
+		})
+	} else {
+		sent.Tokens = slices.DeleteFunc(slices.Clone(req.Tokens), func(token string) bool {
+			return !kept[token]
+		})
+	}
+
+	return &sent, logs
+}
@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFrequencyCaps(t *testing.T) {
+	caps, err := ParseFrequencyCaps([]string{"promotional:3/86400", "*:10/3600", "news:digest:1/60"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]FrequencyCap{
+		"promotional": {Limit: 3, Period: 24 * time.Hour},
+		"*":           {Limit: 10, Period: time.Hour},
+		"news:digest": {Limit: 1, Period: time.Minute},
+	}, caps)
+
+	for _, entry := range []string{"promotional", ":3/60", "promotional:3", "promotional:0/60", "promotional:3/-1", "promotional:a/60"} {
+		_, err := ParseFrequencyCaps([]string{entry})
+		assert.Error(t, err, entry)
+	}
+}
+
+func TestFrequencyCapPerToken(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.FrequencyCap.Enabled = true
+	cfg.FrequencyCap.Caps = []string{"promotional:2/60"}
+	cfg.DeliveryStatus.Enabled = true
+
+	send := func(tokens ...string) *ResponsePush {
+		resp, err := SendNotification(context.Background(), &PushNotification{
+			ID:              "frequency-token",
+			Platform:        core.PlatformIOS,
+			Tokens:          tokens,
+			Message:         "Sale",
+			MessageCategory: "promotional",
+		}, cfg)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	assert.Empty(t, send("cap-aaaa", "cap-bbbb").Logs)
+	assert.Empty(t, send("cap-aaaa").Logs)
+
+	// cap-aaaa got its two promotional pushes
+	resp := send("cap-aaaa", "cap-bbbb")
+	assert.Len(t, resp.Logs, 1)
+	assert.Equal(t, core.CappedPush, resp.Logs[0].Type)
+	assert.Equal(t, "cap-aaaa", resp.Logs[0].Token)
+	assert.Equal(t, ErrFrequencyCapped.Error(), resp.Logs[0].Error)
+	assert.Len(t, mock.Messages("apns"), 4)
+
+	delivery, err := GetDeliveryStatus("frequency-token")
+	assert.NoError(t, err)
+	statuses := map[string]string{}
+	for _, token := range delivery.Tokens {
+		statuses[token.Token] = token.Status
+	}
+	assert.Equal(t, map[string]string{"cap-aaaa": DeliveryCapped, "cap-bbbb": DeliverySent}, statuses)
+
+	// nothing left to send
+	resp = send("cap-aaaa", "cap-bbbb")
+	assert.Len(t, resp.Logs, 2)
+	assert.Len(t, mock.Messages("apns"), 4)
+}
+
+func TestFrequencyCapPerUser(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.FrequencyCap.Enabled = true
+	cfg.FrequencyCap.Caps = []string{"*:1/60"}
+
+	req := &PushNotification{
+		Platform:        core.PlatformAndroid,
+		Tokens:          []string{"phone", "tablet"},
+		Message:         "Sale",
+		MessageCategory: "promotional",
+		UserID:          "42",
+	}
+
+	_, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, mock.Messages("fcm"), 2)
+
+	resp, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 2)
+	assert.Equal(t, core.CappedPush, resp.Logs[0].Type)
+	assert.Len(t, mock.Messages("fcm"), 2)
+
+	// the categories are counted apart, and the exempt ones not at all
+	req.MessageCategory = "news"
+	_, err = SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, mock.Messages("fcm"), 4)
+
+	req.MessageCategory = "transactional"
+	for range 2 {
+		resp, err = SendNotification(context.Background(), req, cfg)
+		assert.NoError(t, err)
+		assert.Empty(t, resp.Logs)
+	}
+	assert.Len(t, mock.Messages("fcm"), 8)
+}
+
+func TestFrequencyCapCampaign(t *testing.T) {
+	cfg, _ := initMockProviders(t)
+	cfg.FrequencyCap.Enabled = true
+	cfg.FrequencyCap.Caps = []string{"promotional:1/60"}
+
+	_, err := CreateCampaign(cfg, "campaign-frequency", "")
+	assert.NoError(t, err)
+
+	for range 2 {
+		req := &PushNotification{
+			Platform:        core.PlatformIOS,
+			Tokens:          []string{"campaign-cap"},
+			Message:         "Sale",
+			MessageCategory: "promotional",
+			CampaignID:      "campaign-frequency",
+		}
+		CampaignQueued(req)
+		_, err = SendNotification(context.Background(), req, cfg)
+		assert.NoError(t, err)
+	}
+
+	campaign, err := GetCampaign("campaign-frequency")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), campaign.Sent)
+	assert.Equal(t, int64(1), campaign.Capped)
+	assert.Equal(t, int64(0), campaign.Canceled)
+	assert.Equal(t, CampaignDone, campaign.State)
+}
+
+func TestFrequencyCapPerUserSplitNotification(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.Ios.Enabled = true
+	cfg.FrequencyCap.Enabled = true
+	cfg.FrequencyCap.Caps = []string{"promotional:1/60"}
+
+	saveTestTemplates(t,
+		&Template{Name: "frequency", Locale: "en", TemplateContent: TemplateContent{Body: "Sale"}},
+		&Template{Name: "frequency", Locale: "fr", TemplateContent: TemplateContent{Body: "Soldes"}},
+	)
+
+	req := &PushNotification{
+		Platform:        core.PlatformIOS,
+		Tokens:          []string{"split-phone", "split-tablet"},
+		Template:        "frequency",
+		MessageCategory: "promotional",
+		UserID:          "split-user",
+		RecipientVars: map[string]TemplateRecipient{
+			"split-tablet": {Locale: "fr"},
+		},
+	}
+
+	// a batch per locale is still a single notification to the user
+	resp, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+	assert.Len(t, mock.Messages("apns"), 2)
+
+	resp, err = SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 2)
+	for _, l := range resp.Logs {
+		assert.Equal(t, core.CappedPush, l.Type)
+	}
+	assert.Len(t, mock.Messages("apns"), 2)
+}
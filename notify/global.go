@@ -17,6 +17,10 @@ var (
 	FCMClient *fcm.Client
 	// HMSClient is Huawei push client
 	HMSClient *core.HMSClient
+	// WebPushClient is Web Push (VAPID) client
+	WebPushClient *VAPIDClient
+	// MockProviders are the fake providers started in mock mode
+	MockProviders *MockServer
 	// MaxConcurrentIOSPushes pool to limit the number of concurrent iOS pushes
 	MaxConcurrentIOSPushes chan struct{}
 
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+)
+
+const idempotencyKey = "gorush-idempotency:"
+
+// IdempotentResult is the result remembered for an idempotency key, it is
+// returned instead of sending the notification again.
+type IdempotentResult struct {
+	ID    string              `json:"notif_id,omitempty"`
+	Count int                 `json:"count"`
+	Done  bool                `json:"done"`
+	Logs  []logx.LogPushEntry `json:"logs,omitempty"`
+}
+
+// notificationCount counts the notification like the push API does, the
+// topic message is counted on top of the tokens.
+func notificationCount(req *PushNotification) int {
+	count := len(req.Recipients())
+	if req.Topic != "" {
+		count++
+	}
+	return count
+}
+
+func idempotencyWindow(req *PushNotification, cfg *config.ConfYaml) time.Duration {
+	if req.IdempotencyKey == "" || cfg.Core.IdempotencyWindow <= 0 {
+		return 0
+	}
+	return time.Duration(cfg.Core.IdempotencyWindow) * time.Second
+}
+
+// ClaimIdempotencyKey reserves the idempotency key of the notification for the
+// configured window. It returns nil when the notification has to be sent, or
+// the result of the first notification sent with the same key.
+func ClaimIdempotencyKey(cfg *config.ConfYaml, req *PushNotification) (*IdempotentResult, error) {
+	window := idempotencyWindow(req, cfg)
+	if window == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(IdempotentResult{ID: req.ID, Count: notificationCount(req)})
+	if err != nil {
+		return nil, err
+	}
+
+	// the storage sets the key atomically, so a single replica wins the key
+	stored, err := status.StatStorage.SetValueNX(idempotencyKey+req.IdempotencyKey, data, window)
+	if err != nil || stored {
+		return nil, err
+	}
+
+	data, err = status.StatStorage.GetValue(idempotencyKey + req.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key may have expired right after the claim failed
+	result := &IdempotentResult{}
+	if data != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// SaveIdempotentResult remembers the delivery result of the notification for
+// the next requests using the same idempotency key.
+func SaveIdempotentResult(cfg *config.ConfYaml, req *PushNotification, logs []logx.LogPushEntry) error {
+	window := idempotencyWindow(req, cfg)
+	if window == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(IdempotentResult{
+		ID:    req.ID,
+		Count: notificationCount(req),
+		Done:  true,
+		Logs:  logs,
+	})
+	if err != nil {
+		return err
+	}
+
+	return status.StatStorage.SetValue(idempotencyKey+req.IdempotencyKey, data, window)
+}
+
+// ReleaseIdempotencyKey forgets the idempotency key of a notification that
+// could not be queued, so that it can be sent again.
+func ReleaseIdempotencyKey(cfg *config.ConfYaml, req *PushNotification) error {
+	if idempotencyWindow(req, cfg) == 0 {
+		return nil
+	}
+	return status.StatStorage.DelValue(idempotencyKey + req.IdempotencyKey)
+}
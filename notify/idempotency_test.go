@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	req := &PushNotification{
+		ID:             "notif-1",
+		IdempotencyKey: "claim-1",
+		Tokens:         []string{"aaaaa", "bbbbb"},
+		Topic:          "news",
+		Platform:       core.PlatformAndroid,
+	}
+
+	original, err := ClaimIdempotencyKey(cfg, req)
+	assert.NoError(t, err)
+	assert.Nil(t, original)
+
+	// the notification is still in progress
+	original, err = ClaimIdempotencyKey(cfg, req)
+	assert.NoError(t, err)
+	assert.Equal(t, &IdempotentResult{ID: "notif-1", Count: 3}, original)
+
+	logs := []logx.LogPushEntry{{Type: core.FailedPush, Token: "bbbbb", Error: "invalid token"}}
+	assert.NoError(t, SaveIdempotentResult(cfg, req, logs))
+
+	original, err = ClaimIdempotencyKey(cfg, req)
+	assert.NoError(t, err)
+	assert.True(t, original.Done)
+	assert.Equal(t, 3, original.Count)
+	assert.Equal(t, logs, original.Logs)
+
+	// the key can be used again once released
+	assert.NoError(t, ReleaseIdempotencyKey(cfg, req))
+	original, err = ClaimIdempotencyKey(cfg, req)
+	assert.NoError(t, err)
+	assert.Nil(t, original)
+}
+
+func TestIdempotencyKeyDisabled(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	// notifications without key are always sent
+	req := &PushNotification{Tokens: []string{"aaaaa"}, Platform: core.PlatformAndroid}
+	for i := 0; i < 2; i++ {
+		original, err := ClaimIdempotencyKey(cfg, req)
+		assert.NoError(t, err)
+		assert.Nil(t, original)
+	}
+
+	cfg.Core.IdempotencyWindow = 0
+	req.IdempotencyKey = "disabled-1"
+	for i := 0; i < 2; i++ {
+		original, err := ClaimIdempotencyKey(cfg, req)
+		assert.NoError(t, err)
+		assert.Nil(t, original)
+	}
+}
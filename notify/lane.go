@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/appleboy/gorush/config"
+
+	"github.com/golang-queue/queue"
+)
+
+// The lanes of the queue. Each lane has its own workers, so that transactional
+// notifications never wait behind a bulk send.
+const (
+	LaneDefault = "default"
+	LaneHigh    = "high"
+)
+
+// ErrInvalidLane is returned for an unknown lane.
+var ErrInvalidLane = errors.New("lane must be default or high")
+
+// PriorityQueue is the queue of the high priority lane, nil when the lane is
+// disabled.
+var PriorityQueue *queue.Queue
+
+// CheckLane validates the lane of the notification.
+func CheckLane(req *PushNotification) error {
+	switch req.Lane {
+	case "", LaneDefault, LaneHigh:
+		return nil
+	}
+	return ErrInvalidLane
+}
+
+// AssignLane moves the notifications of the high priority platforms to the
+// high priority lane. The lane is kept with the notification, so that its
+// retries and dead letters go back to the same lane.
+func AssignLane(cfg *config.ConfYaml, req *PushNotification) {
+	if !cfg.Queue.Priority.Enabled {
+		return
+	}
+
+	if req.Lane == "" && slices.Contains(cfg.Queue.Priority.Platforms, req.Platform) {
+		req.Lane = LaneHigh
+	}
+}
+
+// NotificationQueue returns the queue the notification is sent on: the high
+// priority lane, the own pool of its platform, or q.
+func NotificationQueue(req *PushNotification, q *queue.Queue) *queue.Queue {
+	if req.Lane == LaneHigh && PriorityQueue != nil {
+		return PriorityQueue
+	}
+	if platformQueue, ok := PlatformQueues[req.Platform]; ok {
+		return platformQueue
+	}
+	return q
+}
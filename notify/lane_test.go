@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/golang-queue/queue"
+	qcore "github.com/golang-queue/queue/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLane(t *testing.T) {
+	assert.NoError(t, CheckLane(&PushNotification{}))
+	assert.NoError(t, CheckLane(&PushNotification{Lane: LaneDefault}))
+	assert.NoError(t, CheckLane(&PushNotification{Lane: LaneHigh}))
+	assert.ErrorIs(t, CheckLane(&PushNotification{Lane: "urgent"}), ErrInvalidLane)
+}
+
+func TestAssignLane(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Queue.Priority.Platforms = []int{core.PlatformSMS}
+
+	// nothing changes while the lane is disabled
+	req := &PushNotification{Platform: core.PlatformSMS}
+	AssignLane(cfg, req)
+	assert.Empty(t, req.Lane)
+
+	cfg.Queue.Priority.Enabled = true
+
+	AssignLane(cfg, req)
+	assert.Equal(t, LaneHigh, req.Lane)
+
+	req = &PushNotification{Platform: core.PlatformAndroid}
+	AssignLane(cfg, req)
+	assert.Empty(t, req.Lane)
+
+	// the lane of the request wins over the platform
+	req = &PushNotification{Platform: core.PlatformSMS, Lane: LaneDefault}
+	AssignLane(cfg, req)
+	assert.Equal(t, LaneDefault, req.Lane)
+}
+
+func newTestQueue(t *testing.T) (*queue.Queue, chan *PushNotification) {
+	t.Helper()
+
+	received := make(chan *PushNotification, 1)
+	q := queue.NewPool(1, queue.WithFn(func(ctx context.Context, msg qcore.TaskMessage) error {
+		v := &PushNotification{}
+		if err := json.Unmarshal(msg.Payload(), v); err != nil {
+			return err
+		}
+		received <- v
+		return nil
+	}))
+	t.Cleanup(q.Release)
+
+	return q, received
+}
+
+func TestPriorityQueue(t *testing.T) {
+	defaultQueue, _ := newTestQueue(t)
+	assert.Equal(t, defaultQueue, NotificationQueue(&PushNotification{Lane: LaneHigh}, defaultQueue))
+
+	priorityQueue, _ := newTestQueue(t)
+	PriorityQueue = priorityQueue
+	t.Cleanup(func() { PriorityQueue = nil })
+
+	assert.Equal(t, priorityQueue, NotificationQueue(&PushNotification{Lane: LaneHigh}, defaultQueue))
+	assert.Equal(t, defaultQueue, NotificationQueue(&PushNotification{Lane: LaneDefault}, defaultQueue))
+	assert.Equal(t, defaultQueue, NotificationQueue(&PushNotification{}, defaultQueue))
+}
+
+func TestRetryKeepsLane(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Core.Retry.Backoff = 0
+
+	retryQueue, _ := newTestQueue(t)
+	priorityQueue, received := newTestQueue(t)
+	RetryQueue, PriorityQueue = retryQueue, priorityQueue
+	t.Cleanup(func() { RetryQueue, PriorityQueue = nil, nil })
+
+	queued, err := scheduleRetry(context.Background(), cfg, &PushNotification{
+		ID:       "lane-retry",
+		Platform: core.PlatformSMS,
+		Lane:     LaneHigh,
+	}, 1, 0)
+	assert.NoError(t, err)
+	assert.True(t, queued)
+
+	select {
+	case v := <-received:
+		assert.Equal(t, "lane-retry", v.ID)
+		assert.Equal(t, 1, v.RetryAttempt)
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry was not queued on the high priority lane")
+	}
+}
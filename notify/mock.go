@@ -0,0 +1,523 @@
+package notify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"io"
+	mrand "math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+
+	"github.com/appleboy/go-fcm"
+	c "github.com/appleboy/go-hms-push/push/config"
+	client "github.com/appleboy/go-hms-push/push/core"
+	"github.com/sideshow/apns2"
+	"google.golang.org/api/option"
+)
+
+const (
+	// MockResultOK is recorded for the requests accepted by a fake provider.
+	MockResultOK = "ok"
+	// MockResultInvalidToken is recorded for the requests rejected because of
+	// an invalid token.
+	MockResultInvalidToken = "invalid_token"
+	// MockResultUnavailable is recorded for the requests answered with a 5xx
+	// error.
+	MockResultUnavailable = "unavailable"
+
+	mockProjectID = "gorush-mock"
+	mockAppID     = "gorush-mock"
+	mockAppSecret = "gorush-mock"
+
+	// mockURLHeader keeps the URL a provider client called before being
+	// redirected to the mock server.
+	mockURLHeader = "X-Gorush-Mock-Url"
+)
+
+// MockMessage is a request received by a fake provider.
+type MockMessage struct {
+	ID       int64       `json:"id"`
+	Provider string      `json:"provider"`
+	Tokens   []string    `json:"tokens,omitempty"`
+	Result   string      `json:"result"`
+	Body     interface{} `json:"body,omitempty"`
+	Time     int64       `json:"time"`
+}
+
+// MockFailures are the failures injected by the fake providers.
+type MockFailures struct {
+	// Latency in milliseconds before answering.
+	Latency int64 `json:"latency"`
+	// ErrorRate is the percentage of requests answered with a 5xx error.
+	ErrorRate int `json:"error_rate"`
+	// InvalidTokens are rejected as unregistered.
+	InvalidTokens []string `json:"invalid_tokens"`
+}
+
+// MockServer fakes the APIs of the providers on a loopback listener. In mock
+// mode every provider client is pointed at it, so nothing leaves gorush.
+type MockServer struct {
+	URL string
+
+	server      *http.Server
+	maxMessages int
+
+	mu       sync.Mutex
+	messages []MockMessage
+	sequence int64
+	failures MockFailures
+}
+
+// NewMockServer starts the fake providers, keeping the last maxMessages
+// requests they received.
+func NewMockServer(maxMessages int, failures MockFailures) (*MockServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MockServer{
+		URL:         "http://" + listener.Addr().String(),
+		maxMessages: maxMessages,
+		failures:    failures,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /apns/3/device/{token}", m.serveAPNs)
+	mux.HandleFunc("POST /fcm/v1/projects/{project}/{method}", m.serveFCM)
+	mux.HandleFunc("POST /hms/oauth2/v3/token", m.serveHMSToken)
+	mux.HandleFunc("POST /hms/v1/{app}/{method}", m.serveHMS)
+	mux.HandleFunc("POST /webpush/", m.serveWebPush)
+	mux.HandleFunc("POST /sms/mts", m.serveSMS)
+	mux.HandleFunc("POST /sms/devino", m.serveSMS)
+	mux.HandleFunc("POST /sms/devino/v1/user/sessionid", m.serveDevinoSession)
+	mux.HandleFunc("POST /sms/devino/v1/Sms/Send", m.serveSMS)
+	mux.HandleFunc("POST /telegram", m.serveTelegram)
+	mux.HandleFunc("POST /telphin", m.serveTelphin)
+
+	m.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := m.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logx.LogError.Error("mock providers error: " + err.Error())
+		}
+	}()
+
+	return m, nil
+}
+
+// Close stops the fake providers.
+func (m *MockServer) Close() error {
+	return m.server.Close()
+}
+
+// Messages returns the requests received by the fake providers, oldest
+// first. An empty provider returns the requests of every provider.
+func (m *MockServer) Messages(provider string) []MockMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := []MockMessage{}
+	for _, message := range m.messages {
+		if provider == "" || message.Provider == provider {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Reset forgets the received requests.
+func (m *MockServer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
+
+// Failures returns the failures currently injected.
+func (m *MockServer) Failures() MockFailures {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.failures
+}
+
+// SetFailures changes the failures injected in the next requests.
+func (m *MockServer) SetFailures(failures MockFailures) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures = failures
+}
+
+// HTTPClient returns a client sending every request to the fake provider
+// mounted under prefix, whatever the host it was built for.
+func (m *MockServer) HTTPClient(prefix string) *http.Client {
+	target, _ := url.Parse(m.URL + prefix)
+	return &http.Client{
+		Transport: &mockTransport{target: target, base: &http.Transport{}},
+		Timeout:   30 * time.Second,
+	}
+}
+
+type mockTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(mockURLHeader, req.URL.String())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	req.URL.Path = t.target.Path + req.URL.Path
+	req.URL.RawPath = ""
+	req.Host = ""
+	return t.base.RoundTrip(req)
+}
+
+// receive waits for the injected latency, picks the result of the request and
+// records it.
+func (m *MockServer) receive(r *http.Request, provider string, tokens []string, body []byte) MockMessage {
+	failures := m.Failures()
+
+	if failures.Latency > 0 {
+		select {
+		case <-time.After(time.Duration(failures.Latency) * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}
+
+	result := MockResultOK
+	switch {
+	case failures.ErrorRate > 0 && mrand.Intn(100) < failures.ErrorRate: //nolint:gosec
+		result = MockResultUnavailable
+	case len(failures.invalid(tokens)) > 0:
+		result = MockResultInvalidToken
+	}
+
+	message := MockMessage{
+		Provider: provider,
+		Tokens:   tokens,
+		Result:   result,
+		Time:     time.Now().Unix(),
+	}
+	if err := json.Unmarshal(body, &message.Body); err != nil && len(body) > 0 {
+		message.Body = string(body)
+	}
+	if message.Body == nil && r.URL.RawQuery != "" {
+		message.Body = r.URL.Query()
+	}
+
+	m.mu.Lock()
+	m.sequence++
+	message.ID = m.sequence
+	m.messages = append(m.messages, message)
+	if m.maxMessages > 0 && len(m.messages) > m.maxMessages {
+		m.messages = m.messages[len(m.messages)-m.maxMessages:]
+	}
+	m.mu.Unlock()
+
+	return message
+}
+
+// invalid returns the tokens rejected as unregistered.
+func (f MockFailures) invalid(tokens []string) []string {
+	var invalid []string
+	for _, token := range tokens {
+		for _, t := range f.InvalidTokens {
+			if token == t {
+				invalid = append(invalid, token)
+				break
+			}
+		}
+	}
+	return invalid
+}
+
+func writeMockJSON(w http.ResponseWriter, code int, body interface{}) {
+	data, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+func (m *MockServer) serveAPNs(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	switch m.receive(r, "apns", []string{r.PathValue("token")}, body).Result {
+	case MockResultInvalidToken:
+		writeMockJSON(w, http.StatusGone, D{
+			"reason":    apns2.ReasonUnregistered,
+			"timestamp": time.Now().UnixMilli(),
+		})
+	case MockResultUnavailable:
+		writeMockJSON(w, http.StatusServiceUnavailable, D{"reason": apns2.ReasonServiceUnavailable})
+	default:
+		w.Header().Set("apns-id", r.Header.Get("apns-id"))
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (m *MockServer) serveFCM(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var req struct {
+		Message struct {
+			Token     string `json:"token"`
+			Topic     string `json:"topic"`
+			Condition string `json:"condition"`
+		} `json:"message"`
+	}
+	_ = json.Unmarshal(body, &req)
+
+	var tokens []string
+	if req.Message.Token != "" {
+		tokens = append(tokens, req.Message.Token)
+	}
+
+	message := m.receive(r, "fcm", tokens, body)
+	switch message.Result {
+	case MockResultInvalidToken:
+		writeMockJSON(w, http.StatusNotFound, D{"error": D{
+			"code":    http.StatusNotFound,
+			"message": "Requested entity was not found.",
+			"status":  "NOT_FOUND",
+			"details": []D{{
+				"@type":     "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+				"errorCode": "UNREGISTERED",
+			}},
+		}})
+	case MockResultUnavailable:
+		writeMockJSON(w, http.StatusServiceUnavailable, D{"error": D{
+			"code":    http.StatusServiceUnavailable,
+			"message": "The service is currently unavailable.",
+			"status":  "UNAVAILABLE",
+		}})
+	default:
+		writeMockJSON(w, http.StatusOK, D{
+			"name": "projects/" + r.PathValue("project") + "/messages/" + strconv.FormatInt(message.ID, 10),
+		})
+	}
+}
+
+func (m *MockServer) serveHMSToken(w http.ResponseWriter, _ *http.Request) {
+	writeMockJSON(w, http.StatusOK, D{
+		"access_token": "gorush-mock-token",
+		"expires_in":   3600,
+	})
+}
+
+func (m *MockServer) serveHMS(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var req struct {
+		Message struct {
+			Token []string `json:"token"`
+		} `json:"message"`
+	}
+	_ = json.Unmarshal(body, &req)
+
+	switch m.receive(r, "hms", req.Message.Token, body).Result {
+	case MockResultInvalidToken:
+		invalid := m.Failures().invalid(req.Message.Token)
+		if len(invalid) == len(req.Message.Token) {
+			writeMockJSON(w, http.StatusOK, D{"code": "80300007", "msg": "All the tokens are invalid"})
+			return
+		}
+		msg, _ := json.Marshal(D{
+			"success":        len(req.Message.Token) - len(invalid),
+			"failure":        len(invalid),
+			"illegal_tokens": invalid,
+		})
+		writeMockJSON(w, http.StatusOK, D{"code": "80100000", "msg": string(msg)})
+	case MockResultUnavailable:
+		writeMockJSON(w, http.StatusServiceUnavailable, D{"code": "81000001", "msg": "System inner error"})
+	default:
+		writeMockJSON(w, http.StatusOK, D{"code": "80000000", "msg": "Success"})
+	}
+}
+
+func (m *MockServer) serveWebPush(w http.ResponseWriter, r *http.Request) {
+	// the payload is encrypted for the browser, only keep the endpoint
+	_, _ = io.Copy(io.Discard, r.Body)
+
+	switch m.receive(r, "webpush", []string{r.Header.Get(mockURLHeader)}, nil).Result {
+	case MockResultInvalidToken:
+		w.WriteHeader(http.StatusGone)
+	case MockResultUnavailable:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	default:
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (m *MockServer) serveSMS(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var req struct {
+		// MTS
+		Destination string `json:"destination"`
+		// Devino
+		Messages []struct {
+			To string `json:"to"`
+		} `json:"messages"`
+	}
+	_ = json.Unmarshal(body, &req)
+
+	var tokens []string
+	switch {
+	case req.Destination != "":
+		tokens = append(tokens, req.Destination)
+	case len(req.Messages) > 0:
+		for _, message := range req.Messages {
+			tokens = append(tokens, message.To)
+		}
+	default:
+		// Devino v1 sends everything in the query string
+		tokens = append(tokens, r.URL.Query().Get("DestinationAddress"))
+	}
+
+	switch m.receive(r, "sms", tokens, body).Result {
+	case MockResultInvalidToken:
+		writeMockJSON(w, http.StatusBadRequest, D{"error": "invalid phone number"})
+	case MockResultUnavailable:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	default:
+		writeMockJSON(w, http.StatusOK, D{"result": "ok"})
+	}
+}
+
+func (m *MockServer) serveDevinoSession(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, `"gorush-mock-session"`)
+}
+
+func (m *MockServer) serveTelegram(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var req telegramGatewayRequest
+	_ = json.Unmarshal(body, &req)
+
+	message := m.receive(r, "telegram", []string{req.PhoneNumber}, body)
+	switch message.Result {
+	case MockResultInvalidToken:
+		writeMockJSON(w, http.StatusOK, D{"ok": false, "error": "PHONE_NUMBER_INVALID"})
+	case MockResultUnavailable:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	default:
+		writeMockJSON(w, http.StatusOK, D{
+			"ok":     true,
+			"result": D{"request_id": "gorush-mock-" + strconv.FormatInt(message.ID, 10)},
+		})
+	}
+}
+
+func (m *MockServer) serveTelphin(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var req TelphinCallRequest
+	_ = json.Unmarshal(body, &req)
+
+	switch m.receive(r, "telphin", []string{req.Number}, body).Result {
+	case MockResultInvalidToken:
+		writeMockJSON(w, http.StatusBadRequest, D{"error": "invalid number"})
+	case MockResultUnavailable:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	default:
+		writeMockJSON(w, http.StatusOK, D{"status": "ok"})
+	}
+}
+
+// InitMockProviders starts the fake providers and points every provider
+// client at them. The credentials are not needed in mock mode, the missing
+// ones are replaced by placeholders.
+func InitMockProviders(ctx context.Context, cfg *config.ConfYaml) (*MockServer, error) {
+	mock, err := NewMockServer(cfg.Mock.MaxMessages, MockFailures{
+		Latency:       cfg.Mock.Latency,
+		ErrorRate:     cfg.Mock.ErrorRate,
+		InvalidTokens: cfg.Mock.InvalidTokens,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the host is overridden by the transport, whatever the environment
+	ApnsClient = apns2.NewClient(tls.Certificate{})
+	ApnsClient.HTTPClient = mock.HTTPClient("/apns")
+	MaxConcurrentIOSPushes = make(chan struct{}, cfg.Ios.MaxConcurrentPushes)
+
+	FCMClient, err = fcm.NewClient(
+		ctx,
+		fcm.WithProjectID(mockProjectID),
+		fcm.WithCustomClientOption(option.WithHTTPClient(mock.HTTPClient("/fcm"))),
+	)
+	if err != nil {
+		_ = mock.Close()
+		return nil, err
+	}
+
+	// the HMS client can't be given an HTTP client, only its URLs
+	if cfg.Huawei.AppID == "" {
+		cfg.Huawei.AppID = mockAppID
+	}
+	if cfg.Huawei.AppSecret == "" {
+		cfg.Huawei.AppSecret = mockAppSecret
+	}
+	HMSClient, err = client.NewHttpClient(&c.Config{
+		AppId:     cfg.Huawei.AppID,
+		AppSecret: cfg.Huawei.AppSecret,
+		AuthUrl:   mock.URL + "/hms/oauth2/v3/token",
+		PushUrl:   mock.URL + "/hms",
+	})
+	if err != nil {
+		_ = mock.Close()
+		return nil, err
+	}
+
+	privateKey := cfg.WebPush.VAPIDPrivateKey
+	if privateKey == "" {
+		if privateKey, err = generateVAPIDKey(); err != nil {
+			_ = mock.Close()
+			return nil, err
+		}
+	}
+	if WebPushClient, err = NewVAPIDClient(privateKey, cfg.WebPush.Subject); err != nil {
+		_ = mock.Close()
+		return nil, err
+	}
+	WebPushClient.HTTPClient = mock.HTTPClient("/webpush")
+
+	cfg.SMS.MTSApiURL = mock.URL + "/sms/mts"
+	cfg.SMS.DevinoApiURLV1 = mock.URL + "/sms/devino/v1"
+	cfg.SMS.DevinoApiURLV2 = mock.URL + "/sms/devino"
+	cfg.TelegramGateway.ApiURL = mock.URL + "/telegram"
+	cfg.CallAuto.ApiURL = mock.URL + "/telphin"
+
+	MockProviders = mock
+	logx.LogAccess.Info("mock providers are listening on ", mock.URL)
+
+	return mock, nil
+}
+
+// generateVAPIDKey returns a new VAPID private key, encoded as expected by
+// webpush.vapid_private_key.
+func generateVAPIDKey() (string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.D.FillBytes(make([]byte, 32))), nil
+}
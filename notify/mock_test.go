@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func initMockProviders(t *testing.T) (*config.ConfYaml, *MockServer) {
+	t.Helper()
+
+	apnsClient, fcmClient, hmsClient, webPushClient := ApnsClient, FCMClient, HMSClient, WebPushClient
+	t.Cleanup(func() {
+		ApnsClient, FCMClient, HMSClient, WebPushClient = apnsClient, fcmClient, hmsClient, webPushClient
+		MockProviders = nil
+	})
+
+	cfg, _ := config.LoadConf()
+	cfg.Mock.Enabled = true
+	cfg.Log.HideToken = false
+	FCMClient = nil
+
+	mock, err := InitMockProviders(context.Background(), cfg)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = mock.Close() })
+
+	return cfg, mock
+}
+
+func TestMockProvidersNeedNoCredential(t *testing.T) {
+	cfg, _ := initMockProviders(t)
+	cfg.Ios.Enabled = true
+	cfg.Android.Enabled = true
+
+	assert.NoError(t, CheckPushConf(cfg))
+}
+
+func TestMockProvidersIOS(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	mock.SetFailures(MockFailures{InvalidTokens: []string{"bbbb"}})
+
+	req := &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa", "bbbb"},
+		Message:  "Welcome",
+	}
+
+	resp, err := PushToIOS(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 1)
+	assert.Equal(t, "bbbb", resp.Logs[0].Token)
+
+	messages := mock.Messages("apns")
+	assert.Len(t, messages, 2)
+	for _, message := range messages {
+		if message.Tokens[0] == "bbbb" {
+			assert.Equal(t, MockResultInvalidToken, message.Result)
+			continue
+		}
+		assert.Equal(t, MockResultOK, message.Result)
+		assert.Equal(t, []string{"aaaa"}, message.Tokens)
+	}
+	assert.Empty(t, mock.Messages("fcm"))
+}
+
+func TestMockProvidersErrorRate(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	mock.SetFailures(MockFailures{ErrorRate: 100})
+
+	req := &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}
+
+	resp, err := PushToIOS(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 1)
+
+	messages := mock.Messages("apns")
+	assert.Len(t, messages, 1)
+	assert.Equal(t, MockResultUnavailable, messages[0].Result)
+}
+
+func TestMockProvidersAndroid(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+
+	req := &PushNotification{
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}
+
+	resp, err := PushToAndroid(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+
+	messages := mock.Messages("fcm")
+	assert.Len(t, messages, 1)
+	assert.Equal(t, []string{"aaaa"}, messages[0].Tokens)
+	assert.Equal(t, MockResultOK, messages[0].Result)
+	assert.NotNil(t, messages[0].Body)
+
+	mock.Reset()
+	assert.Empty(t, mock.Messages(""))
+}
+
+func TestMockProvidersHuawei(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+
+	req := &PushNotification{
+		Platform: core.PlatformHuawei,
+		Tokens:   []string{"aaaa", "bbbb"},
+		Message:  "Welcome",
+	}
+
+	resp, err := PushToHuawei(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+
+	messages := mock.Messages("hms")
+	assert.Len(t, messages, 1)
+	assert.Equal(t, []string{"aaaa", "bbbb"}, messages[0].Tokens)
+}
+
+func TestMockProvidersLatency(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	mock.SetFailures(MockFailures{Latency: 100})
+
+	req := &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}
+
+	start := time.Now()
+	_, err := PushToIOS(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestMockProvidersMaxMessages(t *testing.T) {
+	cfg, _ := initMockProviders(t)
+
+	mock, err := NewMockServer(2, MockFailures{})
+	assert.NoError(t, err)
+	defer mock.Close()
+	ApnsClient.HTTPClient = mock.HTTPClient("/apns")
+
+	for i := 0; i < 3; i++ {
+		_, err = PushToIOS(context.Background(), &PushNotification{
+			Platform: core.PlatformIOS,
+			Tokens:   []string{"aaaa"},
+			Message:  "Welcome",
+		}, cfg)
+		assert.NoError(t, err)
+	}
+
+	messages := mock.Messages("")
+	assert.Len(t, messages, 2)
+	assert.Equal(t, int64(3), messages[1].ID)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/appleboy/gorush/config"
 	"github.com/appleboy/gorush/core"
@@ -84,6 +85,24 @@ type PushNotification struct {
 	Sound            interface{} `json:"sound,omitempty"`
 	Data             D           `json:"data,omitempty"`
 	Retry            int         `json:"retry,omitempty"`
+	RetryAttempt     int         `json:"retry_attempt,omitempty"`
+	IdempotencyKey   string      `json:"idempotency_key,omitempty"`
+	DryRun           bool        `json:"dry_run,omitempty"`
+	Lane             string      `json:"lane,omitempty"`
+	ReplyTo          string      `json:"reply_to,omitempty"` // set by the API waiting for the result in sync mode
+	CampaignID       string      `json:"campaign_id,omitempty"`
+	Audience         string      `json:"audience,omitempty"` // tag expression matched by the registered devices
+	MessageCategory  string      `json:"message_category,omitempty"`
+	UserID           string      `json:"user_id,omitempty"`
+	Template         string      `json:"template,omitempty"` // rendered for each recipient before sending
+	Locale           string      `json:"locale,omitempty"`
+	Vars             D           `json:"vars,omitempty"`
+	Experiment       string      `json:"experiment,omitempty"`
+	Variants         []Variant   `json:"variants,omitempty"` // split between the recipients by weight
+	Variant          string      `json:"variant,omitempty"`  // set by the split to the name of the variant sent
+
+	// the locale and the variables of the recipients, by token
+	RecipientVars map[string]TemplateRecipient `json:"recipient_vars,omitempty"`
 
 	// Android
 	Notification *messaging.Notification  `json:"notification,omitempty"`
@@ -124,6 +143,10 @@ type PushNotification struct {
 	SMSMessage   string   `json:"SMSMessage,omitempty"`
 	TemplateID   string   `json:"template_id,omitempty"`
 
+	// Web Push
+	Subscriptions []WebPushSubscription `json:"subscriptions,omitempty"`
+	TTL           *int                  `json:"ttl,omitempty"`
+
 	// Telegram gateway
 	TelegramGatewayCode string `json:"telegram_gateway_code,omitempty"`
 
@@ -137,6 +160,21 @@ type PushNotification struct {
 	DismissalDate int64  `json:"dismissal-date"`
 	Event         string `json:"event,omitempty"`
 	Timestamp     int64  `json:"timestamp,omitempty"`
+
+	// push-to-start live-activity support
+	// ref: https://developer.apple.com/documentation/activitykit/starting-and-updating-live-activities-with-activitykit-push-notifications
+	AttributesType string `json:"attributes-type,omitempty"`
+	Attributes     D      `json:"attributes,omitempty"`
+
+	// the failed messages of the attempt sent again, in place or by a retry
+	// through the queue, and those left to the retry through the queue
+	retried  int
+	deferred int
+
+	// the batches of a split notification were counted once for their user,
+	// and whether the user was over the frequency cap
+	userCounted bool
+	userCapped  bool
 }
 
 // Bytes for queue message
@@ -163,6 +201,43 @@ func (p *PushNotification) IsTopic() bool {
 	return false
 }
 
+// Recipients returns the device tokens, or the subscription endpoints for
+// web push notifications.
+func (p *PushNotification) Recipients() []string {
+	if p.Platform != core.PlatformWebPush {
+		return p.Tokens
+	}
+
+	endpoints := make([]string, 0, len(p.Subscriptions))
+	for _, sub := range p.Subscriptions {
+		endpoints = append(endpoints, sub.Endpoint)
+	}
+
+	return endpoints
+}
+
+// CheckNotification validates a notification before it is queued, whichever
+// API it comes from: its lane, payload size, audience, templates, variants
+// and campaign. The oversized payloads are rejected before they fail for
+// every token.
+func CheckNotification(cfg *config.ConfYaml, req *PushNotification) error {
+	checks := []func(*PushNotification) error{
+		CheckLane,
+		CheckPayloadSize,
+		CheckAudience,
+		func(req *PushNotification) error { return CheckTemplate(cfg, req) },
+		CheckVariants,
+		CheckCampaign,
+	}
+	for _, check := range checks {
+		if err := check(req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // CheckMessage for check request message
 func CheckMessage(req *PushNotification) error {
 	var msg string
@@ -171,6 +246,14 @@ func CheckMessage(req *PushNotification) error {
 		req.Tokens = append(req.Tokens, req.To)
 	}
 
+	// web push targets browser subscriptions instead of device tokens
+	if req.Platform == core.PlatformWebPush {
+		if err := checkWebPushSubscriptions(req.Subscriptions); err != nil {
+			return err
+		}
+		return CheckPayloadSize(req)
+	}
+
 	// if the message is a topic, the tokens field is not required
 	if !req.IsTopic() && len(req.Tokens) == 0 {
 		return errors.New("please provide at least one device token")
@@ -183,19 +266,10 @@ func CheckMessage(req *PushNotification) error {
 			logx.LogAccess.Debug(msg)
 			return errors.New(msg)
 		}
-	case
-		core.PlatformAndroid,
-		core.PlatformHuawei:
-		if len(req.Tokens) > 500 {
-			// https://firebase.google.com/docs/cloud-messaging/send-message#send-messages-to-multiple-devices
-			msg = "you can specify up to 500 device registration tokens per invocation"
-			logx.LogAccess.Debug(msg)
-			return errors.New(msg)
-		}
 	default:
 	}
 
-	return nil
+	return CheckPayloadSize(req)
 }
 
 // SetProxy only working for FCM server.
@@ -213,8 +287,19 @@ func SetProxy(proxy string) error {
 
 // CheckPushConf provide check your yml config.
 func CheckPushConf(cfg *config.ConfYaml) error {
-	if !cfg.Ios.Enabled && !cfg.Android.Enabled && !cfg.Huawei.Enabled && !cfg.SMS.Enabled {
-		return errors.New("please enable iOS, Android, Huawei or SMS config in yml config")
+	if !cfg.Ios.Enabled && !cfg.Android.Enabled && !cfg.Huawei.Enabled && !cfg.WebPush.Enabled && !cfg.SMS.Enabled {
+		return errors.New("please enable iOS, Android, Huawei, Web Push or SMS config in yml config")
+	}
+
+	if cfg.FrequencyCap.Enabled {
+		if _, err := ParseFrequencyCaps(cfg.FrequencyCap.Caps); err != nil {
+			return err
+		}
+	}
+
+	// the fake providers don't need any credential
+	if cfg.Mock.Enabled {
+		return nil
 	}
 
 	if cfg.Ios.Enabled {
@@ -249,6 +334,12 @@ func CheckPushConf(cfg *config.ConfYaml) error {
 		}
 	}
 
+	if cfg.WebPush.Enabled {
+		if cfg.WebPush.VAPIDPrivateKey == "" {
+			return errors.New("missing web push vapid private key")
+		}
+	}
+
 	return nil
 }
 
@@ -265,6 +356,175 @@ func SendNotification(
 		}
 	}
 
+	// the API waiting on the result may run on another replica
+	if v.ReplyTo != "" {
+		defer func() {
+			if err := SendReply(cfg, v, resp, err); err != nil {
+				logx.LogError.Error("can't send notification result: " + err.Error())
+			}
+		}()
+	}
+
+	// a paused campaign holds its notifications, a canceled one drops them
+	if held, err := holdCampaign(ctx, cfg, v); held {
+		return &ResponsePush{}, err
+	}
+
+	// the devices of an audience may change until it is sent
+	if v.Audience != "" {
+		return sendAudience(ctx, v, cfg)
+	}
+
+	// each recipient gets the variant it was assigned
+	if len(v.Variants) > 0 {
+		return sendVariants(ctx, v, cfg)
+	}
+
+	// each recipient gets the template in its own locale
+	if v.Template != "" {
+		return sendTemplate(ctx, v, cfg)
+	}
+
+	// the gateways have no validate-only mode, never send for real
+	if v.IsDryRun(cfg) && (v.Platform == core.PlatformSMS ||
+		v.Platform == core.PlatformTelegramGateway || v.Platform == core.PlatformCallAuto) {
+		logx.LogAccess.Debugf("dry run is not supported by platform %d, skip the notification", v.Platform)
+		return &ResponsePush{}, nil
+	}
+
+	// a notification over the burst of its provider is sent a burst at a time
+	if _, burst := rateLimitBucket(cfg, rateLimitProvider(v.Platform)); burst > 0 && messageCount(v) > burst {
+		return sendBursts(ctx, v, cfg, burst)
+	}
+
+	// don't tie up a worker with a provider that keeps failing
+	if breaker := providerBreaker(cfg, platformProvider(v.Platform)); breaker != nil {
+		generation, err := breaker.Allow()
+		if err != nil {
+			// a half-open breaker busy with its probes has no time left to wait
+			delay := max(NewRetryPolicy(cfg).Delay(1, breaker.Remaining()), time.Second)
+			return holdNotification(cfg, v, delay, err, DeadLetterCircuitOpen)
+		}
+		defer breaker.Done(generation)
+	}
+
+	// wait for the capacity of the provider rather than getting throttled
+	if wait, err := waitForCapacity(ctx, cfg, v, rateLimitProvider(v.Platform)); err != nil {
+		if errors.Is(err, ErrRateLimited) {
+			return holdNotification(cfg, v, max(wait, time.Second), err, DeadLetterRateLimited)
+		}
+		return nil, err
+	}
+
+	SetDeliveryState(cfg, v, DeliverySending, nil)
+
+	// the recipients over their frequency cap are answered without a message
+	sent, capped := applyFrequencyCap(cfg, v)
+	if sent != nil {
+		// the providers narrow the tokens down to those they retry
+		attempt := *sent
+		resp, err = pushToProvider(ctx, &attempt, cfg)
+		v.retried, v.deferred = attempt.retried, attempt.deferred
+	}
+	if sent == nil || len(capped) > 0 {
+		if resp == nil {
+			resp = &ResponsePush{}
+		}
+		resp.Logs = append(resp.Logs, capped...)
+	}
+
+	finishDelivery(cfg, v, err)
+	countCampaignResult(v, resp, err)
+	countVariantResult(v, resp, err)
+
+	// retries only resend the failed tokens, keep the result of the first attempt
+	if v.IdempotencyKey != "" && v.RetryAttempt == 0 {
+		var logs []logx.LogPushEntry
+		if resp != nil {
+			logs = resp.Logs
+		}
+		if err := SaveIdempotentResult(cfg, v, logs); err != nil {
+			logx.LogError.Error(err)
+		}
+	}
+
+	// a dry run delivered nothing to report
+	if cfg.Core.FeedbackURL != "" && !v.IsDryRun(cfg) {
+		for _, l := range resp.Logs {
+			err := DispatchFeedback(ctx, l, cfg.Core.FeedbackURL, cfg.Core.FeedbackTimeout, cfg.Core.FeedbackHeader)
+			if err != nil {
+				logx.LogError.Error(err)
+			}
+		}
+	}
+
+	return resp, err
+}
+
+// sendBatches sends the batches the notification is split into, each a copy
+// of the notification, and adds their logs to resp. The result and the
+// reply are those of the whole notification, not of a batch. In async mode,
+// the batches of another platform go to its own pool and log nothing here.
+func sendBatches(ctx context.Context, req *PushNotification, cfg *config.ConfYaml, resp *ResponsePush, batches []*PushNotification) (*ResponsePush, error) {
+	counted, capped := countUserFrequency(cfg, req)
+
+	var errs []error
+	for _, batch := range batches {
+		batch.ReplyTo = ""
+		batch.IdempotencyKey = ""
+		batch.userCounted, batch.userCapped = counted, capped
+
+		if queueBatch(cfg, req, batch) {
+			continue
+		}
+
+		res, err := SendNotification(ctx, batch, cfg)
+		if res != nil {
+			resp.Logs = append(resp.Logs, res.Logs...)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// each batch has a delivery state of its own, the notification is done
+	// with once they are sent
+	finishDelivery(cfg, req, nil)
+
+	if req.IdempotencyKey != "" && req.RetryAttempt == 0 {
+		if err := SaveIdempotentResult(cfg, req, resp.Logs); err != nil {
+			logx.LogError.Error(err)
+		}
+	}
+
+	return resp, errors.Join(errs...)
+}
+
+// queueBatch hands the batch of another platform than the notification over
+// to the own pool of that platform, so that the worker of the notification
+// doesn't wait for a provider it isn't sized for. It reports whether the batch
+// was queued.
+func queueBatch(cfg *config.ConfYaml, req, batch *PushNotification) bool {
+	if cfg.Core.Sync || batch.Platform == req.Platform {
+		return false
+	}
+
+	q, ok := PlatformQueues[batch.Platform]
+	if !ok {
+		return false
+	}
+
+	SetDeliveryState(cfg, batch, DeliveryQueued, nil)
+	if err := q.Queue(batch); err != nil {
+		logx.LogError.Errorf("can't queue the batch to the %s pool, sending it now: %v", PlatformPoolName(batch.Platform), err)
+		return false
+	}
+
+	return true
+}
+
+// pushToProvider sends the notification to the provider of its platform.
+func pushToProvider(ctx context.Context, v *PushNotification, cfg *config.ConfYaml) (resp *ResponsePush, err error) {
 	switch v.Platform {
 	case core.PlatformIOS:
 		resp, err = PushToIOS(ctx, v, cfg)
@@ -272,6 +532,8 @@ func SendNotification(
 		resp, err = PushToAndroid(ctx, v, cfg)
 	case core.PlatformHuawei:
 		resp, err = PushToHuawei(ctx, v, cfg)
+	case core.PlatformWebPush:
+		resp, err = PushToWebPush(ctx, v, cfg)
 	case core.PlatformSMS:
 		SendRUSMS(v, cfg, -1)
 	case core.PlatformTelegramGateway:
@@ -280,15 +542,6 @@ func SendNotification(
 		SendTelphinCall(v, cfg)
 	}
 
-	if cfg.Core.FeedbackURL != "" {
-		for _, l := range resp.Logs {
-			err := DispatchFeedback(ctx, l, cfg.Core.FeedbackURL, cfg.Core.FeedbackTimeout, cfg.Core.FeedbackHeader)
-			if err != nil {
-				logx.LogError.Error(err)
-			}
-		}
-	}
-
 	return resp, err
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"errors"
 	"net"
@@ -149,21 +150,23 @@ func InitAPNSClient(ctx context.Context, cfg *config.ConfYaml) error {
 }
 
 func newApnsClient(cfg *config.ConfYaml, certificate tls.Certificate) (*apns2.Client, error) {
-	var client *apns2.Client
+	client := apns2.NewClient(certificate)
+	client.Host = apnsHost(cfg, &PushNotification{})
 
-	if cfg.Ios.Production {
-		client = apns2.NewClient(certificate).Production()
-	} else {
-		client = apns2.NewClient(certificate).Development()
+	rootCAs, err := loadCAPool(cfg.Ios.CAPath)
+	if err != nil {
+		return nil, err
 	}
 
 	if cfg.Core.HTTPProxy == "" {
+		trustAPNSRootCAs(client, rootCAs)
 		return client, nil
 	}
 
 	//nolint:gosec
 	tlsConfig := &tls.Config{
 		Certificates: []tls.Certificate{certificate},
+		RootCAs:      rootCAs,
 	}
 
 	if len(certificate.Certificate) > 0 {
@@ -191,20 +194,27 @@ func newApnsClient(cfg *config.ConfYaml, certificate tls.Certificate) (*apns2.Cl
 }
 
 func newApnsTokenClient(cfg *config.ConfYaml, token *token.Token) (*apns2.Client, error) {
-	var client *apns2.Client
+	client := apns2.NewTokenClient(token)
+	client.Host = apnsHost(cfg, &PushNotification{})
 
-	if cfg.Ios.Production {
-		client = apns2.NewTokenClient(token).Production()
-	} else {
-		client = apns2.NewTokenClient(token).Development()
+	rootCAs, err := loadCAPool(cfg.Ios.CAPath)
+	if err != nil {
+		return nil, err
 	}
 
 	if cfg.Core.HTTPProxy == "" {
+		trustAPNSRootCAs(client, rootCAs)
 		return client, nil
 	}
 
+	var tlsConfig *tls.Config
+	if rootCAs != nil {
+		//nolint:gosec
+		tlsConfig = &tls.Config{RootCAs: rootCAs}
+	}
+
 	transport := &http.Transport{
-		DialTLS:         DialTLS(nil),
+		DialTLS:         DialTLS(tlsConfig),
 		Proxy:           http.DefaultTransport.(*http.Transport).Proxy,
 		IdleConnTimeout: idleConnTimeout,
 	}
@@ -221,6 +231,21 @@ func newApnsTokenClient(cfg *config.ConfYaml, token *token.Token) (*apns2.Client
 	return client, nil
 }
 
+// trustAPNSRootCAs makes the HTTP/2 transport of the client trust the CA
+// bundle of ios.ca_path.
+func trustAPNSRootCAs(client *apns2.Client, rootCAs *x509.CertPool) {
+	transport, ok := client.HTTPClient.Transport.(*http2.Transport)
+	if !ok || rootCAs == nil {
+		return
+	}
+
+	if transport.TLSClientConfig == nil {
+		//nolint:gosec
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.RootCAs = rootCAs
+}
+
 func configureHTTP2ConnHealthCheck(h2Transport *http2.Transport) {
 	h2Transport.ReadIdleTimeout = 1 * time.Second
 	h2Transport.PingTimeout = 1 * time.Second
@@ -315,6 +340,14 @@ func iosAlertDictionary(notificationPayload *payload.Payload, req *PushNotificat
 		notificationPayload.SetTimestamp(req.Timestamp)
 	}
 
+	if len(req.AttributesType) > 0 {
+		notificationPayload.SetAttributesType(req.AttributesType)
+	}
+
+	if len(req.Attributes) > 0 {
+		notificationPayload.SetAttributes(req.Attributes)
+	}
+
 	return notificationPayload
 }
 
@@ -404,21 +437,37 @@ func GetIOSNotification(req *PushNotification) *apns2.Notification {
 	return notification
 }
 
-func getApnsClient(cfg *config.ConfYaml, req *PushNotification) (client *apns2.Client) {
+// apnsHost returns the APNs host of the notification: the environment it
+// asks for, the one of the config otherwise. A stand-in replaces the host of
+// both environments.
+func apnsHost(cfg *config.ConfYaml, req *PushNotification) string {
 	switch {
+	case cfg.Ios.Endpoint != "":
+		return cfg.Ios.Endpoint
 	case req.Production:
-		client = ApnsClient.Production()
+		return apns2.HostProduction
 	case req.Development:
-		client = ApnsClient.Development()
+		return apns2.HostDevelopment
+	case cfg.Ios.Production:
+		return apns2.HostProduction
 	default:
-		if cfg.Ios.Production {
-			client = ApnsClient.Production()
-		} else {
-			client = ApnsClient.Development()
-		}
+		return apns2.HostDevelopment
+	}
+}
+
+// getApnsClient returns the client of the APNs host of the notification. The
+// shared client is set to the host of the config when it is built, and never
+// changed afterwards: a notification for the other environment gets a copy,
+// which shares its connections.
+func getApnsClient(cfg *config.ConfYaml, req *PushNotification) *apns2.Client {
+	host := apnsHost(cfg, req)
+	if ApnsClient.Host == host {
+		return ApnsClient
 	}
 
-	return
+	client := *ApnsClient
+	client.Host = host
+	return &client
 }
 
 // PushToIOS provide send notification to APNs server.
@@ -426,18 +475,28 @@ func PushToIOS(ctx context.Context, req *PushNotification, cfg *config.ConfYaml)
 	logx.LogAccess.Debug("Start push notification for iOS")
 
 	var (
-		retryCount = 0
+		retryCount = req.RetryAttempt
 		maxRetry   = cfg.Ios.MaxRetry
+		policy     = NewRetryPolicy(cfg)
 	)
 
 	if req.Retry > 0 && req.Retry < maxRetry {
 		maxRetry = req.Retry
 	}
 
+	// APNs has no validate-only endpoint, don't call it at all
+	if req.IsDryRun(cfg) {
+		return dryRunIOS(cfg, req), nil
+	}
+
 	resp = &ResponsePush{}
 
 Retry:
-	var newTokens []string
+	var (
+		newTokens []string
+		hint      time.Duration
+		mu        sync.Mutex
+	)
 
 	notification := GetIOSNotification(req)
 	client := getApnsClient(cfg, req)
@@ -453,26 +512,42 @@ Retry:
 			// send ios notification
 			res, err := client.PushWithContext(ctx, &notification)
 			if err != nil || (res != nil && res.StatusCode != http.StatusOK) {
+				retryable, delay := retryAPNs(res, err, policy)
+				recordProviderCall(ProviderAPNs, retryable)
 				if err == nil {
 					// error message:
 					// ref: https://github.com/sideshow/apns2/blob/master/response.go#L14-L65
-					err = errors.New(res.Reason)
+					err = apnsError(res.StatusCode, res.Reason)
+				} else {
+					err = transportError(err)
+				}
+
+				apnsID := ""
+				if res != nil {
+					apnsID = res.ApnsID
 				}
 
 				// apns server error
-				errLog := logPush(cfg, core.FailedPush, token, req, err)
-				resp.Logs = append(resp.Logs, errLog)
+				errLog := logPushID(cfg, core.FailedPush, token, apnsID, req, err)
 
-				status.StatStorage.AddIosError(1)
+				mu.Lock()
+				resp.Logs = append(resp.Logs, errLog)
 				// We should retry only "retryable" statuses. More info about response:
 				// See https://apple.co/3AdNane (Handling Notification Responses from APNs)
-				if res != nil && res.StatusCode >= http.StatusInternalServerError {
+				if retryable {
 					newTokens = append(newTokens, token)
+					if delay > hint {
+						hint = delay
+					}
 				}
+				mu.Unlock()
+
+				status.StatStorage.AddIosError(1)
 			}
 
 			if res != nil && res.Sent() {
-				logPush(cfg, core.SucceededPush, token, req, nil)
+				recordProviderCall(ProviderAPNs, false)
+				logPushID(cfg, core.SucceededPush, token, res.ApnsID, req, nil)
 				status.StatStorage.AddIosSuccess(1)
 			}
 
@@ -489,8 +564,19 @@ Retry:
 
 		// resend fail token
 		req.Tokens = newTokens
+		queued, err := scheduleRetry(ctx, cfg, req, retryCount, hint)
+		if err != nil || queued {
+			return resp, err
+		}
 		goto Retry
 	}
 
+	if len(newTokens) > 0 {
+		letter := *req
+		letter.Tokens = newTokens
+		letter.RetryAttempt = retryCount
+		addRetriedDeadLetter(cfg, &letter, resp)
+	}
+
 	return resp, nil
 }
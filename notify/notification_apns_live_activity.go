@@ -0,0 +1,464 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+
+	"github.com/sideshow/apns2"
+	"github.com/sideshow/apns2/payload"
+)
+
+const (
+	// LiveActivityStarting is an activity started remotely whose update
+	// token has not been registered yet.
+	LiveActivityStarting = "starting"
+	// LiveActivityActive is an activity that can receive updates.
+	LiveActivityActive = "active"
+	// LiveActivityEnded is an activity that received the end event.
+	LiveActivityEnded = "ended"
+
+	// liveActivityTTL is how long the state of an activity is kept after its
+	// last change: up to 8 hours running, then up to 4 hours on the Lock Screen.
+	liveActivityTTL = 12 * time.Hour
+
+	liveActivityKey     = "gorush-live-activity:"
+	liveActivityUserKey = "gorush-live-activity-user:"
+	pushToStartKey      = "gorush-push-to-start:"
+
+	liveActivityTopicSuffix = ".push-type.liveactivity"
+)
+
+var (
+	// ErrLiveActivityNotFound is returned for unknown or expired activities.
+	ErrLiveActivityNotFound = errors.New("live activity not found")
+	// ErrLiveActivityNoToken is returned when the update token of the
+	// activity has not been registered yet.
+	ErrLiveActivityNoToken = errors.New("live activity has no update token yet")
+)
+
+// LiveActivity is the state kept for each Live Activity.
+type LiveActivity struct {
+	ID             string `json:"activity_id"`
+	UserID         string `json:"user_id,omitempty"`
+	AttributesType string `json:"attributes_type,omitempty"`
+	Topic          string `json:"topic,omitempty"`
+	// Topics are those an activity was started on, one for each app of the
+	// user. The update token is only sent to Topic, which is left empty until
+	// the app registers the token when there are several of them.
+	Topics    []string `json:"topics,omitempty"`
+	Token     string   `json:"token,omitempty"`
+	State     string   `json:"state"`
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+// PushToStartToken is a push-to-start token registered for a user.
+type PushToStartToken struct {
+	Token          string `json:"token"`
+	AttributesType string `json:"attributes_type,omitempty"`
+	Topic          string `json:"topic,omitempty"`
+}
+
+// RequestLiveActivity is the request body of the Live Activity endpoints.
+type RequestLiveActivity struct {
+	ActivityID     string      `json:"activity_id,omitempty"`
+	UserID         string      `json:"user_id,omitempty"`
+	Token          string      `json:"token,omitempty"`
+	Topic          string      `json:"topic,omitempty"`
+	AttributesType string      `json:"attributes_type,omitempty"`
+	Attributes     D           `json:"attributes,omitempty"`
+	ContentState   D           `json:"content-state,omitempty"`
+	StaleDate      int64       `json:"stale-date,omitempty"`
+	DismissalDate  int64       `json:"dismissal-date,omitempty"`
+	Title          string      `json:"title,omitempty"`
+	Message        string      `json:"message,omitempty"`
+	Alert          Alert       `json:"alert,omitempty"`
+	Sound          interface{} `json:"sound,omitempty"`
+	Priority       string      `json:"priority,omitempty"`
+}
+
+func activityKey(id string) string {
+	return liveActivityKey + id
+}
+
+// userKeyPrefix escapes the user ID so the prefix of one user never matches
+// the keys of another.
+func userKeyPrefix(prefix, userID string) string {
+	return prefix + url.QueryEscape(userID) + ":"
+}
+
+// liveActivityTopic returns the APNs topic of Live Activity pushes for the
+// bundle ID, which may already carry the suffix.
+func liveActivityTopic(topic string) string {
+	if topic == "" || strings.HasSuffix(topic, liveActivityTopicSuffix) {
+		return topic
+	}
+
+	return topic + liveActivityTopicSuffix
+}
+
+// GetLiveActivity returns the stored state of the activity.
+func GetLiveActivity(id string) (*LiveActivity, error) {
+	data, err := status.StatStorage.GetValue(activityKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, ErrLiveActivityNotFound
+	}
+
+	activity := &LiveActivity{}
+	if err := json.Unmarshal(data, activity); err != nil {
+		return nil, err
+	}
+
+	return activity, nil
+}
+
+func saveLiveActivity(activity *LiveActivity) error {
+	now := time.Now().Unix()
+	if activity.CreatedAt == 0 {
+		activity.CreatedAt = now
+	}
+	activity.UpdatedAt = now
+
+	data, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+
+	if err := status.StatStorage.SetValue(activityKey(activity.ID), data, liveActivityTTL); err != nil {
+		return err
+	}
+
+	if activity.UserID == "" {
+		return nil
+	}
+
+	// index of the activities of the user, used to end all of them at once
+	return status.StatStorage.SetValue(
+		userKeyPrefix(liveActivityUserKey, activity.UserID)+activity.ID, []byte{}, liveActivityTTL,
+	)
+}
+
+// RegisterPushToStartToken stores the push-to-start token of a user device.
+func RegisterPushToStartToken(req *RequestLiveActivity) error {
+	switch {
+	case req.UserID == "":
+		return errors.New("the user id cannot be empty")
+	case req.Token == "":
+		return errors.New("the push-to-start token cannot be empty")
+	case req.Topic == "":
+		return errors.New("the topic cannot be empty")
+	}
+
+	data, err := json.Marshal(PushToStartToken{
+		Token:          req.Token,
+		AttributesType: req.AttributesType,
+		Topic:          liveActivityTopic(req.Topic),
+	})
+	if err != nil {
+		return err
+	}
+
+	return status.StatStorage.SetValue(userKeyPrefix(pushToStartKey, req.UserID)+req.Token, data, 0)
+}
+
+// UnregisterPushToStartToken removes the push-to-start token of a user
+// device, for example when the user signs out. Unknown tokens are not an
+// error.
+func UnregisterPushToStartToken(req *RequestLiveActivity) error {
+	switch {
+	case req.UserID == "":
+		return errors.New("the user id cannot be empty")
+	case req.Token == "":
+		return errors.New("the push-to-start token cannot be empty")
+	}
+
+	return status.StatStorage.DelValue(userKeyPrefix(pushToStartKey, req.UserID) + req.Token)
+}
+
+// pushToStartTokens returns the push-to-start tokens of the user that can
+// start activities of the attributes type.
+func pushToStartTokens(userID, attributesType string) ([]PushToStartToken, error) {
+	keys, err := status.StatStorage.Keys(userKeyPrefix(pushToStartKey, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]PushToStartToken, 0, len(keys))
+	for _, key := range keys {
+		data, err := status.StatStorage.GetValue(key)
+		if err != nil {
+			return nil, err
+		}
+
+		var token PushToStartToken
+		if data == nil || json.Unmarshal(data, &token) != nil {
+			continue
+		}
+
+		if token.AttributesType != "" && attributesType != "" && token.AttributesType != attributesType {
+			continue
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// RegisterLiveActivityToken stores the update token the app receives once the
+// activity is running, started locally or remotely.
+func RegisterLiveActivityToken(req *RequestLiveActivity) (*LiveActivity, error) {
+	if req.ActivityID == "" {
+		return nil, errors.New("the activity id cannot be empty")
+	}
+
+	if req.Token == "" {
+		return nil, errors.New("the update token cannot be empty")
+	}
+
+	activity, err := GetLiveActivity(req.ActivityID)
+	if errors.Is(err, ErrLiveActivityNotFound) {
+		activity, err = &LiveActivity{ID: req.ActivityID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if req.UserID != "" {
+		activity.UserID = req.UserID
+	}
+	if req.AttributesType != "" {
+		activity.AttributesType = req.AttributesType
+	}
+	if req.Topic != "" {
+		activity.Topic = liveActivityTopic(req.Topic)
+	}
+
+	if activity.Topic == "" {
+		return nil, errors.New("the topic cannot be empty")
+	}
+
+	activity.Token = req.Token
+	activity.State = LiveActivityActive
+
+	return activity, saveLiveActivity(activity)
+}
+
+// liveActivityNotification builds the APNs notification of a Live Activity event.
+func liveActivityNotification(req *RequestLiveActivity, event string, tokens []string, topic string) *PushNotification {
+	return &PushNotification{
+		ID:            req.ActivityID,
+		Platform:      core.PlatformIOS,
+		Tokens:        tokens,
+		Topic:         topic,
+		PushType:      string(apns2.PushTypeLiveActivity),
+		Event:         event,
+		Timestamp:     time.Now().Unix(),
+		ContentState:  req.ContentState,
+		StaleDate:     req.StaleDate,
+		DismissalDate: req.DismissalDate,
+		Title:         req.Title,
+		Message:       req.Message,
+		Alert:         req.Alert,
+		Sound:         req.Sound,
+		Priority:      req.Priority,
+	}
+}
+
+// pushLiveActivity sends the notification of a Live Activity event through
+// the circuit breaker and the rate limit like any other notification. The
+// failed tokens are retried in place rather than through the queue: the state
+// of the activity follows what APNs accepted by the time it returns.
+func pushLiveActivity(ctx context.Context, notification *PushNotification, cfg *config.ConfYaml) (*ResponsePush, error) {
+	syncCfg := *cfg
+	syncCfg.Core.Sync = true
+
+	return SendNotification(ctx, notification, &syncCfg)
+}
+
+// StartLiveActivity starts the activity on every device of the user with a
+// push-to-start token for the attributes type.
+func StartLiveActivity(ctx context.Context, req *RequestLiveActivity, cfg *config.ConfYaml) (*ResponsePush, error) {
+	switch {
+	case req.ActivityID == "":
+		return nil, errors.New("the activity id cannot be empty")
+	case req.UserID == "":
+		return nil, errors.New("the user id cannot be empty")
+	case req.AttributesType == "":
+		return nil, errors.New("the attributes type cannot be empty")
+	case len(req.ContentState) == 0:
+		return nil, errors.New("the content state cannot be empty")
+	}
+
+	tokens, err := pushToStartTokens(req.UserID, req.AttributesType)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, errors.New("no push-to-start token registered for the user")
+	}
+
+	// devices may run different apps of the same user
+	topics := map[string][]string{}
+	for _, token := range tokens {
+		topic := token.Topic
+		if req.Topic != "" {
+			topic = liveActivityTopic(req.Topic)
+		}
+		topics[topic] = append(topics[topic], token.Token)
+	}
+
+	resp := &ResponsePush{}
+	activity := &LiveActivity{
+		ID:             req.ActivityID,
+		UserID:         req.UserID,
+		AttributesType: req.AttributesType,
+		State:          LiveActivityStarting,
+	}
+
+	names := make([]string, 0, len(topics))
+	for topic := range topics {
+		names = append(names, topic)
+	}
+	slices.Sort(names)
+
+	for _, topic := range names {
+		notification := liveActivityNotification(req, "start", topics[topic], topic)
+		notification.AttributesType = req.AttributesType
+		notification.Attributes = req.Attributes
+
+		res, err := pushLiveActivity(ctx, notification, cfg)
+		if res != nil {
+			resp.Logs = append(resp.Logs, res.Logs...)
+		}
+		if err != nil {
+			return resp, err
+		}
+
+		activity.Topics = append(activity.Topics, topic)
+	}
+	if len(activity.Topics) == 1 {
+		activity.Topic = activity.Topics[0]
+	}
+
+	logx.LogAccess.Debugf("live activity %s started for user %s", req.ActivityID, req.UserID)
+
+	return resp, saveLiveActivity(activity)
+}
+
+// UpdateLiveActivity sends the update event to the activity.
+func UpdateLiveActivity(ctx context.Context, req *RequestLiveActivity, cfg *config.ConfYaml) (*ResponsePush, error) {
+	if len(req.ContentState) == 0 {
+		return nil, errors.New("the content state cannot be empty")
+	}
+
+	activity, err := GetLiveActivity(req.ActivityID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sendLiveActivityEvent(ctx, activity, req, string(payload.LiveActivityEventUpdate), cfg)
+}
+
+// EndLiveActivity sends the end event to the activity.
+func EndLiveActivity(ctx context.Context, req *RequestLiveActivity, cfg *config.ConfYaml) (*ResponsePush, error) {
+	activity, err := GetLiveActivity(req.ActivityID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sendLiveActivityEvent(ctx, activity, req, string(payload.LiveActivityEventEnd), cfg)
+}
+
+// EndUserLiveActivities ends every open activity of the user, for example
+// when the order they follow has been delivered.
+func EndUserLiveActivities(ctx context.Context, req *RequestLiveActivity, cfg *config.ConfYaml) (*ResponsePush, error) {
+	if req.UserID == "" {
+		return nil, errors.New("the user id cannot be empty")
+	}
+
+	prefix := userKeyPrefix(liveActivityUserKey, req.UserID)
+	keys, err := status.StatStorage.Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ResponsePush{}
+	for _, key := range keys {
+		activity, err := GetLiveActivity(strings.TrimPrefix(key, prefix))
+		if errors.Is(err, ErrLiveActivityNotFound) {
+			_ = status.StatStorage.DelValue(key)
+			continue
+		}
+		if err != nil {
+			return resp, err
+		}
+
+		if activity.State != LiveActivityActive {
+			continue
+		}
+
+		res, err := sendLiveActivityEvent(ctx, activity, req, string(payload.LiveActivityEventEnd), cfg)
+		if res != nil {
+			resp.Logs = append(resp.Logs, res.Logs...)
+		}
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	return resp, nil
+}
+
+func sendLiveActivityEvent(
+	ctx context.Context,
+	activity *LiveActivity,
+	req *RequestLiveActivity,
+	event string,
+	cfg *config.ConfYaml,
+) (*ResponsePush, error) {
+	if activity.Token == "" {
+		return nil, ErrLiveActivityNoToken
+	}
+
+	if activity.State == LiveActivityEnded {
+		return nil, errors.New("live activity has already ended")
+	}
+
+	eventReq := *req
+	eventReq.ActivityID = activity.ID
+	notification := liveActivityNotification(&eventReq, event, []string{activity.Token}, activity.Topic)
+
+	resp, err := pushLiveActivity(ctx, notification, cfg)
+	if err != nil {
+		return resp, err
+	}
+
+	// the token APNs rejected or that was capped is only in the logs, the
+	// activity is unchanged
+	for _, l := range resp.Logs {
+		if l.Type != core.SucceededPush {
+			return resp, nil
+		}
+	}
+
+	if event == string(payload.LiveActivityEventEnd) {
+		activity.State = LiveActivityEnded
+	}
+
+	return resp, saveLiveActivity(activity)
+}
@@ -0,0 +1,312 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/appleboy/gorush/config"
+
+	"github.com/sideshow/apns2"
+	"github.com/stretchr/testify/assert"
+)
+
+type apnsRequest struct {
+	Token    string
+	Topic    string
+	PushType string
+	Body     map[string]interface{}
+}
+
+// redirectTransport sends every request to the test server, whatever the
+// APNs host the client was configured with.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newTestAPNsServer(t *testing.T) (*[]apnsRequest, func()) {
+	var (
+		mu       sync.Mutex
+		requests []apnsRequest
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(data, &body))
+
+		mu.Lock()
+		requests = append(requests, apnsRequest{
+			Token:    strings.TrimPrefix(r.URL.Path, "/3/device/"),
+			Topic:    r.Header.Get("apns-topic"),
+			PushType: r.Header.Get("apns-push-type"),
+			Body:     body,
+		})
+		mu.Unlock()
+
+		// the tokens starting with bad are rejected, those starting with down
+		// find APNs unavailable
+		token := strings.TrimPrefix(r.URL.Path, "/3/device/")
+		if strings.HasPrefix(token, "bad") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+			return
+		}
+		if strings.HasPrefix(token, "down") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"reason":"ServiceUnavailable"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	target, _ := url.Parse(server.URL)
+	client := apns2.NewTokenClient(nil)
+	client.HTTPClient = &http.Client{Transport: &redirectTransport{target: target}}
+
+	oldClient, oldPushes := ApnsClient, MaxConcurrentIOSPushes
+	ApnsClient = client
+	MaxConcurrentIOSPushes = make(chan struct{}, 10)
+
+	return &requests, func() {
+		server.Close()
+		ApnsClient, MaxConcurrentIOSPushes = oldClient, oldPushes
+	}
+}
+
+func TestLiveActivityTopic(t *testing.T) {
+	assert.Equal(t, "", liveActivityTopic(""))
+	assert.Equal(t, "com.example.app.push-type.liveactivity", liveActivityTopic("com.example.app"))
+	assert.Equal(t, "com.example.app.push-type.liveactivity", liveActivityTopic("com.example.app.push-type.liveactivity"))
+}
+
+func TestRegisterLiveActivityTokens(t *testing.T) {
+	assert.Error(t, RegisterPushToStartToken(&RequestLiveActivity{Token: "a", Topic: "com.example.app"}))
+	assert.Error(t, RegisterPushToStartToken(&RequestLiveActivity{UserID: "u", Topic: "com.example.app"}))
+
+	assert.NoError(t, RegisterPushToStartToken(&RequestLiveActivity{
+		UserID: "register:1", Token: "start-1", Topic: "com.example.app", AttributesType: "DeliveryAttributes",
+	}))
+	assert.NoError(t, RegisterPushToStartToken(&RequestLiveActivity{
+		UserID: "register:1", Token: "start-2", Topic: "com.example.app", AttributesType: "GameAttributes",
+	}))
+	// the prefix of this user contains the one of the first user
+	assert.NoError(t, RegisterPushToStartToken(&RequestLiveActivity{
+		UserID: "register:1:2", Token: "start-3", Topic: "com.example.app",
+	}))
+
+	tokens, err := pushToStartTokens("register:1", "DeliveryAttributes")
+	assert.NoError(t, err)
+	assert.Equal(t, []PushToStartToken{{
+		Token:          "start-1",
+		AttributesType: "DeliveryAttributes",
+		Topic:          "com.example.app.push-type.liveactivity",
+	}}, tokens)
+
+	assert.Error(t, UnregisterPushToStartToken(&RequestLiveActivity{UserID: "register:1"}))
+	assert.NoError(t, UnregisterPushToStartToken(&RequestLiveActivity{UserID: "register:1", Token: "start-1"}))
+	tokens, err = pushToStartTokens("register:1", "DeliveryAttributes")
+	assert.NoError(t, err)
+	assert.Empty(t, tokens)
+
+	_, err = RegisterLiveActivityToken(&RequestLiveActivity{ActivityID: "register-activity", Token: "update-1"})
+	assert.EqualError(t, err, "the topic cannot be empty")
+
+	activity, err := RegisterLiveActivityToken(&RequestLiveActivity{
+		ActivityID: "register-activity", UserID: "register:1", Token: "update-1", Topic: "com.example.app",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, LiveActivityActive, activity.State)
+
+	stored, err := GetLiveActivity("register-activity")
+	assert.NoError(t, err)
+	assert.Equal(t, "update-1", stored.Token)
+	assert.Equal(t, "com.example.app.push-type.liveactivity", stored.Topic)
+
+	_, err = GetLiveActivity("missing-activity")
+	assert.ErrorIs(t, err, ErrLiveActivityNotFound)
+}
+
+func TestLiveActivityLifecycle(t *testing.T) {
+	requests, closeServer := newTestAPNsServer(t)
+	defer closeServer()
+
+	cfg, _ := config.LoadConf()
+	ctx := context.Background()
+
+	_, err := StartLiveActivity(ctx, &RequestLiveActivity{
+		ActivityID: "order-1", UserID: "lifecycle", AttributesType: "DeliveryAttributes",
+		ContentState: D{"status": "preparing"},
+	}, cfg)
+	assert.EqualError(t, err, "no push-to-start token registered for the user")
+
+	assert.NoError(t, RegisterPushToStartToken(&RequestLiveActivity{
+		UserID: "lifecycle", Token: "start-token", Topic: "com.example.app", AttributesType: "DeliveryAttributes",
+	}))
+
+	resp, err := StartLiveActivity(ctx, &RequestLiveActivity{
+		ActivityID:     "order-1",
+		UserID:         "lifecycle",
+		AttributesType: "DeliveryAttributes",
+		Attributes:     D{"orderID": "1"},
+		ContentState:   D{"status": "preparing"},
+		Title:          "Order confirmed",
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+
+	assert.Len(t, *requests, 1)
+	start := (*requests)[0]
+	assert.Equal(t, "start-token", start.Token)
+	assert.Equal(t, "com.example.app.push-type.liveactivity", start.Topic)
+	assert.Equal(t, "liveactivity", start.PushType)
+	aps := start.Body["aps"].(map[string]interface{})
+	assert.Equal(t, "start", aps["event"])
+	assert.Equal(t, "DeliveryAttributes", aps["attributes-type"])
+	assert.Equal(t, map[string]interface{}{"orderID": "1"}, aps["attributes"])
+
+	activity, err := GetLiveActivity("order-1")
+	assert.NoError(t, err)
+	assert.Equal(t, LiveActivityStarting, activity.State)
+
+	// the app has not sent the update token yet
+	_, err = UpdateLiveActivity(ctx, &RequestLiveActivity{
+		ActivityID: "order-1", ContentState: D{"status": "on the way"},
+	}, cfg)
+	assert.ErrorIs(t, err, ErrLiveActivityNoToken)
+
+	_, err = RegisterLiveActivityToken(&RequestLiveActivity{ActivityID: "order-1", Token: "update-token"})
+	assert.NoError(t, err)
+
+	_, err = UpdateLiveActivity(ctx, &RequestLiveActivity{
+		ActivityID: "order-1", ContentState: D{"status": "on the way"},
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, *requests, 2)
+	update := (*requests)[1]
+	assert.Equal(t, "update-token", update.Token)
+	assert.Equal(t, "update", update.Body["aps"].(map[string]interface{})["event"])
+
+	_, err = EndUserLiveActivities(ctx, &RequestLiveActivity{
+		UserID: "lifecycle", ContentState: D{"status": "delivered"}, DismissalDate: 1700000000,
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, *requests, 3)
+	end := (*requests)[2].Body["aps"].(map[string]interface{})
+	assert.Equal(t, "end", end["event"])
+	assert.Equal(t, float64(1700000000), end["dismissal-date"])
+
+	activity, err = GetLiveActivity("order-1")
+	assert.NoError(t, err)
+	assert.Equal(t, LiveActivityEnded, activity.State)
+
+	// ended activities are skipped
+	_, err = EndUserLiveActivities(ctx, &RequestLiveActivity{UserID: "lifecycle"}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, *requests, 3)
+}
+
+func TestStartLiveActivitySeveralApps(t *testing.T) {
+	requests, closeServer := newTestAPNsServer(t)
+	defer closeServer()
+
+	cfg, _ := config.LoadConf()
+	ctx := context.Background()
+
+	assert.NoError(t, RegisterPushToStartToken(&RequestLiveActivity{
+		UserID: "several-apps", Token: "start-a", Topic: "com.example.a", AttributesType: "DeliveryAttributes",
+	}))
+	assert.NoError(t, RegisterPushToStartToken(&RequestLiveActivity{
+		UserID: "several-apps", Token: "start-b", Topic: "com.example.b", AttributesType: "DeliveryAttributes",
+	}))
+
+	_, err := StartLiveActivity(ctx, &RequestLiveActivity{
+		ActivityID: "order-several", UserID: "several-apps", AttributesType: "DeliveryAttributes",
+		ContentState: D{"status": "preparing"},
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, *requests, 2)
+
+	activity, err := GetLiveActivity("order-several")
+	assert.NoError(t, err)
+	assert.Empty(t, activity.Topic)
+	assert.Equal(t, []string{
+		"com.example.a.push-type.liveactivity",
+		"com.example.b.push-type.liveactivity",
+	}, activity.Topics)
+
+	// the app that got the activity tells which one it is
+	_, err = RegisterLiveActivityToken(&RequestLiveActivity{ActivityID: "order-several", Token: "update-b"})
+	assert.EqualError(t, err, "the topic cannot be empty")
+
+	activity, err = RegisterLiveActivityToken(&RequestLiveActivity{
+		ActivityID: "order-several", Token: "update-b", Topic: "com.example.b",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "com.example.b.push-type.liveactivity", activity.Topic)
+}
+
+func TestEndLiveActivityRejected(t *testing.T) {
+	requests, closeServer := newTestAPNsServer(t)
+	defer closeServer()
+
+	cfg, _ := config.LoadConf()
+	ctx := context.Background()
+
+	_, err := RegisterLiveActivityToken(&RequestLiveActivity{
+		ActivityID: "order-rejected", Token: "bad-token", Topic: "com.example.app",
+	})
+	assert.NoError(t, err)
+
+	resp, err := EndLiveActivity(ctx, &RequestLiveActivity{ActivityID: "order-rejected"}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, *requests, 1)
+	assert.Len(t, resp.Logs, 1)
+
+	// the activity is still running on the device
+	activity, err := GetLiveActivity("order-rejected")
+	assert.NoError(t, err)
+	assert.Equal(t, LiveActivityActive, activity.State)
+}
+
+func TestEndLiveActivityUnavailable(t *testing.T) {
+	requests, closeServer := newTestAPNsServer(t)
+	defer closeServer()
+
+	q, received := newTestQueue(t)
+	RetryQueue = q
+	t.Cleanup(func() { RetryQueue = nil })
+
+	cfg, _ := config.LoadConf()
+	cfg.Ios.MaxRetry = 1
+	cfg.Core.Retry.Backoff = 0
+	ctx := context.Background()
+
+	_, err := RegisterLiveActivityToken(&RequestLiveActivity{
+		ActivityID: "order-unavailable", Token: "down-token", Topic: "com.example.app",
+	})
+	assert.NoError(t, err)
+
+	// the retry is done in place, never through the queue
+	resp, err := EndLiveActivity(ctx, &RequestLiveActivity{ActivityID: "order-unavailable"}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, *requests, 2)
+	assert.NotEmpty(t, resp.Logs)
+	assert.Empty(t, received)
+
+	activity, err := GetLiveActivity("order-unavailable")
+	assert.NoError(t, err)
+	assert.Equal(t, LiveActivityActive, activity.State)
+}
@@ -42,7 +42,7 @@ func TestDisabledAndroidIosConf(t *testing.T) {
 	err := CheckPushConf(cfg)
 
 	assert.Error(t, err)
-	assert.Equal(t, "please enable iOS, Android or Huawei config in yml config", err.Error())
+	assert.Equal(t, "please enable iOS, Android, Huawei, Web Push or SMS config in yml config", err.Error())
 }
 
 func TestMissingIOSCertificate(t *testing.T) {
@@ -790,6 +790,8 @@ func TestApnsHostFromRequest(t *testing.T) {
 	}
 	client := getApnsClient(cfg, req)
 	assert.Equal(t, apns2.HostProduction, client.Host)
+	// the shared client keeps the host of the config
+	assert.Equal(t, apns2.HostDevelopment, ApnsClient.Host)
 
 	req = &PushNotification{
 		Development: true,
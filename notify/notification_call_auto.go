@@ -44,6 +44,7 @@ func SendTelphinCall(req *PushNotification, cfg *config.ConfYaml) {
 		request.Header.Set("content-type", "application/json")
 
 		response, err := http.DefaultClient.Do(request)
+		recordProviderCall(ProviderTelphin, providerCallFailed(response, err))
 		if err != nil {
 			logx.LogAccess.Errorf("| TELPHIN CALL ERROR | ERROR: %v | RESPONSE: %v", err, response)
 			return
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/appleboy/gorush/config"
 	"github.com/appleboy/gorush/core"
@@ -27,6 +29,11 @@ func fileExists(filename string) bool {
 func InitFCMClient(ctx context.Context, cfg *config.ConfYaml) (*fcm.Client, error) {
 	var opts []fcm.Option
 
+	// the client may also be the fake one of mock mode, without credentials
+	if FCMClient != nil {
+		return FCMClient, nil
+	}
+
 	credential := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
 	if cfg.Android.Credential == "" &&
 		cfg.Android.KeyPath == "" &&
@@ -42,8 +49,21 @@ func InitFCMClient(ctx context.Context, cfg *config.ConfYaml) (*fcm.Client, erro
 		opts = append(opts, fcm.WithCredentialsJSON([]byte(cfg.Android.Credential)))
 	}
 
-	if FCMClient != nil {
-		return FCMClient, nil
+	if cfg.Android.CAPath != "" {
+		transport, err := newCATransport(cfg.Android.CAPath)
+		if err != nil {
+			return nil, err
+		}
+		// the custom client is only authenticated with credential data, the
+		// file of GOOGLE_APPLICATION_CREDENTIALS is read when nothing else is set
+		if len(opts) == 0 {
+			opts = append(opts, fcm.WithCredentialsFile(credential))
+		}
+		opts = append(opts, fcm.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+
+	if cfg.Android.Endpoint != "" {
+		opts = append(opts, fcm.WithEndpoint(cfg.Android.Endpoint))
 	}
 
 	var err error
@@ -203,8 +223,9 @@ func PushToAndroid(ctx context.Context, req *PushNotification, cfg *config.ConfY
 
 	var (
 		client     *fcm.Client
-		retryCount = 0
+		retryCount = req.RetryAttempt
 		maxRetry   = cfg.Android.MaxRetry
+		dryRun     = req.IsDryRun(cfg)
 	)
 
 	if req.Retry > 0 && req.Retry < maxRetry {
@@ -236,22 +257,27 @@ Retry:
 		}
 	}
 
-	res, err := client.Send(ctx, messages...)
+	res, err := sendAndroidMessages(ctx, client, messages, cfg, dryRun)
 	if err != nil {
-		newErr := fmt.Errorf("fcm service send message error: %v", err)
+		newErr := fmt.Errorf("fcm service send message error: %w", err)
 		logx.LogError.Error(newErr)
 		errLog := logPush(cfg, core.FailedPush, "", req, newErr)
 		resp.Logs = append(resp.Logs, errLog)
-		status.StatStorage.AddAndroidError(1)
+		if !dryRun {
+			status.StatStorage.AddAndroidError(1)
+		}
 
 		return resp, newErr
 	}
 
 	logx.LogAccess.Debug(fmt.Sprintf("Android Success count: %d, Failure count: %d", res.SuccessCount, res.FailureCount))
-	status.StatStorage.AddAndroidSuccess(int64(res.SuccessCount))
-	status.StatStorage.AddAndroidError(int64(res.FailureCount))
+	if !dryRun {
+		status.StatStorage.AddAndroidSuccess(int64(res.SuccessCount))
+		status.StatStorage.AddAndroidError(int64(res.FailureCount))
+	}
 
 	// result from Send messages to topics
+	var hint time.Duration
 	retryTopic := false
 	if req.IsTopic() {
 		to := ""
@@ -265,14 +291,17 @@ Retry:
 
 		newResp := res.Responses[0]
 		if newResp.Success {
-			logPush(cfg, core.SucceededPush, to, req, nil)
+			succeededLog := logPushID(cfg, core.SucceededPush, to, newResp.MessageID, req, nil)
+			if dryRun {
+				resp.Logs = append(resp.Logs, succeededLog)
+			}
 		}
 
 		if newResp.Error != nil {
 			// failure
 			errLog := logPush(cfg, core.FailedPush, to, req, newResp.Error)
 			resp.Logs = append(resp.Logs, errLog)
-			retryTopic = true
+			retryTopic, hint = retryFCM(newResp.Error)
 		}
 
 		// remove the first response
@@ -284,13 +313,23 @@ Retry:
 		if result.Error != nil {
 			errLog := logPush(cfg, core.FailedPush, req.Tokens[k], req, result.Error)
 			resp.Logs = append(resp.Logs, errLog)
-			newTokens = append(newTokens, req.Tokens[k])
+			// permanent errors such as unregistered tokens are never retried
+			if retryable, delay := retryFCM(result.Error); retryable {
+				newTokens = append(newTokens, req.Tokens[k])
+				if delay > hint {
+					hint = delay
+				}
+			}
 			continue
 		}
-		logPush(cfg, core.SucceededPush, req.Tokens[k], req, nil)
+		succeededLog := logPushID(cfg, core.SucceededPush, req.Tokens[k], result.MessageID, req, nil)
+		// a dry run reports the result of every token
+		if dryRun {
+			resp.Logs = append(resp.Logs, succeededLog)
+		}
 	}
 
-	if len(newTokens) > 0 && retryCount < maxRetry {
+	if (len(newTokens) > 0 || retryTopic) && retryCount < maxRetry {
 		retryCount++
 
 		if req.IsTopic() && !retryTopic {
@@ -300,22 +339,137 @@ Retry:
 
 		// resend fail token
 		req.Tokens = newTokens
+		queued, err := scheduleRetry(ctx, cfg, req, retryCount, hint)
+		if err != nil || queued {
+			return resp, err
+		}
 		goto Retry
 	}
 
+	if (len(newTokens) > 0 || retryTopic) && !dryRun {
+		letter := *req
+		if !retryTopic {
+			letter.Topic = ""
+			letter.Condition = ""
+		}
+		letter.Tokens = newTokens
+		letter.RetryAttempt = retryCount
+		addRetriedDeadLetter(cfg, &letter, resp)
+	}
+
 	return resp, nil
 }
 
+// sendAndroidMessages sends the messages in provider-sized batches, running at
+// most android.max_concurrent_batches batches at once, and stitches the
+// responses back together in the order of messages. A dry run only validates
+// the messages.
+func sendAndroidMessages(
+	ctx context.Context,
+	client *fcm.Client,
+	messages []*messaging.Message,
+	cfg *config.ConfYaml,
+	dryRun bool,
+) (*messaging.BatchResponse, error) {
+	sendFn := client.Send
+	if dryRun {
+		sendFn = client.SendDryRun
+	}
+	send := func(ctx context.Context, messages ...*messaging.Message) (*messaging.BatchResponse, error) {
+		res, err := sendFn(ctx, messages...)
+		recordProviderCall(ProviderFCM, fcmCallFailed(res, err))
+		return res, err
+	}
+
+	batches := chunkSlice(messages, MaxAndroidBatchSize)
+	if len(batches) == 1 {
+		return send(ctx, messages...)
+	}
+
+	results := make([]*messaging.BatchResponse, len(batches))
+	runBatches(len(batches), cfg.Android.MaxConcurrentBatches, func(i int) {
+		res, err := send(ctx, batches[i]...)
+		if err != nil {
+			err = fmt.Errorf("fcm service send message error: %v", err)
+			logx.LogError.Error(err)
+			res = failedBatchResponse(len(batches[i]), err)
+		}
+		results[i] = res
+	})
+
+	return mergeBatchResponses(results), nil
+}
+
+// fcmCallFailed reports whether a batch failed because FCM is unavailable,
+// which is when no message of the batch went through for a retryable error.
+func fcmCallFailed(res *messaging.BatchResponse, err error) bool {
+	if err != nil {
+		return true
+	}
+	if res == nil || res.SuccessCount > 0 {
+		return false
+	}
+	for _, r := range res.Responses {
+		if retryable, _ := retryFCM(r.Error); retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// failedBatchResponse marks every message of a batch as failed with err.
+func failedBatchResponse(size int, err error) *messaging.BatchResponse {
+	res := &messaging.BatchResponse{
+		FailureCount: size,
+		Responses:    make([]*messaging.SendResponse, size),
+	}
+	for i := range res.Responses {
+		res.Responses[i] = &messaging.SendResponse{Error: err}
+	}
+	return res
+}
+
+// mergeBatchResponses concatenates the batch responses in order.
+func mergeBatchResponses(results []*messaging.BatchResponse) *messaging.BatchResponse {
+	res := &messaging.BatchResponse{}
+	for _, r := range results {
+		res.SuccessCount += r.SuccessCount
+		res.FailureCount += r.FailureCount
+		res.Responses = append(res.Responses, r.Responses...)
+	}
+	return res
+}
+
 func logPush(cfg *config.ConfYaml, status, token string, req *PushNotification, err error) logx.LogPushEntry {
-	return logx.LogPush(&logx.InputLog{
+	return logPushID(cfg, status, token, "", req, err)
+}
+
+// logPushID logs the result like logPush and records it in the delivery status
+// of the notification with the ID the provider gave to the message.
+func logPushID(
+	cfg *config.ConfYaml,
+	status, token, providerID string,
+	req *PushNotification,
+	err error,
+) logx.LogPushEntry {
+	entry := logx.LogPush(pushLogInput(cfg, status, token, req, err))
+	recordTokenStatus(cfg, req, token, providerID, entry)
+	countPushError(cfg, req, entry)
+	return entry
+}
+
+func pushLogInput(cfg *config.ConfYaml, status, token string, req *PushNotification, err error) *logx.InputLog {
+	return &logx.InputLog{
 		ID:          req.ID,
 		Status:      status,
 		Token:       token,
 		Message:     req.Message,
 		Platform:    req.Platform,
 		Error:       err,
+		Code:        ErrorCode(err),
 		HideToken:   cfg.Log.HideToken,
 		HideMessage: cfg.Log.HideMessages,
 		Format:      cfg.Log.Format,
-	})
+		Variant:     req.Variant,
+	}
 }
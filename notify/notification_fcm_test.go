@@ -126,7 +126,7 @@ func TestFCMMessage(t *testing.T) {
 	err = CheckMessage(req)
 	assert.NoError(t, err)
 
-	// the message may specify at most 1000 registration IDs
+	// large token lists are split into batches when sending
 	req = &PushNotification{
 		Message:  "Test",
 		Platform: core.PlatformAndroid,
@@ -134,7 +134,7 @@ func TestFCMMessage(t *testing.T) {
 	}
 
 	err = CheckMessage(req)
-	assert.Error(t, err)
+	assert.NoError(t, err)
 
 	// Pass
 	req = &PushNotification{
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/appleboy/go-fcm"
+)
+
+// MaxTopicTokens is the maximum number of registration tokens FCM accepts
+// in a single topic management call.
+// ref: https://firebase.google.com/docs/cloud-messaging/manage-topics
+const MaxTopicTokens = 1000
+
+// RequestTopic is the request body of the topic subscription endpoints.
+type RequestTopic struct {
+	Topic  string   `json:"topic" binding:"required"`
+	Tokens []string `json:"tokens" binding:"required"`
+}
+
+// CheckTopicMessage for check topic management request.
+func CheckTopicMessage(req *RequestTopic) error {
+	var msg string
+
+	switch {
+	case req.Topic == "":
+		msg = "the topic cannot be empty"
+	case len(req.Tokens) == 0:
+		msg = "please provide at least one device token"
+	case len(req.Tokens) > MaxTopicTokens:
+		msg = fmt.Sprintf("you can specify up to %d device registration tokens per invocation", MaxTopicTokens)
+	default:
+		return nil
+	}
+
+	logx.LogAccess.Debug(msg)
+	return errors.New(msg)
+}
+
+type topicManageFunc func(
+	client *fcm.Client,
+	ctx context.Context,
+	tokens []string,
+	topic string,
+) (*messaging.TopicManagementResponse, error)
+
+// SubscribeTopic subscribes the FCM registration tokens to the topic.
+func SubscribeTopic(ctx context.Context, req *RequestTopic, cfg *config.ConfYaml) (*ResponsePush, error) {
+	logx.LogAccess.Debug("Start subscribe tokens to topic: ", req.Topic)
+	return manageTopic(ctx, req, cfg, (*fcm.Client).SubscribeTopic)
+}
+
+// UnsubscribeTopic unsubscribes the FCM registration tokens from the topic.
+func UnsubscribeTopic(ctx context.Context, req *RequestTopic, cfg *config.ConfYaml) (*ResponsePush, error) {
+	logx.LogAccess.Debug("Start unsubscribe tokens from topic: ", req.Topic)
+	return manageTopic(ctx, req, cfg, (*fcm.Client).UnsubscribeTopic)
+}
+
+func manageTopic(
+	ctx context.Context,
+	req *RequestTopic,
+	cfg *config.ConfYaml,
+	fn topicManageFunc,
+) (*ResponsePush, error) {
+	if err := CheckTopicMessage(req); err != nil {
+		logx.LogError.Error("request error: " + err.Error())
+		return nil, err
+	}
+
+	client, err := InitFCMClient(ctx, cfg)
+	if err != nil {
+		logx.LogError.Error("FCM server error: " + err.Error())
+		return nil, err
+	}
+
+	resp := &ResponsePush{}
+	res, err := fn(client, ctx, req.Tokens, req.Topic)
+	if err != nil {
+		newErr := fmt.Errorf("fcm service manage topic error: %v", err)
+		logx.LogError.Error(newErr)
+		for _, token := range req.Tokens {
+			resp.Logs = append(resp.Logs, logTopic(cfg, core.FailedPush, token, req, newErr))
+		}
+		return resp, newErr
+	}
+
+	logx.LogAccess.Debug(fmt.Sprintf("Topic %s success count: %d, failure count: %d",
+		req.Topic, res.SuccessCount, res.FailureCount))
+
+	for _, e := range res.Errors {
+		if e == nil || e.Index < 0 || e.Index >= len(req.Tokens) {
+			continue
+		}
+		errLog := logTopic(cfg, core.FailedPush, req.Tokens[e.Index], req, errors.New(e.Reason))
+		resp.Logs = append(resp.Logs, errLog)
+	}
+
+	return resp, nil
+}
+
+func logTopic(cfg *config.ConfYaml, status, token string, req *RequestTopic, err error) logx.LogPushEntry {
+	return logx.LogPush(&logx.InputLog{
+		Status:    status,
+		Token:     token,
+		Message:   req.Topic,
+		Platform:  core.PlatformAndroid,
+		Error:     err,
+		HideToken: cfg.Log.HideToken,
+		Format:    cfg.Log.Format,
+	})
+}
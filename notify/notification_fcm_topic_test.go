@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/appleboy/gorush/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTopicMessage(t *testing.T) {
+	tokens := make([]string, MaxTopicTokens+1)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("token-%d", i)
+	}
+
+	tests := []struct {
+		name string
+		req  *RequestTopic
+		err  string
+	}{
+		{"empty topic", &RequestTopic{Tokens: []string{"a"}}, "the topic cannot be empty"},
+		{"empty tokens", &RequestTopic{Topic: "news"}, "please provide at least one device token"},
+		{
+			"too many tokens",
+			&RequestTopic{Topic: "news", Tokens: tokens},
+			"you can specify up to 1000 device registration tokens per invocation",
+		},
+		{"valid", &RequestTopic{Topic: "news", Tokens: tokens[:MaxTopicTokens]}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTopicMessage(tt.req)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestSubscribeTopicWithoutTokens(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	resp, err := SubscribeTopic(context.Background(), &RequestTopic{Topic: "news"}, cfg)
+	assert.Nil(t, resp)
+	assert.EqualError(t, err, "please provide at least one device token")
+
+	resp, err = UnsubscribeTopic(context.Background(), &RequestTopic{Topic: "news"}, cfg)
+	assert.Nil(t, resp)
+	assert.EqualError(t, err, "please provide at least one device token")
+}
@@ -52,6 +52,13 @@ func InitHMSClient(cfg *config.ConfYaml, appSecret, appID string) (*client.HMSCl
 		PushUrl:   "https://push-api.cloud.huawei.com",
 	}
 
+	if cfg.Huawei.AuthURL != "" {
+		conf.AuthUrl = cfg.Huawei.AuthURL
+	}
+	if cfg.Huawei.PushURL != "" {
+		conf.PushUrl = cfg.Huawei.PushURL
+	}
+
 	if appSecret != cfg.Huawei.AppSecret || appID != cfg.Huawei.AppID {
 		return GetPushClient(conf)
 	}
@@ -165,6 +172,8 @@ func PushToHuawei(ctx context.Context, req *PushNotification, cfg *config.ConfYa
 		client     *client.HMSClient
 		retryCount = 0
 		maxRetry   = cfg.Huawei.MaxRetry
+		sendErr    error
+		dryRun     = req.IsDryRun(cfg)
 	)
 
 	if req.Retry > 0 && req.Retry < maxRetry {
@@ -188,35 +197,144 @@ func PushToHuawei(ctx context.Context, req *PushNotification, cfg *config.ConfYa
 	resp = &ResponsePush{}
 
 Retry:
-	isError := false
+	var (
+		newTokens []string
+		isError   bool
+	)
 
 	notification, _ := GetHuaweiNotification(req)
+	notification.ValidateOnly = dryRun
+	batches := splitHuaweiNotification(notification)
+
+	results := make([]*model.MessageResponse, len(batches))
+	errs := make([]error, len(batches))
+	runBatches(len(batches), cfg.Huawei.MaxConcurrentBatches, func(i int) {
+		results[i], errs[i] = client.SendMessage(ctx, batches[i])
+		recordProviderCall(ProviderHMS, errs[i] != nil)
+	})
+
+	for i, batch := range batches {
+		if errs[i] != nil {
+			// Send Message error
+			sendErr = transportError(errs[i])
+			logx.LogError.Error("HMS server send message error: " + sendErr.Error())
+			if len(batch.Message.Token) == 0 {
+				resp.Logs = append(resp.Logs, logPush(cfg, core.FailedPush, req.Topic, req, sendErr))
+			}
+			for _, token := range batch.Message.Token {
+				resp.Logs = append(resp.Logs, logPush(cfg, core.FailedPush, token, req, sendErr))
+			}
+			continue
+		}
+
+		// Huawei Push Send API does not support exact results for each token
+		if results[i].Code == "80000000" {
+			if dryRun {
+				resp.Logs = append(resp.Logs, logHuaweiDryRun(cfg, core.SucceededPush, batch, req, nil)...)
+				continue
+			}
+			status.StatStorage.AddHuaweiSuccess(int64(1))
+			recordHuaweiBatch(cfg, core.SucceededPush, batch, req, results[i].RequestId, nil)
+			logx.LogAccess.Debug("Huwaei Send Notification is completed successfully!")
+			continue
+		}
+
+		if dryRun {
+			err := hmsError(results[i].Code, results[i].Msg)
+			resp.Logs = append(resp.Logs, logHuaweiDryRun(cfg, core.FailedPush, batch, req, err)...)
+			continue
+		}
 
-	res, err := client.SendMessage(ctx, notification)
-	if err != nil {
-		// Send Message error
-		errLog := logPush(cfg, core.FailedPush, req.Topic, req, err)
-		resp.Logs = append(resp.Logs, errLog)
-		logx.LogError.Error("HMS server send message error: " + err.Error())
-		return resp, err
-	}
-
-	// Huawei Push Send API does not support exact results for each token
-	if res.Code == "80000000" {
-		status.StatStorage.AddHuaweiSuccess(int64(1))
-		logx.LogAccess.Debug("Huwaei Send Notification is completed successfully!")
-	} else {
 		isError = true
+		newTokens = append(newTokens, batch.Message.Token...)
 		status.StatStorage.AddHuaweiError(int64(1))
-		logx.LogAccess.Debug("Huawei Send Notification is failed! Code: " + res.Code)
+		recordHuaweiBatch(cfg, core.FailedPush, batch, req, results[i].RequestId,
+			hmsError(results[i].Code, results[i].Msg))
+		logx.LogAccess.Debug("Huawei Send Notification is failed! Code: " + results[i].Code)
 	}
 
 	if isError && retryCount < maxRetry {
 		retryCount++
 
-		// resend all tokens
+		// resend all tokens of the failed batches
+		if len(batches) > 1 {
+			req.Tokens = newTokens
+		}
+		req.retried += messageCount(req)
 		goto Retry
 	}
 
-	return resp, nil
+	if isError {
+		letter := *req
+		if len(batches) > 1 {
+			letter.Tokens = newTokens
+		}
+		letter.RetryAttempt = retryCount
+		addRetriedDeadLetter(cfg, &letter, resp)
+	}
+
+	return resp, sendErr
+}
+
+// splitHuaweiNotification splits the message into provider-sized token batches,
+// sharing everything but the token list. Only the first batch keeps the topic
+// and condition of the original message.
+func splitHuaweiNotification(notification *model.MessageRequest) []*model.MessageRequest {
+	tokens := chunkSlice(notification.Message.Token, MaxHuaweiBatchSize)
+	if len(tokens) == 1 {
+		return []*model.MessageRequest{notification}
+	}
+
+	batches := make([]*model.MessageRequest, 0, len(tokens))
+	for i, chunk := range tokens {
+		message := *notification.Message
+		message.Token = chunk
+		if i > 0 {
+			message.Topic = ""
+			message.Condition = ""
+		}
+		batch := *notification
+		batch.Message = &message
+		batches = append(batches, &batch)
+	}
+
+	return batches
+}
+
+// recordHuaweiBatch records the result of a batch in the delivery status of
+// each of its tokens, the batch is only logged as a whole.
+func recordHuaweiBatch(
+	cfg *config.ConfYaml,
+	state string,
+	batch *model.MessageRequest,
+	req *PushNotification,
+	requestID string,
+	err error,
+) {
+	for _, token := range batch.Message.Token {
+		entry := logx.GetLogPushEntry(pushLogInput(cfg, state, token, req, err))
+		recordTokenStatus(cfg, req, token, requestID, entry)
+		countPushError(cfg, req, entry)
+	}
+}
+
+// logHuaweiDryRun logs the validation result of a batch for each of its
+// tokens, or for the topic of a batch without token.
+func logHuaweiDryRun(
+	cfg *config.ConfYaml,
+	state string,
+	batch *model.MessageRequest,
+	req *PushNotification,
+	err error,
+) []logx.LogPushEntry {
+	targets := batch.Message.Token
+	if len(targets) == 0 {
+		targets = []string{req.Topic}
+	}
+
+	logs := make([]logx.LogPushEntry, 0, len(targets))
+	for _, target := range targets {
+		logs = append(logs, logPush(cfg, state, target, req, err))
+	}
+	return logs
 }
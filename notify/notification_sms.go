@@ -61,6 +61,12 @@ func SendRUSMS(req *PushNotification, cfg *config.ConfYaml, index int) {
 		return
 	}
 
+	// also reached by the Telegram fallback, which has no gate of its own
+	if !providerReady(cfg, ProviderSMS) {
+		logx.LogError.Error("can't send SMS: " + ErrCircuitOpen.Error())
+		return
+	}
+
 	var sendSMS func(phoneNumber string, req *PushNotification, cfg config.SectionSMS) bool
 
 	switch cfg.SMS.Provider {
@@ -121,7 +127,7 @@ func sendViaMTS(phoneNumber string, req *PushNotification, cfg config.SectionSMS
 	}
 
 	authKey := fmt.Sprintf("Bearer %s", cfg.MTSApiKey)
-	return sendSMS(cfg.MTSApiURL, authKey, phoneNumber, payload)
+	return sendSMS(cfg, cfg.MTSApiURL, authKey, phoneNumber, payload)
 }
 
 func sendViaDevinoV2(phoneNumber string, req *PushNotification, cfg config.SectionSMS) bool {
@@ -143,10 +149,10 @@ func sendViaDevinoV2(phoneNumber string, req *PushNotification, cfg config.Secti
 	}
 
 	authKey := fmt.Sprintf("Key %s", cfg.DevinoApiKey)
-	return sendSMS(cfg.DevinoApiURLV2, authKey, phoneNumber, payload)
+	return sendSMS(cfg, cfg.DevinoApiURLV2, authKey, phoneNumber, payload)
 }
 
-func sendSMS(url, authKey, phoneNumber string, payload any) bool {
+func sendSMS(cfg config.SectionSMS, url, authKey, phoneNumber string, payload any) bool {
 	logx.LogAccess.Debugf("Start push notification via SMS, url: %s", url)
 
 	jsonBody, err := json.Marshal(payload)
@@ -165,7 +171,7 @@ func sendSMS(url, authKey, phoneNumber string, payload any) bool {
 	request.Header.Set("Authorization", authKey)
 	request.Header.Set("Content-Type", "application/json")
 
-	response, err := http.DefaultClient.Do(request)
+	response, err := doSMSRequest(cfg, request)
 	if err != nil {
 		logx.LogError.Error(err)
 		return false
@@ -184,6 +190,18 @@ func sendSMS(url, authKey, phoneNumber string, payload any) bool {
 	return true
 }
 
+// doSMSRequest sends the request to the SMS provider, trusting the CA bundle
+// of sms.ca_path when set.
+func doSMSRequest(cfg config.SectionSMS, request *http.Request) (*http.Response, error) {
+	client, err := providerHTTPClient(cfg.CAPath)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	recordProviderCall(ProviderSMS, providerCallFailed(response, err))
+	return response, err
+}
+
 func sendViaDevinoV1(phoneNumber string, req *PushNotification, cfg config.SectionSMS) bool {
 	if !isValidPhonePrefix(phoneNumber) {
 		logx.LogAccess.Debugf(
@@ -207,7 +225,7 @@ func sendViaDevinoV1(phoneNumber string, req *PushNotification, cfg config.Secti
 
 	request.Header.Set("content-type", "application/x-www-form-urlencoded")
 
-	response, err := http.DefaultClient.Do(request)
+	response, err := doSMSRequest(cfg, request)
 	if err != nil {
 		logx.LogError.Error(err)
 		return false
@@ -239,7 +257,7 @@ func getDevinoSessionID(cfg config.SectionSMS) string {
 
 	request.Header.Set("content-type", "application/x-www-form-urlencoded")
 
-	response, err := http.DefaultClient.Do(request)
+	response, err := doSMSRequest(cfg, request)
 	if err != nil {
 		logx.LogError.Error(err)
 		return ""
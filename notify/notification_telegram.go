@@ -65,7 +65,20 @@ func sendTelegramGateway(cfg *config.ConfYaml, phoneNumber, code string) (string
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.TelegramGateway.ApiToken))
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	// fall back to SMS while the gateway keeps failing
+	if !providerReady(cfg, ProviderTelegram) {
+		logx.LogError.Error("can't send Telegram gateway message: " + ErrCircuitOpen.Error())
+		return "", false
+	}
+
+	client, err := providerHTTPClient(cfg.TelegramGateway.CAPath)
+	if err != nil {
+		logx.LogError.Error(err)
+		return "", false
+	}
+
+	resp, err := (&http.Client{Transport: client.Transport, Timeout: 10 * time.Second}).Do(req)
+	recordProviderCall(ProviderTelegram, providerCallFailed(resp, err))
 	if err != nil {
 		logx.LogError.Error(err)
 		return "", false
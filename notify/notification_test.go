@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,3 +34,25 @@ func TestSetProxyURL(t *testing.T) {
 	err = SetProxy("http://87.236.233.92:8080")
 	assert.NoError(t, err)
 }
+
+func TestCheckNotification(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	req := &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}
+	assert.NoError(t, CheckNotification(cfg, req))
+
+	req.Lane = "urgent"
+	assert.ErrorIs(t, CheckNotification(cfg, req), ErrInvalidLane)
+
+	req.Lane = ""
+	req.Template = "missing"
+	assert.ErrorIs(t, CheckNotification(cfg, req), ErrTemplateNotFound)
+
+	req.Template = ""
+	req.CampaignID = "missing"
+	assert.ErrorIs(t, CheckNotification(cfg, req), ErrCampaignNotFound)
+}
@@ -0,0 +1,511 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// webPushRecordSize is the record size of the aes128gcm content coding.
+	// The whole message is sent as a single record.
+	webPushRecordSize = 4096
+
+	// webPushHeaderSize is salt (16) + record size (4) + key id length (1)
+	// + uncompressed P-256 public key (65).
+	webPushHeaderSize = 86
+
+	// MaxWebPushPayloadSize is the largest payload push services must accept
+	// once the encryption header, padding delimiter and AEAD tag are added.
+	// ref: https://datatracker.ietf.org/doc/html/rfc8291#section-4
+	MaxWebPushPayloadSize = webPushRecordSize - webPushHeaderSize - 1 - 16
+
+	// maxConcurrentWebPushes limits the number of requests sent to push
+	// services at the same time for one notification.
+	maxConcurrentWebPushes = 20
+)
+
+// ErrInvalidSubscription is returned when the push service reports the
+// subscription as expired or unknown, it must not be used again.
+var ErrInvalidSubscription = errors.New("web push subscription is no longer valid")
+
+// WebPushSubscription is the browser PushSubscription, in the format
+// returned by PushSubscription.toJSON().
+type WebPushSubscription struct {
+	Endpoint string         `json:"endpoint"`
+	Keys     WebPushKeysSet `json:"keys"`
+}
+
+// WebPushKeysSet holds the base64url encoded client keys of a subscription.
+type WebPushKeysSet struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// webPushPayload is the message handed to the service worker push event.
+type webPushPayload struct {
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message,omitempty"`
+	Image   string `json:"image,omitempty"`
+	Data    D      `json:"data,omitempty"`
+}
+
+// VAPIDClient sends Web Push messages signed with the application server key.
+// ref: https://datatracker.ietf.org/doc/html/rfc8292
+type VAPIDClient struct {
+	HTTPClient *http.Client
+
+	privateKey *ecdsa.PrivateKey
+	publicKey  string
+	subject    string
+}
+
+// NewVAPIDClient creates a client from the base64url encoded P-256 private key.
+func NewVAPIDClient(privateKey, subject string) (*VAPIDClient, error) {
+	raw, err := decodeBase64URL(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid private key: %w", err)
+	}
+
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid private key: %w", err)
+	}
+
+	// uncompressed point: 0x04 || X || Y
+	pub := key.PublicKey().Bytes()
+
+	return &VAPIDClient{
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:]),
+			},
+			D: new(big.Int).SetBytes(raw),
+		},
+		publicKey: base64.RawURLEncoding.EncodeToString(pub),
+		subject:   subject,
+	}, nil
+}
+
+// PublicKey returns the base64url encoded application server key, the value
+// browsers expect as applicationServerKey when subscribing.
+func (c *VAPIDClient) PublicKey() string {
+	return c.publicKey
+}
+
+// InitWebPushClient use for initialize Web Push client.
+func InitWebPushClient(cfg *config.ConfYaml) (*VAPIDClient, error) {
+	if WebPushClient != nil {
+		return WebPushClient, nil
+	}
+
+	if cfg.WebPush.VAPIDPrivateKey == "" {
+		return nil, errors.New("missing web push vapid private key")
+	}
+
+	client, err := NewVAPIDClient(cfg.WebPush.VAPIDPrivateKey, cfg.WebPush.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	WebPushClient = client
+
+	return WebPushClient, nil
+}
+
+// webPushOptions are the per message headers of the push service request.
+// ref: https://datatracker.ietf.org/doc/html/rfc8030#section-5
+type webPushOptions struct {
+	TTL     int
+	Urgency string
+	Topic   string
+	DryRun  bool
+}
+
+// Send encrypts the payload for the subscription and posts it to the push
+// service. The response body is already closed when it is returned.
+func (c *VAPIDClient) Send(
+	ctx context.Context,
+	sub *WebPushSubscription,
+	payload []byte,
+	opts webPushOptions,
+) (*http.Response, error) {
+	body, err := encryptWebPush(sub, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	authorization, err := c.authorization(sub.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	// push services have no validate-only mode, a dry run stops once the
+	// payload is encrypted and the request signed
+	if opts.DryRun {
+		return nil, nil
+	}
+
+	httpReq.Header.Set("Authorization", authorization)
+	httpReq.Header.Set("Content-Encoding", "aes128gcm")
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+	httpReq.Header.Set("TTL", strconv.Itoa(opts.TTL))
+	if opts.Urgency != "" {
+		httpReq.Header.Set("Urgency", opts.Urgency)
+	}
+	if opts.Topic != "" {
+		httpReq.Header.Set("Topic", opts.Topic)
+	}
+
+	res, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		err = fmt.Errorf("push service returned %d %s", res.StatusCode, http.StatusText(res.StatusCode))
+		if msg, _ := io.ReadAll(io.LimitReader(res.Body, 512)); len(bytes.TrimSpace(msg)) > 0 {
+			err = fmt.Errorf("%w: %s", err, bytes.TrimSpace(msg))
+		}
+		return res, err
+	}
+
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	return res, nil
+}
+
+// authorization builds the vapid Authorization header for the push service
+// origin of the endpoint.
+func (c *VAPIDClient) authorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub,omitempty"`
+	}{
+		Aud: u.Scheme + "://" + u.Host,
+		Exp: time.Now().Add(12 * time.Hour).Unix(),
+		Sub: c.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`)) +
+		"." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, c.privateKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// ES256 signatures are the fixed size concatenation of r and s
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	return fmt.Sprintf("vapid t=%s.%s, k=%s",
+		unsigned, base64.RawURLEncoding.EncodeToString(sig), c.publicKey), nil
+}
+
+// encryptWebPush encrypts the payload with the aes128gcm content coding
+// using the subscription keys.
+// ref: https://datatracker.ietf.org/doc/html/rfc8291#section-3.4
+func encryptWebPush(sub *WebPushSubscription, payload []byte) ([]byte, error) {
+	if len(payload) > MaxWebPushPayloadSize {
+		return nil, fmt.Errorf("web push payload is %d bytes, over the %d bytes limit",
+			len(payload), MaxWebPushPayloadSize)
+	}
+
+	uaPublic, err := decodeBase64URL(sub.Keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+
+	authSecret, err := decodeBase64URL(sub.Keys.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %w", err)
+	}
+
+	curve := ecdh.P256()
+	uaKey, err := curve.NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+
+	asKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	sharedSecret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	// key_info = "WebPush: info" || 0x00 || ua_public || as_public
+	keyInfo := make([]byte, 0, 14+len(uaPublic)+len(asPublic))
+	keyInfo = append(keyInfo, "WebPush: info\x00"...)
+	keyInfo = append(keyInfo, uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+
+	ikm, err := hkdfDerive(sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, err := hkdfDerive(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := hkdfDerive(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// header: salt || rs || idlen || keyid
+	header := make([]byte, 0, webPushHeaderSize+len(payload)+1+gcm.Overhead())
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	// the single, and so last, record ends with the 0x02 padding delimiter
+	plaintext := make([]byte, 0, len(payload)+1)
+	plaintext = append(plaintext, payload...)
+	plaintext = append(plaintext, 0x02)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+func hkdfDerive(secret, salt, info []byte, size int) ([]byte, error) {
+	key := make([]byte, size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// decodeBase64URL accepts the padded and unpadded base64url encodings
+// browsers and key generators use.
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// checkWebPushSubscriptions for check the subscriptions of a web push request.
+func checkWebPushSubscriptions(subs []WebPushSubscription) error {
+	if len(subs) == 0 {
+		return errors.New("please provide at least one web push subscription")
+	}
+
+	for _, sub := range subs {
+		u, err := url.Parse(sub.Endpoint)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid web push endpoint: %q", sub.Endpoint)
+		}
+
+		if sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+			return errors.New("web push subscription keys p256dh and auth are required")
+		}
+	}
+
+	return nil
+}
+
+// GetWebPushPayload returns the JSON payload delivered to the service worker.
+func GetWebPushPayload(req *PushNotification) ([]byte, error) {
+	return json.Marshal(webPushPayload{
+		Title:   req.Title,
+		Message: req.Message,
+		Image:   req.Image,
+		Data:    req.Data,
+	})
+}
+
+// retryWebPush reports whether a push service failure is worth retrying,
+// together with the delay requested by the Retry-After header, if any.
+func retryWebPush(res *http.Response, err error) (bool, time.Duration) {
+	if res == nil {
+		return err != nil && !errors.Is(err, context.Canceled), 0
+	}
+
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError {
+		return true, retryAfter(res)
+	}
+
+	return false, 0
+}
+
+// PushToWebPush provide send notification to browsers through their push service.
+func PushToWebPush(ctx context.Context, req *PushNotification, cfg *config.ConfYaml) (resp *ResponsePush, err error) {
+	logx.LogAccess.Debug("Start push notification for Web Push")
+
+	var (
+		retryCount = req.RetryAttempt
+		maxRetry   = cfg.WebPush.MaxRetry
+	)
+
+	if req.Retry > 0 && req.Retry < maxRetry {
+		maxRetry = req.Retry
+	}
+
+	// check message
+	err = CheckMessage(req)
+	if err != nil {
+		logx.LogError.Error("request error: " + err.Error())
+		return nil, err
+	}
+
+	client, err := InitWebPushClient(cfg)
+	if err != nil {
+		logx.LogError.Error("Web Push client error: " + err.Error())
+		return nil, err
+	}
+
+	payload, err := GetWebPushPayload(req)
+	if err != nil {
+		logx.LogError.Error("Web Push payload error: " + err.Error())
+		return nil, err
+	}
+
+	opts := webPushOptions{
+		TTL:   cfg.WebPush.TTL,
+		Topic: req.CollapseID,
+	}
+	if req.TTL != nil {
+		opts.TTL = *req.TTL
+	}
+	opts.DryRun = req.IsDryRun(cfg)
+	switch req.Priority {
+	case HIGH:
+		opts.Urgency = "high"
+	case "normal":
+		opts.Urgency = "normal"
+	}
+
+	resp = &ResponsePush{}
+
+Retry:
+	var (
+		newSubs []WebPushSubscription
+		hint    time.Duration
+		mu      sync.Mutex
+	)
+
+	subs := req.Subscriptions
+	runBatches(len(subs), maxConcurrentWebPushes, func(i int) {
+		sub := subs[i]
+		res, err := client.Send(ctx, &sub, payload, opts)
+
+		if res != nil && (res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone) {
+			err = fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+		}
+		if err != nil && res == nil {
+			err = transportError(err)
+		} else if err != nil {
+			err = &ProviderError{Code: statusErrorCode(res.StatusCode), Err: err}
+		}
+
+		retryable, delay := retryWebPush(res, err)
+		recordProviderCall(ProviderWebPush, err != nil && retryable)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			logx.LogError.Error("Web Push send error: " + err.Error())
+			resp.Logs = append(resp.Logs, logPush(cfg, core.FailedPush, sub.Endpoint, req, err))
+
+			if retryable {
+				newSubs = append(newSubs, sub)
+				if delay > hint {
+					hint = delay
+				}
+			}
+			return
+		}
+
+		succeededLog := logPush(cfg, core.SucceededPush, sub.Endpoint, req, nil)
+		if opts.DryRun {
+			resp.Logs = append(resp.Logs, succeededLog)
+		}
+	})
+
+	if len(newSubs) > 0 && retryCount < maxRetry {
+		retryCount++
+
+		// resend fail subscriptions
+		req.Subscriptions = newSubs
+		queued, err := scheduleRetry(ctx, cfg, req, retryCount, hint)
+		if err != nil || queued {
+			return resp, err
+		}
+		goto Retry
+	}
+
+	if len(newSubs) > 0 && !opts.DryRun {
+		letter := *req
+		letter.Subscriptions = newSubs
+		letter.RetryAttempt = retryCount
+		addRetriedDeadLetter(cfg, &letter, resp)
+	}
+
+	return resp, nil
+}
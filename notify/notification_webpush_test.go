@@ -0,0 +1,317 @@
+package notify
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBrowser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newTestBrowser(t *testing.T) *testBrowser {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	auth := make([]byte, 16)
+	_, _ = rand.Read(auth)
+
+	return &testBrowser{key: key, auth: auth}
+}
+
+func (b *testBrowser) subscription(endpoint string) WebPushSubscription {
+	return WebPushSubscription{
+		Endpoint: endpoint,
+		Keys: WebPushKeysSet{
+			P256dh: base64.RawURLEncoding.EncodeToString(b.key.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString(b.auth),
+		},
+	}
+}
+
+// decrypt is the user agent side of RFC 8291.
+func (b *testBrowser) decrypt(t *testing.T, body []byte) []byte {
+	salt := body[:16]
+	assert.Equal(t, uint32(webPushRecordSize), binary.BigEndian.Uint32(body[16:20]))
+	idlen := int(body[20])
+	asPublic := body[21 : 21+idlen]
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	assert.NoError(t, err)
+	sharedSecret, err := b.key.ECDH(asKey)
+	assert.NoError(t, err)
+
+	keyInfo := append([]byte("WebPush: info\x00"), b.key.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm, _ := hkdfDerive(sharedSecret, b.auth, keyInfo, 32)
+	cek, _ := hkdfDerive(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce, _ := hkdfDerive(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, body[21+idlen:], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x02), plaintext[len(plaintext)-1])
+
+	return plaintext[:len(plaintext)-1]
+}
+
+func newTestVAPIDKey(t *testing.T) string {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	return base64.RawURLEncoding.EncodeToString(key.Bytes())
+}
+
+func verifyVAPID(t *testing.T, header, publicKey string) {
+	assert.True(t, strings.HasPrefix(header, "vapid t="))
+	parts := strings.SplitN(strings.TrimPrefix(header, "vapid t="), ", k=", 2)
+	assert.Len(t, parts, 2)
+	assert.Equal(t, publicKey, parts[1])
+
+	jwt := strings.Split(parts[0], ".")
+	assert.Len(t, jwt, 3)
+
+	raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	pub, err := ecdh.P256().NewPublicKey(raw)
+	assert.NoError(t, err)
+	pubBytes := pub.Bytes()
+
+	sig, _ := base64.RawURLEncoding.DecodeString(jwt[2])
+	digest := sha256.Sum256([]byte(jwt[0] + "." + jwt[1]))
+	key := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(pubBytes[1:33]),
+		Y:     new(big.Int).SetBytes(pubBytes[33:]),
+	}
+	assert.True(t, ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])))
+}
+
+func TestNewVAPIDClientInvalidKey(t *testing.T) {
+	_, err := NewVAPIDClient("not a key", "mailto:admin@example.com")
+	assert.Error(t, err)
+
+	_, err = NewVAPIDClient(base64.RawURLEncoding.EncodeToString([]byte("short")), "")
+	assert.Error(t, err)
+}
+
+func TestMissingWebPushVAPIDKey(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	cfg.Android.Enabled = false
+	cfg.WebPush.Enabled = true
+	cfg.WebPush.VAPIDPrivateKey = ""
+
+	err := CheckPushConf(cfg)
+
+	assert.Error(t, err)
+	assert.Equal(t, "missing web push vapid private key", err.Error())
+}
+
+func TestCheckWebPushSubscriptions(t *testing.T) {
+	req := &PushNotification{Platform: core.PlatformWebPush}
+	assert.EqualError(t, CheckMessage(req), "please provide at least one web push subscription")
+
+	req.Subscriptions = []WebPushSubscription{{Endpoint: "ftp://example.com/push"}}
+	assert.EqualError(t, CheckMessage(req), `invalid web push endpoint: "ftp://example.com/push"`)
+
+	req.Subscriptions = []WebPushSubscription{{Endpoint: "https://example.com/push"}}
+	assert.EqualError(t, CheckMessage(req), "web push subscription keys p256dh and auth are required")
+
+	req.Subscriptions[0].Keys = WebPushKeysSet{P256dh: "a", Auth: "b"}
+	assert.NoError(t, CheckMessage(req))
+	assert.Equal(t, []string{"https://example.com/push"}, req.Recipients())
+}
+
+func TestEncryptWebPushPayloadTooLarge(t *testing.T) {
+	sub := newTestBrowser(t).subscription("https://example.com/push")
+
+	_, err := encryptWebPush(&sub, make([]byte, MaxWebPushPayloadSize))
+	assert.NoError(t, err)
+
+	_, err = encryptWebPush(&sub, make([]byte, MaxWebPushPayloadSize+1))
+	assert.Error(t, err)
+}
+
+func TestPushToWebPush(t *testing.T) {
+	browser := newTestBrowser(t)
+	received := make(chan []byte, 1)
+
+	client, err := NewVAPIDClient(newTestVAPIDKey(t), "mailto:admin@example.com")
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "aes128gcm", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "60", r.Header.Get("TTL"))
+		assert.Equal(t, "high", r.Header.Get("Urgency"))
+		verifyVAPID(t, r.Header.Get("Authorization"), client.PublicKey())
+
+		body, _ := io.ReadAll(r.Body)
+		received <- browser.decrypt(t, body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client.HTTPClient = server.Client()
+	WebPushClient = client
+	defer func() {
+		WebPushClient = nil
+	}()
+
+	cfg, _ := config.LoadConf()
+	cfg.WebPush.Enabled = true
+
+	ttl := 60
+	req := &PushNotification{
+		Platform:      core.PlatformWebPush,
+		Title:         "Hello",
+		Message:       "Welcome",
+		Priority:      HIGH,
+		TTL:           &ttl,
+		Subscriptions: []WebPushSubscription{browser.subscription(server.URL + "/push/1")},
+	}
+
+	resp, err := PushToWebPush(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+	select {
+	case payload := <-received:
+		assert.JSONEq(t, `{"title":"Hello","message":"Welcome"}`, string(payload))
+	case <-time.After(5 * time.Second):
+		t.Fatal("push service did not receive the message")
+	}
+}
+
+func TestPushToWebPushInvalidSubscription(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	client, err := NewVAPIDClient(newTestVAPIDKey(t), "")
+	assert.NoError(t, err)
+	client.HTTPClient = server.Client()
+	WebPushClient = client
+	defer func() {
+		WebPushClient = nil
+	}()
+
+	cfg, _ := config.LoadConf()
+	cfg.WebPush.MaxRetry = 2
+
+	req := &PushNotification{
+		Platform:      core.PlatformWebPush,
+		Message:       "Welcome",
+		Subscriptions: []WebPushSubscription{newTestBrowser(t).subscription(server.URL + "/push/gone")},
+	}
+
+	resp, err := PushToWebPush(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 1)
+	assert.Equal(t, "webpush", resp.Logs[0].Platform)
+	assert.Contains(t, resp.Logs[0].Error, ErrInvalidSubscription.Error())
+	// gone subscriptions are never retried
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestPushToWebPushRetryUnavailable(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client, err := NewVAPIDClient(newTestVAPIDKey(t), "")
+	assert.NoError(t, err)
+	client.HTTPClient = server.Client()
+	WebPushClient = client
+	defer func() {
+		WebPushClient = nil
+	}()
+
+	cfg, _ := config.LoadConf()
+	cfg.WebPush.MaxRetry = 1
+	cfg.Core.Retry.Backoff = 0
+
+	req := &PushNotification{
+		Platform:      core.PlatformWebPush,
+		Message:       "Welcome",
+		Subscriptions: []WebPushSubscription{newTestBrowser(t).subscription(server.URL + "/push/1")},
+	}
+
+	resp, err := PushToWebPush(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 1)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestPushToWebPushDryRun(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client, err := NewVAPIDClient(newTestVAPIDKey(t), "")
+	assert.NoError(t, err)
+	client.HTTPClient = server.Client()
+	WebPushClient = client
+	defer func() {
+		WebPushClient = nil
+	}()
+
+	cfg, _ := config.LoadConf()
+	cfg.Log.HideToken = false
+
+	req := &PushNotification{
+		Platform: core.PlatformWebPush,
+		Message:  "Welcome",
+		DryRun:   true,
+		Subscriptions: []WebPushSubscription{
+			newTestBrowser(t).subscription(server.URL + "/push/1"),
+			{Endpoint: server.URL + "/push/2", Keys: WebPushKeysSet{P256dh: "invalid", Auth: "invalid"}},
+		},
+	}
+
+	resp, err := PushToWebPush(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 2)
+	for _, l := range resp.Logs {
+		if l.Token == server.URL+"/push/1" {
+			assert.Equal(t, core.SucceededPush, l.Type)
+		} else {
+			assert.Equal(t, core.FailedPush, l.Type)
+		}
+	}
+	// nothing is sent to the push service
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
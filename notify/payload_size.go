@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/appleboy/gorush/core"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/appleboy/go-hms-push/push/model"
+	"github.com/sideshow/apns2"
+)
+
+const (
+	// MaxIOSPayloadSize is the largest payload APNs accepts for regular
+	// remote notifications, and MaxIOSVoIPPayloadSize the one for VoIP.
+	// ref: https://developer.apple.com/documentation/usernotifications/generating-a-remote-notification
+	MaxIOSPayloadSize     = 4096
+	MaxIOSVoIPPayloadSize = 5120
+
+	// MaxAndroidPayloadSize is the largest FCM payload, its data and
+	// notification.
+	// ref: https://firebase.google.com/docs/cloud-messaging/concept-options
+	MaxAndroidPayloadSize = 4096
+
+	// MaxHuaweiPayloadSize is the largest HMS message, the tokens excluded.
+	// HMS rejects a larger one with 80300008, the message body is too large.
+	// ref: https://developer.huawei.com/consumer/en/doc/development/HMSCore-References/https-send-api-0000001050986197
+	MaxHuaweiPayloadSize = 4096
+)
+
+// ErrPayloadTooLarge is returned when the payload built for the platform is
+// over the size accepted by the provider.
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// CheckPayloadSize builds the payload sent to the provider of the platform
+// and checks that its serialized size is within the provider limit.
+func CheckPayloadSize(req *PushNotification) error {
+	var (
+		payload  []byte
+		limit    int
+		provider string
+		err      error
+	)
+
+	switch req.Platform {
+	case core.PlatformIOS:
+		notification := GetIOSNotification(req)
+		provider, limit = "APNs", MaxIOSPayloadSize
+		if notification.PushType == apns2.PushTypeVOIP {
+			provider, limit = "APNs VoIP", MaxIOSVoIPPayloadSize
+		}
+		payload, err = json.Marshal(notification)
+	case core.PlatformAndroid:
+		messages := GetAndroidNotification(req)
+		if len(messages) == 0 {
+			return nil
+		}
+		// every message only differs by its target, the limit is on the data
+		// and notification the app gets
+		provider, limit = "FCM", MaxAndroidPayloadSize
+		payload, err = json.Marshal(struct {
+			Data         map[string]string       `json:"data,omitempty"`
+			Notification *messaging.Notification `json:"notification,omitempty"`
+		}{messages[0].Data, messages[0].Notification})
+	case core.PlatformHuawei:
+		var notification *model.MessageRequest
+		notification, err = GetHuaweiNotification(req)
+		if err != nil {
+			return err
+		}
+		// every token gets the same message
+		message := *notification.Message
+		message.Token = nil
+		provider, limit = "HMS", MaxHuaweiPayloadSize
+		payload, err = json.Marshal(&message)
+	case core.PlatformWebPush:
+		provider, limit = "web push", MaxWebPushPayloadSize
+		payload, err = GetWebPushPayload(req)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > limit {
+		return fmt.Errorf("%w: the %s payload is %d bytes, %d bytes over the %d bytes limit",
+			ErrPayloadTooLarge, provider, len(payload), len(payload)-limit, limit)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/appleboy/gorush/core"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPayloadSize(t *testing.T) {
+	long := strings.Repeat("a", 4200)
+
+	tests := []struct {
+		name  string
+		req   *PushNotification
+		error string
+	}{
+		{
+			name: "ios",
+			req:  &PushNotification{Platform: core.PlatformIOS, Tokens: []string{"aaaaa"}, Message: "Welcome"},
+		},
+		{
+			name:  "ios oversized",
+			req:   &PushNotification{Platform: core.PlatformIOS, Tokens: []string{"aaaaa"}, Message: long},
+			error: "payload too large: the APNs payload is 4220 bytes, 124 bytes over the 4096 bytes limit",
+		},
+		{
+			name: "ios voip",
+			req:  &PushNotification{Platform: core.PlatformIOS, Tokens: []string{"aaaaa"}, Message: long, PushType: "voip"},
+		},
+		{
+			name:  "android oversized",
+			req:   &PushNotification{Platform: core.PlatformAndroid, Tokens: []string{"aaaaa"}, Data: D{"text": long}},
+			error: "payload too large: the FCM payload is 4220 bytes, 124 bytes over the 4096 bytes limit",
+		},
+		{
+			// the size does not depend on the number of tokens
+			name: "android many tokens",
+			req:  &PushNotification{Platform: core.PlatformAndroid, Tokens: strings.Split(strings.Repeat("token,", 1000), ","), Message: "Welcome"},
+		},
+		{
+			// the other options of the message are not part of the payload
+			name: "android options",
+			req: &PushNotification{
+				Platform: core.PlatformAndroid,
+				Tokens:   []string{"aaaaa"},
+				Message:  strings.Repeat("a", 4000),
+				Android:  &messaging.AndroidConfig{CollapseKey: strings.Repeat("a", 200)},
+			},
+		},
+		{
+			name:  "huawei oversized",
+			req:   &PushNotification{Platform: core.PlatformHuawei, Tokens: []string{"aaaaa"}, HuaweiData: long},
+			error: "payload too large: the HMS payload is",
+		},
+		{
+			// the size does not depend on the number of tokens
+			name: "huawei many tokens",
+			req:  &PushNotification{Platform: core.PlatformHuawei, Tokens: strings.Split(strings.Repeat("token,", 1000), ","), Message: "Welcome"},
+		},
+		{
+			name:  "web push oversized",
+			req:   &PushNotification{Platform: core.PlatformWebPush, Message: long},
+			error: "payload too large",
+		},
+		{
+			name: "sms",
+			req:  &PushNotification{Platform: core.PlatformSMS, Tokens: []string{"aaaaa"}, Message: long},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckPayloadSize(tt.req)
+			if tt.error == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrPayloadTooLarge)
+			assert.Contains(t, err.Error(), tt.error)
+		})
+	}
+}
+
+func TestCheckMessagePayloadSize(t *testing.T) {
+	err := CheckMessage(&PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaaa"},
+		Message:  strings.Repeat("a", 4200),
+	})
+	assert.ErrorIs(t, err, ErrPayloadTooLarge)
+}
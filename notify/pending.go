@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+)
+
+const (
+	pendingKey      = "gorush-pending:"
+	pendingClaimKey = "gorush-pending-claim:"
+
+	// pendingClaimTTL keeps the replicas sharing the storage from queuing a
+	// pending notification twice.
+	pendingClaimTTL = time.Minute
+)
+
+// pendingNotification is a notification waiting to go back to its queue. It
+// is kept in the storage until it is queued, so that a shutdown or a crash
+// doesn't lose it.
+type pendingNotification struct {
+	Due          int64             `json:"due"`
+	Notification *PushNotification `json:"notification"`
+}
+
+// ErrQueueUnavailable is returned when the queue of a pending notification
+// is gone.
+var ErrQueueUnavailable = errors.New("queue unavailable")
+
+var (
+	pendingMu     sync.Mutex
+	pendingTimers = map[string]*pendingTimer{}
+)
+
+type pendingTimer struct {
+	timer *time.Timer
+	req   *PushNotification
+}
+
+func newPendingID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// deferNotification puts the notification back on its queue after delay.
+func deferNotification(cfg *config.ConfYaml, req *PushNotification, delay time.Duration) {
+	id, err := newPendingID()
+	if err != nil {
+		failPending(cfg, req, err)
+		return
+	}
+
+	data, err := json.Marshal(pendingNotification{
+		Due:          time.Now().Add(delay).UnixNano(),
+		Notification: req,
+	})
+	if err == nil {
+		err = status.StatStorage.SetValue(pendingKey+id, data, 0)
+	}
+	if err != nil {
+		// still queued later, but lost on shutdown
+		logx.LogError.Error("can't store pending notification: " + err.Error())
+	}
+
+	schedulePending(cfg, id, req, delay)
+}
+
+func schedulePending(cfg *config.ConfYaml, id string, req *PushNotification, delay time.Duration) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+
+	pendingTimers[id] = &pendingTimer{
+		timer: time.AfterFunc(delay, func() { queuePending(cfg, id, req) }),
+		req:   req,
+	}
+}
+
+// queuePending puts the pending notification on its queue, unless another
+// replica did it first.
+func queuePending(cfg *config.ConfYaml, id string, req *PushNotification) {
+	pendingMu.Lock()
+	delete(pendingTimers, id)
+	pendingMu.Unlock()
+
+	claimed, err := status.StatStorage.SetValueNX(pendingClaimKey+id, []byte{1}, pendingClaimTTL)
+	if err != nil {
+		logx.LogError.Error("can't claim pending notification: " + err.Error())
+	} else if !claimed {
+		return
+	}
+
+	if err := status.StatStorage.DelValue(pendingKey + id); err != nil {
+		logx.LogError.Error("can't remove pending notification: " + err.Error())
+	}
+
+	q := NotificationQueue(req, RetryQueue)
+	if q == nil {
+		failPending(cfg, req, ErrQueueUnavailable)
+		return
+	}
+	if err := q.Queue(req); err != nil {
+		logx.LogError.Error("can't queue pending notification: " + err.Error())
+		failPending(cfg, req, err)
+	}
+}
+
+// failPending fails the notification that can't go back to its queue.
+func failPending(cfg *config.ConfYaml, req *PushNotification, err error) {
+	SetDeliveryState(cfg, req, DeliveryFailed, err)
+	CampaignFailed(req)
+	if _, err := AddDeadLetter(cfg, req, DeadLetterMaxCapacity, err.Error()); err != nil {
+		logx.LogError.Error("can't store dead letter: " + err.Error())
+	}
+}
+
+// RequeuePendingNotifications schedules the notifications left pending in
+// the storage by the last shutdown, those overdue are queued right away. It
+// is called once the queues are ready.
+func RequeuePendingNotifications(cfg *config.ConfYaml) error {
+	keys, err := status.StatStorage.Keys(pendingKey)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		id := strings.TrimPrefix(key, pendingKey)
+		pendingMu.Lock()
+		_, scheduled := pendingTimers[id]
+		pendingMu.Unlock()
+		if scheduled {
+			continue
+		}
+
+		data, err := status.StatStorage.GetValue(key)
+		if err != nil {
+			return err
+		}
+
+		pending := &pendingNotification{}
+		if data == nil || json.Unmarshal(data, pending) != nil || pending.Notification == nil {
+			continue
+		}
+
+		delay := max(time.Until(time.Unix(0, pending.Due)), 0)
+		schedulePending(cfg, id, pending.Notification, delay)
+	}
+
+	if len(keys) > 0 {
+		logx.LogAccess.Infof("%d pending notifications scheduled again", len(keys))
+	}
+
+	return nil
+}
+
+// StopPendingNotifications stops the timers of the pending notifications on
+// shutdown. They stay in a persistent storage for the next start; the
+// memory storage doesn't outlive the process, so they are queued right away
+// for the queue to drain them.
+func StopPendingNotifications(cfg *config.ConfYaml) {
+	pendingMu.Lock()
+	timers := pendingTimers
+	pendingTimers = map[string]*pendingTimer{}
+	pendingMu.Unlock()
+
+	for id, pending := range timers {
+		if pending.timer.Stop() && cfg.Stat.Engine == "memory" {
+			queuePending(cfg, id, pending.req)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/status"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPendingNotificationsSurviveShutdown(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	retryQueue, received := newTestQueue(t)
+	RetryQueue = retryQueue
+	t.Cleanup(func() { RetryQueue = nil })
+
+	deferNotification(cfg, &PushNotification{ID: "pending", Tokens: []string{"aaaa"}}, time.Hour)
+	keys, err := status.StatStorage.Keys(pendingKey)
+	assert.NoError(t, err)
+	if !assert.Len(t, keys, 1) {
+		return
+	}
+
+	// a persistent storage keeps the notification for the next start
+	cfg.Stat.Engine = "redis"
+	StopPendingNotifications(cfg)
+	keys, err = status.StatStorage.Keys(pendingKey)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	// overdue, it is queued as soon as it is scheduled again
+	data, err := json.Marshal(pendingNotification{
+		Due:          time.Now().Add(-time.Minute).UnixNano(),
+		Notification: &PushNotification{ID: "pending", Tokens: []string{"aaaa"}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, status.StatStorage.SetValue(keys[0], data, 0))
+	assert.NoError(t, RequeuePendingNotifications(cfg))
+
+	select {
+	case v := <-received:
+		assert.Equal(t, "pending", v.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending notification was not queued again")
+	}
+
+	assert.Eventually(t, func() bool {
+		keys, _ := status.StatStorage.Keys(pendingKey)
+		return len(keys) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPendingNotificationsDrainOnShutdown(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	retryQueue, received := newTestQueue(t)
+	RetryQueue = retryQueue
+	t.Cleanup(func() { RetryQueue = nil })
+
+	deferNotification(cfg, &PushNotification{ID: "drained", Tokens: []string{"aaaa"}}, time.Hour)
+
+	// the memory storage is gone with the process, queue it right away
+	StopPendingNotifications(cfg)
+
+	select {
+	case v := <-received:
+		assert.Equal(t, "drained", v.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending notification was not drained")
+	}
+
+	keys, err := status.StatStorage.Keys(pendingKey)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
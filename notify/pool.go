@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/golang-queue/queue"
+)
+
+// PlatformQueues are the own pools of the platforms, so that a slow provider
+// only stalls its own notifications. The platforms without one share the
+// default pool.
+var PlatformQueues = map[int]*queue.Queue{}
+
+// PlatformPoolName returns the name of the pool of the platform, which is
+// also the suffix of its topic, subject or stream.
+func PlatformPoolName(platform int) string {
+	switch platform {
+	case core.PlatformIOS:
+		return "ios"
+	case core.PlatformAndroid:
+		return "android"
+	case core.PlatformHuawei:
+		return "huawei"
+	case core.PlatformSMS:
+		return "sms"
+	case core.PlatformTelegramGateway:
+		return "telegram"
+	case core.PlatformCallAuto:
+		return "call"
+	case core.PlatformWebPush:
+		return "webpush"
+	}
+	return ""
+}
+
+// PlatformPool returns the worker number and capacity of the own pool of the
+// platform, no worker when it shares the default pool.
+func PlatformPool(cfg *config.ConfYaml, platform int) (workerNum, queueNum int64) {
+	switch platform {
+	case core.PlatformIOS:
+		workerNum, queueNum = cfg.Ios.WorkerNum, cfg.Ios.QueueNum
+	case core.PlatformAndroid:
+		workerNum, queueNum = cfg.Android.WorkerNum, cfg.Android.QueueNum
+	case core.PlatformHuawei:
+		workerNum, queueNum = cfg.Huawei.WorkerNum, cfg.Huawei.QueueNum
+	case core.PlatformSMS:
+		workerNum, queueNum = cfg.SMS.WorkerNum, cfg.SMS.QueueNum
+	case core.PlatformTelegramGateway:
+		workerNum, queueNum = cfg.TelegramGateway.WorkerNum, cfg.TelegramGateway.QueueNum
+	case core.PlatformCallAuto:
+		workerNum, queueNum = cfg.CallAuto.WorkerNum, cfg.CallAuto.QueueNum
+	case core.PlatformWebPush:
+		workerNum, queueNum = cfg.WebPush.WorkerNum, cfg.WebPush.QueueNum
+	}
+
+	if queueNum == 0 {
+		queueNum = cfg.Core.QueueNum
+	}
+
+	return workerNum, queueNum
+}
+
+// Pools returns the queues of the high priority lane and of the platforms by
+// pool name, the default pool excluded.
+func Pools() map[string]*queue.Queue {
+	pools := make(map[string]*queue.Queue, len(PlatformQueues)+1)
+	for platform, q := range PlatformQueues {
+		pools[PlatformPoolName(platform)] = q
+	}
+	if PriorityQueue != nil {
+		pools[LaneHigh] = PriorityQueue
+	}
+	return pools
+}
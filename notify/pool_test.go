@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/golang-queue/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlatformPool(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	workerNum, _ := PlatformPool(cfg, core.PlatformIOS)
+	assert.Equal(t, int64(0), workerNum)
+
+	cfg.Ios.WorkerNum = 4
+	workerNum, queueNum := PlatformPool(cfg, core.PlatformIOS)
+	assert.Equal(t, int64(4), workerNum)
+	assert.Equal(t, cfg.Core.QueueNum, queueNum)
+
+	cfg.SMS.WorkerNum = 2
+	cfg.SMS.QueueNum = 100
+	workerNum, queueNum = PlatformPool(cfg, core.PlatformSMS)
+	assert.Equal(t, int64(2), workerNum)
+	assert.Equal(t, int64(100), queueNum)
+
+	assert.Equal(t, "telegram", PlatformPoolName(core.PlatformTelegramGateway))
+	assert.Equal(t, "", PlatformPoolName(0))
+}
+
+func TestPlatformQueues(t *testing.T) {
+	defaultQueue, _ := newTestQueue(t)
+	iosQueue, _ := newTestQueue(t)
+	priorityQueue, _ := newTestQueue(t)
+	PlatformQueues[core.PlatformIOS] = iosQueue
+	t.Cleanup(func() {
+		PlatformQueues = map[int]*queue.Queue{}
+		PriorityQueue = nil
+	})
+
+	assert.Equal(t, iosQueue, NotificationQueue(&PushNotification{Platform: core.PlatformIOS}, defaultQueue))
+	assert.Equal(t, defaultQueue, NotificationQueue(&PushNotification{Platform: core.PlatformAndroid}, defaultQueue))
+	assert.Equal(t, map[string]*queue.Queue{"ios": iosQueue}, Pools())
+
+	// the high priority lane is shared by the platforms
+	PriorityQueue = priorityQueue
+	assert.Equal(t, priorityQueue, NotificationQueue(&PushNotification{
+		Platform: core.PlatformIOS,
+		Lane:     LaneHigh,
+	}, defaultQueue))
+	assert.Len(t, Pools(), 2)
+}
@@ -0,0 +1,239 @@
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+)
+
+const rateLimitKey = "gorush-rate-limit:"
+
+// DeadLetterRateLimited is the reason of the notifications that waited too
+// long for capacity and could not go back to the queue.
+const DeadLetterRateLimited = "rate_limited"
+
+// ErrRateLimited is returned when the provider has no capacity left within
+// max_wait.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// localRateLimiter keeps the buckets in memory, for a single replica.
+type localRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]time.Time
+}
+
+func newLocalRateLimiter() *localRateLimiter {
+	return &localRateLimiter{
+		buckets: map[string]time.Time{},
+	}
+}
+
+// Reserve implements the same generic cell rate algorithm as the redis
+// storage: the bucket holds the theoretical arrival time of the next token.
+func (l *localRateLimiter) Reserve(key string, interval time.Duration, burst, count int, maxWait time.Duration) (time.Duration, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	tat := l.buckets[key]
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(time.Duration(count) * interval)
+	wait := max(next.Add(-time.Duration(burst)*interval).Sub(now), 0)
+	if maxWait > 0 && wait > maxWait {
+		return wait, false, nil
+	}
+
+	l.buckets[key] = next
+	return wait, true, nil
+}
+
+// RateLimitWait sums up the time the notifications of a provider waited for
+// capacity.
+type RateLimitWait struct {
+	Count   uint64
+	Seconds float64
+}
+
+var (
+	localLimiter = newLocalRateLimiter()
+
+	rateLimitMu    sync.Mutex
+	rateLimitWaits = map[string]RateLimitWait{}
+)
+
+// RateLimitWaits returns the time waited for capacity by provider.
+func RateLimitWaits() map[string]RateLimitWait {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+
+	result := make(map[string]RateLimitWait, len(rateLimitWaits))
+	for provider, wait := range rateLimitWaits {
+		result[provider] = wait
+	}
+	return result
+}
+
+func recordRateLimitWait(provider string, wait time.Duration) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+
+	total := rateLimitWaits[provider]
+	total.Count++
+	total.Seconds += wait.Seconds()
+	rateLimitWaits[provider] = total
+}
+
+// rateLimitProvider returns the provider the notifications of the platform
+// take their capacity from.
+func rateLimitProvider(platform int) string {
+	if platform == core.PlatformTelegramGateway {
+		return ProviderTelegram
+	}
+	return platformProvider(platform)
+}
+
+func providerRateLimit(cfg *config.ConfYaml, provider string) config.SectionRateLimitProvider {
+	switch provider {
+	case ProviderAPNs:
+		return cfg.RateLimit.APNs
+	case ProviderFCM:
+		return cfg.RateLimit.FCM
+	case ProviderHMS:
+		return cfg.RateLimit.HMS
+	case ProviderWebPush:
+		return cfg.RateLimit.WebPush
+	case ProviderSMS:
+		return cfg.RateLimit.SMS
+	case ProviderTelegram:
+		return cfg.RateLimit.Telegram
+	case ProviderTelphin:
+		return cfg.RateLimit.Telphin
+	}
+	return config.SectionRateLimitProvider{}
+}
+
+// credentialProfile identifies the credential used with the provider, the
+// quotas of the providers are per credential. It is hashed to keep the
+// secrets out of the storage keys.
+func credentialProfile(cfg *config.ConfYaml, provider string) string {
+	var id string
+	switch provider {
+	case ProviderAPNs:
+		id = cfg.Ios.TeamID + "/" + cfg.Ios.KeyID + "/" + cfg.Ios.KeyPath + "/" + cfg.Ios.KeyBase64
+	case ProviderFCM:
+		id = cfg.Android.KeyPath + "/" + cfg.Android.Credential + "/" + os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	case ProviderHMS:
+		id = cfg.Huawei.AppID
+	case ProviderWebPush:
+		id = cfg.WebPush.VAPIDPrivateKey
+	case ProviderSMS:
+		id = cfg.SMS.Provider + "/" + cfg.SMS.MTSApiKey + "/" + cfg.SMS.DevinoApiKey + "/" + cfg.SMS.DevinoLogin
+	case ProviderTelegram:
+		id = cfg.TelegramGateway.ApiToken
+	case ProviderTelphin:
+		id = cfg.CallAuto.AppID
+	}
+
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:8])
+}
+
+// messageCount returns the number of messages the notification sends to the
+// provider, a topic or condition message counts as one.
+func messageCount(req *PushNotification) int {
+	switch req.Platform {
+	case core.PlatformSMS, core.PlatformTelegramGateway, core.PlatformCallAuto:
+		return max(len(req.PhoneNumbers), 1)
+	}
+	return max(len(req.Recipients()), 1)
+}
+
+// rateLimitBucket returns the interval between two messages of the provider
+// and the number of messages it takes at once, a zero burst without limit.
+func rateLimitBucket(cfg *config.ConfYaml, provider string) (time.Duration, int) {
+	limit := providerRateLimit(cfg, provider)
+	if !cfg.RateLimit.Enabled || limit.Rate <= 0 {
+		return 0, 0
+	}
+
+	interval := time.Duration(float64(time.Second) / limit.Rate)
+	burst := limit.Burst
+	if burst < 1 {
+		burst = max(int(math.Ceil(limit.Rate)), 1)
+	}
+	return interval, burst
+}
+
+// waitForCapacity blocks until the provider has the capacity to send the
+// notification. The buckets are shared through the storage engine when it
+// is shared between replicas, each replica has its own otherwise. It
+// returns ErrRateLimited with the remaining wait when that is over max_wait.
+func waitForCapacity(ctx context.Context, cfg *config.ConfYaml, req *PushNotification, provider string) (time.Duration, error) {
+	interval, burst := rateLimitBucket(cfg, provider)
+	if burst == 0 {
+		return 0, nil
+	}
+
+	maxWait := time.Duration(cfg.RateLimit.MaxWait) * time.Second
+	key := rateLimitKey + provider + ":" + credentialProfile(cfg, provider)
+
+	var limiter core.RateLimiter = localLimiter
+	if status.StatStorage != nil {
+		if shared := status.StatStorage.RateLimiter(); shared != nil {
+			limiter = shared
+		}
+	}
+
+	wait, ok, err := limiter.Reserve(key, interval, burst, messageCount(req), maxWait)
+	if err != nil {
+		// better a limit per replica than no limit at all
+		logx.LogError.Error("can't reserve shared rate limit: " + err.Error())
+		wait, ok, _ = localLimiter.Reserve(key, interval, burst, messageCount(req), maxWait)
+	}
+	if !ok {
+		return wait - maxWait, ErrRateLimited
+	}
+
+	recordRateLimitWait(provider, wait)
+	if wait <= 0 {
+		return 0, nil
+	}
+
+	logx.LogAccess.Debugf("rate limit of %s reached, notification waits %s", provider, wait)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-timer.C:
+		return 0, nil
+	}
+}
+
+// sendBursts sends the notification in batches of the burst of its
+// provider. The capacity of the whole notification may never be there at
+// once, each batch waits for its own or goes back to the queue alone.
+func sendBursts(ctx context.Context, req *PushNotification, cfg *config.ConfYaml, burst int) (*ResponsePush, error) {
+	batches := []*PushNotification{}
+	for _, recipients := range chunkSlice(templateRecipients(req), burst) {
+		batch := *req
+		setRecipients(&batch, recipients)
+		batches = append(batches, &batch)
+	}
+
+	return sendBatches(ctx, req, cfg, &ResponsePush{}, batches)
+}
@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func resetRateLimits(t *testing.T) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		localLimiter = newLocalRateLimiter()
+		rateLimitMu.Lock()
+		rateLimitWaits = map[string]RateLimitWait{}
+		rateLimitMu.Unlock()
+	})
+}
+
+func TestLocalRateLimiter(t *testing.T) {
+	limiter := newLocalRateLimiter()
+
+	// the burst goes through right away
+	for i := 0; i < 2; i++ {
+		wait, ok, err := limiter.Reserve("bucket", time.Second, 2, 1, 0)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Zero(t, wait)
+	}
+
+	wait, ok, err := limiter.Reserve("bucket", time.Second, 2, 1, 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.InDelta(t, time.Second, wait, float64(10*time.Millisecond))
+
+	// nothing is taken when the wait is too long
+	wait, ok, err = limiter.Reserve("bucket", time.Second, 2, 1, time.Second)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.InDelta(t, 2*time.Second, wait, float64(10*time.Millisecond))
+
+	wait, ok, err = limiter.Reserve("bucket", time.Second, 2, 1, 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.InDelta(t, 2*time.Second, wait, float64(10*time.Millisecond))
+
+	// the buckets are apart
+	wait, ok, err = limiter.Reserve("other", time.Second, 2, 2, 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Zero(t, wait)
+}
+
+func TestCredentialProfile(t *testing.T) {
+	cfg, _ := initMockProviders(t)
+	cfg.Huawei.AppID = "app-1"
+	profile := credentialProfile(cfg, ProviderHMS)
+	assert.Len(t, profile, 16)
+	assert.NotContains(t, profile, "app-1")
+
+	cfg.Huawei.AppID = "app-2"
+	assert.NotEqual(t, profile, credentialProfile(cfg, ProviderHMS))
+}
+
+func TestRateLimitWaits(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	resetRateLimits(t)
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.APNs.Rate = 20
+	cfg.RateLimit.APNs.Burst = 2
+
+	req := &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa", "bbbb"},
+		Message:  "Welcome",
+	}
+
+	start := time.Now()
+	_, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// the second one waits for the two tokens to come back
+	_, err = SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Len(t, mock.Messages("apns"), 4)
+
+	waits := RateLimitWaits()[ProviderAPNs]
+	assert.Equal(t, uint64(2), waits.Count)
+	assert.InDelta(t, 0.1, waits.Seconds, 0.02)
+
+	// the other providers have no limit
+	_, err = SendNotification(context.Background(), &PushNotification{
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}, cfg)
+	assert.NoError(t, err)
+	assert.NotContains(t, RateLimitWaits(), ProviderFCM)
+}
+
+func TestRateLimitHoldsNotifications(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	resetRateLimits(t)
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.MaxWait = 1
+	cfg.RateLimit.APNs.Rate = 0.1
+	cfg.DeadLetter.Enabled = true
+
+	req := &PushNotification{
+		ID:       "rate-limit",
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}
+
+	_, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+
+	// without a queue to go back to, the notification is kept as a dead letter
+	resp, err := SendNotification(context.Background(), req, cfg)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Len(t, resp.Logs, 1)
+	assert.Equal(t, core.FailedPush, resp.Logs[0].Type)
+	assert.Len(t, mock.Messages("apns"), 1)
+
+	letters, err := ListDeadLetters(DeadLetterFilter{NotifID: "rate-limit", Reason: DeadLetterRateLimited})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+
+	_, err = DeleteDeadLetters(nil, DeadLetterFilter{NotifID: "rate-limit"})
+	assert.NoError(t, err)
+}
+
+func TestRateLimitRequeues(t *testing.T) {
+	cfg, _ := initMockProviders(t)
+	resetRateLimits(t)
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.MaxWait = 1
+	cfg.RateLimit.FCM.Rate = 0.5
+
+	retryQueue, received := newTestQueue(t)
+	RetryQueue = retryQueue
+	t.Cleanup(func() { RetryQueue = nil })
+
+	req := &PushNotification{
+		ID:       "rate-limit-requeue",
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}
+
+	_, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+
+	resp, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+
+	select {
+	case v := <-received:
+		assert.Equal(t, "rate-limit-requeue", v.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("held notification was not queued again")
+	}
+}
+
+func TestRateLimitSendsInBursts(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	resetRateLimits(t)
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.MaxWait = 1
+	cfg.RateLimit.APNs.Rate = 200
+	cfg.RateLimit.APNs.Burst = 10
+
+	// more tokens than the burst and max_wait allow at once
+	tokens := make([]string, 250)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("token-%d", i)
+	}
+
+	resp, err := SendNotification(context.Background(), &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   tokens,
+		Message:  "Welcome",
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 0)
+	assert.Len(t, mock.Messages("apns"), 250)
+	assert.Equal(t, uint64(25), RateLimitWaits()[ProviderAPNs].Count)
+}
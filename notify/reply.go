@@ -0,0 +1,177 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+)
+
+const replyKey = "gorush-reply:"
+
+// ErrReplyTimeout is returned when no worker answered within sync_timeout.
+var ErrReplyTimeout = errors.New("timeout waiting for the notification result")
+
+// Reply is the result a worker sends back to the API waiting for it, when
+// the notification went through a queue engine other than local.
+type Reply struct {
+	Logs  []logx.LogPushEntry `json:"logs,omitempty"`
+	Error string              `json:"error,omitempty"`
+}
+
+// NewReplyID returns a request ID to send the result of a notification to.
+func NewReplyID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// CheckSyncWait checks that the API can wait for the results of the
+// notifications. With a queue engine other than local, the worker of any
+// replica sends them back within sync_timeout through the mailbox of the
+// stat engine.
+func CheckSyncWait(cfg *config.ConfYaml) error {
+	if core.IsLocalQueue(core.Queue(cfg.Queue.Engine)) {
+		return nil
+	}
+
+	// the API would give up on every reply right away
+	if cfg.Core.SyncTimeout <= 0 {
+		return errors.New("core.sync_timeout must be positive to wait for the results with a queue engine other than local")
+	}
+
+	// the replies of the other replicas would never come
+	if status.StatStorage == nil || status.StatStorage.Mailbox() == nil {
+		return errors.New("stat engine " + cfg.Stat.Engine + " can't carry the results of a queue engine other than local, use redis")
+	}
+
+	return nil
+}
+
+func replyTimeout(cfg *config.ConfYaml) time.Duration {
+	return time.Duration(cfg.Core.SyncTimeout) * time.Second
+}
+
+// localMailbox hands the replies over in process, for the storage engines
+// without mailbox. The API refuses to wait for the replies of an external
+// queue with them, it only serves a worker running in the same process.
+type localMailbox struct {
+	mu    sync.Mutex
+	boxes map[string]chan []byte
+}
+
+var localReplies = &localMailbox{boxes: map[string]chan []byte{}}
+
+// box returns the mailbox of key, created when missing. The mailbox a message
+// is posted to before anyone waits for it is removed after ttl.
+func (m *localMailbox) box(key string, ttl time.Duration) chan []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	box, ok := m.boxes[key]
+	if !ok {
+		box = make(chan []byte, 1)
+		m.boxes[key] = box
+		if ttl > 0 {
+			time.AfterFunc(ttl, func() { m.remove(key, box) })
+		}
+	}
+	return box
+}
+
+func (m *localMailbox) remove(key string, box chan []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.boxes[key] == box {
+		delete(m.boxes, key)
+	}
+}
+
+func (m *localMailbox) Post(key string, message []byte, ttl time.Duration) error {
+	select {
+	case m.box(key, ttl) <- message:
+	default:
+		// a reply ID gets a single reply
+	}
+	return nil
+}
+
+func (m *localMailbox) Receive(ctx context.Context, key string, timeout time.Duration) ([]byte, error) {
+	box := m.box(key, 0)
+	defer m.remove(key, box)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case message := <-box:
+		return message, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, nil
+	}
+}
+
+func replyMailbox() core.Mailbox {
+	if status.StatStorage != nil {
+		if shared := status.StatStorage.Mailbox(); shared != nil {
+			return shared
+		}
+	}
+	return localReplies
+}
+
+// SendReply posts the result of the notification to the API waiting on its
+// reply ID. It is kept no longer than the API waits for it.
+func SendReply(cfg *config.ConfYaml, req *PushNotification, resp *ResponsePush, err error) error {
+	if req.ReplyTo == "" {
+		return nil
+	}
+
+	reply := Reply{}
+	if resp != nil {
+		reply.Logs = resp.Logs
+	}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+
+	return replyMailbox().Post(replyKey+req.ReplyTo, data, replyTimeout(cfg))
+}
+
+// WaitReply waits for the worker to send the result of the notification, up
+// to sync_timeout or until ctx is done.
+func WaitReply(ctx context.Context, cfg *config.ConfYaml, id string) (*Reply, error) {
+	ctx, cancel := context.WithTimeout(ctx, replyTimeout(cfg))
+	defer cancel()
+
+	data, err := replyMailbox().Receive(ctx, replyKey+id, replyTimeout(cfg))
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), err == nil && data == nil:
+		return nil, ErrReplyTimeout
+	case err != nil:
+		return nil, err
+	}
+
+	reply := &Reply{}
+	if err := json.Unmarshal(data, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
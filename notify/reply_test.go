@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+	"github.com/appleboy/gorush/storage/redis"
+
+	"github.com/golang-queue/queue/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReply(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	id, err := NewReplyID()
+	assert.NoError(t, err)
+	assert.Len(t, id, 32)
+
+	req := &PushNotification{
+		ID:       "notif-1",
+		Tokens:   []string{"aaaaa"},
+		Platform: core.PlatformAndroid,
+		ReplyTo:  id,
+	}
+	logs := []logx.LogPushEntry{{Type: core.FailedPush, Token: "aaaaa", Error: "invalid token"}}
+
+	time.AfterFunc(100*time.Millisecond, func() {
+		assert.NoError(t, SendReply(cfg, req, &ResponsePush{Logs: logs}, errors.New("invalid token")))
+	})
+
+	reply, err := WaitReply(context.Background(), cfg, id)
+	assert.NoError(t, err)
+	assert.Equal(t, logs, reply.Logs)
+	assert.Equal(t, "invalid token", reply.Error)
+
+	// the reply is read once
+	cfg.Core.SyncTimeout = 0
+	_, err = WaitReply(context.Background(), cfg, id)
+	assert.ErrorIs(t, err, ErrReplyTimeout)
+}
+
+func TestReplyBeforeWait(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Core.SyncTimeout = 1
+
+	req := &PushNotification{ID: "notif-early", ReplyTo: "early"}
+	assert.NoError(t, SendReply(cfg, req, &ResponsePush{}, errors.New("sent before the wait")))
+
+	reply, err := WaitReply(context.Background(), cfg, "early")
+	assert.NoError(t, err)
+	assert.Equal(t, "sent before the wait", reply.Error)
+
+	// the replies nobody waits for are dropped with their mailbox
+	req.ReplyTo = "unread"
+	assert.NoError(t, SendReply(cfg, req, &ResponsePush{}, nil))
+	assert.Eventually(t, func() bool {
+		localReplies.mu.Lock()
+		defer localReplies.mu.Unlock()
+		_, ok := localReplies.boxes[replyKey+"unread"]
+		return !ok
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestReplyCanceled(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := WaitReply(ctx, cfg, "canceled")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSendNotificationReplies(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.Core.Sync = true
+	mock.SetFailures(MockFailures{InvalidTokens: []string{"bbbb"}})
+
+	id, err := NewReplyID()
+	assert.NoError(t, err)
+
+	// the worker of an external queue only gets the payload
+	msg := job.NewMessage(&PushNotification{
+		ID:       "notif-reply",
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa", "bbbb"},
+		Message:  "Welcome",
+		ReplyTo:  id,
+	})
+	assert.NoError(t, Run(cfg)(context.Background(), &msg))
+
+	reply, err := WaitReply(context.Background(), cfg, id)
+	assert.NoError(t, err)
+	assert.Len(t, reply.Logs, 1)
+	assert.Equal(t, "bbbb", reply.Logs[0].Token)
+	assert.Equal(t, core.FailedPush, reply.Logs[0].Type)
+}
+
+func TestCheckSyncWait(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Core.SyncTimeout = 0
+
+	// the local queue doesn't wait for replies
+	assert.NoError(t, CheckSyncWait(cfg))
+
+	cfg.Queue.Engine = string(core.NSQ)
+	assert.EqualError(t, CheckSyncWait(cfg), "core.sync_timeout must be positive to wait for the results with a queue engine other than local")
+
+	// the memory engine can't carry the replies of the other replicas
+	cfg.Core.SyncTimeout = 30
+	assert.EqualError(t, CheckSyncWait(cfg), "stat engine memory can't carry the results of a queue engine other than local, use redis")
+
+	storage := status.StatStorage
+	t.Cleanup(func() { status.StatStorage = storage })
+	status.StatStorage = status.NewStateStorage(redis.New("localhost:6379", "", "", 0, false))
+	assert.NoError(t, CheckSyncWait(cfg))
+}
@@ -0,0 +1,192 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+
+	"firebase.google.com/go/v4/errorutils"
+	"firebase.google.com/go/v4/messaging"
+	"github.com/golang-queue/queue"
+	"github.com/sideshow/apns2"
+)
+
+// RetryQueue receives the notifications that have to be retried later. When it
+// is nil, or the server runs in sync mode, retries are done in place.
+var RetryQueue *queue.Queue
+
+// RetryPolicy describes how long to wait between two send attempts.
+type RetryPolicy struct {
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewRetryPolicy returns the retry policy from the core section of config.
+func NewRetryPolicy(cfg *config.ConfYaml) RetryPolicy {
+	return RetryPolicy{
+		Backoff:    time.Duration(cfg.Core.Retry.Backoff) * time.Second,
+		MaxBackoff: time.Duration(cfg.Core.Retry.MaxBackoff) * time.Second,
+	}
+}
+
+// Delay returns the exponential backoff with jitter for the given attempt,
+// starting from 1. A server hint such as Retry-After always takes precedence
+// when it asks for a longer wait.
+func (p RetryPolicy) Delay(attempt int, hint time.Duration) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	// equal jitter: keep half of the delay and randomize the other half
+	if half := int64(delay / 2); half > 0 {
+		//nolint:gosec
+		delay = time.Duration(half + rand.Int63n(half+1))
+	}
+
+	if hint > delay {
+		return hint
+	}
+
+	return delay
+}
+
+// retryAPNs reports whether an APNs failure is worth retrying, together with
+// the minimum delay suggested by the response.
+// See https://apple.co/3AdNane (Handling Notification Responses from APNs)
+func retryAPNs(res *apns2.Response, err error, policy RetryPolicy) (bool, time.Duration) {
+	if res == nil {
+		// network errors are transient unless the request itself was canceled
+		return err != nil && !errors.Is(err, context.Canceled), 0
+	}
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		if res.Reason == apns2.ReasonTooManyProviderTokenUpdates {
+			// the provider token can't be refreshed again right away
+			return true, policy.MaxBackoff
+		}
+		return true, 0
+	case res.StatusCode >= http.StatusInternalServerError:
+		return true, 0
+	default:
+		return false, 0
+	}
+}
+
+// retryFCM reports whether an FCM failure is worth retrying, together with
+// the delay requested by the Retry-After header, if any.
+// See https://firebase.google.com/docs/cloud-messaging/scale-fcm#errors
+func retryFCM(err error) (bool, time.Duration) {
+	if err == nil {
+		return false, 0
+	}
+
+	retryable := messaging.IsUnavailable(err) ||
+		messaging.IsInternal(err) ||
+		messaging.IsQuotaExceeded(err) ||
+		errorutils.IsDeadlineExceeded(err)
+	if !retryable {
+		return false, 0
+	}
+
+	return true, retryAfter(errorutils.HTTPResponse(err))
+}
+
+// retryAfter parses the Retry-After header in seconds or HTTP-date format.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// scheduleRetry waits for the retry delay of the given attempt. In async mode
+// the notification is put back on its queue once the delay has elapsed, so
+// the worker is released immediately and scheduleRetry returns true. Otherwise
+// it blocks until the delay has elapsed or ctx is done, and returns false so
+// the caller retries in place.
+func scheduleRetry(
+	ctx context.Context,
+	cfg *config.ConfYaml,
+	req *PushNotification,
+	attempt int,
+	hint time.Duration,
+) (bool, error) {
+	delay := NewRetryPolicy(cfg).Delay(attempt, hint)
+	req.retried += messageCount(req)
+
+	if q := NotificationQueue(req, RetryQueue); q != nil && !cfg.Core.Sync {
+		req.deferred = messageCount(req)
+		retry := *req
+		retry.RetryAttempt = attempt
+		retry.retried, retry.deferred = 0, 0
+		SetDeliveryState(cfg, &retry, DeliveryRetrying, nil)
+		logx.LogAccess.Debugf("retry #%d for %d tokens queued in %s", attempt, len(retry.Tokens), delay)
+		deferNotification(cfg, &retry, delay)
+		return true, nil
+	}
+
+	logx.LogAccess.Debugf("retry #%d for %d tokens in %s", attempt, len(req.Tokens), delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-timer.C:
+		return false, nil
+	}
+}
+
+// holdNotification sends the notification back to the queue after delay,
+// when the provider can't take it now. Without a queue to go back to, it is
+// failed with cause and kept as a dead letter.
+func holdNotification(cfg *config.ConfYaml, req *PushNotification, delay time.Duration, cause error, reason string) (*ResponsePush, error) {
+	if q := NotificationQueue(req, RetryQueue); q != nil && !cfg.Core.Sync {
+		held := *req
+		logx.LogAccess.Debugf("%s, notification queued again in %s", cause, delay)
+		SetDeliveryState(cfg, req, DeliveryRetrying, cause)
+		deferNotification(cfg, &held, delay)
+		return &ResponsePush{}, nil
+	}
+
+	SetDeliveryState(cfg, req, DeliveryFailed, cause)
+
+	resp := &ResponsePush{}
+	for _, token := range req.Recipients() {
+		resp.Logs = append(resp.Logs, logPush(cfg, core.FailedPush, token, req, cause))
+	}
+	countCampaignResult(req, resp, cause)
+	if _, err := AddDeadLetter(cfg, req, reason, cause.Error()); err != nil {
+		logx.LogError.Error("can't store dead letter: " + err.Error())
+	}
+
+	return resp, cause
+}
@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+
+	"github.com/golang-queue/queue"
+	qcore "github.com/golang-queue/queue/core"
+	"github.com/sideshow/apns2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{
+		Backoff:    time.Second,
+		MaxBackoff: 8 * time.Second,
+	}
+
+	for attempt, max := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		9: 8 * time.Second,
+	} {
+		delay := policy.Delay(attempt, 0)
+		assert.GreaterOrEqual(t, delay, max/2)
+		assert.LessOrEqual(t, delay, max)
+	}
+
+	// server hint wins when it is longer
+	assert.Equal(t, time.Minute, policy.Delay(1, time.Minute))
+	assert.Equal(t, time.Duration(0), RetryPolicy{}.Delay(3, 0))
+}
+
+func TestRetryAPNs(t *testing.T) {
+	policy := RetryPolicy{MaxBackoff: time.Minute}
+
+	tests := []struct {
+		name      string
+		res       *apns2.Response
+		err       error
+		retryable bool
+		hint      time.Duration
+	}{
+		{"network error", nil, errors.New("connection reset"), true, 0},
+		{"canceled", nil, context.Canceled, false, 0},
+		{"bad token", &apns2.Response{StatusCode: http.StatusBadRequest, Reason: apns2.ReasonBadDeviceToken}, nil, false, 0},
+		{"unregistered", &apns2.Response{StatusCode: http.StatusGone, Reason: apns2.ReasonUnregistered}, nil, false, 0},
+		{"too many requests", &apns2.Response{StatusCode: http.StatusTooManyRequests, Reason: apns2.ReasonTooManyRequests}, nil, true, 0},
+		{
+			"too many provider token updates",
+			&apns2.Response{StatusCode: http.StatusTooManyRequests, Reason: apns2.ReasonTooManyProviderTokenUpdates},
+			nil, true, time.Minute,
+		},
+		{"unavailable", &apns2.Response{StatusCode: http.StatusServiceUnavailable}, nil, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryable, hint := retryAPNs(tt.res, tt.err, policy)
+			assert.Equal(t, tt.retryable, retryable)
+			assert.Equal(t, tt.hint, hint)
+		})
+	}
+}
+
+func TestRetryFCMPermanentError(t *testing.T) {
+	retryable, _ := retryFCM(nil)
+	assert.False(t, retryable)
+
+	retryable, _ = retryFCM(errors.New("invalid registration token"))
+	assert.False(t, retryable)
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), retryAfter(nil))
+
+	resp := &http.Response{Header: http.Header{}}
+	assert.Equal(t, time.Duration(0), retryAfter(resp))
+
+	resp.Header.Set("Retry-After", "120")
+	assert.Equal(t, 2*time.Minute, retryAfter(resp))
+
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.Greater(t, retryAfter(resp), 59*time.Minute)
+
+	resp.Header.Set("Retry-After", "soon")
+	assert.Equal(t, time.Duration(0), retryAfter(resp))
+}
+
+func TestScheduleRetryInPlace(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Core.Retry.Backoff = 0
+
+	queued, err := scheduleRetry(context.Background(), cfg, &PushNotification{}, 1, 0)
+	assert.NoError(t, err)
+	assert.False(t, queued)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = scheduleRetry(ctx, cfg, &PushNotification{}, 1, time.Hour)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestScheduleRetryThroughQueue(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.Core.Sync = false
+	cfg.Core.Retry.Backoff = 0
+
+	received := make(chan *PushNotification, 1)
+	q := queue.NewPool(1, queue.WithFn(func(ctx context.Context, msg qcore.TaskMessage) error {
+		v := &PushNotification{}
+		if err := json.Unmarshal(msg.Payload(), v); err != nil {
+			return err
+		}
+		received <- v
+		return nil
+	}))
+	defer q.Release()
+
+	RetryQueue = q
+	defer func() {
+		RetryQueue = nil
+	}()
+
+	req := &PushNotification{Tokens: []string{"aaa"}}
+	queued, err := scheduleRetry(context.Background(), cfg, req, 2, 0)
+	assert.NoError(t, err)
+	assert.True(t, queued)
+
+	select {
+	case v := <-received:
+		assert.Equal(t, 2, v.RetryAttempt)
+		assert.Equal(t, []string{"aaa"}, v.Tokens)
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry notification was not queued")
+	}
+}
@@ -0,0 +1,537 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+)
+
+const templateKey = "gorush-template:"
+
+var (
+	// ErrTemplateNotFound is returned when no locale of the fallback chain
+	// has the template.
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrInvalidTemplate is returned for a template that can't be stored.
+	ErrInvalidTemplate = errors.New("invalid template")
+	// ErrTemplateRender is returned when the variables can't render the
+	// template, such as a missing variable.
+	ErrTemplateRender = errors.New("can't render template")
+)
+
+// TemplateContent is the text of a notification, each field is a Go
+// text/template rendered with the variables of the recipient.
+type TemplateContent struct {
+	Title string            `json:"title,omitempty"`
+	Body  string            `json:"body,omitempty"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Template is the content of a named template in a locale.
+type Template struct {
+	Name   string `json:"name"`
+	Locale string `json:"locale"`
+	TemplateContent
+	// Platforms override the fields of the content by platform: ios,
+	// android, huawei, webpush, sms, telegram or call.
+	Platforms map[string]TemplateContent `json:"platforms,omitempty"`
+	UpdatedAt int64                      `json:"updated_at"`
+}
+
+// TemplateRecipient is the locale and the variables of a single recipient,
+// over those of the notification.
+type TemplateRecipient struct {
+	Locale string `json:"locale,omitempty"`
+	Vars   D      `json:"vars,omitempty"`
+}
+
+func templateNameKey(name string) string {
+	return templateKey + url.QueryEscape(name) + ":"
+}
+
+func templateRecordKey(name, locale string) string {
+	return templateNameKey(name) + url.QueryEscape(locale)
+}
+
+// normalizeLocale writes the locales the same way, pt_BR and PT-br are pt-br.
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// localeFallbacks returns the locales to try in order: the locale, then the
+// less specific ones, down to the language, then the default locale the
+// same way. zh-hant-tw falls back to zh-hant, then zh.
+func localeFallbacks(locale, defaultLocale string) []string {
+	locales := []string{}
+	for _, l := range []string{locale, defaultLocale} {
+		l = normalizeLocale(l)
+		for l != "" {
+			if !slices.Contains(locales, l) {
+				locales = append(locales, l)
+			}
+			i := strings.LastIndex(l, "-")
+			if i < 0 {
+				break
+			}
+			l = l[:i]
+		}
+	}
+	return locales
+}
+
+func parseTemplateText(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+// executeTemplateText renders a text parsed by parseTemplateText, nil for an
+// empty text.
+func executeTemplateText(tmpl *template.Template, vars D) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, map[string]interface{}(vars)); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrTemplateRender, err)
+	}
+
+	return buf.String(), nil
+}
+
+func checkTemplateContent(name string, content TemplateContent) error {
+	texts := []string{content.Title, content.Body}
+	for _, value := range content.Data {
+		texts = append(texts, value)
+	}
+
+	for _, text := range texts {
+		if _, err := parseTemplateText(name, text); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+		}
+	}
+
+	return nil
+}
+
+// SaveTemplate stores the template in its locale, replacing the one it had.
+func SaveTemplate(tmpl *Template) error {
+	tmpl.Locale = normalizeLocale(tmpl.Locale)
+	switch {
+	case tmpl.Name == "":
+		return fmt.Errorf("%w: the name cannot be empty", ErrInvalidTemplate)
+	case tmpl.Locale == "":
+		return fmt.Errorf("%w: the locale cannot be empty", ErrInvalidTemplate)
+	}
+
+	if err := checkTemplateContent(tmpl.Name, tmpl.TemplateContent); err != nil {
+		return err
+	}
+
+	for platform, content := range tmpl.Platforms {
+		if !slices.ContainsFunc([]int{
+			core.PlatformIOS, core.PlatformAndroid, core.PlatformHuawei, core.PlatformWebPush,
+			core.PlatformSMS, core.PlatformTelegramGateway, core.PlatformCallAuto,
+		}, func(p int) bool { return PlatformPoolName(p) == platform }) {
+			return fmt.Errorf("%w: unknown platform %q", ErrInvalidTemplate, platform)
+		}
+		if err := checkTemplateContent(tmpl.Name, content); err != nil {
+			return err
+		}
+	}
+
+	tmpl.UpdatedAt = time.Now().Unix()
+
+	data, err := json.Marshal(tmpl)
+	if err != nil {
+		return err
+	}
+
+	return status.StatStorage.SetValue(templateRecordKey(tmpl.Name, tmpl.Locale), data, 0)
+}
+
+// GetTemplate returns the template in exactly this locale.
+func GetTemplate(name, locale string) (*Template, error) {
+	data, err := status.StatStorage.GetValue(templateRecordKey(name, normalizeLocale(locale)))
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, ErrTemplateNotFound
+	}
+
+	tmpl := &Template{}
+	if err := json.Unmarshal(data, tmpl); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// ListTemplates returns the locales of the template, sorted by locale.
+func ListTemplates(name string) ([]*Template, error) {
+	keys, err := status.StatStorage.Keys(templateNameKey(name))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(keys)
+
+	templates := []*Template{}
+	for _, key := range keys {
+		data, err := status.StatStorage.GetValue(key)
+		if err != nil {
+			return nil, err
+		}
+
+		tmpl := &Template{}
+		if data == nil || json.Unmarshal(data, tmpl) != nil {
+			continue
+		}
+		templates = append(templates, tmpl)
+	}
+
+	return templates, nil
+}
+
+// DeleteTemplate removes the template in the locale.
+func DeleteTemplate(name, locale string) error {
+	if _, err := GetTemplate(name, locale); err != nil {
+		return err
+	}
+
+	return status.StatStorage.DelValue(templateRecordKey(name, normalizeLocale(locale)))
+}
+
+// ResolveTemplate returns the template in the first locale of the fallback
+// chain that has it.
+func ResolveTemplate(cfg *config.ConfYaml, name, locale string) (*Template, error) {
+	for _, l := range localeFallbacks(locale, cfg.Template.DefaultLocale) {
+		tmpl, err := GetTemplate(name, l)
+		if errors.Is(err, ErrTemplateNotFound) {
+			continue
+		}
+		return tmpl, err
+	}
+
+	return nil, fmt.Errorf("%w: %s in locale %q", ErrTemplateNotFound, name, locale)
+}
+
+// templateRecipients returns the recipients a template is rendered for: the
+// phone numbers of the gateways, the tokens or subscriptions otherwise.
+func templateRecipients(req *PushNotification) []string {
+	switch req.Platform {
+	case core.PlatformSMS, core.PlatformTelegramGateway, core.PlatformCallAuto:
+		return req.PhoneNumbers
+	}
+
+	recipients := req.Recipients()
+	if req.To != "" {
+		recipients = append(slices.Clone(recipients), req.To)
+	}
+	return recipients
+}
+
+// CheckTemplate checks that the templates of the notification and of its
+// variants exist for the locale of the notification and of each recipient.
+func CheckTemplate(cfg *config.ConfYaml, req *PushNotification) error {
+	names := []string{}
+	if req.Template != "" {
+		names = append(names, req.Template)
+	}
+	for _, variant := range req.Variants {
+		if variant.Template != "" && !slices.Contains(names, variant.Template) {
+			names = append(names, variant.Template)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	locales := []string{req.Locale}
+	for _, recipient := range req.RecipientVars {
+		if recipient.Locale != "" && !slices.Contains(locales, recipient.Locale) {
+			locales = append(locales, recipient.Locale)
+		}
+	}
+
+	for _, name := range names {
+		for _, locale := range locales {
+			if _, err := ResolveTemplate(cfg, name, locale); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parsedTemplate is the content of a template for a platform, parsed.
+type parsedTemplate struct {
+	title *template.Template
+	body  *template.Template
+	data  map[string]*template.Template
+}
+
+// templateCache keeps the template of a notification parsed by locale, it is
+// resolved and parsed once for all the recipients of the locale.
+type templateCache struct {
+	cfg       *config.ConfYaml
+	name      string
+	platform  string
+	templates map[string]*parsedTemplate
+	errs      map[string]error
+}
+
+func newTemplateCache(cfg *config.ConfYaml, req *PushNotification) *templateCache {
+	return &templateCache{
+		cfg:       cfg,
+		name:      req.Template,
+		platform:  PlatformPoolName(req.Platform),
+		templates: map[string]*parsedTemplate{},
+		errs:      map[string]error{},
+	}
+}
+
+func (c *templateCache) get(locale string) (*parsedTemplate, error) {
+	locale = normalizeLocale(locale)
+	if tmpl, ok := c.templates[locale]; ok {
+		return tmpl, nil
+	}
+	if err, ok := c.errs[locale]; ok {
+		return nil, err
+	}
+
+	tmpl, err := c.parse(locale)
+	if err != nil {
+		c.errs[locale] = err
+		return nil, err
+	}
+	c.templates[locale] = tmpl
+	return tmpl, nil
+}
+
+func (c *templateCache) parse(locale string) (*parsedTemplate, error) {
+	tmpl, err := ResolveTemplate(c.cfg, c.name, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	content := TemplateContent{
+		Title: tmpl.Title,
+		Body:  tmpl.Body,
+		Data:  map[string]string{},
+	}
+	for k, v := range tmpl.Data {
+		content.Data[k] = v
+	}
+	if override, ok := tmpl.Platforms[c.platform]; ok {
+		if override.Title != "" {
+			content.Title = override.Title
+		}
+		if override.Body != "" {
+			content.Body = override.Body
+		}
+		for k, v := range override.Data {
+			content.Data[k] = v
+		}
+	}
+
+	parse := func(text string) (*template.Template, error) {
+		if text == "" {
+			return nil, nil
+		}
+		return parseTemplateText(tmpl.Name, text)
+	}
+
+	parsed := &parsedTemplate{data: map[string]*template.Template{}}
+	if parsed.title, err = parse(content.Title); err != nil {
+		return nil, err
+	}
+	if parsed.body, err = parse(content.Body); err != nil {
+		return nil, err
+	}
+	for k, v := range content.Data {
+		if parsed.data[k], err = parse(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return parsed, nil
+}
+
+// render renders the template for the recipient, whose own locale and
+// variables win over those of the notification.
+func (c *templateCache) render(req *PushNotification, recipient string) (*TemplateContent, error) {
+	locale, vars := req.Locale, req.Vars
+	if r, ok := req.RecipientVars[recipient]; ok {
+		if r.Locale != "" {
+			locale = r.Locale
+		}
+		if len(r.Vars) > 0 {
+			vars = D{}
+			for k, v := range req.Vars {
+				vars[k] = v
+			}
+			for k, v := range r.Vars {
+				vars[k] = v
+			}
+		}
+	}
+
+	tmpl, err := c.get(locale)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := &TemplateContent{}
+	if rendered.Title, err = executeTemplateText(tmpl.title, vars); err != nil {
+		return nil, err
+	}
+	if rendered.Body, err = executeTemplateText(tmpl.body, vars); err != nil {
+		return nil, err
+	}
+	for k, v := range tmpl.data {
+		value, err := executeTemplateText(v, vars)
+		if err != nil {
+			return nil, err
+		}
+		if rendered.Data == nil {
+			rendered.Data = map[string]string{}
+		}
+		rendered.Data[k] = value
+	}
+
+	return rendered, nil
+}
+
+// RenderTemplate renders the template of the notification for the platform
+// of the notification and the recipient, whose own locale and variables win
+// over those of the notification.
+func RenderTemplate(cfg *config.ConfYaml, req *PushNotification, recipient string) (*TemplateContent, error) {
+	return newTemplateCache(cfg, req).render(req, recipient)
+}
+
+// applyTemplateContent sets the rendered content on the notification, the
+// data of the notification wins over the data of the template.
+func applyTemplateContent(req *PushNotification, content *TemplateContent) {
+	req.Title = content.Title
+	req.Message = content.Body
+	switch req.Platform {
+	case core.PlatformSMS, core.PlatformTelegramGateway, core.PlatformCallAuto:
+		req.SMSMessage = content.Body
+	}
+
+	if len(content.Data) == 0 {
+		return
+	}
+	data := D{}
+	for k, v := range content.Data {
+		data[k] = v
+	}
+	for k, v := range req.Data {
+		data[k] = v
+	}
+	req.Data = data
+}
+
+// setRecipients narrows the notification to the recipients.
+func setRecipients(req *PushNotification, recipients []string) {
+	req.To = ""
+	switch req.Platform {
+	case core.PlatformSMS, core.PlatformTelegramGateway, core.PlatformCallAuto:
+		req.PhoneNumbers = recipients
+	case core.PlatformWebPush:
+		kept := make(map[string]bool, len(recipients))
+		for _, recipient := range recipients {
+			kept[recipient] = true
+		}
+		req.Subscriptions = slices.DeleteFunc(slices.Clone(req.Subscriptions), func(sub WebPushSubscription) bool {
+			return !kept[sub.Endpoint]
+		})
+	default:
+		req.Tokens = recipients
+	}
+}
+
+// sendTemplate renders the template of the notification for each recipient
+// and sends a notification for each distinct content. The recipients it
+// can't be rendered for fail.
+func sendTemplate(ctx context.Context, req *PushNotification, cfg *config.ConfYaml) (*ResponsePush, error) {
+	type group struct {
+		content    *TemplateContent
+		recipients []string
+	}
+
+	recipients := templateRecipients(req)
+	// a topic is rendered once, for no recipient in particular
+	targeted := len(recipients) > 0
+	if !targeted {
+		recipients = []string{""}
+	}
+
+	resp := &ResponsePush{}
+	groups := []*group{}
+	index := map[string]*group{}
+	cache := newTemplateCache(cfg, req)
+	var renderErr error
+	failed := 0
+	for _, recipient := range recipients {
+		content, err := cache.render(req, recipient)
+		if err != nil {
+			logx.LogError.Errorf("can't render template %q: %v", req.Template, err)
+			resp.Logs = append(resp.Logs, logPush(cfg, core.FailedPush, recipient, req, err))
+			renderErr = err
+			failed++
+			continue
+		}
+
+		data, err := json.Marshal(content)
+		if err != nil {
+			return nil, err
+		}
+		g, ok := index[string(data)]
+		if !ok {
+			g = &group{content: content}
+			index[string(data)] = g
+			groups = append(groups, g)
+		}
+		if targeted {
+			g.recipients = append(g.recipients, recipient)
+		}
+	}
+
+	if len(groups) == 0 {
+		SetDeliveryState(cfg, req, DeliveryFailed, renderErr)
+		countCampaignResult(req, resp, renderErr)
+		countVariantResult(req, resp, renderErr)
+		return resp, renderErr
+	}
+	addCampaignCount(req, campaignFailed, failed)
+	addVariantCount(req, variantFailed, failed)
+
+	batches := make([]*PushNotification, 0, len(groups))
+	for _, g := range groups {
+		batch := *req
+		batch.Template = ""
+		batch.Locale = ""
+		batch.Vars = nil
+		batch.RecipientVars = nil
+		applyTemplateContent(&batch, g.content)
+		if targeted {
+			setRecipients(&batch, g.recipients)
+		}
+		batches = append(batches, &batch)
+	}
+
+	return sendBatches(ctx, req, cfg, resp, batches)
+}
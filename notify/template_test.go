@@ -0,0 +1,235 @@
+package notify
+
+import (
+	"context"
+	"testing"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func saveTestTemplates(t *testing.T, templates ...*Template) {
+	t.Helper()
+
+	for _, tmpl := range templates {
+		assert.NoError(t, SaveTemplate(tmpl))
+		name, locale := tmpl.Name, tmpl.Locale
+		t.Cleanup(func() { _ = DeleteTemplate(name, locale) })
+	}
+}
+
+func TestLocaleFallbacks(t *testing.T) {
+	assert.Equal(t, []string{"zh-hant-tw", "zh-hant", "zh", "en"}, localeFallbacks("zh_Hant_TW", "en"))
+	assert.Equal(t, []string{"pt-br", "pt", "en-gb", "en"}, localeFallbacks("pt-BR", "en-GB"))
+	assert.Equal(t, []string{"en"}, localeFallbacks("", "en"))
+	assert.Equal(t, []string{"en"}, localeFallbacks("EN", "en"))
+	assert.Empty(t, localeFallbacks("", ""))
+}
+
+func TestSaveTemplate(t *testing.T) {
+	saveTestTemplates(t, &Template{
+		Name:            "save",
+		Locale:          "pt_BR",
+		TemplateContent: TemplateContent{Title: "Olá {{.name}}"},
+	})
+
+	tmpl, err := GetTemplate("save", "PT-br")
+	assert.NoError(t, err)
+	assert.Equal(t, "pt-br", tmpl.Locale)
+	assert.Equal(t, "Olá {{.name}}", tmpl.Title)
+	assert.NotZero(t, tmpl.UpdatedAt)
+
+	templates, err := ListTemplates("save")
+	assert.NoError(t, err)
+	assert.Len(t, templates, 1)
+
+	assert.ErrorIs(t, SaveTemplate(&Template{Locale: "en"}), ErrInvalidTemplate)
+	assert.ErrorIs(t, SaveTemplate(&Template{Name: "save"}), ErrInvalidTemplate)
+	assert.ErrorIs(t, SaveTemplate(&Template{
+		Name:            "save",
+		Locale:          "en",
+		TemplateContent: TemplateContent{Body: "Hello {{.name"},
+	}), ErrInvalidTemplate)
+	assert.ErrorIs(t, SaveTemplate(&Template{
+		Name:      "save",
+		Locale:    "en",
+		Platforms: map[string]TemplateContent{"pager": {Body: "Hello"}},
+	}), ErrInvalidTemplate)
+
+	assert.NoError(t, DeleteTemplate("save", "pt-br"))
+	_, err = GetTemplate("save", "pt-br")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.ErrorIs(t, DeleteTemplate("save", "pt-br"), ErrTemplateNotFound)
+}
+
+func TestRenderTemplate(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	saveTestTemplates(t,
+		&Template{
+			Name:   "render",
+			Locale: "en",
+			TemplateContent: TemplateContent{
+				Title: "Hello {{.name}}",
+				Body:  "Your order {{.order}} has shipped",
+				Data:  map[string]string{"order": "{{.order}}"},
+			},
+			Platforms: map[string]TemplateContent{
+				"sms": {Body: "Order {{.order}} shipped"},
+			},
+		},
+		&Template{
+			Name:            "render",
+			Locale:          "ru",
+			TemplateContent: TemplateContent{Title: "Привет, {{.name}}", Body: "Заказ {{.order}} отправлен"},
+		},
+	)
+
+	req := &PushNotification{
+		Platform: core.PlatformIOS,
+		Template: "render",
+		Locale:   "ru-RU",
+		Vars:     D{"name": "Ivan", "order": 42},
+		RecipientVars: map[string]TemplateRecipient{
+			"bbbb": {Locale: "de", Vars: D{"name": "Hans"}},
+		},
+	}
+
+	content, err := RenderTemplate(cfg, req, "aaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, &TemplateContent{Title: "Привет, Ivan", Body: "Заказ 42 отправлен"}, content)
+
+	// no german template, the default locale is used
+	content, err = RenderTemplate(cfg, req, "bbbb")
+	assert.NoError(t, err)
+	assert.Equal(t, &TemplateContent{
+		Title: "Hello Hans",
+		Body:  "Your order 42 has shipped",
+		Data:  map[string]string{"order": "42"},
+	}, content)
+
+	req.Platform = core.PlatformSMS
+	req.Locale = ""
+	content, err = RenderTemplate(cfg, req, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello Ivan", content.Title)
+	assert.Equal(t, "Order 42 shipped", content.Body)
+
+	req.Vars = D{"name": "Ivan"}
+	_, err = RenderTemplate(cfg, req, "")
+	assert.ErrorIs(t, err, ErrTemplateRender)
+
+	req.Template = "missing"
+	_, err = RenderTemplate(cfg, req, "")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.ErrorIs(t, CheckTemplate(cfg, req), ErrTemplateNotFound)
+}
+
+func TestTemplateCacheParsesOnce(t *testing.T) {
+	cfg, _ := config.LoadConf()
+
+	saveTestTemplates(t, &Template{
+		Name:            "cached",
+		Locale:          "en",
+		TemplateContent: TemplateContent{Body: "Hello {{.name}}"},
+	})
+
+	req := &PushNotification{
+		Platform: core.PlatformIOS,
+		Template: "cached",
+		Vars:     D{"name": "Ivan"},
+		RecipientVars: map[string]TemplateRecipient{
+			"bbbb": {Vars: D{"name": "Hans"}},
+		},
+	}
+	cache := newTemplateCache(cfg, req)
+
+	content, err := cache.render(req, "aaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello Ivan", content.Body)
+
+	// the other recipients of the locale don't read the template again
+	assert.NoError(t, DeleteTemplate("cached", "en"))
+	content, err = cache.render(req, "bbbb")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello Hans", content.Body)
+}
+
+func TestSendTemplate(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.Ios.Enabled = true
+
+	saveTestTemplates(t,
+		&Template{
+			Name:            "send",
+			Locale:          "en",
+			TemplateContent: TemplateContent{Body: "Hello {{.name}}"},
+		},
+		&Template{
+			Name:            "send",
+			Locale:          "fr",
+			TemplateContent: TemplateContent{Body: "Bonjour {{.name}}"},
+		},
+	)
+
+	_, err := CreateCampaign(cfg, "campaign-template", "")
+	assert.NoError(t, err)
+
+	req := &PushNotification{
+		Platform:   core.PlatformIOS,
+		Tokens:     []string{"template-aaaa", "template-bbbb", "template-cccc", "template-dddd"},
+		Template:   "send",
+		Vars:       D{"name": "Alex"},
+		CampaignID: "campaign-template",
+		RecipientVars: map[string]TemplateRecipient{
+			"template-bbbb": {Locale: "fr"},
+			"template-cccc": {Locale: "fr_CA"},
+			"template-dddd": {Vars: D{"name": "{{.name}}"}},
+		},
+	}
+	CampaignQueued(req)
+
+	resp, err := SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+
+	bodies := map[string]string{}
+	for _, message := range mock.Messages("apns") {
+		data, err := json.Marshal(message.Body)
+		assert.NoError(t, err)
+		bodies[message.Tokens[0]] = string(data)
+	}
+	assert.Len(t, bodies, 4)
+	assert.Contains(t, bodies["template-aaaa"], "Hello Alex")
+	assert.Contains(t, bodies["template-bbbb"], "Bonjour Alex")
+	assert.Contains(t, bodies["template-cccc"], "Bonjour Alex")
+	// the variables are not templates themselves
+	assert.Contains(t, bodies["template-dddd"], "Hello {{.name}}")
+
+	campaign, err := GetCampaign("campaign-template")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), campaign.Sent)
+
+	// the recipients without their variables fail, the others get it
+	req = &PushNotification{
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"template-aaaa", "template-bbbb"},
+		Template: "send",
+		RecipientVars: map[string]TemplateRecipient{
+			"template-aaaa": {Vars: D{"name": "Alex"}},
+		},
+	}
+	resp, err = SendNotification(context.Background(), req, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 1)
+	assert.Equal(t, core.FailedPush, resp.Logs[0].Type)
+	assert.Equal(t, "template-bbbb", resp.Logs[0].Token)
+	assert.Len(t, mock.Messages("apns"), 5)
+
+	req.RecipientVars = nil
+	_, err = SendNotification(context.Background(), req, cfg)
+	assert.ErrorIs(t, err, ErrTemplateRender)
+	assert.Len(t, mock.Messages("apns"), 5)
+}
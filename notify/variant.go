@@ -0,0 +1,286 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/status"
+)
+
+const (
+	experimentKey      = "gorush-experiment:"
+	experimentCountKey = "gorush-experiment-count:"
+)
+
+// The counters of the recipients of a variant.
+const (
+	variantAssigned = "assigned"
+	variantSent     = "sent"
+	variantFailed   = "failed"
+	variantCapped   = "capped"
+)
+
+var (
+	// ErrInvalidVariants is returned for variants that can't be sent.
+	ErrInvalidVariants = errors.New("invalid variants")
+	// ErrExperimentNotFound is returned for an experiment without variant
+	// sent.
+	ErrExperimentNotFound = errors.New("experiment not found")
+)
+
+// Variant is a version of the message sent to a share of the recipients, in
+// proportion to its weight. Its fields replace those of the notification.
+type Variant struct {
+	Name     string `json:"name"`
+	Weight   int    `json:"weight"`
+	Title    string `json:"title,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Template string `json:"template,omitempty"`
+	Data     D      `json:"data,omitempty"`
+}
+
+// VariantStats are the counts of the recipients of a variant: the assigned
+// ones, then those it was sent to, failed for or capped for.
+type VariantStats struct {
+	Name      string `json:"name"`
+	Weight    int    `json:"weight"`
+	Assigned  int64  `json:"assigned"`
+	Sent      int64  `json:"sent"`
+	Failed    int64  `json:"failed"`
+	Capped    int64  `json:"capped"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Experiment is the stats of the variants sent with the same experiment name.
+type Experiment struct {
+	Name     string          `json:"name"`
+	Variants []*VariantStats `json:"variants"`
+}
+
+func experimentNameKey(experiment string) string {
+	return experimentKey + url.QueryEscape(experiment) + ":"
+}
+
+func variantRecordKey(experiment, variant string) string {
+	return experimentNameKey(experiment) + url.QueryEscape(variant)
+}
+
+func variantCounterKey(experiment, variant, counter string) string {
+	return experimentCountKey + url.QueryEscape(experiment) + ":" + url.QueryEscape(variant) + ":" + counter
+}
+
+// CheckVariants validates the variants of the notification, which need an
+// experiment and recipients to split.
+func CheckVariants(req *PushNotification) error {
+	if len(req.Variants) == 0 {
+		return nil
+	}
+
+	if req.Experiment == "" {
+		return fmt.Errorf("%w: the experiment cannot be empty", ErrInvalidVariants)
+	}
+
+	if req.IsTopic() {
+		return fmt.Errorf("%w: the recipients of a topic can't be split", ErrInvalidVariants)
+	}
+
+	names := make([]string, 0, len(req.Variants))
+	total := 0
+	for _, variant := range req.Variants {
+		switch {
+		case variant.Name == "":
+			return fmt.Errorf("%w: the name cannot be empty", ErrInvalidVariants)
+		case slices.Contains(names, variant.Name):
+			return fmt.Errorf("%w: duplicate name %q", ErrInvalidVariants, variant.Name)
+		case variant.Weight < 0:
+			return fmt.Errorf("%w: variant %q has a negative weight", ErrInvalidVariants, variant.Name)
+		}
+		names = append(names, variant.Name)
+		total += variant.Weight
+	}
+
+	if total == 0 {
+		return fmt.Errorf("%w: the weights add up to zero", ErrInvalidVariants)
+	}
+
+	return nil
+}
+
+// AssignVariant returns the variant of the key, a token or a user ID, picked
+// by weight from the hash of the experiment and the key: the same key always
+// gets the same variant of the same experiment. It returns nil when the
+// weights add up to zero.
+func AssignVariant(experiment string, variants []Variant, key string) *Variant {
+	total := 0
+	for _, variant := range variants {
+		total += max(variant.Weight, 0)
+	}
+	if total == 0 {
+		return nil
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(experiment))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	n := h.Sum64() % uint64(total)
+
+	for i := range variants {
+		weight := uint64(max(variants[i].Weight, 0))
+		if n < weight {
+			return &variants[i]
+		}
+		n -= weight
+	}
+
+	return nil
+}
+
+// applyVariant replaces the fields of the notification with those of the
+// variant, the data of the variant wins over the data of the notification.
+func applyVariant(req *PushNotification, variant *Variant) {
+	req.Variants = nil
+	req.Variant = variant.Name
+	if variant.Title != "" {
+		req.Title = variant.Title
+	}
+	if variant.Message != "" {
+		req.Message = variant.Message
+		switch req.Platform {
+		case core.PlatformSMS, core.PlatformTelegramGateway, core.PlatformCallAuto:
+			req.SMSMessage = variant.Message
+		}
+	}
+	if variant.Template != "" {
+		req.Template = variant.Template
+	}
+
+	if len(variant.Data) == 0 {
+		return
+	}
+	data := D{}
+	for k, v := range req.Data {
+		data[k] = v
+	}
+	for k, v := range variant.Data {
+		data[k] = v
+	}
+	req.Data = data
+}
+
+// recordVariant keeps the variant of the experiment, for its stats to be
+// listed.
+func recordVariant(experiment string, variant *Variant) {
+	data, err := json.Marshal(VariantStats{
+		Name:      variant.Name,
+		Weight:    variant.Weight,
+		UpdatedAt: time.Now().Unix(),
+	})
+	if err == nil {
+		err = status.StatStorage.SetValue(variantRecordKey(experiment, variant.Name), data, 0)
+	}
+	if err != nil {
+		logx.LogError.Error("can't record variant: " + err.Error())
+	}
+}
+
+// addVariantCount counts the recipients of a variant.
+func addVariantCount(req *PushNotification, counter string, count int) {
+	if req.Experiment == "" || req.Variant == "" || count <= 0 {
+		return
+	}
+	status.StatStorage.AddCount(variantCounterKey(req.Experiment, req.Variant, counter), int64(count))
+}
+
+// countVariantResult counts the recipients of a variant sent, failed or
+// capped.
+func countVariantResult(req *PushNotification, resp *ResponsePush, err error) {
+	if req.Variant == "" {
+		return
+	}
+
+	sent, failed, capped := resultCounts(req, resp, err)
+	addVariantCount(req, variantSent, sent)
+	addVariantCount(req, variantFailed, failed)
+	addVariantCount(req, variantCapped, capped)
+}
+
+// GetExperiment returns the stats of the variants of the experiment, sorted
+// by name.
+func GetExperiment(name string) (*Experiment, error) {
+	keys, err := status.StatStorage.Keys(experimentNameKey(name))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(keys)
+
+	experiment := &Experiment{Name: name, Variants: []*VariantStats{}}
+	for _, key := range keys {
+		data, err := status.StatStorage.GetValue(key)
+		if err != nil {
+			return nil, err
+		}
+
+		stats := &VariantStats{}
+		if data == nil || json.Unmarshal(data, stats) != nil {
+			continue
+		}
+		stats.Assigned = status.StatStorage.GetCount(variantCounterKey(name, stats.Name, variantAssigned))
+		stats.Sent = status.StatStorage.GetCount(variantCounterKey(name, stats.Name, variantSent))
+		stats.Failed = status.StatStorage.GetCount(variantCounterKey(name, stats.Name, variantFailed))
+		stats.Capped = status.StatStorage.GetCount(variantCounterKey(name, stats.Name, variantCapped))
+		experiment.Variants = append(experiment.Variants, stats)
+	}
+
+	if len(experiment.Variants) == 0 {
+		return nil, ErrExperimentNotFound
+	}
+
+	return experiment, nil
+}
+
+// sendVariants assigns each recipient of the notification a variant, per
+// user when it has a user ID, and sends a notification for each variant.
+func sendVariants(ctx context.Context, req *PushNotification, cfg *config.ConfYaml) (*ResponsePush, error) {
+	assigned := map[string][]string{}
+	for _, recipient := range templateRecipients(req) {
+		key := req.UserID
+		if key == "" {
+			key = recipient
+		}
+		if variant := AssignVariant(req.Experiment, req.Variants, key); variant != nil {
+			assigned[variant.Name] = append(assigned[variant.Name], recipient)
+		}
+	}
+
+	batches := []*PushNotification{}
+	for i := range req.Variants {
+		variant := &req.Variants[i]
+		recipients := assigned[variant.Name]
+		if len(recipients) == 0 {
+			continue
+		}
+
+		batch := *req
+		applyVariant(&batch, variant)
+		setRecipients(&batch, recipients)
+		batches = append(batches, &batch)
+
+		if req.RetryAttempt == 0 {
+			recordVariant(req.Experiment, variant)
+			addVariantCount(&batch, variantAssigned, len(recipients))
+		}
+		logx.LogAccess.Debugf("experiment %q assigned %d recipients to variant %q",
+			req.Experiment, len(recipients), variant.Name)
+	}
+
+	return sendBatches(ctx, req, cfg, &ResponsePush{}, batches)
+}
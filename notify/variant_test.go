@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/appleboy/gorush/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckVariants(t *testing.T) {
+	variants := []Variant{{Name: "a", Weight: 50}, {Name: "b", Weight: 50}}
+
+	assert.NoError(t, CheckVariants(&PushNotification{}))
+	assert.NoError(t, CheckVariants(&PushNotification{Experiment: "check", Variants: variants}))
+
+	assert.ErrorIs(t, CheckVariants(&PushNotification{Variants: variants}), ErrInvalidVariants)
+	assert.ErrorIs(t, CheckVariants(&PushNotification{
+		Experiment: "check",
+		Platform:   core.PlatformAndroid,
+		Topic:      "/topics/news",
+		Variants:   variants,
+	}), ErrInvalidVariants)
+
+	for _, invalid := range [][]Variant{
+		{{Weight: 1}},
+		{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}},
+		{{Name: "a", Weight: -1}, {Name: "b", Weight: 2}},
+		{{Name: "a"}, {Name: "b"}},
+	} {
+		assert.ErrorIs(t, CheckVariants(&PushNotification{Experiment: "check", Variants: invalid}), ErrInvalidVariants)
+	}
+}
+
+func TestAssignVariant(t *testing.T) {
+	variants := []Variant{{Name: "a", Weight: 90}, {Name: "b", Weight: 10}, {Name: "off"}}
+
+	counts := map[string]int{}
+	for i := range 1000 {
+		key := fmt.Sprintf("token-%d", i)
+		variant := AssignVariant("assign", variants, key)
+		counts[variant.Name]++
+		// the same key always gets the same variant
+		assert.Equal(t, variant, AssignVariant("assign", variants, key))
+	}
+
+	assert.InDelta(t, 900, counts["a"], 50)
+	assert.InDelta(t, 100, counts["b"], 50)
+	assert.Zero(t, counts["off"])
+
+	assert.Nil(t, AssignVariant("assign", []Variant{{Name: "off"}}, "token-0"))
+}
+
+func TestSendVariants(t *testing.T) {
+	cfg, mock := initMockProviders(t)
+	cfg.Ios.Enabled = true
+	cfg.DeliveryStatus.Enabled = true
+
+	variants := []Variant{
+		{Name: "short", Weight: 1, Message: "Sale"},
+		{Name: "long", Weight: 1, Message: "Our summer sale starts today", Data: D{"cta": "shop"}},
+	}
+
+	tokens := make([]string, 0, 20)
+	for i := range 20 {
+		tokens = append(tokens, fmt.Sprintf("variant-%02d", i))
+	}
+
+	resp, err := SendNotification(context.Background(), &PushNotification{
+		ID:         "variant-send",
+		Platform:   core.PlatformIOS,
+		Tokens:     tokens,
+		Message:    "Hello",
+		Experiment: "summer-sale",
+		Variants:   variants,
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Logs)
+	assert.Len(t, mock.Messages("apns"), 20)
+
+	delivery, err := GetDeliveryStatus("variant-send")
+	assert.NoError(t, err)
+	assert.Len(t, delivery.Tokens, 20)
+	for _, token := range delivery.Tokens {
+		assert.Equal(t, AssignVariant("summer-sale", variants, token.Token).Name, token.Variant)
+	}
+
+	experiment, err := GetExperiment("summer-sale")
+	assert.NoError(t, err)
+	assert.Len(t, experiment.Variants, 2)
+	assigned := int64(0)
+	for _, stats := range experiment.Variants {
+		assert.Equal(t, stats.Assigned, stats.Sent)
+		assert.Zero(t, stats.Failed)
+		assert.Equal(t, 1, stats.Weight)
+		assigned += stats.Assigned
+	}
+	assert.Equal(t, int64(20), assigned)
+
+	// the devices of a user all get the variant of the user
+	mock.SetFailures(MockFailures{InvalidTokens: []string{"user-bbbb"}})
+	resp, err = SendNotification(context.Background(), &PushNotification{
+		Platform:   core.PlatformIOS,
+		Tokens:     []string{"user-aaaa", "user-bbbb"},
+		Message:    "Hello",
+		UserID:     "42",
+		Experiment: "summer-sale",
+		Variants:   variants,
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Logs, 1)
+	assert.Equal(t, AssignVariant("summer-sale", variants, "42").Name, resp.Logs[0].Variant)
+
+	experiment, err = GetExperiment("summer-sale")
+	assert.NoError(t, err)
+	failed := int64(0)
+	for _, stats := range experiment.Variants {
+		failed += stats.Failed
+	}
+	assert.Equal(t, int64(1), failed)
+
+	_, err = GetExperiment("winter-sale")
+	assert.ErrorIs(t, err, ErrExperimentNotFound)
+}
@@ -0,0 +1,124 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// audienceRequest selects the audience to measure, restricted to the
+// platform unless it is zero.
+type audienceRequest struct {
+	Audience string `json:"audience"`
+	Platform int    `json:"platform"`
+}
+
+func registerAudienceRoutes(r *gin.Engine, cfg *config.ConfYaml) {
+	g := r.Group(cfg.API.AudienceURI)
+	g.POST("/devices", registerDeviceHandler)
+	g.GET("/devices/:token", deviceHandler)
+	g.DELETE("/devices/:token", deleteDeviceHandler)
+	g.POST("/size", audienceSizeHandler(cfg))
+}
+
+func audienceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, notify.ErrDeviceNotFound):
+		abortWithError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, notify.ErrInvalidDevice), errors.Is(err, notify.ErrInvalidAudience):
+		abortWithError(c, http.StatusBadRequest, err.Error())
+	default:
+		logx.LogError.Error(err)
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// registerDeviceHandler stores the tags of a device, replacing those it had.
+func registerDeviceHandler(c *gin.Context) {
+	var device notify.Device
+
+	if err := c.ShouldBindJSON(&device); err != nil {
+		logx.LogAccess.Debug(err)
+		abortWithError(c, http.StatusBadRequest, "Invalid device request body.")
+		return
+	}
+
+	if err := notify.RegisterDevice(&device); err != nil {
+		audienceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+func deviceHandler(c *gin.Context) {
+	device, err := notify.GetDevice(c.Param("token"))
+	if err != nil {
+		audienceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+func deleteDeviceHandler(c *gin.Context) {
+	if err := notify.DeleteDevice(c.Param("token")); err != nil {
+		audienceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": "ok",
+	})
+}
+
+// audienceSizeHandler counts the devices an audience matches now, by
+// platform, without sending anything.
+func audienceSizeHandler(cfg *config.ConfYaml) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var form audienceRequest
+
+		if err := c.ShouldBindJSON(&form); err != nil {
+			logx.LogAccess.Debug(err)
+			abortWithError(c, http.StatusBadRequest, "Invalid audience request body.")
+			return
+		}
+
+		if form.Audience == "" {
+			abortWithError(c, http.StatusBadRequest, "The audience cannot be empty.")
+			return
+		}
+
+		if err := notify.CheckAudience(&notify.PushNotification{
+			Audience: form.Audience,
+			Platform: form.Platform,
+		}); err != nil {
+			audienceError(c, err)
+			return
+		}
+
+		tokens, err := notify.ResolveAudience(cfg, form.Audience, form.Platform)
+		if err != nil {
+			audienceError(c, err)
+			return
+		}
+
+		size := 0
+		platforms := gin.H{}
+		for platform, platformTokens := range tokens {
+			size += len(platformTokens)
+			platforms[notify.PlatformPoolName(platform)] = len(platformTokens)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"audience":  form.Audience,
+			"size":      size,
+			"platforms": platforms,
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/notify"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-queue/queue"
+)
+
+// campaignRequest creates a campaign. Its notifications are optional, more
+// can be sent later with the campaign_id field of the push APIs.
+type campaignRequest struct {
+	ID            string                    `json:"id"`
+	Name          string                    `json:"name"`
+	Notifications []notify.PushNotification `json:"notifications"`
+}
+
+func registerCampaignRoutes(r *gin.Engine, cfg *config.ConfYaml, q *queue.Queue) {
+	g := r.Group(cfg.API.CampaignURI)
+	g.POST("", createCampaignHandler(cfg, q))
+	g.GET("/:id", campaignHandler)
+	g.POST("/:id/pause", campaignStateHandler(cfg, notify.CampaignPaused))
+	g.POST("/:id/resume", campaignStateHandler(cfg, notify.CampaignRunning))
+	g.POST("/:id/cancel", campaignStateHandler(cfg, notify.CampaignCanceled))
+}
+
+func campaignError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, notify.ErrCampaignNotFound):
+		abortWithError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, notify.ErrCampaignExists), errors.Is(err, notify.ErrCampaignState):
+		abortWithError(c, http.StatusConflict, err.Error())
+	default:
+		logx.LogError.Error(err)
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// createCampaignHandler creates the campaign and queues its notifications.
+// They are never waited for, the progress is read from the status endpoint.
+func createCampaignHandler(cfg *config.ConfYaml, q *queue.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var form campaignRequest
+
+		if err := c.ShouldBindJSON(&form); err != nil {
+			logx.LogAccess.Debug(err)
+			abortWithError(c, http.StatusBadRequest, "Invalid campaign request body.")
+			return
+		}
+
+		// the campaign doesn't exist yet, it is set once created
+		for i := range form.Notifications {
+			form.Notifications[i].CampaignID = ""
+		}
+		if !checkPushNotifications(c, cfg, form.Notifications) {
+			return
+		}
+
+		campaign, err := notify.CreateCampaign(cfg, form.ID, form.Name)
+		if err != nil {
+			campaignError(c, err)
+			return
+		}
+
+		for i := range form.Notifications {
+			form.Notifications[i].CampaignID = campaign.ID
+		}
+
+		asyncCfg := *cfg
+		asyncCfg.Core.Sync = false
+
+		logs := []logx.LogPushEntry{}
+		counts := dispatchNotification(
+			context.Background(),
+			&asyncCfg,
+			notify.RequestPush{Notifications: form.Notifications},
+			q,
+			func(entries []logx.LogPushEntry) {
+				logs = append(logs, entries...)
+			},
+		)
+
+		if campaign, err = notify.GetCampaign(campaign.ID); err != nil {
+			campaignError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":  "ok",
+			"counts":   counts,
+			"logs":     logs,
+			"campaign": campaign,
+		})
+	}
+}
+
+func campaignHandler(c *gin.Context) {
+	campaign, err := notify.GetCampaign(c.Param("id"))
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign)
+}
+
+// campaignStateHandler pauses, resumes or cancels the campaign. The workers
+// check its state before sending each notification.
+func campaignStateHandler(cfg *config.ConfYaml, state string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaign, err := notify.SetCampaignState(cfg, c.Param("id"), state)
+		if err != nil {
+			campaignError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, campaign)
+	}
+}
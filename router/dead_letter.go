@@ -0,0 +1,156 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/notify"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-queue/queue"
+)
+
+// deadLetterBatch selects dead letters by ID, or by filter when no ID is
+// given. An empty filter selects every dead letter.
+type deadLetterBatch struct {
+	IDs    []string                 `json:"ids"`
+	Filter *notify.DeadLetterFilter `json:"filter"`
+}
+
+func registerDeadLetterRoutes(r *gin.Engine, cfg *config.ConfYaml, q *queue.Queue) {
+	g := r.Group(cfg.API.DeadLetterURI)
+	g.GET("", deadLettersHandler)
+	g.GET("/:id", deadLetterHandler)
+	g.DELETE("/:id", deleteDeadLetterHandler)
+	g.POST("/:id/requeue", requeueDeadLetterHandler(q))
+	g.POST("/delete", deleteDeadLettersHandler)
+	g.POST("/requeue", requeueDeadLettersHandler(q))
+}
+
+func deadLetterError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, notify.ErrDeadLetterNotFound):
+		abortWithError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, notify.ErrDeadLetterNoQueue):
+		abortWithError(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		logx.LogError.Error(err)
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func deadLettersResponse(c *gin.Context, letters []*notify.DeadLetter) {
+	c.JSON(http.StatusOK, gin.H{
+		"counts":       len(letters),
+		"dead_letters": letters,
+	})
+}
+
+// deadLettersHandler lists the dead letters selected by the query parameters.
+func deadLettersHandler(c *gin.Context) {
+	var filter notify.DeadLetterFilter
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		logx.LogAccess.Debug(err)
+		abortWithError(c, http.StatusBadRequest, "Invalid dead letter filter.")
+		return
+	}
+
+	letters, err := notify.ListDeadLetters(filter)
+	if err != nil {
+		deadLetterError(c, err)
+		return
+	}
+
+	deadLettersResponse(c, letters)
+}
+
+func deadLetterHandler(c *gin.Context) {
+	letter, err := notify.GetDeadLetter(c.Param("id"))
+	if err != nil {
+		deadLetterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, letter)
+}
+
+func deleteDeadLetterHandler(c *gin.Context) {
+	if err := notify.DeleteDeadLetter(c.Param("id")); err != nil {
+		deadLetterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": "ok",
+	})
+}
+
+func requeueDeadLetterHandler(q *queue.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		letter, err := notify.RequeueDeadLetter(q, c.Param("id"))
+		if err != nil {
+			deadLetterError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, letter)
+	}
+}
+
+// bindDeadLetterBatch parses the bulk request body, which has to give either
+// the IDs or a filter.
+func bindDeadLetterBatch(c *gin.Context) (*deadLetterBatch, bool) {
+	var batch deadLetterBatch
+
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		logx.LogAccess.Debug(err)
+		abortWithError(c, http.StatusBadRequest, "Invalid dead letter request body.")
+		return nil, false
+	}
+
+	if len(batch.IDs) == 0 && batch.Filter == nil {
+		abortWithError(c, http.StatusBadRequest, "The ids or the filter of the dead letters are required.")
+		return nil, false
+	}
+
+	if batch.Filter == nil {
+		batch.Filter = &notify.DeadLetterFilter{}
+	}
+
+	return &batch, true
+}
+
+func deleteDeadLettersHandler(c *gin.Context) {
+	batch, ok := bindDeadLetterBatch(c)
+	if !ok {
+		return
+	}
+
+	letters, err := notify.DeleteDeadLetters(batch.IDs, *batch.Filter)
+	if err != nil {
+		deadLetterError(c, err)
+		return
+	}
+
+	deadLettersResponse(c, letters)
+}
+
+func requeueDeadLettersHandler(q *queue.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		batch, ok := bindDeadLetterBatch(c)
+		if !ok {
+			return
+		}
+
+		letters, err := notify.RequeueDeadLetters(q, batch.IDs, *batch.Filter)
+		if err != nil {
+			deadLetterError(c, err)
+			return
+		}
+
+		deadLettersResponse(c, letters)
+	}
+}
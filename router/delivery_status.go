@@ -0,0 +1,33 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+func registerDeliveryStatusRoutes(r *gin.Engine, cfg *config.ConfYaml) {
+	r.GET(cfg.API.DeliveryStatusURI+"/:id", deliveryStatusHandler)
+}
+
+// deliveryStatusHandler returns the delivery status of the notification with
+// the notif_id.
+func deliveryStatusHandler(c *gin.Context) {
+	record, err := notify.GetDeliveryStatus(c.Param("id"))
+	if errors.Is(err, notify.ErrDeliveryStatusNotFound) {
+		abortWithError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logx.LogError.Error(err)
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
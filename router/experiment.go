@@ -0,0 +1,34 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+func registerExperimentRoutes(r *gin.Engine, cfg *config.ConfYaml) {
+	g := r.Group(cfg.API.ExperimentURI)
+	g.GET("/:name", experimentHandler)
+}
+
+// experimentHandler shows the delivery stats of each variant of the
+// experiment.
+func experimentHandler(c *gin.Context) {
+	experiment, err := notify.GetExperiment(c.Param("name"))
+	if err != nil {
+		if errors.Is(err, notify.ErrExperimentNotFound) {
+			abortWithError(c, http.StatusNotFound, err.Error())
+			return
+		}
+		logx.LogError.Error(err)
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, experiment)
+}
@@ -0,0 +1,161 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/notify"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type liveActivityFunc func(context.Context, *notify.RequestLiveActivity, *config.ConfYaml) (*notify.ResponsePush, error)
+
+func registerLiveActivityRoutes(r *gin.Engine, cfg *config.ConfYaml) {
+	g := r.Group(cfg.API.LiveActivityURI)
+	g.POST("/push-to-start", liveActivityTokenHandler(cfg, true))
+	g.DELETE("/push-to-start", pushToStartDeleteHandler(cfg))
+	g.POST("/token", liveActivityTokenHandler(cfg, false))
+	g.POST("/start", liveActivityHandler(cfg, notify.StartLiveActivity))
+	g.POST("/end-all", liveActivityHandler(cfg, notify.EndUserLiveActivities))
+	g.GET("/activities/:id", liveActivityStatusHandler(cfg))
+	g.POST("/activities/:id/update", liveActivityHandler(cfg, notify.UpdateLiveActivity))
+	g.POST("/activities/:id/end", liveActivityHandler(cfg, notify.EndLiveActivity))
+}
+
+// bindLiveActivity parses the request body, the activity ID of the path wins
+// over the one of the body.
+func bindLiveActivity(c *gin.Context, cfg *config.ConfYaml) (*notify.RequestLiveActivity, bool) {
+	var req notify.RequestLiveActivity
+
+	if !cfg.Ios.Enabled {
+		msg := "iOS notification is disabled."
+		logx.LogAccess.Debug(msg)
+		abortWithError(c, http.StatusBadRequest, msg)
+		return nil, false
+	}
+
+	// ending an activity doesn't need a body
+	if err := c.ShouldBindWith(&req, binding.JSON); err != nil && !errors.Is(err, io.EOF) {
+		logx.LogAccess.Debug(err)
+		abortWithError(c, http.StatusBadRequest, "Invalid live activity request body.")
+		return nil, false
+	}
+
+	if id := c.Param("id"); id != "" {
+		req.ActivityID = id
+	}
+
+	return &req, true
+}
+
+func liveActivityError(c *gin.Context, err error, badRequest bool) {
+	switch {
+	case errors.Is(err, notify.ErrLiveActivityNotFound):
+		abortWithError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, notify.ErrLiveActivityNoToken):
+		abortWithError(c, http.StatusConflict, err.Error())
+	case badRequest:
+		abortWithError(c, http.StatusBadRequest, err.Error())
+	default:
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func liveActivityTokenHandler(cfg *config.ConfYaml, pushToStart bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, ok := bindLiveActivity(c, cfg)
+		if !ok {
+			return
+		}
+
+		if pushToStart {
+			if err := notify.RegisterPushToStartToken(req); err != nil {
+				liveActivityError(c, err, true)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"success": "ok",
+			})
+			return
+		}
+
+		activity, err := notify.RegisterLiveActivityToken(req)
+		if err != nil {
+			liveActivityError(c, err, true)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":  "ok",
+			"activity": activity,
+		})
+	}
+}
+
+func pushToStartDeleteHandler(cfg *config.ConfYaml) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, ok := bindLiveActivity(c, cfg)
+		if !ok {
+			return
+		}
+
+		if err := notify.UnregisterPushToStartToken(req); err != nil {
+			liveActivityError(c, err, true)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": "ok",
+		})
+	}
+}
+
+func liveActivityHandler(cfg *config.ConfYaml, fn liveActivityFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, ok := bindLiveActivity(c, cfg)
+		if !ok {
+			return
+		}
+
+		resp, err := fn(c.Request.Context(), req, cfg)
+		if err != nil {
+			// no response means the request was rejected before sending
+			liveActivityError(c, err, resp == nil)
+			return
+		}
+
+		logs := resp.Logs
+		if logs == nil {
+			logs = []logx.LogPushEntry{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": "ok",
+			"logs":    logs,
+		})
+	}
+}
+
+func liveActivityStatusHandler(cfg *config.ConfYaml) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.Ios.Enabled {
+			abortWithError(c, http.StatusBadRequest, "iOS notification is disabled.")
+			return
+		}
+
+		activity, err := notify.GetLiveActivity(c.Param("id"))
+		if err != nil {
+			liveActivityError(c, err, false)
+			return
+		}
+
+		c.JSON(http.StatusOK, activity)
+	}
+}
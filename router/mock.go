@@ -0,0 +1,52 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+func registerMockRoutes(r *gin.Engine, cfg *config.ConfYaml) {
+	r.GET(cfg.API.MockURI, mockMessagesHandler)
+	r.DELETE(cfg.API.MockURI, mockResetHandler)
+	r.PUT(cfg.API.MockURI+"/failures", mockFailuresHandler)
+}
+
+// mockMessagesHandler lists the messages received by the fake providers,
+// optionally only the ones of the provider query parameter.
+func mockMessagesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"messages": notify.MockProviders.Messages(c.Query("provider")),
+		"failures": notify.MockProviders.Failures(),
+	})
+}
+
+func mockResetHandler(c *gin.Context) {
+	notify.MockProviders.Reset()
+	c.JSON(http.StatusOK, gin.H{
+		"success": "ok",
+	})
+}
+
+// mockFailuresHandler replaces the failures simulated by the fake providers.
+func mockFailuresHandler(c *gin.Context) {
+	var failures notify.MockFailures
+
+	if err := c.ShouldBindJSON(&failures); err != nil {
+		logx.LogAccess.Debug(err)
+		abortWithError(c, http.StatusBadRequest, "Invalid mock failures request body.")
+		return
+	}
+
+	if failures.ErrorRate < 0 || failures.ErrorRate > 100 {
+		abortWithError(c, http.StatusBadRequest, "The error rate must be between 0 and 100.")
+		return
+	}
+
+	notify.MockProviders.SetFailures(failures)
+	c.JSON(http.StatusOK, notify.MockProviders.Failures())
+}
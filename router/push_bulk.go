@@ -0,0 +1,113 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/notify"
+	"github.com/appleboy/gorush/status"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-queue/queue"
+)
+
+// bulkQueueInterval is how long a bulk request waits for room in a full queue
+// before trying again.
+var bulkQueueInterval = 100 * time.Millisecond
+
+// pushBulkHandler queues the notifications of an NDJSON body, one per line,
+// as it is read. The body is not limited by max_notification: the reading
+// waits while the queue is full instead.
+func pushBulkHandler(cfg *config.ConfYaml, q *queue.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		count := 0
+		accepted, rejected, err := notify.ReadBulkNotifications(
+			c.Request.Context(),
+			cfg,
+			c.Request.Body,
+			func(_ int, notification *notify.PushNotification) error {
+				duplicate, err := queueBulkNotification(c.Request.Context(), cfg, notification, q)
+				if err != nil {
+					return err
+				}
+				if !duplicate {
+					count += len(notification.Recipients())
+					// Count topic message
+					if notification.Topic != "" {
+						count++
+					}
+				}
+				return nil
+			},
+		)
+
+		status.StatStorage.AddTotalCount(int64(count))
+
+		if err != nil {
+			// the client went away, nobody reads the answer
+			logx.LogAccess.Debugf("bulk request stopped after %d notifications: %v", accepted, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":  "ok",
+			"counts":   count,
+			"accepted": accepted,
+			"rejected": len(rejected),
+			"errors":   rejected,
+		})
+	}
+}
+
+// queueBulkNotification queues a notification of a bulk request, waiting for
+// room while its queue is full. It reports whether the notification was a
+// duplicate of one already sent.
+func queueBulkNotification(
+	ctx context.Context,
+	cfg *config.ConfYaml,
+	notification *notify.PushNotification,
+	q *queue.Queue,
+) (bool, error) {
+	original, err := notify.ClaimIdempotencyKey(cfg, notification)
+	if err != nil {
+		logx.LogError.Error(err)
+	}
+	if original != nil {
+		logx.LogAccess.Debugf("duplicate notification with idempotency key %s", notification.IdempotencyKey)
+		return true, nil
+	}
+
+	notify.AssignLane(cfg, notification)
+	lane := notify.NotificationQueue(notification, q)
+	// nobody waits for the result of a bulk request
+	notification.ReplyTo = ""
+
+	notify.SetDeliveryState(cfg, notification, notify.DeliveryAccepted, nil)
+	notify.SetDeliveryState(cfg, notification, notify.DeliveryQueued, nil)
+	notify.CampaignQueued(notification)
+
+	err = lane.Queue(notification)
+	for errors.Is(err, queue.ErrMaxCapacity) {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(bulkQueueInterval):
+			err = lane.Queue(notification)
+		}
+	}
+
+	if err != nil {
+		if err := notify.ReleaseIdempotencyKey(cfg, notification); err != nil {
+			logx.LogError.Error(err)
+		}
+		notify.SetDeliveryState(cfg, notification, notify.DeliveryFailed, err)
+		notify.CampaignFailed(notification)
+		return false, err
+	}
+
+	return false, nil
+}
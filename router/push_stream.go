@@ -0,0 +1,59 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/notify"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-queue/queue"
+)
+
+// pushStreamHandler sends the notifications like the push API in sync mode,
+// but streams the results as Server-Sent Events: a log event for every entry
+// as soon as its notification is done, then a summary event. Notifications
+// not sent yet when the client goes away are dropped.
+func pushStreamHandler(cfg *config.ConfYaml, q *queue.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := notify.CheckSyncWait(cfg); err != nil {
+			abortWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		form, ok := bindPushRequest(c, cfg)
+		if !ok {
+			return
+		}
+
+		// the results are waited for whatever the sync setting is
+		syncCfg := *cfg
+		syncCfg.Core.Sync = true
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		failed := 0
+		counts := dispatchNotification(c.Request.Context(), &syncCfg, form, q, func(entries []logx.LogPushEntry) {
+			for _, entry := range entries {
+				if entry.Type == core.FailedPush {
+					failed++
+				}
+				c.SSEvent("log", entry)
+			}
+			c.Writer.Flush()
+		})
+
+		c.SSEvent("summary", gin.H{
+			"success": "ok",
+			"counts":  counts,
+			"failed":  failed,
+		})
+		c.Writer.Flush()
+	}
+}
@@ -56,29 +56,61 @@ func versionHandler(c *gin.Context) {
 	})
 }
 
-func pushHandler(cfg *config.ConfYaml, q *queue.Queue) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var form notify.RequestPush
-		var msg string
+// bindPushRequest reads and checks the notifications of a push request. It
+// aborts the request and returns false when they are invalid.
+func bindPushRequest(c *gin.Context, cfg *config.ConfYaml) (notify.RequestPush, bool) {
+	var form notify.RequestPush
+	var msg string
+
+	if err := c.ShouldBindWith(&form, binding.JSON); err != nil {
+		msg = "Missing notifications field."
+		logx.LogAccess.Debug(err)
+		abortWithError(c, http.StatusBadRequest, msg)
+		return form, false
+	}
 
-		if err := c.ShouldBindWith(&form, binding.JSON); err != nil {
-			msg = "Missing notifications field."
-			logx.LogAccess.Debug(err)
-			abortWithError(c, http.StatusBadRequest, msg)
-			return
-		}
+	if len(form.Notifications) == 0 {
+		msg = "Notifications field is empty."
+		logx.LogAccess.Debug(msg)
+		abortWithError(c, http.StatusBadRequest, msg)
+		return form, false
+	}
+
+	if !checkPushNotifications(c, cfg, form.Notifications) {
+		return form, false
+	}
+
+	return form, true
+}
 
-		if len(form.Notifications) == 0 {
-			msg = "Notifications field is empty."
+// checkPushNotifications checks the notifications of a request before they
+// are queued. It aborts the request and returns false when one is invalid.
+func checkPushNotifications(c *gin.Context, cfg *config.ConfYaml, notifications []notify.PushNotification) bool {
+	var msg string
+
+	if int64(len(notifications)) > cfg.Core.MaxNotification {
+		msg = fmt.Sprintf("Number of notifications(%d) over limit(%d)", len(notifications), cfg.Core.MaxNotification)
+		logx.LogAccess.Debug(msg)
+		abortWithError(c, http.StatusBadRequest, msg)
+		return false
+	}
+
+	for i := range notifications {
+		if err := notify.CheckNotification(cfg, &notifications[i]); err != nil {
+			msg = fmt.Sprintf("Notification #%d is invalid: %s", i, err)
 			logx.LogAccess.Debug(msg)
 			abortWithError(c, http.StatusBadRequest, msg)
-			return
+			return false
 		}
+	}
 
-		if int64(len(form.Notifications)) > cfg.Core.MaxNotification {
-			msg = fmt.Sprintf("Number of notifications(%d) over limit(%d)", len(form.Notifications), cfg.Core.MaxNotification)
-			logx.LogAccess.Debug(msg)
-			abortWithError(c, http.StatusBadRequest, msg)
+	return true
+}
+
+func pushHandler(cfg *config.ConfYaml, q *queue.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		form, ok := bindPushRequest(c, cfg)
+		if !ok {
 			return
 		}
 
@@ -106,6 +138,52 @@ func pushHandler(cfg *config.ConfYaml, q *queue.Queue) gin.HandlerFunc {
 	}
 }
 
+func topicHandler(
+	cfg *config.ConfYaml,
+	fn func(context.Context, *notify.RequestTopic, *config.ConfYaml) (*notify.ResponsePush, error),
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req notify.RequestTopic
+		var msg string
+
+		if err := c.ShouldBindWith(&req, binding.JSON); err != nil {
+			msg = "Missing topic or tokens field."
+			logx.LogAccess.Debug(err)
+			abortWithError(c, http.StatusBadRequest, msg)
+			return
+		}
+
+		if !cfg.Android.Enabled {
+			msg = "Android notification is disabled."
+			logx.LogAccess.Debug(msg)
+			abortWithError(c, http.StatusBadRequest, msg)
+			return
+		}
+
+		if err := notify.CheckTopicMessage(&req); err != nil {
+			abortWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		resp, err := fn(c.Request.Context(), &req, cfg)
+		if resp == nil {
+			abortWithError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		logs := resp.Logs
+		if logs == nil {
+			logs = []logx.LogPushEntry{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": "ok",
+			"counts":  len(req.Tokens),
+			"logs":    logs,
+		})
+	}
+}
+
 func deleteScheduledRUSMSHandler(cfg *config.ConfYaml) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var body notify.RequestDeleteScheduledRUSMS
@@ -157,6 +235,18 @@ func appStatusHandler(q *queue.Queue) gin.HandlerFunc {
 		result.Huawei.PushSuccess = status.StatStorage.GetHuaweiSuccess()
 		result.Huawei.PushError = status.StatStorage.GetHuaweiError()
 
+		if pools := notify.Pools(); len(pools) > 0 {
+			result.Pools = make(map[string]status.PoolStatus, len(pools))
+			for name, pool := range pools {
+				result.Pools[name] = status.PoolStatus{
+					BusyWorkers:    pool.BusyWorkers(),
+					SuccessTasks:   pool.SuccessTasks(),
+					FailureTasks:   pool.FailureTasks(),
+					SubmittedTasks: pool.SubmittedTasks(),
+				}
+			}
+		}
+
 		c.JSON(http.StatusOK, result)
 	}
 }
@@ -235,7 +325,21 @@ func routerEngine(cfg *config.ConfYaml, q *queue.Queue) *gin.Engine {
 	r.GET(cfg.API.ConfigURI, configHandler(cfg))
 	r.GET(cfg.API.SysStatURI, sysStatsHandler())
 	r.POST(cfg.API.PushURI, pushHandler(cfg, q))
+	r.POST(cfg.API.PushStreamURI, pushStreamHandler(cfg, q))
+	r.POST(cfg.API.PushBulkURI, pushBulkHandler(cfg, q))
+	r.POST(cfg.API.TopicSubscribeURI, topicHandler(cfg, notify.SubscribeTopic))
+	r.POST(cfg.API.TopicUnsubscribeURI, topicHandler(cfg, notify.UnsubscribeTopic))
 	r.DELETE(cfg.API.ScheduledRUSMSURI, deleteScheduledRUSMSHandler(cfg))
+	registerLiveActivityRoutes(r, cfg)
+	registerDeadLetterRoutes(r, cfg, q)
+	registerDeliveryStatusRoutes(r, cfg)
+	registerCampaignRoutes(r, cfg, q)
+	registerAudienceRoutes(r, cfg)
+	registerTemplateRoutes(r, cfg)
+	registerExperimentRoutes(r, cfg)
+	if cfg.Mock.Enabled && notify.MockProviders != nil {
+		registerMockRoutes(r, cfg)
+	}
 	r.GET(cfg.API.MetricURI, metricsHandler)
 	r.GET(cfg.API.HealthURI, heartbeatHandler)
 	r.HEAD(cfg.API.HealthURI, heartbeatHandler)
@@ -253,7 +357,7 @@ func markFailedNotification(
 ) []logx.LogPushEntry {
 	logx.LogError.Error(reason)
 	logs := make([]logx.LogPushEntry, 0)
-	for _, token := range notification.Tokens {
+	for _, token := range notification.Recipients() {
 		logs = append(logs, logx.GetLogPushEntry(&logx.InputLog{
 			ID:        notification.ID,
 			Status:    core.FailedPush,
@@ -271,19 +375,34 @@ func markFailedNotification(
 
 // HandleNotification add notification to queue list.
 func handleNotification(
-	_ context.Context,
+	ctx context.Context,
 	cfg *config.ConfYaml,
 	req notify.RequestPush,
 	q *queue.Queue,
 ) (int, []logx.LogPushEntry) {
+	logs := make([]logx.LogPushEntry, 0)
+	count := dispatchNotification(ctx, cfg, req, q, func(entries []logx.LogPushEntry) {
+		logs = append(logs, entries...)
+	})
+
+	return count, logs
+}
+
+// dispatchNotification adds the notifications to the queue and passes the
+// logs of each one to emit as soon as they are known: once it is sent in sync
+// mode, or when it can't be queued. emit is never called concurrently.
+func dispatchNotification(
+	ctx context.Context,
+	cfg *config.ConfYaml,
+	req notify.RequestPush,
+	q *queue.Queue,
+	emit func([]logx.LogPushEntry),
+) int {
 	var count int
+	var mu sync.Mutex
 	wg := sync.WaitGroup{}
 	newNotification := []*notify.PushNotification{}
 
-	if cfg.Core.Sync && !core.IsLocalQueue(core.Queue(cfg.Queue.Engine)) {
-		cfg.Core.Sync = false
-	}
-
 	for i := range req.Notifications {
 		notification := &req.Notifications[i]
 		switch notification.Platform {
@@ -299,41 +418,113 @@ func handleNotification(
 			if !cfg.Huawei.Enabled {
 				continue
 			}
+		case core.PlatformWebPush:
+			if !cfg.WebPush.Enabled {
+				continue
+			}
 		}
 		newNotification = append(newNotification, notification)
 	}
 
-	logs := make([]logx.LogPushEntry, 0, count)
+	// a notification the queue can't take fails for all its recipients
+	queueFailed := func(notification *notify.PushNotification, err error) {
+		logx.LogError.Error(err)
+		if err := notify.ReleaseIdempotencyKey(cfg, notification); err != nil {
+			logx.LogError.Error(err)
+		}
+		notify.SetDeliveryState(cfg, notification, notify.DeliveryFailed, err)
+		resp := markFailedNotification(cfg, notification, "max capacity reached")
+		if _, err := notify.AddDeadLetter(cfg, notification, notify.DeadLetterMaxCapacity, "max capacity reached"); err != nil {
+			logx.LogError.Error(err)
+		}
+		notify.CampaignFailed(notification)
+		// add log
+		mu.Lock()
+		emit(resp)
+		mu.Unlock()
+		if cfg.Core.Sync {
+			wg.Done()
+		}
+	}
+
+	duplicates := 0
 	for _, notification := range newNotification {
+		// acknowledge a notification already sent with the same idempotency
+		// key with its original result instead of sending it again
+		original, err := notify.ClaimIdempotencyKey(cfg, notification)
+		if err != nil {
+			logx.LogError.Error(err)
+		}
+		if original != nil {
+			logx.LogAccess.Debugf("duplicate notification with idempotency key %s", notification.IdempotencyKey)
+			count += original.Count
+			duplicates += original.Count
+			mu.Lock()
+			emit(original.Logs)
+			mu.Unlock()
+			continue
+		}
+
+		notify.AssignLane(cfg, notification)
+		lane := notify.NotificationQueue(notification, q)
+
+		// queued before the workers can pick it up and start sending
+		notify.SetDeliveryState(cfg, notification, notify.DeliveryAccepted, nil)
+		notify.SetDeliveryState(cfg, notification, notify.DeliveryQueued, nil)
+		notify.CampaignQueued(notification)
+
 		if cfg.Core.Sync {
 			wg.Add(1)
 		}
 
+		// the worker may run on another replica, it sends the result back
+		// through the stat storage
+		notification.ReplyTo = ""
+		if cfg.Core.Sync && !core.IsLocalQueue(core.Queue(cfg.Queue.Engine)) {
+			if notification.ReplyTo, err = notify.NewReplyID(); err != nil {
+				logx.LogError.Error(err)
+			}
+		}
+
 		if core.IsLocalQueue(core.Queue(cfg.Queue.Engine)) && cfg.Core.Sync {
 			func(msg *notify.PushNotification, cfg *config.ConfYaml) {
-				if err := q.QueueTask(func(ctx context.Context) error {
+				if err := lane.QueueTask(func(workerCtx context.Context) error {
 					defer wg.Done()
-					resp, err := notify.SendNotification(ctx, msg, cfg)
+					// the client went away before the notification was sent
+					if err := ctx.Err(); err != nil {
+						notify.SetDeliveryState(cfg, msg, notify.DeliveryFailed, err)
+						return err
+					}
+					resp, err := notify.SendNotification(workerCtx, msg, cfg)
 					if err != nil {
 						return err
 					}
 
 					// add log
-					logs = append(logs, resp.Logs...)
+					mu.Lock()
+					emit(resp.Logs)
+					mu.Unlock()
 
 					return nil
 				}); err != nil {
-					logx.LogError.Error(err)
+					queueFailed(msg, err)
 				}
 			}(notification, cfg)
-		} else if err := q.Queue(notification); err != nil {
-			resp := markFailedNotification(cfg, notification, "max capacity reached")
-			// add log
-			logs = append(logs, resp...)
-			wg.Done()
+		} else if err := lane.Queue(notification); err != nil {
+			queueFailed(notification, err)
+		} else if cfg.Core.Sync {
+			go func(msg *notify.PushNotification) {
+				defer wg.Done()
+				resp := waitNotificationReply(ctx, cfg, msg)
+
+				// add log
+				mu.Lock()
+				emit(resp)
+				mu.Unlock()
+			}(notification)
 		}
 
-		count += len(notification.Tokens)
+		count += len(notification.Recipients())
 		// Count topic message
 		if notification.Topic != "" {
 			count++
@@ -344,9 +535,34 @@ func handleNotification(
 		wg.Wait()
 	}
 
-	status.StatStorage.AddTotalCount(int64(count))
+	status.StatStorage.AddTotalCount(int64(count - duplicates))
 
-	return count, logs
+	return count
+}
+
+// waitNotificationReply returns the logs the worker sends back for a
+// notification queued on an engine other than local.
+func waitNotificationReply(
+	ctx context.Context,
+	cfg *config.ConfYaml,
+	notification *notify.PushNotification,
+) []logx.LogPushEntry {
+	if notification.ReplyTo == "" {
+		return nil
+	}
+
+	reply, err := notify.WaitReply(ctx, cfg, notification.ReplyTo)
+	if err != nil {
+		// the notification may still be delivered, its delivery status tells
+		logx.LogError.Errorf("no result for notification %s: %v", notification.ID, err)
+		return nil
+	}
+
+	if len(reply.Logs) == 0 && reply.Error != "" {
+		return markFailedNotification(cfg, notification, reply.Error)
+	}
+
+	return reply.Logs
 }
 
 // handleDeleteScheduledRUSMS deletes to be sent ru sms.
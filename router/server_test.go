@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -315,6 +316,33 @@ func TestEmptyNotifications(t *testing.T) {
 		})
 }
 
+func TestOversizedNotification(t *testing.T) {
+	cfg := initTest()
+
+	r := gofight.New()
+
+	r.POST("/api/push").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"tokens":   []string{"aaaaa"},
+					"platform": core.PlatformIOS,
+					"message":  "Welcome",
+				},
+				{
+					"tokens":   []string{"aaaaa", "bbbbb"},
+					"platform": core.PlatformAndroid,
+					"message":  strings.Repeat("a", 4100),
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			message, _ := jsonparser.GetString(r.Body.Bytes(), "message")
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+			assert.Equal(t, "Notification #1 is invalid: payload too large: the FCM payload is 4128 bytes, 32 bytes over the 4096 bytes limit", message)
+		})
+}
+
 func TestMutableContent(t *testing.T) {
 	cfg := initTest()
 
@@ -372,6 +400,118 @@ func TestOutOfRangeMaxNotifications(t *testing.T) {
 		})
 }
 
+func TestMissingTopicParameter(t *testing.T) {
+	cfg := initTest()
+
+	r := gofight.New()
+
+	r.POST("/api/topic/subscribe").
+		SetJSON(gofight.D{
+			"tokens": []string{"aaaaa"},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+}
+
+func TestOutOfRangeTopicTokens(t *testing.T) {
+	cfg := initTest()
+
+	tokens := make([]string, notify.MaxTopicTokens+1)
+	for i := range tokens {
+		tokens[i] = "aaaaa"
+	}
+
+	r := gofight.New()
+
+	r.POST("/api/topic/unsubscribe").
+		SetJSON(gofight.D{
+			"topic":  "news",
+			"tokens": tokens,
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+}
+
+func TestLiveActivityDisabledIOS(t *testing.T) {
+	cfg := initTest()
+	cfg.Ios.Enabled = false
+
+	r := gofight.New()
+
+	r.POST("/api/live-activity/push-to-start").
+		SetJSON(gofight.D{
+			"user_id": "1",
+			"token":   "aaaaa",
+			"topic":   "com.example.app",
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+}
+
+func TestLiveActivityToken(t *testing.T) {
+	cfg := initTest()
+	cfg.Ios.Enabled = true
+
+	r := gofight.New()
+
+	r.POST("/api/live-activity/push-to-start").
+		SetJSON(gofight.D{
+			"token": "aaaaa",
+			"topic": "com.example.app",
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.DELETE("/api/live-activity/push-to-start").
+		SetJSON(gofight.D{
+			"user_id": "1",
+			"token":   "aaaaa",
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+
+	r.POST("/api/live-activity/token").
+		SetJSON(gofight.D{
+			"activity_id": "router-activity",
+			"user_id":     "1",
+			"token":       "bbbbb",
+			"topic":       "com.example.app",
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+
+	r.GET("/api/live-activity/activities/router-activity").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			state, _ := jsonparser.GetString(r.Body.Bytes(), "state")
+			assert.Equal(t, notify.LiveActivityActive, state)
+		})
+}
+
+func TestUnknownLiveActivity(t *testing.T) {
+	cfg := initTest()
+	cfg.Ios.Enabled = true
+
+	r := gofight.New()
+
+	r.GET("/api/live-activity/activities/missing").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+
+	r.POST("/api/live-activity/activities/missing/end").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+}
+
 func TestSuccessPushHandler(t *testing.T) {
 	t.Skip()
 	cfg := initTest()
@@ -398,6 +538,66 @@ func TestSuccessPushHandler(t *testing.T) {
 		})
 }
 
+func TestPushStreamHandler(t *testing.T) {
+	cfg := initTest()
+
+	cfg.Ios.Enabled = true
+	cfg.Ios.KeyPath = testKeyPath
+	err := notify.InitAPNSClient(context.Background(), cfg)
+	assert.Nil(t, err)
+
+	r := gofight.New()
+
+	r.POST("/api/push/stream").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	// the results are streamed even when sync mode is disabled
+	r.POST("/api/push/stream").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"tokens":   []string{"11aa01229f15f0f0c12029d8c111d1ae1f2365f14cebc4af26cd6d76b7919ef7"},
+					"platform": core.PlatformIOS,
+					"message":  "Welcome iOS Stream",
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+			assert.Equal(t, "text/event-stream", r.HeaderMap.Get("Content-Type"))
+
+			body := r.Body.String()
+			assert.Equal(t, 1, strings.Count(body, "event:log\n"))
+			assert.Contains(t, body, "event:summary\n")
+			assert.Contains(t, body, `"counts":1`)
+			assert.Contains(t, body, `"failed":1`)
+		})
+
+	// the results of an external queue can't be waited for without timeout
+	streamCfg := *cfg
+	streamCfg.Queue.Engine = string(core.NSQ)
+	streamCfg.Core.SyncTimeout = 0
+	r.POST("/api/push/stream").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"tokens":   []string{"11aa01229f15f0f0c12029d8c111d1ae1f2365f14cebc4af26cd6d76b7919ef7"},
+					"platform": core.PlatformIOS,
+					"message":  "Welcome iOS Stream",
+				},
+			},
+		}).
+		Run(routerEngine(&streamCfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+			assert.Contains(t, r.Body.String(), "core.sync_timeout must be positive")
+		})
+}
+
 func TestSysStatsHandler(t *testing.T) {
 	cfg := initTest()
 
@@ -504,6 +704,42 @@ func TestSenMultipleNotifications(t *testing.T) {
 	assert.Equal(t, 0, len(logs))
 }
 
+func TestIdempotentNotifications(t *testing.T) {
+	ctx := context.Background()
+	cfg := initTest()
+
+	cfg.Ios.Enabled = true
+	cfg.Ios.KeyPath = testKeyPath
+	err := notify.InitAPNSClient(ctx, cfg)
+	assert.Nil(t, err)
+
+	req := notify.RequestPush{
+		Notifications: []notify.PushNotification{
+			{
+				Tokens:         []string{"11aa01229f15f0f0c52029d8cf8cd0aeaf2365fe4cebc4af26cd6d76b7919ef7", "bbbbb"},
+				Platform:       core.PlatformIOS,
+				Message:        "Welcome iOS",
+				IdempotencyKey: "router-idempotency-1",
+			},
+			{
+				Tokens:   []string{"ccccc"},
+				Platform: core.PlatformIOS,
+				Message:  "Welcome iOS",
+			},
+		},
+	}
+
+	total := status.StatStorage.GetTotalCount()
+	count, _ := handleNotification(ctx, cfg, req, q)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, total+3, status.StatStorage.GetTotalCount())
+
+	// the duplicate is acknowledged with the same counts but not sent again
+	count, _ = handleNotification(ctx, cfg, req, q)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, total+4, status.StatStorage.GetTotalCount())
+}
+
 func TestDisabledAndroidNotifications(t *testing.T) {
 	ctx := context.Background()
 	cfg := initTest()
@@ -581,6 +817,80 @@ func TestSyncModeForNotifications(t *testing.T) {
 	assert.Equal(t, 2, len(logs))
 }
 
+func TestSyncModeForFullLocalQueue(t *testing.T) {
+	ctx := context.Background()
+	cfg := initTest()
+
+	cfg.Ios.Enabled = true
+	cfg.Core.Sync = true
+	cfg.DeadLetter.Enabled = true
+
+	// a released queue takes no more task
+	closed := queue.NewPool(1)
+	closed.Release()
+
+	req := notify.RequestPush{
+		Notifications: []notify.PushNotification{
+			{
+				ID:       "router-full-queue",
+				Tokens:   []string{"aaaaa", "bbbbb"},
+				Platform: core.PlatformIOS,
+				Message:  "Welcome iOS Sync",
+			},
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		count, logs := handleNotification(ctx, cfg, req, closed)
+		assert.Equal(t, 2, count)
+		assert.Len(t, logs, 2)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request waits for a notification the queue didn't take")
+	}
+
+	letters, err := notify.DeleteDeadLetters(nil, notify.DeadLetterFilter{NotifID: "router-full-queue"})
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+}
+
+func TestSyncModeForExternalQueue(t *testing.T) {
+	ctx := context.Background()
+	cfg := initTest()
+
+	cfg.Ios.Enabled = true
+	cfg.Ios.KeyPath = testKeyPath
+	err := notify.InitAPNSClient(ctx, cfg)
+	assert.Nil(t, err)
+
+	// enable sync mode, the worker sends the result back like the worker
+	// of an external queue engine would
+	cfg.Core.Sync = true
+	cfg.Queue.Engine = string(core.NSQ)
+
+	req := notify.RequestPush{
+		Notifications: []notify.PushNotification{
+			{
+				Tokens: []string{
+					"11aa01229f15f0f0c12029d8c111d1ae1f2365f14cebc4af26cd6d76b7919ef7",
+				},
+				Platform: core.PlatformIOS,
+				Message:  "Welcome iOS Sync",
+			},
+		},
+	}
+
+	count, logs := handleNotification(ctx, cfg, req, q)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, len(logs))
+	assert.True(t, cfg.Core.Sync)
+}
+
 func TestSyncModeForTopicNotification(t *testing.T) {
 	ctx := context.Background()
 	cfg := initTest()
@@ -687,3 +997,640 @@ func TestDisabledIosNotifications(t *testing.T) {
 	assert.Equal(t, 2, count)
 	assert.Equal(t, 0, len(logs))
 }
+
+func TestMockRoutes(t *testing.T) {
+	cfg := initTest()
+
+	r := gofight.New()
+
+	// the routes only exist in mock mode
+	r.GET("/api/mock").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+
+	mock, err := notify.NewMockServer(10, notify.MockFailures{})
+	assert.NoError(t, err)
+	defer mock.Close()
+	notify.MockProviders = mock
+	defer func() { notify.MockProviders = nil }()
+	cfg.Mock.Enabled = true
+
+	r.PUT("/api/mock/failures").
+		SetJSON(gofight.D{
+			"error_rate": 101,
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.PUT("/api/mock/failures").
+		SetJSON(gofight.D{
+			"latency":        10,
+			"invalid_tokens": []string{"aaaa"},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+	assert.Equal(t, []string{"aaaa"}, mock.Failures().InvalidTokens)
+
+	r.GET("/api/mock?provider=apns").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			latency, _ := jsonparser.GetInt(r.Body.Bytes(), "failures", "latency")
+			assert.Equal(t, int64(10), latency)
+			messages, _, _, _ := jsonparser.Get(r.Body.Bytes(), "messages")
+			assert.Equal(t, "[]", string(messages))
+		})
+
+	r.DELETE("/api/mock").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+}
+
+func TestDeadLetterRoutes(t *testing.T) {
+	cfg := initTest()
+	cfg.DeadLetter.Enabled = true
+
+	// SMS is disabled, the requeued notification is dropped by the worker
+	first, err := notify.AddDeadLetter(cfg, &notify.PushNotification{
+		ID:           "router-dead-letter",
+		Platform:     core.PlatformSMS,
+		PhoneNumbers: []string{"79000000000"},
+	}, notify.DeadLetterMaxRetry, "ServiceUnavailable")
+	assert.NoError(t, err)
+	second, err := notify.AddDeadLetter(cfg, &notify.PushNotification{
+		ID:       "router-dead-letter",
+		Platform: core.PlatformAndroid,
+		Tokens:   []string{"bbbb"},
+	}, notify.DeadLetterMaxCapacity, "max capacity reached")
+	assert.NoError(t, err)
+
+	r := gofight.New()
+
+	r.GET("/api/dead-letters?notif_id=router-dead-letter&reason=max_retry").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			counts, _ := jsonparser.GetInt(r.Body.Bytes(), "counts")
+			assert.Equal(t, int64(1), counts)
+			id, _ := jsonparser.GetString(r.Body.Bytes(), "dead_letters", "[0]", "id")
+			assert.Equal(t, first.ID, id)
+		})
+
+	r.GET("/api/dead-letters/"+second.ID).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			reason, _ := jsonparser.GetString(r.Body.Bytes(), "reason")
+			assert.Equal(t, notify.DeadLetterMaxCapacity, reason)
+			token, _ := jsonparser.GetString(r.Body.Bytes(), "notification", "tokens", "[0]")
+			assert.Equal(t, "bbbb", token)
+		})
+
+	r.GET("/api/dead-letters/unknown").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+
+	r.POST("/api/dead-letters/requeue").
+		SetJSON(gofight.D{}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.POST("/api/dead-letters/"+first.ID+"/requeue").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+
+	r.DELETE("/api/dead-letters/"+first.ID).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+
+	r.POST("/api/dead-letters/delete").
+		SetJSON(gofight.D{
+			"filter": gofight.D{
+				"notif_id": "router-dead-letter",
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			counts, _ := jsonparser.GetInt(r.Body.Bytes(), "counts")
+			assert.Equal(t, int64(1), counts)
+		})
+}
+
+func TestDeliveryStatusRoutes(t *testing.T) {
+	cfg := initTest()
+	cfg.DeliveryStatus.Enabled = true
+
+	r := gofight.New()
+
+	// SMS is disabled, the notification is dropped by the worker
+	r.POST("/api/push").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"notif_id":     "router-delivery",
+					"platform":     core.PlatformSMS,
+					"phoneNumbers": []string{"79000000000"},
+					"message":      "Welcome",
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+
+	r.GET("/api/delivery/router-delivery").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			id, _ := jsonparser.GetString(r.Body.Bytes(), "notif_id")
+			assert.Equal(t, "router-delivery", id)
+			state, _ := jsonparser.GetString(r.Body.Bytes(), "state")
+			assert.Contains(t, []string{
+				notify.DeliveryQueued,
+				notify.DeliverySending,
+				notify.DeliveryDone,
+			}, state)
+		})
+
+	r.GET("/api/delivery/unknown").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+}
+
+func TestPriorityLane(t *testing.T) {
+	cfg := initTest()
+	cfg.Queue.Priority.Enabled = true
+	cfg.Queue.Priority.Platforms = []int{core.PlatformTelegramGateway}
+
+	received := make(chan string, 2)
+	notify.PriorityQueue = queue.NewPool(1, queue.WithFn(func(ctx context.Context, msg qcore.TaskMessage) error {
+		id, _ := jsonparser.GetString(msg.Payload(), "notif_id")
+		received <- id
+		return nil
+	}))
+	defer func() {
+		notify.PriorityQueue.Release()
+		notify.PriorityQueue = nil
+	}()
+
+	r := gofight.New()
+
+	r.POST("/api/push").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"platform":     core.PlatformSMS,
+					"phoneNumbers": []string{"79000000000"},
+					"message":      "Welcome",
+					"lane":         "urgent",
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.POST("/api/push").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"notif_id":     "lane-field",
+					"platform":     core.PlatformSMS,
+					"phoneNumbers": []string{"79000000000"},
+					"message":      "Your code is 1234",
+					"lane":         notify.LaneHigh,
+				},
+				{
+					"notif_id": "lane-platform",
+					"platform": core.PlatformTelegramGateway,
+					"message":  "Your code is 1234",
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+
+	ids := []string{}
+	for range 2 {
+		select {
+		case id := <-received:
+			ids = append(ids, id)
+		case <-time.After(5 * time.Second):
+			t.Fatal("notification was not queued on the high priority lane")
+		}
+	}
+	assert.ElementsMatch(t, []string{"lane-field", "lane-platform"}, ids)
+}
+
+func TestPlatformPool(t *testing.T) {
+	cfg := initTest()
+
+	received := make(chan string, 1)
+	notify.PlatformQueues[core.PlatformSMS] = queue.NewPool(1, queue.WithFn(func(ctx context.Context, msg qcore.TaskMessage) error {
+		id, _ := jsonparser.GetString(msg.Payload(), "notif_id")
+		received <- id
+		return nil
+	}))
+	defer func() {
+		notify.PlatformQueues[core.PlatformSMS].Release()
+		delete(notify.PlatformQueues, core.PlatformSMS)
+	}()
+
+	r := gofight.New()
+
+	r.POST("/api/push").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"notif_id":     "platform-pool",
+					"platform":     core.PlatformSMS,
+					"phoneNumbers": []string{"79000000000"},
+					"message":      "Welcome",
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+
+	select {
+	case id := <-received:
+		assert.Equal(t, "platform-pool", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification was not queued on the pool of its platform")
+	}
+
+	r.GET("/api/stat/app").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			submitted, _ := jsonparser.GetInt(r.Body.Bytes(), "pools", "sms", "submitted_tasks")
+			assert.Equal(t, int64(1), submitted)
+		})
+}
+
+func TestPushBulkHandler(t *testing.T) {
+	cfg := initTest()
+
+	received := make(chan string, 2)
+	notify.PlatformQueues[core.PlatformSMS] = queue.NewPool(1, queue.WithFn(func(ctx context.Context, msg qcore.TaskMessage) error {
+		id, _ := jsonparser.GetString(msg.Payload(), "notif_id")
+		received <- id
+		return nil
+	}))
+	defer func() {
+		notify.PlatformQueues[core.PlatformSMS].Release()
+		delete(notify.PlatformQueues, core.PlatformSMS)
+	}()
+
+	r := gofight.New()
+
+	// not limited by max_notification
+	cfg.Core.MaxNotification = 1
+
+	r.POST("/api/push/bulk").
+		SetBody(strings.Join([]string{
+			`{"notif_id":"bulk-1","platform":4,"phoneNumbers":["79000000000"],"message":"Welcome"}`,
+			`{"notif_id":"bulk-2","platform":4,"phoneNumbers":["79000000001","79000000002"],"message":"Welcome"}`,
+			`{"notif_id":"bulk-3","platform":4,"message":"Welcome"}`,
+			`{"notif_id":"bulk-4",`,
+		}, "\n")).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			data := r.Body.Bytes()
+			accepted, _ := jsonparser.GetInt(data, "accepted")
+			rejected, _ := jsonparser.GetInt(data, "rejected")
+			line, _ := jsonparser.GetInt(data, "errors", "[0]", "line")
+			msg, _ := jsonparser.GetString(data, "errors", "[0]", "error")
+			assert.Equal(t, int64(2), accepted)
+			assert.Equal(t, int64(2), rejected)
+			assert.Equal(t, int64(3), line)
+			assert.Equal(t, notify.ErrNoTarget.Error(), msg)
+		})
+
+	ids := []string{}
+	for range 2 {
+		select {
+		case id := <-received:
+			ids = append(ids, id)
+		case <-time.After(5 * time.Second):
+			t.Fatal("bulk notification was not queued")
+		}
+	}
+	assert.ElementsMatch(t, []string{"bulk-1", "bulk-2"}, ids)
+}
+
+func TestCampaignRoutes(t *testing.T) {
+	cfg := initTest()
+
+	received := make(chan string, 1)
+	notify.PlatformQueues[core.PlatformSMS] = queue.NewPool(1, queue.WithFn(func(ctx context.Context, msg qcore.TaskMessage) error {
+		id, _ := jsonparser.GetString(msg.Payload(), "campaign_id")
+		received <- id
+		return nil
+	}))
+	defer func() {
+		notify.PlatformQueues[core.PlatformSMS].Release()
+		delete(notify.PlatformQueues, core.PlatformSMS)
+	}()
+
+	r := gofight.New()
+
+	r.POST("/api/campaigns").
+		SetJSON(gofight.D{
+			"id":   "router-campaign",
+			"name": "Spring sale",
+			"notifications": []gofight.D{
+				{
+					"platform":     core.PlatformSMS,
+					"phoneNumbers": []string{"79000000000"},
+					"message":      "Welcome",
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			data := r.Body.Bytes()
+			id, _ := jsonparser.GetString(data, "campaign", "id")
+			state, _ := jsonparser.GetString(data, "campaign", "state")
+			queued, _ := jsonparser.GetInt(data, "campaign", "queued")
+			assert.Equal(t, "router-campaign", id)
+			assert.Equal(t, notify.CampaignRunning, state)
+			assert.Equal(t, int64(1), queued)
+		})
+
+	select {
+	case id := <-received:
+		assert.Equal(t, "router-campaign", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("campaign notification was not queued")
+	}
+
+	r.POST("/api/campaigns").
+		SetJSON(gofight.D{"id": "router-campaign"}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusConflict, r.Code)
+		})
+
+	r.POST("/api/campaigns/router-campaign/pause").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			state, _ := jsonparser.GetString(r.Body.Bytes(), "state")
+			assert.Equal(t, notify.CampaignPaused, state)
+		})
+
+	r.POST("/api/campaigns/router-campaign/pause").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusConflict, r.Code)
+		})
+
+	r.POST("/api/campaigns/router-campaign/cancel").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+
+	// a canceled campaign takes no more notifications
+	r.POST("/api/push").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"platform":     core.PlatformSMS,
+					"phoneNumbers": []string{"79000000000"},
+					"message":      "Welcome",
+					"campaign_id":  "router-campaign",
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.GET("/api/campaigns/router-campaign").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			state, _ := jsonparser.GetString(r.Body.Bytes(), "state")
+			assert.Equal(t, notify.CampaignCanceled, state)
+		})
+
+	r.GET("/api/campaigns/unknown").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+}
+
+func TestAudienceRoutes(t *testing.T) {
+	cfg := initTest()
+	cfg.Ios.Enabled = true
+	cfg.Android.Enabled = true
+
+	r := gofight.New()
+
+	for _, device := range []gofight.D{
+		{"token": "router-aaaa", "platform": core.PlatformIOS, "tags": []string{"router", "lang:ru"}},
+		{"token": "router-bbbb", "platform": core.PlatformAndroid, "tags": []string{"router", "lang:ru"}},
+		{"token": "router-cccc", "platform": core.PlatformAndroid, "tags": []string{"router", "churned"}},
+	} {
+		r.POST("/api/audience/devices").
+			SetJSON(device).
+			Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+				assert.Equal(t, http.StatusOK, r.Code)
+			})
+	}
+
+	r.POST("/api/audience/devices").
+		SetJSON(gofight.D{"token": "router-dddd", "platform": core.PlatformSMS}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.GET("/api/audience/devices/router-aaaa").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			tag, _ := jsonparser.GetString(r.Body.Bytes(), "tags", "[1]")
+			assert.Equal(t, "lang:ru", tag)
+		})
+
+	r.POST("/api/audience/size").
+		SetJSON(gofight.D{"audience": "router AND NOT churned"}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			data := r.Body.Bytes()
+			size, _ := jsonparser.GetInt(data, "size")
+			ios, _ := jsonparser.GetInt(data, "platforms", "ios")
+			android, _ := jsonparser.GetInt(data, "platforms", "android")
+			assert.Equal(t, int64(2), size)
+			assert.Equal(t, int64(1), ios)
+			assert.Equal(t, int64(1), android)
+		})
+
+	r.POST("/api/audience/size").
+		SetJSON(gofight.D{"audience": "router AND"}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.POST("/api/push").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"audience": "router",
+					"platform": core.PlatformAndroid,
+					"topic":    "/topics/news",
+					"message":  "Welcome",
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	for _, token := range []string{"router-aaaa", "router-bbbb", "router-cccc"} {
+		r.DELETE("/api/audience/devices/"+token).
+			Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+				assert.Equal(t, http.StatusOK, r.Code)
+			})
+	}
+
+	r.GET("/api/audience/devices/router-aaaa").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+}
+
+func TestTemplateRoutes(t *testing.T) {
+	cfg := initTest()
+	cfg.Ios.Enabled = true
+
+	r := gofight.New()
+
+	r.PUT("/api/templates/router-welcome/pt_BR").
+		SetJSON(gofight.D{
+			"title": "Olá {{.name}}",
+			"body":  "Bem-vindo ao {{.app}}",
+			"platforms": gofight.D{
+				"ios": gofight.D{"body": "Bem-vindo ao {{.app}} no iPhone"},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			locale, _ := jsonparser.GetString(r.Body.Bytes(), "locale")
+			assert.Equal(t, "pt-br", locale)
+		})
+
+	r.PUT("/api/templates/router-welcome/en").
+		SetJSON(gofight.D{"body": "Welcome to {{.app"}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.GET("/api/templates/router-welcome").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			locale, _ := jsonparser.GetString(r.Body.Bytes(), "templates", "[0]", "locale")
+			assert.Equal(t, "pt-br", locale)
+		})
+
+	r.POST("/api/templates/router-welcome/render").
+		SetJSON(gofight.D{
+			"locale":   "pt-BR",
+			"platform": core.PlatformIOS,
+			"vars":     gofight.D{"name": "Ana", "app": "gorush"},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+
+			data := r.Body.Bytes()
+			title, _ := jsonparser.GetString(data, "title")
+			body, _ := jsonparser.GetString(data, "body")
+			assert.Equal(t, "Olá Ana", title)
+			assert.Equal(t, "Bem-vindo ao gorush no iPhone", body)
+		})
+
+	r.POST("/api/templates/router-welcome/render").
+		SetJSON(gofight.D{"locale": "pt", "vars": gofight.D{"name": "Ana"}}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			// no pt template, nor one in the default locale
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+
+	r.POST("/api/templates/router-welcome/render").
+		SetJSON(gofight.D{"locale": "pt-br", "vars": gofight.D{"name": "Ana"}}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.POST("/api/push").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"tokens":   []string{"router-template"},
+					"platform": core.PlatformIOS,
+					"template": "router-welcome",
+					"locale":   "de",
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.DELETE("/api/templates/router-welcome/pt-br").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+
+	r.GET("/api/templates/router-welcome/pt-br").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+}
+
+func TestExperimentRoutes(t *testing.T) {
+	cfg := initTest()
+	cfg.Ios.Enabled = true
+
+	r := gofight.New()
+
+	r.POST("/api/push").
+		SetJSON(gofight.D{
+			"notifications": []gofight.D{
+				{
+					"tokens":   []string{"router-variant"},
+					"platform": core.PlatformIOS,
+					"message":  "Welcome",
+					"variants": []gofight.D{
+						{"name": "a", "weight": 1},
+						{"name": "b", "weight": 1},
+					},
+				},
+			},
+		}).
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			// no experiment
+			assert.Equal(t, http.StatusBadRequest, r.Code)
+		})
+
+	r.GET("/api/experiments/router-experiment").
+		Run(routerEngine(cfg, q), func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+		})
+}
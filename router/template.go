@@ -0,0 +1,140 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/logx"
+	"github.com/appleboy/gorush/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// templateRenderRequest selects the locale, the variables and the platform
+// of a template preview.
+type templateRenderRequest struct {
+	Locale   string   `json:"locale"`
+	Vars     notify.D `json:"vars"`
+	Platform int      `json:"platform"`
+}
+
+func registerTemplateRoutes(r *gin.Engine, cfg *config.ConfYaml) {
+	g := r.Group(cfg.API.TemplateURI)
+	g.GET("/:name", templatesHandler)
+	g.PUT("/:name/:locale", saveTemplateHandler)
+	g.GET("/:name/:locale", templateHandler)
+	g.DELETE("/:name/:locale", deleteTemplateHandler)
+	g.POST("/:name/render", renderTemplateHandler(cfg))
+}
+
+func templateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, notify.ErrTemplateNotFound):
+		abortWithError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, notify.ErrInvalidTemplate), errors.Is(err, notify.ErrTemplateRender):
+		abortWithError(c, http.StatusBadRequest, err.Error())
+	default:
+		logx.LogError.Error(err)
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// saveTemplateHandler stores the template in the locale, replacing the one
+// it had.
+func saveTemplateHandler(c *gin.Context) {
+	var tmpl notify.Template
+
+	if err := c.ShouldBindJSON(&tmpl); err != nil {
+		logx.LogAccess.Debug(err)
+		abortWithError(c, http.StatusBadRequest, "Invalid template request body.")
+		return
+	}
+
+	tmpl.Name = c.Param("name")
+	tmpl.Locale = c.Param("locale")
+	if err := notify.SaveTemplate(&tmpl); err != nil {
+		templateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tmpl)
+}
+
+// templatesHandler lists the locales of the template.
+func templatesHandler(c *gin.Context) {
+	templates, err := notify.ListTemplates(c.Param("name"))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+
+	if len(templates) == 0 {
+		templateError(c, notify.ErrTemplateNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+	})
+}
+
+func templateHandler(c *gin.Context) {
+	tmpl, err := notify.GetTemplate(c.Param("name"), c.Param("locale"))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tmpl)
+}
+
+func deleteTemplateHandler(c *gin.Context) {
+	if err := notify.DeleteTemplate(c.Param("name"), c.Param("locale")); err != nil {
+		templateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": "ok",
+	})
+}
+
+// renderTemplateHandler previews the template as a recipient with the locale
+// and the variables would get it, without sending anything.
+func renderTemplateHandler(cfg *config.ConfYaml) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var form templateRenderRequest
+
+		if err := c.ShouldBindJSON(&form); err != nil {
+			logx.LogAccess.Debug(err)
+			abortWithError(c, http.StatusBadRequest, "Invalid template request body.")
+			return
+		}
+
+		tmpl, err := notify.ResolveTemplate(cfg, c.Param("name"), form.Locale)
+		if err != nil {
+			templateError(c, err)
+			return
+		}
+
+		content, err := notify.RenderTemplate(cfg, &notify.PushNotification{
+			Template: c.Param("name"),
+			Locale:   form.Locale,
+			Vars:     form.Vars,
+			Platform: form.Platform,
+		}, "")
+		if err != nil {
+			templateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"template": tmpl.Name,
+			"locale":   tmpl.Locale,
+			"title":    content.Title,
+			"body":     content.Body,
+			"data":     content.Data,
+		})
+	}
+}
@@ -23,6 +23,13 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type GorushClient interface {
 	Send(ctx context.Context, in *NotificationRequest, opts ...grpc.CallOption) (*NotificationReply, error)
+	Subscribe(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicReply, error)
+	Unsubscribe(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicReply, error)
+	ListDeadLetters(ctx context.Context, in *DeadLetterFilter, opts ...grpc.CallOption) (*DeadLetterReply, error)
+	GetDeadLetter(ctx context.Context, in *DeadLetterRequest, opts ...grpc.CallOption) (*DeadLetter, error)
+	DeleteDeadLetters(ctx context.Context, in *DeadLetterBatchRequest, opts ...grpc.CallOption) (*DeadLetterReply, error)
+	RequeueDeadLetters(ctx context.Context, in *DeadLetterBatchRequest, opts ...grpc.CallOption) (*DeadLetterReply, error)
+	GetDeliveryStatus(ctx context.Context, in *DeliveryStatusRequest, opts ...grpc.CallOption) (*DeliveryStatus, error)
 }
 
 type gorushClient struct {
@@ -42,11 +49,81 @@ func (c *gorushClient) Send(ctx context.Context, in *NotificationRequest, opts .
 	return out, nil
 }
 
+func (c *gorushClient) Subscribe(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicReply, error) {
+	out := new(TopicReply)
+	err := c.cc.Invoke(ctx, "/proto.Gorush/Subscribe", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gorushClient) Unsubscribe(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicReply, error) {
+	out := new(TopicReply)
+	err := c.cc.Invoke(ctx, "/proto.Gorush/Unsubscribe", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gorushClient) ListDeadLetters(ctx context.Context, in *DeadLetterFilter, opts ...grpc.CallOption) (*DeadLetterReply, error) {
+	out := new(DeadLetterReply)
+	err := c.cc.Invoke(ctx, "/proto.Gorush/ListDeadLetters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gorushClient) GetDeadLetter(ctx context.Context, in *DeadLetterRequest, opts ...grpc.CallOption) (*DeadLetter, error) {
+	out := new(DeadLetter)
+	err := c.cc.Invoke(ctx, "/proto.Gorush/GetDeadLetter", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gorushClient) DeleteDeadLetters(ctx context.Context, in *DeadLetterBatchRequest, opts ...grpc.CallOption) (*DeadLetterReply, error) {
+	out := new(DeadLetterReply)
+	err := c.cc.Invoke(ctx, "/proto.Gorush/DeleteDeadLetters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gorushClient) RequeueDeadLetters(ctx context.Context, in *DeadLetterBatchRequest, opts ...grpc.CallOption) (*DeadLetterReply, error) {
+	out := new(DeadLetterReply)
+	err := c.cc.Invoke(ctx, "/proto.Gorush/RequeueDeadLetters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gorushClient) GetDeliveryStatus(ctx context.Context, in *DeliveryStatusRequest, opts ...grpc.CallOption) (*DeliveryStatus, error) {
+	out := new(DeliveryStatus)
+	err := c.cc.Invoke(ctx, "/proto.Gorush/GetDeliveryStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GorushServer is the server API for Gorush service.
 // All implementations should embed UnimplementedGorushServer
 // for forward compatibility
 type GorushServer interface {
 	Send(context.Context, *NotificationRequest) (*NotificationReply, error)
+	Subscribe(context.Context, *TopicRequest) (*TopicReply, error)
+	Unsubscribe(context.Context, *TopicRequest) (*TopicReply, error)
+	ListDeadLetters(context.Context, *DeadLetterFilter) (*DeadLetterReply, error)
+	GetDeadLetter(context.Context, *DeadLetterRequest) (*DeadLetter, error)
+	DeleteDeadLetters(context.Context, *DeadLetterBatchRequest) (*DeadLetterReply, error)
+	RequeueDeadLetters(context.Context, *DeadLetterBatchRequest) (*DeadLetterReply, error)
+	GetDeliveryStatus(context.Context, *DeliveryStatusRequest) (*DeliveryStatus, error)
 }
 
 // UnimplementedGorushServer should be embedded to have forward compatible implementations.
@@ -56,6 +133,27 @@ type UnimplementedGorushServer struct {
 func (UnimplementedGorushServer) Send(context.Context, *NotificationRequest) (*NotificationReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Send not implemented")
 }
+func (UnimplementedGorushServer) Subscribe(context.Context, *TopicRequest) (*TopicReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedGorushServer) Unsubscribe(context.Context, *TopicRequest) (*TopicReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unsubscribe not implemented")
+}
+func (UnimplementedGorushServer) ListDeadLetters(context.Context, *DeadLetterFilter) (*DeadLetterReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeadLetters not implemented")
+}
+func (UnimplementedGorushServer) GetDeadLetter(context.Context, *DeadLetterRequest) (*DeadLetter, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDeadLetter not implemented")
+}
+func (UnimplementedGorushServer) DeleteDeadLetters(context.Context, *DeadLetterBatchRequest) (*DeadLetterReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteDeadLetters not implemented")
+}
+func (UnimplementedGorushServer) RequeueDeadLetters(context.Context, *DeadLetterBatchRequest) (*DeadLetterReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequeueDeadLetters not implemented")
+}
+func (UnimplementedGorushServer) GetDeliveryStatus(context.Context, *DeliveryStatusRequest) (*DeliveryStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDeliveryStatus not implemented")
+}
 
 // UnsafeGorushServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to GorushServer will
@@ -86,6 +184,132 @@ func _Gorush_Send_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Gorush_Subscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GorushServer).Subscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Gorush/Subscribe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GorushServer).Subscribe(ctx, req.(*TopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gorush_Unsubscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GorushServer).Unsubscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Gorush/Unsubscribe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GorushServer).Unsubscribe(ctx, req.(*TopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gorush_ListDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadLetterFilter)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GorushServer).ListDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Gorush/ListDeadLetters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GorushServer).ListDeadLetters(ctx, req.(*DeadLetterFilter))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gorush_GetDeadLetter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadLetterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GorushServer).GetDeadLetter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Gorush/GetDeadLetter",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GorushServer).GetDeadLetter(ctx, req.(*DeadLetterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gorush_DeleteDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadLetterBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GorushServer).DeleteDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Gorush/DeleteDeadLetters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GorushServer).DeleteDeadLetters(ctx, req.(*DeadLetterBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gorush_RequeueDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadLetterBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GorushServer).RequeueDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Gorush/RequeueDeadLetters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GorushServer).RequeueDeadLetters(ctx, req.(*DeadLetterBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gorush_GetDeliveryStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeliveryStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GorushServer).GetDeliveryStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Gorush/GetDeliveryStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GorushServer).GetDeliveryStatus(ctx, req.(*DeliveryStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Gorush_ServiceDesc is the grpc.ServiceDesc for Gorush service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -97,6 +321,34 @@ var Gorush_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Send",
 			Handler:    _Gorush_Send_Handler,
 		},
+		{
+			MethodName: "Subscribe",
+			Handler:    _Gorush_Subscribe_Handler,
+		},
+		{
+			MethodName: "Unsubscribe",
+			Handler:    _Gorush_Unsubscribe_Handler,
+		},
+		{
+			MethodName: "ListDeadLetters",
+			Handler:    _Gorush_ListDeadLetters_Handler,
+		},
+		{
+			MethodName: "GetDeadLetter",
+			Handler:    _Gorush_GetDeadLetter_Handler,
+		},
+		{
+			MethodName: "DeleteDeadLetters",
+			Handler:    _Gorush_DeleteDeadLetters_Handler,
+		},
+		{
+			MethodName: "RequeueDeadLetters",
+			Handler:    _Gorush_RequeueDeadLetters_Handler,
+		},
+		{
+			MethodName: "GetDeliveryStatus",
+			Handler:    _Gorush_GetDeliveryStatus_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "gorush.proto",
@@ -3,6 +3,7 @@ package rpc
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -26,6 +27,8 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // Server is used to implement gorush grpc server.
@@ -110,6 +113,12 @@ func (s *Server) Send(ctx context.Context, in *proto.NotificationRequest) (*prot
 		notification.Data = in.Data.AsMap()
 	}
 
+	if err := notify.CheckNotification(s.cfg, &notification); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	notify.SetDeliveryState(s.cfg, &notification, notify.DeliveryAccepted, nil)
+
 	go func() {
 		ctx := context.Background()
 		_, err := notify.SendNotification(ctx, &notification, s.cfg)
@@ -129,6 +138,228 @@ func (s *Server) Send(ctx context.Context, in *proto.NotificationRequest) (*prot
 	}, nil
 }
 
+// Subscribe implements `rpc Subscribe`.
+func (s *Server) Subscribe(ctx context.Context, in *proto.TopicRequest) (*proto.TopicReply, error) {
+	return s.manageTopic(ctx, in, notify.SubscribeTopic)
+}
+
+// Unsubscribe implements `rpc Unsubscribe`.
+func (s *Server) Unsubscribe(ctx context.Context, in *proto.TopicRequest) (*proto.TopicReply, error) {
+	return s.manageTopic(ctx, in, notify.UnsubscribeTopic)
+}
+
+func (s *Server) manageTopic(
+	ctx context.Context,
+	in *proto.TopicRequest,
+	fn func(context.Context, *notify.RequestTopic, *config.ConfYaml) (*notify.ResponsePush, error),
+) (*proto.TopicReply, error) {
+	if !s.cfg.Android.Enabled {
+		return nil, status.Error(codes.FailedPrecondition, "android notification is disabled")
+	}
+
+	req := &notify.RequestTopic{
+		Topic:  in.Topic,
+		Tokens: in.Tokens,
+	}
+
+	if err := notify.CheckTopicMessage(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp, err := fn(ctx, req, s.cfg)
+	if resp == nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	counts, err := safeIntToInt32(len(req.Tokens))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &proto.TopicReply{
+		Success: true,
+		Counts:  counts,
+		Logs:    toProtoLogs(resp.Logs),
+	}, nil
+}
+
+// ListDeadLetters implements `rpc ListDeadLetters`.
+func (s *Server) ListDeadLetters(ctx context.Context, in *proto.DeadLetterFilter) (*proto.DeadLetterReply, error) {
+	letters, err := notify.ListDeadLetters(fromProtoDeadLetterFilter(in))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toProtoDeadLetterReply(letters)
+}
+
+// GetDeadLetter implements `rpc GetDeadLetter`.
+func (s *Server) GetDeadLetter(ctx context.Context, in *proto.DeadLetterRequest) (*proto.DeadLetter, error) {
+	letter, err := notify.GetDeadLetter(in.ID)
+	if errors.Is(err, notify.ErrDeadLetterNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toProtoDeadLetter(letter)
+}
+
+// DeleteDeadLetters implements `rpc DeleteDeadLetters`.
+func (s *Server) DeleteDeadLetters(ctx context.Context, in *proto.DeadLetterBatchRequest) (*proto.DeadLetterReply, error) {
+	if len(in.IDs) == 0 && in.Filter == nil {
+		return nil, status.Error(codes.InvalidArgument, "the ids or the filter of the dead letters are required")
+	}
+
+	letters, err := notify.DeleteDeadLetters(in.IDs, fromProtoDeadLetterFilter(in.Filter))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toProtoDeadLetterReply(letters)
+}
+
+// RequeueDeadLetters implements `rpc RequeueDeadLetters`, the notifications
+// are put back on the queue of the retries.
+func (s *Server) RequeueDeadLetters(ctx context.Context, in *proto.DeadLetterBatchRequest) (*proto.DeadLetterReply, error) {
+	if len(in.IDs) == 0 && in.Filter == nil {
+		return nil, status.Error(codes.InvalidArgument, "the ids or the filter of the dead letters are required")
+	}
+
+	letters, err := notify.RequeueDeadLetters(notify.RetryQueue, in.IDs, fromProtoDeadLetterFilter(in.Filter))
+	if errors.Is(err, notify.ErrDeadLetterNoQueue) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toProtoDeadLetterReply(letters)
+}
+
+func fromProtoDeadLetterFilter(in *proto.DeadLetterFilter) notify.DeadLetterFilter {
+	return notify.DeadLetterFilter{
+		Platform: int(in.GetPlatform()),
+		Reason:   in.GetReason(),
+		NotifID:  in.GetNotifID(),
+		Error:    in.GetError(),
+		Since:    in.GetSince(),
+		Until:    in.GetUntil(),
+		Limit:    int(in.GetLimit()),
+	}
+}
+
+// GetDeliveryStatus implements `rpc GetDeliveryStatus`.
+func (s *Server) GetDeliveryStatus(ctx context.Context, in *proto.DeliveryStatusRequest) (*proto.DeliveryStatus, error) {
+	record, err := notify.GetDeliveryStatus(in.NotifID)
+	if errors.Is(err, notify.ErrDeliveryStatusNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	platform, err := safeIntToInt32(record.Platform)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	retryAttempt, err := safeIntToInt32(record.RetryAttempt)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	reply := &proto.DeliveryStatus{
+		NotifID:      record.ID,
+		Platform:     platform,
+		State:        record.State,
+		Error:        record.Error,
+		RetryAttempt: retryAttempt,
+		CreatedAt:    record.CreatedAt,
+		UpdatedAt:    record.UpdatedAt,
+		Tokens:       make([]*proto.TokenStatus, 0, len(record.Tokens)),
+	}
+	for _, token := range record.Tokens {
+		reply.Tokens = append(reply.Tokens, &proto.TokenStatus{
+			Token:      token.Token,
+			Status:     token.Status,
+			ProviderID: token.ProviderID,
+			Error:      token.Error,
+			UpdatedAt:  token.UpdatedAt,
+			Code:       token.Code,
+		})
+	}
+
+	return reply, nil
+}
+
+// toProtoDeadLetter converts a dead letter into the gRPC representation, the
+// notification is kept in its JSON form.
+func toProtoDeadLetter(letter *notify.DeadLetter) (*proto.DeadLetter, error) {
+	data, err := json.Marshal(letter.Notification)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	notification := &structpb.Struct{}
+	if err := protojson.Unmarshal(data, notification); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	platform, err := safeIntToInt32(letter.Platform)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	attempts, err := safeIntToInt32(letter.Attempts)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &proto.DeadLetter{
+		ID:           letter.ID,
+		Reason:       letter.Reason,
+		Error:        letter.Error,
+		Platform:     platform,
+		Attempts:     attempts,
+		CreatedAt:    letter.CreatedAt,
+		Notification: notification,
+	}, nil
+}
+
+func toProtoDeadLetterReply(letters []*notify.DeadLetter) (*proto.DeadLetterReply, error) {
+	counts, err := safeIntToInt32(len(letters))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	reply := &proto.DeadLetterReply{
+		Counts:      counts,
+		DeadLetters: make([]*proto.DeadLetter, 0, len(letters)),
+	}
+	for _, letter := range letters {
+		l, err := toProtoDeadLetter(letter)
+		if err != nil {
+			return nil, err
+		}
+		reply.DeadLetters = append(reply.DeadLetters, l)
+	}
+
+	return reply, nil
+}
+
+// toProtoLogs converts push logs into the gRPC representation.
+func toProtoLogs(logs []logx.LogPushEntry) []*proto.PushLog {
+	result := make([]*proto.PushLog, 0, len(logs))
+	for _, l := range logs {
+		result = append(result, &proto.PushLog{
+			ID:       l.ID,
+			Type:     l.Type,
+			Platform: l.Platform,
+			Token:    l.Token,
+			Message:  l.Message,
+			Error:    l.Error,
+			Code:     l.Code,
+		})
+	}
+	return result
+}
+
 // safeIntToInt32 converts an int to an int32, returning an error if the int is out of range.
 func safeIntToInt32(n int) (int32, error) {
 	if n < math.MinInt32 || n > math.MaxInt32 {
@@ -1,8 +1,19 @@
 package rpc
 
 import (
+	"context"
 	"math"
 	"testing"
+
+	"github.com/appleboy/gorush/config"
+	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/notify"
+	"github.com/appleboy/gorush/rpc/proto"
+	"github.com/appleboy/gorush/status"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
 )
 
 func TestSafeIntToInt32(t *testing.T) {
@@ -33,6 +44,47 @@ func TestSafeIntToInt32(t *testing.T) {
 	}
 }
 
+func TestDeadLetters(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.DeadLetter.Enabled = true
+	assert.NoError(t, status.InitAppStatus(cfg))
+
+	letter, err := notify.AddDeadLetter(cfg, &notify.PushNotification{
+		ID:       "rpc-dead-letter",
+		Platform: core.PlatformIOS,
+		Tokens:   []string{"aaaa"},
+		Message:  "Welcome",
+	}, notify.DeadLetterMaxRetry, "ServiceUnavailable")
+	assert.NoError(t, err)
+
+	s := NewServer(cfg)
+	ctx := context.Background()
+
+	reply, err := s.ListDeadLetters(ctx, &proto.DeadLetterFilter{NotifID: "rpc-dead-letter"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), reply.Counts)
+	assert.Equal(t, letter.ID, reply.DeadLetters[0].ID)
+	assert.Equal(t, "Welcome", reply.DeadLetters[0].Notification.AsMap()["message"])
+
+	deadLetter, err := s.GetDeadLetter(ctx, &proto.DeadLetterRequest{ID: letter.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(core.PlatformIOS), deadLetter.Platform)
+
+	_, err = s.GetDeadLetter(ctx, &proto.DeadLetterRequest{ID: "unknown"})
+	assert.Equal(t, codes.NotFound, grpcstatus.Code(err))
+
+	_, err = s.DeleteDeadLetters(ctx, &proto.DeadLetterBatchRequest{})
+	assert.Equal(t, codes.InvalidArgument, grpcstatus.Code(err))
+
+	// no queue outside of the server mode
+	_, err = s.RequeueDeadLetters(ctx, &proto.DeadLetterBatchRequest{IDs: []string{letter.ID}})
+	assert.Equal(t, codes.Unavailable, grpcstatus.Code(err))
+
+	reply, err = s.DeleteDeadLetters(ctx, &proto.DeadLetterBatchRequest{IDs: []string{letter.ID}})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), reply.Counts)
+}
+
 // const gRPCAddr = "localhost:9000"
 
 // func initTest() *config.ConfYaml {
@@ -73,3 +125,27 @@ func TestSafeIntToInt32(t *testing.T) {
 // 	}
 // 	conn.Close()
 // }
+
+func TestGetDeliveryStatus(t *testing.T) {
+	cfg, _ := config.LoadConf()
+	cfg.DeliveryStatus.Enabled = true
+	assert.NoError(t, status.InitAppStatus(cfg))
+
+	notify.SetDeliveryState(cfg, &notify.PushNotification{
+		ID:       "rpc-delivery",
+		Platform: core.PlatformAndroid,
+	}, notify.DeliveryQueued, nil)
+
+	s := NewServer(cfg)
+	ctx := context.Background()
+
+	reply, err := s.GetDeliveryStatus(ctx, &proto.DeliveryStatusRequest{NotifID: "rpc-delivery"})
+	assert.NoError(t, err)
+	assert.Equal(t, "rpc-delivery", reply.NotifID)
+	assert.Equal(t, int32(core.PlatformAndroid), reply.Platform)
+	assert.Equal(t, notify.DeliveryQueued, reply.State)
+	assert.Empty(t, reply.Tokens)
+
+	_, err = s.GetDeliveryStatus(ctx, &proto.DeliveryStatusRequest{NotifID: "unknown"})
+	assert.Equal(t, codes.NotFound, grpcstatus.Code(err))
+}
@@ -33,6 +33,16 @@ type App struct {
 	Ios            IosStatus     `json:"ios"`
 	Android        AndroidStatus `json:"android"`
 	Huawei         HuaweiStatus  `json:"huawei"`
+	// Pools are the high priority lane and the platform pools, by name.
+	Pools map[string]PoolStatus `json:"pools,omitempty"`
+}
+
+// PoolStatus is the worker structure of a pool
+type PoolStatus struct {
+	BusyWorkers    int64  `json:"busy_workers"`
+	SuccessTasks   uint64 `json:"success_tasks"`
+	FailureTasks   uint64 `json:"failure_tasks"`
+	SubmittedTasks uint64 `json:"submitted_tasks"`
 }
 
 // AndroidStatus is android structure
@@ -2,6 +2,7 @@ package status
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -129,6 +130,7 @@ func TestStatForBoltDBEngine(t *testing.T) {
 	var val int64
 	cfg, _ := config.LoadConf()
 	cfg.Stat.Engine = "boltdb"
+	cfg.Stat.BoltDB.Path = filepath.Join(t.TempDir(), "bolt.db")
 	err := InitAppStatus(cfg)
 	assert.Nil(t, err)
 
@@ -1,6 +1,8 @@
 package status
 
 import (
+	"time"
+
 	"github.com/appleboy/gorush/core"
 )
 
@@ -102,3 +104,76 @@ func (s *StateStorage) GetHuaweiSuccess() int64 {
 func (s *StateStorage) GetHuaweiError() int64 {
 	return s.store.Get(core.HuaweiErrorKey)
 }
+
+// AddCount adds count to the counter of key, shared between the replicas
+// using the same storage engine.
+func (s *StateStorage) AddCount(key string, count int64) {
+	s.store.Add(key, count)
+}
+
+// GetCount returns the counter of key.
+func (s *StateStorage) GetCount(key string) int64 {
+	return s.store.Get(key)
+}
+
+// SetValue stores a raw value in the storage engine, it expires after ttl
+// when ttl is positive.
+func (s *StateStorage) SetValue(key string, value []byte, ttl time.Duration) error {
+	return s.store.SetValue(key, value, ttl)
+}
+
+// SetValueNX stores a raw value only when key does not exist yet, and reports
+// whether it was stored.
+func (s *StateStorage) SetValueNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.store.SetValueNX(key, value, ttl)
+}
+
+// GetValue returns the raw value of key, or nil when it does not exist.
+func (s *StateStorage) GetValue(key string) ([]byte, error) {
+	return s.store.GetValue(key)
+}
+
+// DelValue removes the raw value of key.
+func (s *StateStorage) DelValue(key string) error {
+	return s.store.DelValue(key)
+}
+
+// Keys lists the raw value keys that start with prefix.
+func (s *StateStorage) Keys(prefix string) ([]string, error) {
+	return s.store.Keys(prefix)
+}
+
+// IncrValue adds one to the counter value of key and returns it.
+func (s *StateStorage) IncrValue(key string, ttl time.Duration) (int64, error) {
+	return s.store.IncrValue(key, ttl)
+}
+
+// SetField stores a field of the map of key.
+func (s *StateStorage) SetField(key, field string, value []byte, ttl time.Duration) error {
+	return s.store.SetField(key, field, value, ttl)
+}
+
+// GetFields returns the fields of the map of key.
+func (s *StateStorage) GetFields(key string) (map[string][]byte, error) {
+	return s.store.GetFields(key)
+}
+
+// Mailbox returns the mailbox of the storage engine, nil when the engine is
+// not shared between replicas.
+func (s *StateStorage) Mailbox() core.Mailbox {
+	mailbox, ok := s.store.(core.Mailbox)
+	if !ok {
+		return nil
+	}
+	return mailbox
+}
+
+// RateLimiter returns the rate limiter of the storage engine, nil when the
+// engine is not shared between replicas.
+func (s *StateStorage) RateLimiter() core.RateLimiter {
+	limiter, ok := s.store.(core.RateLimiter)
+	if !ok {
+		return nil
+	}
+	return limiter
+}
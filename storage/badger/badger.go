@@ -1,12 +1,15 @@
 package badger
 
 import (
+	"errors"
 	"log"
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/storage"
 
 	"github.com/dgraph-io/badger/v4"
 )
@@ -48,6 +51,139 @@ func (s *Storage) Get(key string) int64 {
 	return s.getBadger(key)
 }
 
+func (s *Storage) SetValue(key string, value []byte, ttl time.Duration) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		entry := badger.NewEntry([]byte(key), value)
+		if ttl > 0 {
+			entry = entry.WithTTL(ttl)
+		}
+		return txn.SetEntry(entry)
+	})
+}
+
+func (s *Storage) SetValueNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	stored := false
+	err := s.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(key)); !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		entry := badger.NewEntry([]byte(key), value)
+		if ttl > 0 {
+			entry = entry.WithTTL(ttl)
+		}
+		if err := txn.SetEntry(entry); err != nil {
+			return err
+		}
+		stored = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return stored, nil
+}
+
+func (s *Storage) GetValue(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (s *Storage) DelValue(key string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(key))
+	})
+}
+
+func (s *Storage) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Item().KeyCopy(nil)))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (s *Storage) IncrValue(key string, ttl time.Duration) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var count int64
+	err := s.db.Update(func(txn *badger.Txn) error {
+		entry := badger.NewEntry([]byte(key), nil)
+		item, err := txn.Get([]byte(key))
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+			if ttl > 0 {
+				entry = entry.WithTTL(ttl)
+			}
+		case err != nil:
+			return err
+		default:
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			count, _ = strconv.ParseInt(string(value), 10, 64)
+			// the counter keeps the expiration it was created with
+			if expire := item.ExpiresAt(); expire > 0 {
+				entry.ExpiresAt = expire
+			}
+		}
+		count++
+		entry.Value = []byte(strconv.FormatInt(count, 10))
+		return txn.SetEntry(entry)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *Storage) SetField(key, field string, value []byte, ttl time.Duration) error {
+	return s.SetValue(storage.FieldKey(key, field), value, ttl)
+}
+
+func (s *Storage) GetFields(key string) (map[string][]byte, error) {
+	fields := map[string][]byte{}
+	prefix := storage.FieldKey(key, "")
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			fields[string(it.Item().Key()[len(prefix):])] = value
+		}
+		return nil
+	})
+	return fields, err
+}
+
 // Init client storage.
 func (s *Storage) Init() error {
 	var err error
@@ -3,8 +3,10 @@ package badger
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/storage"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,3 +49,116 @@ func TestBadgerEngine(t *testing.T) {
 
 	assert.NoError(t, badger.Close())
 }
+
+func TestBadgerValues(t *testing.T) {
+	badger := New("")
+	assert.NoError(t, badger.Init())
+
+	value, err := badger.GetValue("gorush-test-missing")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	assert.NoError(t, badger.SetValue("gorush-test-value-1", []byte("foo"), 0))
+	assert.NoError(t, badger.SetValue("gorush-test-value-2", []byte("bar"), time.Second))
+	assert.NoError(t, badger.SetValue("gorush-test-other", []byte("baz"), 0))
+
+	value, err = badger.GetValue("gorush-test-value-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("foo"), value)
+
+	keys, err := badger.Keys("gorush-test-value-")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"gorush-test-value-1", "gorush-test-value-2"}, keys)
+
+	// expired values are gone
+	assert.Eventually(t, func() bool {
+		value, err := badger.GetValue("gorush-test-value-2")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// only the first writer stores the value until it expires
+	stored, err := badger.SetValueNX("gorush-test-value-2", []byte("first"), time.Second)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+	stored, err = badger.SetValueNX("gorush-test-value-2", []byte("second"), 0)
+	assert.NoError(t, err)
+	assert.False(t, stored)
+	value, err = badger.GetValue("gorush-test-value-2")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), value)
+	assert.Eventually(t, func() bool {
+		stored, err := badger.SetValueNX("gorush-test-value-2", []byte("third"), 0)
+		return err == nil && stored
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.NoError(t, badger.DelValue("gorush-test-value-2"))
+
+	assert.NoError(t, badger.DelValue("gorush-test-value-1"))
+	assert.NoError(t, badger.DelValue("gorush-test-value-1"))
+	assert.NoError(t, badger.DelValue("gorush-test-other"))
+
+	keys, err = badger.Keys("gorush-test-")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.NoError(t, badger.Close())
+}
+
+func TestBadgerFields(t *testing.T) {
+	badger := New("")
+	assert.NoError(t, badger.Init())
+
+	fields, err := badger.GetFields("gorush-test-map-missing")
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	assert.NoError(t, badger.SetField("gorush-test-map", "a", []byte("foo"), time.Second))
+	assert.NoError(t, badger.SetField("gorush-test-map", "b", []byte("bar"), time.Second))
+	assert.NoError(t, badger.SetField("gorush-test-map", "a", []byte("baz"), time.Second))
+	assert.NoError(t, badger.SetField("gorush-test-map-other", "c", []byte("qux"), 0))
+
+	fields, err = badger.GetFields("gorush-test-map")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("baz"), "b": []byte("bar")}, fields)
+
+	// expired maps are gone
+	assert.Eventually(t, func() bool {
+		fields, err := badger.GetFields("gorush-test-map")
+		return err == nil && len(fields) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	fields, err = badger.GetFields("gorush-test-map-other")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"c": []byte("qux")}, fields)
+	assert.NoError(t, badger.DelValue(storage.FieldKey("gorush-test-map-other", "c")))
+
+	assert.NoError(t, badger.Close())
+}
+
+func TestBadgerIncrValue(t *testing.T) {
+	badger := New("")
+	assert.NoError(t, badger.Init())
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := badger.IncrValue("gorush-test-counter", time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+
+	count, err := badger.IncrValue("gorush-test-counter-other", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// expired counters start over
+	assert.Eventually(t, func() bool {
+		value, err := badger.GetValue("gorush-test-counter")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	count, err = badger.IncrValue("gorush-test-counter", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, badger.DelValue("gorush-test-counter"))
+	assert.NoError(t, badger.DelValue("gorush-test-counter-other"))
+	assert.NoError(t, badger.Close())
+}
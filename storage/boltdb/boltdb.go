@@ -1,17 +1,26 @@
 package boltdb
 
 import (
+	"bytes"
+	"errors"
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/storage"
 
 	"github.com/asdine/storm/v3"
+	bolt "go.etcd.io/bbolt"
 )
 
 var _ core.Storage = (*Storage)(nil)
 
+// sweepInterval is how often the expired values are removed, those read in
+// between are removed right away.
+var sweepInterval = time.Minute
+
 // New func implements the storage interface for gorush (https://github.com/appleboy/gorush)
 func New(dbPath, bucket string) *Storage {
 	return &Storage{
@@ -25,6 +34,8 @@ type Storage struct {
 	dbPath string
 	bucket string
 	db     *storm.DB
+	stop   chan struct{}
+	once   sync.Once
 	sync.RWMutex
 }
 
@@ -46,6 +57,194 @@ func (s *Storage) Get(key string) int64 {
 	return s.getBoltDB(key)
 }
 
+func (s *Storage) SetValue(key string, value []byte, ttl time.Duration) error {
+	return s.db.SetBytes(s.bucket, key, storage.EncodeValue(value, ttl))
+}
+
+func (s *Storage) SetValueNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	stored := false
+	err := s.db.Bolt.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(s.bucket))
+		if err != nil {
+			return err
+		}
+		if _, ok := storage.DecodeValue(bucket.Get([]byte(key))); ok {
+			return nil
+		}
+		if err := bucket.Put([]byte(key), storage.EncodeValue(value, ttl)); err != nil {
+			return err
+		}
+		stored = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return stored, nil
+}
+
+func (s *Storage) GetValue(key string) ([]byte, error) {
+	data, err := s.db.GetBytes(s.bucket, key)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := storage.DecodeValue(data)
+	if !ok {
+		return nil, s.DelValue(key)
+	}
+	return value, nil
+}
+
+func (s *Storage) DelValue(key string) error {
+	err := s.db.Delete(s.bucket, key)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *Storage) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	err := s.db.Bolt.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(s.bucket))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			if _, ok := storage.DecodeValue(v); ok {
+				keys = append(keys, string(k))
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (s *Storage) IncrValue(key string, ttl time.Duration) (int64, error) {
+	var count int64
+	err := s.db.Bolt.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(s.bucket))
+		if err != nil {
+			return err
+		}
+		var data []byte
+		data, count = storage.IncrEncodedValue(bucket.Get([]byte(key)), ttl)
+		return bucket.Put([]byte(key), data)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *Storage) SetField(key, field string, value []byte, ttl time.Duration) error {
+	prefix := []byte(storage.MapKey(key))
+	return s.db.Bolt.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(s.bucket))
+		if err != nil {
+			return err
+		}
+
+		// the fields of an expired map are not part of the new one
+		if _, ok := storage.DecodeValue(bucket.Get(prefix)); !ok {
+			if err := deletePrefix(bucket, prefix); err != nil {
+				return err
+			}
+		}
+
+		if err := bucket.Put(prefix, storage.EncodeValue(nil, ttl)); err != nil {
+			return err
+		}
+		return bucket.Put([]byte(storage.FieldKey(key, field)), storage.EncodeValue(value, 0))
+	})
+}
+
+func (s *Storage) GetFields(key string) (map[string][]byte, error) {
+	fields := map[string][]byte{}
+	prefix := []byte(storage.MapKey(key))
+	err := s.db.Bolt.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(s.bucket))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		k, v := c.Seek(prefix)
+		if !bytes.Equal(k, prefix) {
+			return nil
+		}
+		if _, ok := storage.DecodeValue(v); !ok {
+			return nil
+		}
+
+		for k, v = c.Next(); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if value, ok := storage.DecodeValue(v); ok {
+				fields[string(k[len(prefix):])] = bytes.Clone(value)
+			}
+		}
+		return nil
+	})
+	return fields, err
+}
+
+// deletePrefix removes the keys of the bucket starting with prefix.
+func deletePrefix(bucket *bolt.Bucket, prefix []byte) error {
+	keys := [][]byte{}
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, bytes.Clone(k))
+	}
+
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeExpired removes the values and the maps that have expired.
+func (s *Storage) removeExpired() error {
+	return s.db.Bolt.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(s.bucket))
+		if bucket == nil {
+			return nil
+		}
+
+		expired := [][]byte{}
+		var expiredMap []byte
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			// the fields follow the key of their map
+			if expiredMap != nil && bytes.HasPrefix(k, expiredMap) {
+				expired = append(expired, bytes.Clone(k))
+				continue
+			}
+			expiredMap = nil
+
+			if storage.Expired(v) {
+				expired = append(expired, bytes.Clone(k))
+				if storage.IsMapKey(k) {
+					expiredMap = bytes.Clone(k)
+				}
+			}
+		}
+
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // Init client storage.
 func (s *Storage) Init() error {
 	var err error
@@ -53,7 +252,26 @@ func (s *Storage) Init() error {
 		s.dbPath = os.TempDir() + "boltdb.db"
 	}
 	s.db, err = storm.Open(s.dbPath)
-	return err
+	if err != nil {
+		return err
+	}
+
+	s.stop = make(chan struct{})
+	ticker := time.NewTicker(sweepInterval)
+	go func(stop chan struct{}) {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.removeExpired(); err != nil {
+					log.Println("BoltDB sweep error:", err.Error())
+				}
+			}
+		}
+	}(s.stop)
+	return nil
 }
 
 // Close the storage connection
@@ -62,6 +280,11 @@ func (s *Storage) Close() error {
 		return nil
 	}
 
+	s.once.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+		}
+	})
 	return s.db.Close()
 }
 
@@ -1,11 +1,15 @@
 package boltdb
 
 import (
+	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/storage"
 
+	"github.com/asdine/storm/v3"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -47,3 +51,164 @@ func TestBoltDBEngine(t *testing.T) {
 
 	assert.NoError(t, boltDB.Close())
 }
+
+func TestBoltDBValues(t *testing.T) {
+	boltDB := New("", "gorush")
+	assert.NoError(t, boltDB.Init())
+
+	value, err := boltDB.GetValue("gorush-test-missing")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	assert.NoError(t, boltDB.SetValue("gorush-test-value-1", []byte("foo"), 0))
+	assert.NoError(t, boltDB.SetValue("gorush-test-value-2", []byte("bar"), time.Second))
+	assert.NoError(t, boltDB.SetValue("gorush-test-other", []byte("baz"), 0))
+
+	value, err = boltDB.GetValue("gorush-test-value-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("foo"), value)
+
+	keys, err := boltDB.Keys("gorush-test-value-")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"gorush-test-value-1", "gorush-test-value-2"}, keys)
+
+	// expired values are gone
+	assert.Eventually(t, func() bool {
+		value, err := boltDB.GetValue("gorush-test-value-2")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// only the first writer stores the value until it expires
+	stored, err := boltDB.SetValueNX("gorush-test-value-2", []byte("first"), time.Second)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+	stored, err = boltDB.SetValueNX("gorush-test-value-2", []byte("second"), 0)
+	assert.NoError(t, err)
+	assert.False(t, stored)
+	value, err = boltDB.GetValue("gorush-test-value-2")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), value)
+	assert.Eventually(t, func() bool {
+		stored, err := boltDB.SetValueNX("gorush-test-value-2", []byte("third"), 0)
+		return err == nil && stored
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.NoError(t, boltDB.DelValue("gorush-test-value-2"))
+
+	assert.NoError(t, boltDB.DelValue("gorush-test-value-1"))
+	assert.NoError(t, boltDB.DelValue("gorush-test-value-1"))
+	assert.NoError(t, boltDB.DelValue("gorush-test-other"))
+
+	keys, err = boltDB.Keys("gorush-test-")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.NoError(t, boltDB.Close())
+}
+
+func TestBoltDBFields(t *testing.T) {
+	boltDB := New("", "gorush")
+	assert.NoError(t, boltDB.Init())
+
+	fields, err := boltDB.GetFields("gorush-test-map-missing")
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	assert.NoError(t, boltDB.SetField("gorush-test-map", "a", []byte("foo"), time.Second))
+	assert.NoError(t, boltDB.SetField("gorush-test-map", "b", []byte("bar"), time.Second))
+	assert.NoError(t, boltDB.SetField("gorush-test-map", "a", []byte("baz"), time.Second))
+	assert.NoError(t, boltDB.SetField("gorush-test-map-other", "c", []byte("qux"), 0))
+
+	fields, err = boltDB.GetFields("gorush-test-map")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("baz"), "b": []byte("bar")}, fields)
+
+	// expired maps are gone
+	assert.Eventually(t, func() bool {
+		fields, err := boltDB.GetFields("gorush-test-map")
+		return err == nil && len(fields) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	fields, err = boltDB.GetFields("gorush-test-map-other")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"c": []byte("qux")}, fields)
+	assert.NoError(t, boltDB.DelValue(storage.FieldKey("gorush-test-map-other", "c")))
+	assert.NoError(t, boltDB.DelValue(storage.MapKey("gorush-test-map-other")))
+
+	// the map expires as a whole, after the last change of any field
+	assert.NoError(t, boltDB.SetField("gorush-test-map-ttl", "a", []byte("foo"), time.Second))
+	time.Sleep(600 * time.Millisecond)
+	assert.NoError(t, boltDB.SetField("gorush-test-map-ttl", "b", []byte("bar"), time.Second))
+	time.Sleep(600 * time.Millisecond)
+	fields, err = boltDB.GetFields("gorush-test-map-ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("foo"), "b": []byte("bar")}, fields)
+
+	// an expired map starts over without its fields
+	time.Sleep(time.Second)
+	assert.NoError(t, boltDB.SetField("gorush-test-map-ttl", "c", []byte("baz"), time.Second))
+	fields, err = boltDB.GetFields("gorush-test-map-ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"c": []byte("baz")}, fields)
+
+	assert.NoError(t, boltDB.Close())
+}
+
+func TestBoltDBIncrValue(t *testing.T) {
+	boltDB := New("", "gorush")
+	assert.NoError(t, boltDB.Init())
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := boltDB.IncrValue("gorush-test-counter", time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+
+	count, err := boltDB.IncrValue("gorush-test-counter-other", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// expired counters start over
+	assert.Eventually(t, func() bool {
+		value, err := boltDB.GetValue("gorush-test-counter")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	count, err = boltDB.IncrValue("gorush-test-counter", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, boltDB.DelValue("gorush-test-counter"))
+	assert.NoError(t, boltDB.DelValue("gorush-test-counter-other"))
+	assert.NoError(t, boltDB.Close())
+}
+
+func TestBoltDBSweep(t *testing.T) {
+	sweepInterval = 10 * time.Millisecond
+	t.Cleanup(func() { sweepInterval = time.Minute })
+
+	boltDB := New("", "gorush")
+	assert.NoError(t, boltDB.Init())
+
+	assert.NoError(t, boltDB.SetValue("gorush-test-sweep", []byte("foo"), 50*time.Millisecond))
+	assert.NoError(t, boltDB.SetValue("gorush-test-kept", []byte("bar"), 0))
+	assert.NoError(t, boltDB.SetField("gorush-test-sweep-map", "a", []byte("baz"), 50*time.Millisecond))
+	boltDB.Set("gorush-test-sweep-count", 12345678)
+
+	// the expired entries are removed without being read
+	assert.Eventually(t, func() bool {
+		_, value := boltDB.db.GetBytes("gorush", "gorush-test-sweep")
+		_, field := boltDB.db.GetBytes("gorush", storage.FieldKey("gorush-test-sweep-map", "a"))
+		_, fields := boltDB.db.GetBytes("gorush", storage.MapKey("gorush-test-sweep-map"))
+		return errors.Is(value, storm.ErrNotFound) && errors.Is(field, storm.ErrNotFound) &&
+			errors.Is(fields, storm.ErrNotFound)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	value, err := boltDB.GetValue("gorush-test-kept")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bar"), value)
+	assert.Equal(t, int64(12345678), boltDB.Get("gorush-test-sweep-count"))
+
+	assert.NoError(t, boltDB.DelValue("gorush-test-kept"))
+	assert.NoError(t, boltDB.DelValue("gorush-test-sweep-count"))
+	assert.NoError(t, boltDB.Close())
+}
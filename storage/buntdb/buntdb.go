@@ -1,13 +1,17 @@
 package buntdb
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/storage"
 
 	"github.com/tidwall/buntdb"
 )
@@ -46,6 +50,124 @@ func (s *Storage) Get(key string) int64 {
 	return s.getBuntDB(key)
 }
 
+func (s *Storage) SetValue(key string, value []byte, ttl time.Duration) error {
+	var opts *buntdb.SetOptions
+	if ttl > 0 {
+		opts = &buntdb.SetOptions{Expires: true, TTL: ttl}
+	}
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		_, _, err := tx.Set(key, string(value), opts)
+		return err
+	})
+}
+
+func (s *Storage) SetValueNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	var opts *buntdb.SetOptions
+	if ttl > 0 {
+		opts = &buntdb.SetOptions{Expires: true, TTL: ttl}
+	}
+
+	stored := false
+	err := s.db.Update(func(tx *buntdb.Tx) error {
+		if _, err := tx.Get(key); !errors.Is(err, buntdb.ErrNotFound) {
+			return err
+		}
+		_, _, err := tx.Set(key, string(value), opts)
+		stored = err == nil
+		return err
+	})
+	return stored, err
+}
+
+func (s *Storage) GetValue(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		val, err := tx.Get(key)
+		if err != nil {
+			return err
+		}
+		value = []byte(val)
+		return nil
+	})
+	if errors.Is(err, buntdb.ErrNotFound) {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (s *Storage) DelValue(key string) error {
+	err := s.db.Update(func(tx *buntdb.Tx) error {
+		_, err := tx.Delete(key)
+		return err
+	})
+	if errors.Is(err, buntdb.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *Storage) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		return tx.AscendGreaterOrEqual("", prefix, func(key, _ string) bool {
+			if !strings.HasPrefix(key, prefix) {
+				return false
+			}
+			keys = append(keys, key)
+			return true
+		})
+	})
+	return keys, err
+}
+
+func (s *Storage) IncrValue(key string, ttl time.Duration) (int64, error) {
+	var count int64
+	err := s.db.Update(func(tx *buntdb.Tx) error {
+		var opts *buntdb.SetOptions
+		val, err := tx.Get(key)
+		switch {
+		case errors.Is(err, buntdb.ErrNotFound):
+			if ttl > 0 {
+				opts = &buntdb.SetOptions{Expires: true, TTL: ttl}
+			}
+		case err != nil:
+			return err
+		default:
+			count, _ = strconv.ParseInt(val, 10, 64)
+			// the counter keeps the expiration it was created with
+			if left, err := tx.TTL(key); err == nil && left > 0 {
+				opts = &buntdb.SetOptions{Expires: true, TTL: left}
+			}
+		}
+		count++
+		_, _, err = tx.Set(key, strconv.FormatInt(count, 10), opts)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *Storage) SetField(key, field string, value []byte, ttl time.Duration) error {
+	return s.SetValue(storage.FieldKey(key, field), value, ttl)
+}
+
+func (s *Storage) GetFields(key string) (map[string][]byte, error) {
+	fields := map[string][]byte{}
+	prefix := storage.FieldKey(key, "")
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		return tx.AscendGreaterOrEqual("", prefix, func(k, v string) bool {
+			if !strings.HasPrefix(k, prefix) {
+				return false
+			}
+			fields[k[len(prefix):]] = []byte(v)
+			return true
+		})
+	})
+	return fields, err
+}
+
 // Init client storage.
 func (s *Storage) Init() error {
 	var err error
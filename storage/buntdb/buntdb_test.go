@@ -3,8 +3,10 @@ package buntdb
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/storage"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,3 +49,116 @@ func TestBuntDBEngine(t *testing.T) {
 
 	assert.NoError(t, buntDB.Close())
 }
+
+func TestBuntDBValues(t *testing.T) {
+	buntDB := New("")
+	assert.NoError(t, buntDB.Init())
+
+	value, err := buntDB.GetValue("gorush-test-missing")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	assert.NoError(t, buntDB.SetValue("gorush-test-value-1", []byte("foo"), 0))
+	assert.NoError(t, buntDB.SetValue("gorush-test-value-2", []byte("bar"), time.Second))
+	assert.NoError(t, buntDB.SetValue("gorush-test-other", []byte("baz"), 0))
+
+	value, err = buntDB.GetValue("gorush-test-value-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("foo"), value)
+
+	keys, err := buntDB.Keys("gorush-test-value-")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"gorush-test-value-1", "gorush-test-value-2"}, keys)
+
+	// expired values are gone
+	assert.Eventually(t, func() bool {
+		value, err := buntDB.GetValue("gorush-test-value-2")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// only the first writer stores the value until it expires
+	stored, err := buntDB.SetValueNX("gorush-test-value-2", []byte("first"), time.Second)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+	stored, err = buntDB.SetValueNX("gorush-test-value-2", []byte("second"), 0)
+	assert.NoError(t, err)
+	assert.False(t, stored)
+	value, err = buntDB.GetValue("gorush-test-value-2")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), value)
+	assert.Eventually(t, func() bool {
+		stored, err := buntDB.SetValueNX("gorush-test-value-2", []byte("third"), 0)
+		return err == nil && stored
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.NoError(t, buntDB.DelValue("gorush-test-value-2"))
+
+	assert.NoError(t, buntDB.DelValue("gorush-test-value-1"))
+	assert.NoError(t, buntDB.DelValue("gorush-test-value-1"))
+	assert.NoError(t, buntDB.DelValue("gorush-test-other"))
+
+	keys, err = buntDB.Keys("gorush-test-")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.NoError(t, buntDB.Close())
+}
+
+func TestBuntDBFields(t *testing.T) {
+	buntDB := New("")
+	assert.NoError(t, buntDB.Init())
+
+	fields, err := buntDB.GetFields("gorush-test-map-missing")
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	assert.NoError(t, buntDB.SetField("gorush-test-map", "a", []byte("foo"), time.Second))
+	assert.NoError(t, buntDB.SetField("gorush-test-map", "b", []byte("bar"), time.Second))
+	assert.NoError(t, buntDB.SetField("gorush-test-map", "a", []byte("baz"), time.Second))
+	assert.NoError(t, buntDB.SetField("gorush-test-map-other", "c", []byte("qux"), 0))
+
+	fields, err = buntDB.GetFields("gorush-test-map")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("baz"), "b": []byte("bar")}, fields)
+
+	// expired maps are gone
+	assert.Eventually(t, func() bool {
+		fields, err := buntDB.GetFields("gorush-test-map")
+		return err == nil && len(fields) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	fields, err = buntDB.GetFields("gorush-test-map-other")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"c": []byte("qux")}, fields)
+	assert.NoError(t, buntDB.DelValue(storage.FieldKey("gorush-test-map-other", "c")))
+
+	assert.NoError(t, buntDB.Close())
+}
+
+func TestBuntDBIncrValue(t *testing.T) {
+	buntDB := New("")
+	assert.NoError(t, buntDB.Init())
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := buntDB.IncrValue("gorush-test-counter", time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+
+	count, err := buntDB.IncrValue("gorush-test-counter-other", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// expired counters start over
+	assert.Eventually(t, func() bool {
+		value, err := buntDB.GetValue("gorush-test-counter")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	count, err = buntDB.IncrValue("gorush-test-counter", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, buntDB.DelValue("gorush-test-counter"))
+	assert.NoError(t, buntDB.DelValue("gorush-test-counter-other"))
+	assert.NoError(t, buntDB.Close())
+}
@@ -1,18 +1,28 @@
 package leveldb
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/storage"
 
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 var _ core.Storage = (*Storage)(nil)
 
+// sweepInterval is how often the expired values are removed, those read in
+// between are removed right away.
+var sweepInterval = time.Minute
+
 func (s *Storage) setLevelDB(key string, count int64) {
 	value := fmt.Sprintf("%d", count)
 	_ = s.db.Put([]byte(key), []byte(value), nil)
@@ -35,6 +45,8 @@ func New(dbPath string) *Storage {
 type Storage struct {
 	dbPath string
 	db     *leveldb.DB
+	stop   chan struct{}
+	once   sync.Once
 	sync.RWMutex
 }
 
@@ -56,6 +68,170 @@ func (s *Storage) Get(key string) int64 {
 	return s.getLevelDB(key)
 }
 
+func (s *Storage) SetValue(key string, value []byte, ttl time.Duration) error {
+	return s.db.Put([]byte(key), storage.EncodeValue(value, ttl), nil)
+}
+
+func (s *Storage) SetValueNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	current, err := s.GetValue(key)
+	if err != nil || current != nil {
+		return false, err
+	}
+	return true, s.SetValue(key, value, ttl)
+}
+
+func (s *Storage) GetValue(key string) ([]byte, error) {
+	data, err := s.db.Get([]byte(key), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := storage.DecodeValue(data)
+	if !ok {
+		return nil, s.DelValue(key)
+	}
+	return value, nil
+}
+
+func (s *Storage) DelValue(key string) error {
+	return s.db.Delete([]byte(key), nil)
+}
+
+func (s *Storage) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		if _, ok := storage.DecodeValue(iter.Value()); ok {
+			keys = append(keys, string(iter.Key()))
+		}
+	}
+	return keys, iter.Error()
+}
+
+func (s *Storage) IncrValue(key string, ttl time.Duration) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	data, err := s.db.Get([]byte(key), nil)
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return 0, err
+	}
+	data, count := storage.IncrEncodedValue(data, ttl)
+	return count, s.db.Put([]byte(key), data, nil)
+}
+
+func (s *Storage) SetField(key, field string, value []byte, ttl time.Duration) error {
+	s.Lock()
+	defer s.Unlock()
+
+	prefix := []byte(storage.MapKey(key))
+	batch := new(leveldb.Batch)
+
+	// the fields of an expired map are not part of the new one
+	data, err := s.db.Get(prefix, nil)
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return err
+	}
+	if _, ok := storage.DecodeValue(data); !ok {
+		iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+		for iter.Next() {
+			batch.Delete(append([]byte(nil), iter.Key()...))
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	batch.Put(prefix, storage.EncodeValue(nil, ttl))
+	batch.Put([]byte(storage.FieldKey(key, field)), storage.EncodeValue(value, 0))
+	return s.db.Write(batch, nil)
+}
+
+func (s *Storage) GetFields(key string) (map[string][]byte, error) {
+	fields := map[string][]byte{}
+	prefix := storage.MapKey(key)
+
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
+	data, err := snapshot.Get([]byte(prefix), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return fields, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := storage.DecodeValue(data); !ok {
+		return fields, nil
+	}
+
+	iter := snapshot.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		if len(iter.Key()) == len(prefix) {
+			continue
+		}
+		if value, ok := storage.DecodeValue(iter.Value()); ok {
+			fields[string(iter.Key()[len(prefix):])] = append([]byte(nil), value...)
+		}
+	}
+	return fields, iter.Error()
+}
+
+// removeExpired removes the values and the maps that have expired. The
+// transaction holds the writes back, a value written again meanwhile is never
+// removed.
+func (s *Storage) removeExpired() error {
+	tx, err := s.db.OpenTransaction()
+	if err != nil {
+		return err
+	}
+
+	expired := [][]byte{}
+	var expiredMap []byte
+	iter := tx.NewIterator(nil, nil)
+	for iter.Next() {
+		key := iter.Key()
+		// the fields follow the key of their map
+		if expiredMap != nil && bytes.HasPrefix(key, expiredMap) {
+			expired = append(expired, append([]byte(nil), key...))
+			continue
+		}
+		expiredMap = nil
+
+		if storage.Expired(iter.Value()) {
+			expired = append(expired, append([]byte(nil), key...))
+			if storage.IsMapKey(key) {
+				expiredMap = append([]byte(nil), key...)
+			}
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		tx.Discard()
+		return err
+	}
+
+	for _, key := range expired {
+		if err := tx.Delete(key, nil); err != nil {
+			tx.Discard()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // Init client storage.
 func (s *Storage) Init() error {
 	var err error
@@ -63,7 +239,26 @@ func (s *Storage) Init() error {
 		s.dbPath = os.TempDir() + "leveldb.db"
 	}
 	s.db, err = leveldb.OpenFile(s.dbPath, nil)
-	return err
+	if err != nil {
+		return err
+	}
+
+	s.stop = make(chan struct{})
+	ticker := time.NewTicker(sweepInterval)
+	go func(stop chan struct{}) {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.removeExpired(); err != nil {
+					log.Println("LevelDB sweep error:", err.Error())
+				}
+			}
+		}
+	}(s.stop)
+	return nil
 }
 
 // Close the storage connection
@@ -72,5 +267,10 @@ func (s *Storage) Close() error {
 		return nil
 	}
 
+	s.once.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+		}
+	})
 	return s.db.Close()
 }
@@ -3,8 +3,10 @@ package leveldb
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/appleboy/gorush/core"
+	"github.com/appleboy/gorush/storage"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,3 +49,163 @@ func TestLevelDBEngine(t *testing.T) {
 
 	assert.NoError(t, levelDB.Close())
 }
+
+func TestLevelDBValues(t *testing.T) {
+	levelDB := New("")
+	assert.NoError(t, levelDB.Init())
+
+	value, err := levelDB.GetValue("gorush-test-missing")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	assert.NoError(t, levelDB.SetValue("gorush-test-value-1", []byte("foo"), 0))
+	assert.NoError(t, levelDB.SetValue("gorush-test-value-2", []byte("bar"), time.Second))
+	assert.NoError(t, levelDB.SetValue("gorush-test-other", []byte("baz"), 0))
+
+	value, err = levelDB.GetValue("gorush-test-value-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("foo"), value)
+
+	keys, err := levelDB.Keys("gorush-test-value-")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"gorush-test-value-1", "gorush-test-value-2"}, keys)
+
+	// expired values are gone
+	assert.Eventually(t, func() bool {
+		value, err := levelDB.GetValue("gorush-test-value-2")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// only the first writer stores the value until it expires
+	stored, err := levelDB.SetValueNX("gorush-test-value-2", []byte("first"), time.Second)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+	stored, err = levelDB.SetValueNX("gorush-test-value-2", []byte("second"), 0)
+	assert.NoError(t, err)
+	assert.False(t, stored)
+	value, err = levelDB.GetValue("gorush-test-value-2")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), value)
+	assert.Eventually(t, func() bool {
+		stored, err := levelDB.SetValueNX("gorush-test-value-2", []byte("third"), 0)
+		return err == nil && stored
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.NoError(t, levelDB.DelValue("gorush-test-value-2"))
+
+	assert.NoError(t, levelDB.DelValue("gorush-test-value-1"))
+	assert.NoError(t, levelDB.DelValue("gorush-test-value-1"))
+	assert.NoError(t, levelDB.DelValue("gorush-test-other"))
+
+	keys, err = levelDB.Keys("gorush-test-")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.NoError(t, levelDB.Close())
+}
+
+func TestLevelDBFields(t *testing.T) {
+	levelDB := New("")
+	assert.NoError(t, levelDB.Init())
+
+	fields, err := levelDB.GetFields("gorush-test-map-missing")
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	assert.NoError(t, levelDB.SetField("gorush-test-map", "a", []byte("foo"), time.Second))
+	assert.NoError(t, levelDB.SetField("gorush-test-map", "b", []byte("bar"), time.Second))
+	assert.NoError(t, levelDB.SetField("gorush-test-map", "a", []byte("baz"), time.Second))
+	assert.NoError(t, levelDB.SetField("gorush-test-map-other", "c", []byte("qux"), 0))
+
+	fields, err = levelDB.GetFields("gorush-test-map")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("baz"), "b": []byte("bar")}, fields)
+
+	// expired maps are gone
+	assert.Eventually(t, func() bool {
+		fields, err := levelDB.GetFields("gorush-test-map")
+		return err == nil && len(fields) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	fields, err = levelDB.GetFields("gorush-test-map-other")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"c": []byte("qux")}, fields)
+	assert.NoError(t, levelDB.DelValue(storage.FieldKey("gorush-test-map-other", "c")))
+	assert.NoError(t, levelDB.DelValue(storage.MapKey("gorush-test-map-other")))
+
+	// the map expires as a whole, after the last change of any field
+	assert.NoError(t, levelDB.SetField("gorush-test-map-ttl", "a", []byte("foo"), time.Second))
+	time.Sleep(600 * time.Millisecond)
+	assert.NoError(t, levelDB.SetField("gorush-test-map-ttl", "b", []byte("bar"), time.Second))
+	time.Sleep(600 * time.Millisecond)
+	fields, err = levelDB.GetFields("gorush-test-map-ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("foo"), "b": []byte("bar")}, fields)
+
+	// an expired map starts over without its fields
+	time.Sleep(time.Second)
+	assert.NoError(t, levelDB.SetField("gorush-test-map-ttl", "c", []byte("baz"), time.Second))
+	fields, err = levelDB.GetFields("gorush-test-map-ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"c": []byte("baz")}, fields)
+
+	assert.NoError(t, levelDB.Close())
+}
+
+func TestLevelDBIncrValue(t *testing.T) {
+	levelDB := New("")
+	assert.NoError(t, levelDB.Init())
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := levelDB.IncrValue("gorush-test-counter", time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+
+	count, err := levelDB.IncrValue("gorush-test-counter-other", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// expired counters start over
+	assert.Eventually(t, func() bool {
+		value, err := levelDB.GetValue("gorush-test-counter")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	count, err = levelDB.IncrValue("gorush-test-counter", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, levelDB.DelValue("gorush-test-counter"))
+	assert.NoError(t, levelDB.DelValue("gorush-test-counter-other"))
+	assert.NoError(t, levelDB.Close())
+}
+
+func TestLevelDBSweep(t *testing.T) {
+	sweepInterval = 10 * time.Millisecond
+	t.Cleanup(func() { sweepInterval = time.Minute })
+
+	levelDB := New("")
+	assert.NoError(t, levelDB.Init())
+
+	assert.NoError(t, levelDB.SetValue("gorush-test-sweep", []byte("foo"), 50*time.Millisecond))
+	assert.NoError(t, levelDB.SetValue("gorush-test-kept", []byte("bar"), 0))
+	assert.NoError(t, levelDB.SetField("gorush-test-sweep-map", "a", []byte("baz"), 50*time.Millisecond))
+	levelDB.Set("gorush-test-sweep-count", 12345678)
+
+	// the expired entries are removed without being read
+	assert.Eventually(t, func() bool {
+		value, _ := levelDB.db.Has([]byte("gorush-test-sweep"), nil)
+		field, _ := levelDB.db.Has([]byte(storage.FieldKey("gorush-test-sweep-map", "a")), nil)
+		fields, _ := levelDB.db.Has([]byte(storage.MapKey("gorush-test-sweep-map")), nil)
+		return !value && !field && !fields
+	}, 5*time.Second, 10*time.Millisecond)
+
+	value, err := levelDB.GetValue("gorush-test-kept")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bar"), value)
+	assert.Equal(t, int64(12345678), levelDB.Get("gorush-test-sweep-count"))
+
+	assert.NoError(t, levelDB.DelValue("gorush-test-kept"))
+	assert.NoError(t, levelDB.DelValue("gorush-test-sweep-count"))
+	assert.NoError(t, levelDB.Close())
+}
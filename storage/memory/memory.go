@@ -1,7 +1,10 @@
 package memory
 
 import (
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/appleboy/gorush/core"
 
@@ -10,6 +13,10 @@ import (
 
 var _ core.Storage = (*Storage)(nil)
 
+// sweepInterval is how often the expired values are removed, those read in
+// between are removed right away.
+var sweepInterval = time.Minute
+
 // New func implements the storage interface for gorush (https://github.com/appleboy/gorush)
 func New() *Storage {
 	return &Storage{}
@@ -17,7 +24,31 @@ func New() *Storage {
 
 // Storage is interface structure
 type Storage struct {
-	mem sync.Map
+	mem    sync.Map
+	values sync.Map
+	maps   sync.Map
+	stop   chan struct{}
+	once   sync.Once
+}
+
+type value struct {
+	data   []byte
+	expire time.Time
+}
+
+func (v *value) expired() bool {
+	return !v.expire.IsZero() && !time.Now().Before(v.expire)
+}
+
+type fields struct {
+	sync.Mutex
+	data    map[string][]byte
+	expire  time.Time
+	removed bool
+}
+
+func (f *fields) expired() bool {
+	return !f.expire.IsZero() && !time.Now().Before(f.expire)
 }
 
 func (s *Storage) getValueBtKey(key string) *atomic.Int64 {
@@ -41,12 +72,187 @@ func (s *Storage) Get(key string) int64 {
 	return s.getValueBtKey(key).Load()
 }
 
+func (s *Storage) SetValue(key string, data []byte, ttl time.Duration) error {
+	v := &value{data: append([]byte(nil), data...)}
+	if ttl > 0 {
+		v.expire = time.Now().Add(ttl)
+	}
+	s.values.Store(key, v)
+	return nil
+}
+
+func (s *Storage) SetValueNX(key string, data []byte, ttl time.Duration) (bool, error) {
+	v := &value{data: append([]byte(nil), data...)}
+	if ttl > 0 {
+		v.expire = time.Now().Add(ttl)
+	}
+
+	for {
+		old, loaded := s.values.LoadOrStore(key, v)
+		if !loaded {
+			return true, nil
+		}
+		if !old.(*value).expired() {
+			return false, nil
+		}
+		// replace the expired value, unless someone else did it first
+		if s.values.CompareAndSwap(key, old, v) {
+			return true, nil
+		}
+	}
+}
+
+func (s *Storage) GetValue(key string) ([]byte, error) {
+	val, ok := s.values.Load(key)
+	if !ok {
+		return nil, nil
+	}
+
+	v := val.(*value)
+	if v.expired() {
+		s.values.CompareAndDelete(key, val)
+		return nil, nil
+	}
+
+	return append([]byte(nil), v.data...), nil
+}
+
+func (s *Storage) DelValue(key string) error {
+	s.values.Delete(key)
+	return nil
+}
+
+func (s *Storage) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	s.values.Range(func(key, val any) bool {
+		if val.(*value).expired() {
+			s.values.CompareAndDelete(key, val)
+			return true
+		}
+		if k := key.(string); strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+		return true
+	})
+	return keys, nil
+}
+
+func (s *Storage) IncrValue(key string, ttl time.Duration) (int64, error) {
+	for {
+		val, loaded := s.values.Load(key)
+		next := &value{data: []byte("1")}
+		count := int64(1)
+		if loaded && !val.(*value).expired() {
+			old := val.(*value)
+			count, _ = strconv.ParseInt(string(old.data), 10, 64)
+			count++
+			next = &value{data: []byte(strconv.FormatInt(count, 10)), expire: old.expire}
+		} else if ttl > 0 {
+			next.expire = time.Now().Add(ttl)
+		}
+
+		// start over when someone else changed the value meanwhile
+		if loaded && s.values.CompareAndSwap(key, val, next) {
+			return count, nil
+		}
+		if !loaded {
+			if _, loaded := s.values.LoadOrStore(key, next); !loaded {
+				return count, nil
+			}
+		}
+	}
+}
+
+func (s *Storage) SetField(key, field string, data []byte, ttl time.Duration) error {
+	var f *fields
+	for {
+		val, _ := s.maps.LoadOrStore(key, &fields{data: map[string][]byte{}})
+		f = val.(*fields)
+		f.Lock()
+		// an expired map removed meanwhile is replaced by a new one
+		if !f.removed {
+			break
+		}
+		f.Unlock()
+	}
+	defer f.Unlock()
+
+	if f.expired() {
+		f.data = map[string][]byte{}
+	}
+	f.data[field] = append([]byte(nil), data...)
+	f.expire = time.Time{}
+	if ttl > 0 {
+		f.expire = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+func (s *Storage) GetFields(key string) (map[string][]byte, error) {
+	result := map[string][]byte{}
+	val, ok := s.maps.Load(key)
+	if !ok {
+		return result, nil
+	}
+
+	f := val.(*fields)
+	f.Lock()
+	defer f.Unlock()
+	if f.expired() {
+		f.removed = true
+		s.maps.CompareAndDelete(key, val)
+		return result, nil
+	}
+	for field, data := range f.data {
+		result[field] = append([]byte(nil), data...)
+	}
+	return result, nil
+}
+
+// removeExpired removes the values and the maps that have expired.
+func (s *Storage) removeExpired() {
+	s.values.Range(func(key, val any) bool {
+		if val.(*value).expired() {
+			s.values.CompareAndDelete(key, val)
+		}
+		return true
+	})
+	s.maps.Range(func(key, val any) bool {
+		f := val.(*fields)
+		f.Lock()
+		if f.expired() {
+			f.removed = true
+			s.maps.CompareAndDelete(key, val)
+		}
+		f.Unlock()
+		return true
+	})
+}
+
 // Init client storage.
-func (*Storage) Init() error {
+func (s *Storage) Init() error {
+	s.stop = make(chan struct{})
+	ticker := time.NewTicker(sweepInterval)
+	go func(stop chan struct{}) {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.removeExpired()
+			}
+		}
+	}(s.stop)
 	return nil
 }
 
 // Close the storage connection
-func (*Storage) Close() error {
+func (s *Storage) Close() error {
+	s.once.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+		}
+	})
 	return nil
 }
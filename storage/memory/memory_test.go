@@ -3,6 +3,7 @@ package memory
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/appleboy/gorush/core"
 
@@ -47,3 +48,141 @@ func TestMemoryEngine(t *testing.T) {
 
 	assert.NoError(t, memory.Close())
 }
+
+func TestMemoryValues(t *testing.T) {
+	memory := New()
+	assert.NoError(t, memory.Init())
+
+	value, err := memory.GetValue("gorush-test-missing")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	assert.NoError(t, memory.SetValue("gorush-test-value-1", []byte("foo"), 0))
+	assert.NoError(t, memory.SetValue("gorush-test-value-2", []byte("bar"), time.Second))
+	assert.NoError(t, memory.SetValue("gorush-test-other", []byte("baz"), 0))
+
+	value, err = memory.GetValue("gorush-test-value-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("foo"), value)
+
+	keys, err := memory.Keys("gorush-test-value-")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"gorush-test-value-1", "gorush-test-value-2"}, keys)
+
+	// expired values are gone
+	assert.Eventually(t, func() bool {
+		value, err := memory.GetValue("gorush-test-value-2")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// only the first writer stores the value until it expires
+	stored, err := memory.SetValueNX("gorush-test-value-2", []byte("first"), time.Second)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+	stored, err = memory.SetValueNX("gorush-test-value-2", []byte("second"), 0)
+	assert.NoError(t, err)
+	assert.False(t, stored)
+	value, err = memory.GetValue("gorush-test-value-2")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), value)
+	assert.Eventually(t, func() bool {
+		stored, err := memory.SetValueNX("gorush-test-value-2", []byte("third"), 0)
+		return err == nil && stored
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.NoError(t, memory.DelValue("gorush-test-value-2"))
+
+	assert.NoError(t, memory.DelValue("gorush-test-value-1"))
+	assert.NoError(t, memory.DelValue("gorush-test-value-1"))
+	assert.NoError(t, memory.DelValue("gorush-test-other"))
+
+	keys, err = memory.Keys("gorush-test-")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.NoError(t, memory.Close())
+}
+
+func TestMemoryFields(t *testing.T) {
+	memory := New()
+	assert.NoError(t, memory.Init())
+
+	fields, err := memory.GetFields("gorush-test-map-missing")
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	assert.NoError(t, memory.SetField("gorush-test-map", "a", []byte("foo"), time.Second))
+	assert.NoError(t, memory.SetField("gorush-test-map", "b", []byte("bar"), time.Second))
+	assert.NoError(t, memory.SetField("gorush-test-map", "a", []byte("baz"), time.Second))
+	assert.NoError(t, memory.SetField("gorush-test-map-other", "c", []byte("qux"), 0))
+
+	fields, err = memory.GetFields("gorush-test-map")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("baz"), "b": []byte("bar")}, fields)
+
+	// expired maps are gone
+	assert.Eventually(t, func() bool {
+		fields, err := memory.GetFields("gorush-test-map")
+		return err == nil && len(fields) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	fields, err = memory.GetFields("gorush-test-map-other")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"c": []byte("qux")}, fields)
+
+	assert.NoError(t, memory.Close())
+}
+
+func TestMemoryIncrValue(t *testing.T) {
+	memory := New()
+	assert.NoError(t, memory.Init())
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := memory.IncrValue("gorush-test-counter", time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+
+	count, err := memory.IncrValue("gorush-test-counter-other", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// expired counters start over
+	assert.Eventually(t, func() bool {
+		value, err := memory.GetValue("gorush-test-counter")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	count, err = memory.IncrValue("gorush-test-counter", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, memory.DelValue("gorush-test-counter"))
+	assert.NoError(t, memory.DelValue("gorush-test-counter-other"))
+	assert.NoError(t, memory.Close())
+}
+
+func TestMemorySweep(t *testing.T) {
+	sweepInterval = 10 * time.Millisecond
+	t.Cleanup(func() { sweepInterval = time.Minute })
+
+	memory := New()
+	assert.NoError(t, memory.Init())
+
+	assert.NoError(t, memory.SetValue("gorush-test-sweep", []byte("foo"), 50*time.Millisecond))
+	assert.NoError(t, memory.SetValue("gorush-test-kept", []byte("bar"), 0))
+	assert.NoError(t, memory.SetField("gorush-test-sweep-map", "a", []byte("baz"), 50*time.Millisecond))
+
+	// the expired entries are removed without being read
+	assert.Eventually(t, func() bool {
+		_, value := memory.values.Load("gorush-test-sweep")
+		_, fields := memory.maps.Load("gorush-test-sweep-map")
+		return !value && !fields
+	}, 5*time.Second, 10*time.Millisecond)
+
+	value, err := memory.GetValue("gorush-test-kept")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bar"), value)
+
+	assert.NoError(t, memory.Close())
+	assert.NoError(t, memory.Close())
+}
@@ -2,17 +2,65 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/appleboy/gorush/core"
 
 	"github.com/redis/go-redis/v9"
 )
 
-var _ core.Storage = (*Storage)(nil)
+var (
+	_ core.Storage     = (*Storage)(nil)
+	_ core.RateLimiter = (*Storage)(nil)
+	_ core.Mailbox     = (*Storage)(nil)
+)
+
+// reserveScript is a generic cell rate algorithm: the key holds the
+// theoretical arrival time of the next token, in microseconds of the redis
+// clock so that the replicas agree on it.
+var reserveScript = redis.NewScript(`
+local now = redis.call("TIME")
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local count = tonumber(ARGV[3])
+local max_wait = tonumber(ARGV[4])
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+  tat = now
+end
+
+local next_tat = tat + count * interval
+local wait = math.max(next_tat - burst * interval - now, 0)
+if max_wait > 0 and wait > max_wait then
+  return {0, wait}
+end
+
+redis.call("SET", KEYS[1], next_tat, "PX", math.ceil((next_tat - now) / 1000) + 1)
+return {1, wait}
+`)
+
+// incrScript increments the counter and sets its expiration when it is new,
+// in one step so that a counter never stays without one.
+var incrScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 and tonumber(ARGV[1]) > 0 then
+  redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// mailboxPoolSize is the number of API requests waiting for their reply at
+// once. Each one holds a connection while it blocks, they have a pool of
+// their own so that they never starve the other commands.
+const mailboxPoolSize = 1000
 
 // New func implements the storage interface for gorush (https://github.com/appleboy/gorush)
 func New(
@@ -36,6 +84,7 @@ func New(
 type Storage struct {
 	ctx       context.Context
 	client    redis.Cmdable
+	mailbox   redis.Cmdable
 	addr      string
 	username  string
 	password  string
@@ -57,22 +106,159 @@ func (s *Storage) Get(key string) int64 {
 	return count
 }
 
-// Init client storage.
-func (s *Storage) Init() error {
+func (s *Storage) SetValue(key string, value []byte, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return s.client.Set(s.ctx, key, value, ttl).Err()
+}
+
+func (s *Storage) SetValueNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return s.client.SetNX(s.ctx, key, value, ttl).Result()
+}
+
+func (s *Storage) GetValue(key string) ([]byte, error) {
+	val, err := s.client.Get(s.ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return val, err
+}
+
+func (s *Storage) DelValue(key string) error {
+	return s.client.Del(s.ctx, key).Err()
+}
+
+func (s *Storage) Keys(prefix string) ([]string, error) {
+	cluster, ok := s.client.(*redis.ClusterClient)
+	if !ok {
+		return scanKeys(s.ctx, s.client, prefix)
+	}
+
+	// every master holds its own part of the key space
+	var (
+		mu   sync.Mutex
+		keys = []string{}
+	)
+	err := cluster.ForEachMaster(s.ctx, func(ctx context.Context, client *redis.Client) error {
+		found, err := scanKeys(ctx, client, prefix)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		keys = append(keys, found...)
+		mu.Unlock()
+		return nil
+	})
+	return keys, err
+}
+
+func (s *Storage) IncrValue(key string, ttl time.Duration) (int64, error) {
+	return incrScript.Run(s.ctx, s.client, []string{key}, ttl.Milliseconds()).Int64()
+}
+
+func (s *Storage) SetField(key, field string, value []byte, ttl time.Duration) error {
+	_, err := s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(s.ctx, key, field, value)
+		if ttl > 0 {
+			pipe.Expire(s.ctx, key, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *Storage) GetFields(key string) (map[string][]byte, error) {
+	values, err := s.client.HGetAll(s.ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string][]byte, len(values))
+	for field, value := range values {
+		fields[field] = []byte(value)
+	}
+	return fields, nil
+}
+
+func (s *Storage) Reserve(key string, interval time.Duration, burst, count int, maxWait time.Duration) (time.Duration, bool, error) {
+	res, err := reserveScript.Run(s.ctx, s.client, []string{key},
+		interval.Microseconds(), burst, count, maxWait.Microseconds()).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	return time.Duration(res[1]) * time.Microsecond, res[0] == 1, nil
+}
+
+func scanKeys(ctx context.Context, client redis.Cmdable, prefix string) ([]string, error) {
+	keys := []string{}
+	iter := client.Scan(ctx, 0, escapePattern(prefix)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// escapePattern escapes the glob characters of a SCAN MATCH pattern.
+func escapePattern(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(prefix)
+}
+
+func (s *Storage) Post(key string, message []byte, ttl time.Duration) error {
+	_, err := s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(s.ctx, key, message)
+		if ttl > 0 {
+			pipe.PExpire(s.ctx, key, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *Storage) Receive(ctx context.Context, key string, timeout time.Duration) ([]byte, error) {
+	// BLPOP waits forever with a zero timeout
+	if timeout <= 0 {
+		return nil, nil
+	}
+
+	values, err := s.mailbox.BLPop(ctx, timeout, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values[1]), nil
+}
+
+// newClient returns a client with a pool of poolSize connections, the
+// default size when zero.
+func (s *Storage) newClient(poolSize int) redis.Cmdable {
 	if s.isCluster {
-		s.client = redis.NewClusterClient(&redis.ClusterOptions{
+		return redis.NewClusterClient(&redis.ClusterOptions{
 			Addrs:    strings.Split(s.addr, ","),
 			Username: s.username,
 			Password: s.password,
-		})
-	} else {
-		s.client = redis.NewClient(&redis.Options{
-			Addr:     s.addr,
-			Password: s.password,
-			DB:       s.db,
+			PoolSize: poolSize,
 		})
 	}
 
+	return redis.NewClient(&redis.Options{
+		Addr:     s.addr,
+		Password: s.password,
+		DB:       s.db,
+		PoolSize: poolSize,
+	})
+}
+
+// Init client storage.
+func (s *Storage) Init() error {
+	s.client = s.newClient(0)
+	s.mailbox = s.newClient(mailboxPoolSize)
+
 	if err := s.client.Ping(s.ctx).Err(); err != nil {
 		return err
 	}
@@ -82,7 +268,11 @@ func (s *Storage) Init() error {
 
 // Close the storage connection
 func (s *Storage) Close() error {
-	switch v := s.client.(type) {
+	return errors.Join(closeClient(s.mailbox), closeClient(s.client))
+}
+
+func closeClient(client redis.Cmdable) error {
+	switch v := client.(type) {
 	case *redis.Client:
 		return v.Close()
 	case *redis.ClusterClient:
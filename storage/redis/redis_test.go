@@ -1,8 +1,10 @@
 package redis
 
 import (
+	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/appleboy/gorush/core"
 
@@ -66,3 +68,193 @@ func TestRedisEngine(t *testing.T) {
 
 	assert.NoError(t, redis.Close())
 }
+
+func TestRedisValues(t *testing.T) {
+	redis := New(
+		"redis:6379", // addr
+		"",           // username
+		"",           // password
+		0,            // db
+		false,        // cluster
+	)
+	assert.NoError(t, redis.Init())
+
+	value, err := redis.GetValue("gorush-test-missing")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	assert.NoError(t, redis.SetValue("gorush-test-value-1", []byte("foo"), 0))
+	assert.NoError(t, redis.SetValue("gorush-test-value-2", []byte("bar"), time.Second))
+	assert.NoError(t, redis.SetValue("gorush-test-other", []byte("baz"), 0))
+
+	value, err = redis.GetValue("gorush-test-value-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("foo"), value)
+
+	keys, err := redis.Keys("gorush-test-value-")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"gorush-test-value-1", "gorush-test-value-2"}, keys)
+
+	// expired values are gone
+	assert.Eventually(t, func() bool {
+		value, err := redis.GetValue("gorush-test-value-2")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// only the first writer stores the value until it expires
+	stored, err := redis.SetValueNX("gorush-test-value-2", []byte("first"), time.Second)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+	stored, err = redis.SetValueNX("gorush-test-value-2", []byte("second"), 0)
+	assert.NoError(t, err)
+	assert.False(t, stored)
+	value, err = redis.GetValue("gorush-test-value-2")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), value)
+	assert.Eventually(t, func() bool {
+		stored, err := redis.SetValueNX("gorush-test-value-2", []byte("third"), 0)
+		return err == nil && stored
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.NoError(t, redis.DelValue("gorush-test-value-2"))
+
+	assert.NoError(t, redis.DelValue("gorush-test-value-1"))
+	assert.NoError(t, redis.DelValue("gorush-test-value-1"))
+	assert.NoError(t, redis.DelValue("gorush-test-other"))
+
+	keys, err = redis.Keys("gorush-test-")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.NoError(t, redis.Close())
+}
+
+func TestRedisReserve(t *testing.T) {
+	redis := New(
+		"redis:6379", // addr
+		"",           // username
+		"",           // password
+		0,            // db
+		false,        // cluster
+	)
+	assert.NoError(t, redis.Init())
+	assert.NoError(t, redis.DelValue("gorush-test-bucket"))
+
+	// the burst goes through right away
+	for i := 0; i < 2; i++ {
+		wait, ok, err := redis.Reserve("gorush-test-bucket", time.Second, 2, 1, 0)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Zero(t, wait)
+	}
+
+	wait, ok, err := redis.Reserve("gorush-test-bucket", time.Second, 2, 1, 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.InDelta(t, time.Second, wait, float64(100*time.Millisecond))
+
+	// nothing is taken when the wait is too long
+	wait, ok, err = redis.Reserve("gorush-test-bucket", time.Second, 2, 1, time.Second)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.InDelta(t, 2*time.Second, wait, float64(100*time.Millisecond))
+
+	assert.NoError(t, redis.DelValue("gorush-test-bucket"))
+	assert.NoError(t, redis.Close())
+}
+
+func TestRedisFields(t *testing.T) {
+	redis := New(
+		"redis:6379", // addr
+		"",           // username
+		"",           // password
+		0,            // db
+		false,        // cluster
+	)
+	assert.NoError(t, redis.Init())
+
+	fields, err := redis.GetFields("gorush-test-map-missing")
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	assert.NoError(t, redis.SetField("gorush-test-map", "a", []byte("foo"), time.Second))
+	assert.NoError(t, redis.SetField("gorush-test-map", "b", []byte("bar"), time.Second))
+	assert.NoError(t, redis.SetField("gorush-test-map", "a", []byte("baz"), time.Second))
+	assert.NoError(t, redis.SetField("gorush-test-map-other", "c", []byte("qux"), 0))
+
+	fields, err = redis.GetFields("gorush-test-map")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("baz"), "b": []byte("bar")}, fields)
+
+	// expired maps are gone
+	assert.Eventually(t, func() bool {
+		fields, err := redis.GetFields("gorush-test-map")
+		return err == nil && len(fields) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	fields, err = redis.GetFields("gorush-test-map-other")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"c": []byte("qux")}, fields)
+	assert.NoError(t, redis.DelValue("gorush-test-map-other"))
+
+	assert.NoError(t, redis.Close())
+}
+
+func TestRedisIncrValue(t *testing.T) {
+	redis := New(
+		"redis:6379", // addr
+		"",           // username
+		"",           // password
+		0,            // db
+		false,        // cluster
+	)
+	assert.NoError(t, redis.Init())
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := redis.IncrValue("gorush-test-counter", time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+
+	count, err := redis.IncrValue("gorush-test-counter-other", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// expired counters start over
+	assert.Eventually(t, func() bool {
+		value, err := redis.GetValue("gorush-test-counter")
+		return err == nil && value == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	count, err = redis.IncrValue("gorush-test-counter", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, redis.DelValue("gorush-test-counter"))
+	assert.NoError(t, redis.DelValue("gorush-test-counter-other"))
+	assert.NoError(t, redis.Close())
+}
+
+func TestRedisMailbox(t *testing.T) {
+	redis := New(
+		"redis:6379", // addr
+		"",           // username
+		"",           // password
+		0,            // db
+		false,        // cluster
+	)
+	assert.NoError(t, redis.Init())
+
+	ctx := context.Background()
+	message, err := redis.Receive(ctx, "gorush-test-mailbox", time.Second)
+	assert.NoError(t, err)
+	assert.Nil(t, message)
+
+	time.AfterFunc(100*time.Millisecond, func() {
+		assert.NoError(t, redis.Post("gorush-test-mailbox", []byte("reply"), time.Second))
+	})
+	message, err = redis.Receive(ctx, "gorush-test-mailbox", 5*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("reply"), message)
+
+	assert.NoError(t, redis.Close())
+}
@@ -1 +1,88 @@
 package storage
+
+import (
+	"encoding/binary"
+	"strconv"
+	"time"
+)
+
+// expireSize is the size of the expiration header added by EncodeValue.
+const expireSize = 8
+
+// FieldKey returns the key of the field of a map, for the engines that store
+// each field of a map as a value of its own. The fields of the map are the
+// keys starting with FieldKey(key, "").
+func FieldKey(key, field string) string {
+	return key + "\x00" + field
+}
+
+// MapKey returns the key holding the expiration of the map of key, for the
+// engines that store each field of a map as a value of its own: the map
+// expires as a whole, its fields have no expiration of their own. A map
+// without it does not exist. It sorts before the fields of the map.
+func MapKey(key string) string {
+	return FieldKey(key, "")
+}
+
+// IsMapKey reports whether the raw key is one returned by MapKey.
+func IsMapKey(key []byte) bool {
+	return len(key) > 0 && key[len(key)-1] == 0
+}
+
+// EncodeValue prefixes the value with its expiration time in unix nanoseconds,
+// for the engines that don't support expiring keys. Zero never expires.
+func EncodeValue(value []byte, ttl time.Duration) []byte {
+	var expire int64
+	if ttl > 0 {
+		expire = time.Now().Add(ttl).UnixNano()
+	}
+
+	data := make([]byte, expireSize, expireSize+len(value))
+	binary.BigEndian.PutUint64(data, uint64(expire))
+
+	return append(data, value...)
+}
+
+// DecodeValue returns the value stored by EncodeValue, and false when it is
+// malformed or has expired.
+func DecodeValue(data []byte) ([]byte, bool) {
+	if len(data) < expireSize {
+		return nil, false
+	}
+
+	expire := int64(binary.BigEndian.Uint64(data))
+	if expire > 0 && time.Now().UnixNano() >= expire {
+		return nil, false
+	}
+
+	return data[expireSize:], true
+}
+
+// Expired reports whether the value stored by EncodeValue has expired, for
+// the engines to remove it. A malformed value is not reported, it may be a
+// counter stored without expiration.
+func Expired(data []byte) bool {
+	if len(data) < expireSize {
+		return false
+	}
+
+	expire := int64(binary.BigEndian.Uint64(data))
+	return expire > 0 && time.Now().UnixNano() >= expire
+}
+
+// IncrEncodedValue adds one to the counter stored by EncodeValue in data, and
+// returns it encoded with its expiration kept. A malformed or expired counter
+// starts over at one and expires after ttl.
+func IncrEncodedValue(data []byte, ttl time.Duration) ([]byte, int64) {
+	value, ok := DecodeValue(data)
+	if !ok {
+		return EncodeValue([]byte("1"), ttl), 1
+	}
+
+	count, _ := strconv.ParseInt(string(value), 10, 64)
+	count++
+
+	out := make([]byte, expireSize, expireSize+20)
+	copy(out, data[:expireSize])
+	return strconv.AppendInt(out, count, 10), count
+}